@@ -1,16 +1,111 @@
 package main
 
 import (
+	"fmt"
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/api"
 )
 
+type CreateCmd struct {
+	Filename string
+}
+
+var createFromManifest CreateCmd
+
 // createCmd represents the create command
 var createCmd = &cobra.Command{
 	Use:   "create",
 	Short: "create clusters",
-	Long:  `Create clusters`,
+	Long: `Create clusters.
+
+With -f, registers the Cluster and InstanceGroups from a YAML manifest (as written by get cluster -o yaml)
+in the state store; run create cluster to apply the configuration.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if createFromManifest.Filename == "" {
+			cmd.Help()
+			return
+		}
+		err := createFromManifest.Run()
+		if err != nil {
+			glog.Exitf("%v", err)
+		}
+	},
 }
 
 func init() {
 	rootCommand.AddCommand(createCmd)
+
+	createCmd.Flags().StringVarP(&createFromManifest.Filename, "filename", "f", "", "YAML manifest containing the Cluster and its InstanceGroups")
+}
+
+func (c *CreateCmd) Run() error {
+	cluster, instanceGroups, err := readManifest(c.Filename)
+	if err != nil {
+		return err
+	}
+
+	stateStore, err := rootCommand.StateStoreForCluster(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "create")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	existing, _, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+	if existing.Name != "" {
+		return fmt.Errorf("cluster %q already exists; use replace to change it", cluster.Name)
+	}
+
+	err = api.WriteConfig(stateStore, cluster, instanceGroups)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created cluster %q; run create cluster --name %s to apply the configuration\n", cluster.Name, cluster.Name)
+	return nil
+}
+
+// readManifest reads and validates a manifest containing a Cluster and its InstanceGroups
+func readManifest(filename string) (*api.Cluster, []*api.InstanceGroup, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading file %q: %v", filename, err)
+	}
+
+	cluster, instanceGroups, err := api.ParseManifest(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing file %q: %v", filename, err)
+	}
+
+	if cluster.Name == "" {
+		return nil, nil, fmt.Errorf("Cluster in %q did not have a Name", filename)
+	}
+	if rootCommand.clusterName != "" && rootCommand.clusterName != cluster.Name {
+		return nil, nil, fmt.Errorf("--name %q does not match the name of the Cluster in %q (%q)", rootCommand.clusterName, filename, cluster.Name)
+	}
+
+	err = cluster.Validate(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, g := range instanceGroups {
+		err = g.CrossValidate(cluster)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if countMasterInstanceGroups(instanceGroups) == 0 {
+		return nil, nil, fmt.Errorf("%q must contain at least one master InstanceGroup", filename)
+	}
+
+	return cluster, instanceGroups, nil
 }
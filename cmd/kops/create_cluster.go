@@ -14,6 +14,7 @@ import (
 	"os/exec"
 	"path"
 	"strings"
+	"time"
 )
 
 type CreateClusterCmd struct {
@@ -31,11 +32,19 @@ type CreateClusterCmd struct {
 	Project           string
 	KubernetesVersion string
 	OutDir            string
+	TerraformModule   bool
 	Image             string
 	SSHPublicKey      string
 	VPCID             string
 	NetworkCIDR       string
 	DNSZone           string
+	PlanFormat        string
+	SavePlan          string
+	Plan              string
+	MaxConcurrency    int
+	TaskTimeout       time.Duration
+	MaxTaskAttempts   int
+	TraceFile         string
 }
 
 var createCluster CreateClusterCmd
@@ -55,15 +64,10 @@ func init() {
 
 	createCmd.AddCommand(cmd)
 
-	executableLocation, err := exec.LookPath(os.Args[0])
-	if err != nil {
-		glog.Fatalf("Cannot determine location of kops tool: %q.  Please report this problem!", os.Args[0])
-	}
-
-	modelsBaseDirDefault := path.Join(path.Dir(executableLocation), "models")
+	modelsBaseDirDefault := defaultModelsBaseDir()
 
 	cmd.Flags().BoolVar(&createCluster.DryRun, "dryrun", false, "Don't create cloud resources; just show what would be done")
-	cmd.Flags().StringVar(&createCluster.Target, "target", "direct", "Target - direct, terraform")
+	cmd.Flags().StringVar(&createCluster.Target, "target", "direct", "Target - direct, terraform, cloudformation")
 	//configFile := cmd.Flags().StringVar(&createCluster., "conf", "", "Configuration file to load")
 	cmd.Flags().StringVar(&createCluster.ModelsBaseDir, "modeldir", modelsBaseDirDefault, "Source directory where models are stored")
 	cmd.Flags().StringVar(&createCluster.Models, "model", "config,proto,cloudup", "Models to apply (separate multiple models with commas)")
@@ -96,6 +100,26 @@ func init() {
 
 	cmd.Flags().StringVar(&createCluster.DNSZone, "dns-zone", "", "DNS hosted zone to use (defaults to last two components of cluster name)")
 	cmd.Flags().StringVar(&createCluster.OutDir, "out", "", "Path to write any local output")
+	cmd.Flags().BoolVar(&createCluster.TerraformModule, "terraform-module", false, "Write the terraform output as a module, without a provider block")
+
+	cmd.Flags().StringVar(&createCluster.PlanFormat, "plan-format", "", "Format of the dry-run output - text (default), json, yaml")
+	cmd.Flags().StringVar(&createCluster.SavePlan, "save-plan", "", "Save the dry-run plan to this file (JSON if it ends in .json, otherwise YAML)")
+	cmd.Flags().StringVar(&createCluster.Plan, "plan", "", "Only apply changes if they match this saved plan")
+
+	cmd.Flags().IntVar(&createCluster.MaxConcurrency, "max-concurrency", 10, "Maximum number of tasks to run at the same time")
+	cmd.Flags().DurationVar(&createCluster.TaskTimeout, "task-timeout", 0, "Time after which a task is abandoned, failing the update (0 for no timeout)")
+	cmd.Flags().IntVar(&createCluster.MaxTaskAttempts, "max-task-attempts", 8, "Number of times to try a task before giving up")
+	cmd.Flags().StringVar(&createCluster.TraceFile, "trace-file", "", "Also write the execution trace (JSON) to this local file")
+}
+
+// defaultModelsBaseDir returns the models directory alongside the kops executable
+func defaultModelsBaseDir() string {
+	executableLocation, err := exec.LookPath(os.Args[0])
+	if err != nil {
+		glog.Fatalf("Cannot determine location of kops tool: %q.  Please report this problem!", os.Args[0])
+	}
+
+	return path.Join(path.Dir(executableLocation), "models")
 }
 
 var EtcdClusters = []string{"main", "events"}
@@ -107,6 +131,10 @@ func (c *CreateClusterCmd) Run() error {
 		c.Target = "dryrun"
 	}
 
+	if c.PlanFormat != "" && !isDryrun {
+		return fmt.Errorf("--plan-format can only be used with --dryrun")
+	}
+
 	stateStoreLocation := rootCommand.stateLocation
 	if stateStoreLocation == "" {
 		return fmt.Errorf("--state is required")
@@ -128,11 +156,24 @@ func (c *CreateClusterCmd) Run() error {
 		c.OutDir = "out"
 	}
 
-	stateStore, err := fi.NewVFSStateStore(statePath, clusterName, isDryrun)
+	keyProvider, err := rootCommand.KeyProvider()
+	if err != nil {
+		return err
+	}
+
+	stateStore, err := fi.NewVFSStateStore(statePath, clusterName, isDryrun, keyProvider)
 	if err != nil {
 		return fmt.Errorf("error building state store: %v", err)
 	}
 
+	if !isDryrun {
+		lock, err := rootCommand.LockState(stateStore, "create cluster")
+		if err != nil {
+			return err
+		}
+		defer releaseStateLock(lock, stateStore)
+	}
+
 	cluster, instanceGroups, err := api.ReadConfig(stateStore)
 	if err != nil {
 		return fmt.Errorf("error loading configuration: %v", err)
@@ -325,15 +366,25 @@ func (c *CreateClusterCmd) Run() error {
 	}
 
 	cmd := &cloudup.CreateClusterCmd{
-		Cluster:        cluster,
-		InstanceGroups: instanceGroups,
-		ModelStore:     c.ModelsBaseDir,
-		Models:         strings.Split(c.Models, ","),
-		StateStore:     stateStore,
-		Target:         c.Target,
-		NodeModel:      c.NodeModel,
-		SSHPublicKey:   c.SSHPublicKey,
-		OutDir:         c.OutDir,
+		Cluster:         cluster,
+		InstanceGroups:  instanceGroups,
+		ModelStore:      c.ModelsBaseDir,
+		Models:          strings.Split(c.Models, ","),
+		StateStore:      stateStore,
+		Target:          c.Target,
+		NodeModel:       c.NodeModel,
+		SSHPublicKey:    c.SSHPublicKey,
+		OutDir:          c.OutDir,
+		TerraformModule: c.TerraformModule,
+		PlanFormat:      c.PlanFormat,
+		SavePlan:        c.SavePlan,
+		ApplyPlan:       c.Plan,
+		RunTasksOptions: fi.RunTasksOptions{
+			MaxConcurrency:  c.MaxConcurrency,
+			TaskTimeout:     c.TaskTimeout,
+			MaxTaskAttempts: c.MaxTaskAttempts,
+		},
+		TraceFile: c.TraceFile,
 	}
 	//if *configFile != "" {
 	//	//confFile := path.Join(cmd.StateDir, "kubernetes.yaml")
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/utils"
+)
+
+type CreateInstanceGroupCmd struct {
+	Filename    string
+	Role        string
+	MachineType string
+	Image       string
+	MinSize     int
+	MaxSize     int
+	Zones       string
+}
+
+var createInstanceGroupCmd CreateInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup NAME",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Create instancegroup",
+		Long: `Create an InstanceGroup, from flags or from a YAML file (with -f).
+
+This changes only the configuration in the state store; run update cluster to apply it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := createInstanceGroupCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	createCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&createInstanceGroupCmd.Filename, "filename", "f", "", "YAML file containing the InstanceGroup")
+	cmd.Flags().StringVar(&createInstanceGroupCmd.Role, "role", string(api.InstanceGroupRoleNode), "Role of the instances in the group: Master or Node")
+	cmd.Flags().StringVar(&createInstanceGroupCmd.MachineType, "machine-type", "", "Machine type for the instances")
+	cmd.Flags().StringVar(&createInstanceGroupCmd.Image, "image", "", "Image to use for the instances")
+	cmd.Flags().IntVar(&createInstanceGroupCmd.MinSize, "min-size", 0, "Minimum number of instances")
+	cmd.Flags().IntVar(&createInstanceGroupCmd.MaxSize, "max-size", 0, "Maximum number of instances")
+	cmd.Flags().StringVar(&createInstanceGroupCmd.Zones, "zones", "", "Zones for the instances (defaults to all the zones of the cluster, for nodes)")
+}
+
+func (c *CreateInstanceGroupCmd) Run(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("Specify only the name of the InstanceGroup to create")
+	}
+
+	group := &api.InstanceGroup{}
+	if c.Filename != "" {
+		data, err := ioutil.ReadFile(c.Filename)
+		if err != nil {
+			return fmt.Errorf("error reading file %q: %v", c.Filename, err)
+		}
+		err = utils.YamlUnmarshal(data, group)
+		if err != nil {
+			return fmt.Errorf("error parsing file %q: %v", c.Filename, err)
+		}
+		if len(args) == 1 && group.Name != args[0] {
+			return fmt.Errorf("InstanceGroup name %q does not match the name in the file %q", args[0], group.Name)
+		}
+	} else {
+		if len(args) != 1 {
+			return fmt.Errorf("Specify the name of the InstanceGroup to create")
+		}
+		group.Name = args[0]
+		group.Spec.Role = api.InstanceGroupRole(c.Role)
+		group.Spec.MachineType = c.MachineType
+		group.Spec.Image = c.Image
+		if c.MinSize != 0 {
+			group.Spec.MinSize = fi.Int(c.MinSize)
+		}
+		if c.MaxSize != 0 {
+			group.Spec.MaxSize = fi.Int(c.MaxSize)
+		}
+		group.Spec.Zones = parseZoneList(c.Zones)
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "create instancegroup")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+	if cluster.Name == "" {
+		return fmt.Errorf("cluster %q not found", rootCommand.clusterName)
+	}
+
+	if findInstanceGroup(instanceGroups, group.Name) != nil {
+		return fmt.Errorf("InstanceGroup %q already exists", group.Name)
+	}
+
+	if group.Spec.Role == api.InstanceGroupRoleNode && len(group.Spec.Zones) == 0 {
+		for _, z := range cluster.Spec.Zones {
+			group.Spec.Zones = append(group.Spec.Zones, z.Name)
+		}
+	}
+
+	err = group.CrossValidate(cluster)
+	if err != nil {
+		return err
+	}
+
+	instanceGroups = append(instanceGroups, group)
+	err = api.WriteConfig(stateStore, cluster, instanceGroups)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created InstanceGroup %q\n", group.Name)
+	return nil
+}
@@ -76,6 +76,14 @@ func (c *DeleteClusterCmd) Run() error {
 			return err
 		}
 
+		if c.Yes {
+			lock, err := rootCommand.LockState(stateStore, "delete cluster")
+			if err != nil {
+				return err
+			}
+			defer releaseStateLock(lock, stateStore)
+		}
+
 		cluster, _, err := api.ReadConfig(stateStore)
 		if err != nil {
 			return err
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+)
+
+type DeleteInstanceGroupCmd struct {
+	Yes bool
+}
+
+var deleteInstanceGroupCmd DeleteInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup NAME",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Delete instancegroup",
+		Long: `Deletes an InstanceGroup from the cluster configuration.
+
+This changes only the configuration in the state store; the cloud resources of the group are not deleted.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := deleteInstanceGroupCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	deleteCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&deleteInstanceGroupCmd.Yes, "yes", false, "Delete without confirmation")
+}
+
+func (c *DeleteInstanceGroupCmd) Run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Specify the name of the InstanceGroup to delete")
+	}
+	groupName := args[0]
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	if c.Yes {
+		lock, err := rootCommand.LockState(stateStore, "delete instancegroup")
+		if err != nil {
+			return err
+		}
+		defer releaseStateLock(lock, stateStore)
+	}
+
+	_, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+
+	group := findInstanceGroup(instanceGroups, groupName)
+	if group == nil {
+		return fmt.Errorf("InstanceGroup %q not found", groupName)
+	}
+
+	if group.Spec.Role == api.InstanceGroupRoleMaster && countMasterInstanceGroups(instanceGroups) == 1 {
+		return fmt.Errorf("cannot delete InstanceGroup %q: it is the last master InstanceGroup", groupName)
+	}
+
+	if !c.Yes {
+		return fmt.Errorf("Must specify --yes to delete InstanceGroup %q", groupName)
+	}
+
+	err = api.DeleteInstanceGroup(stateStore, groupName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Deleted InstanceGroup %q\n", groupName)
+	return nil
+}
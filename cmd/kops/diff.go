@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// diffCmd represents the diff command
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "show differences in configuration",
+	Long:  `show differences in configuration`,
+}
+
+func init() {
+	rootCommand.AddCommand(diffCmd)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/utils"
+)
+
+type DiffClusterCmd struct {
+	Revision int
+}
+
+var diffClusterCmd DiffClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Show changes to cluster configuration",
+		Long:  `Shows the changes between a previous revision of the cluster configuration and the current configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := diffClusterCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	diffCmd.AddCommand(cmd)
+
+	cmd.Flags().IntVar(&diffClusterCmd.Revision, "revision", 0, "Revision to compare against (see get cluster --history)")
+}
+
+// configSnapshot is what we compare: the cluster and its instance groups
+type configSnapshot struct {
+	Cluster        *api.Cluster         `json:"cluster"`
+	InstanceGroups []*api.InstanceGroup `json:"instanceGroups,omitempty"`
+}
+
+func (c *DiffClusterCmd) Run() error {
+	if c.Revision <= 0 {
+		return fmt.Errorf("--revision is required")
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	revision, err := api.ReadRevision(stateStore, c.Revision)
+	if err != nil {
+		return err
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+
+	oldYaml, err := utils.YamlMarshal(&configSnapshot{Cluster: revision.Cluster, InstanceGroups: revision.InstanceGroups})
+	if err != nil {
+		return fmt.Errorf("error serializing revision %d: %v", c.Revision, err)
+	}
+	newYaml, err := utils.YamlMarshal(&configSnapshot{Cluster: cluster, InstanceGroups: instanceGroups})
+	if err != nil {
+		return fmt.Errorf("error serializing current configuration: %v", err)
+	}
+
+	diff := utils.FormatDiff(string(oldYaml), string(newYaml))
+	if diff == "" {
+		fmt.Printf("No changes since revision %d\n", c.Revision)
+		return nil
+	}
+
+	fmt.Printf("--- revision %d\n+++ current\n", c.Revision)
+	fmt.Print(diff)
+	return nil
+}
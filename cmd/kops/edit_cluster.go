@@ -3,17 +3,23 @@ package main
 import (
 	"fmt"
 
+	"bufio"
 	"bytes"
 	"github.com/golang/glog"
 	"github.com/spf13/cobra"
+	"io"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kubernetes/pkg/kubectl/cmd/util/editor"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 var editorEnvs = []string{"KUBE_EDITOR", "EDITOR"}
 
 type EditClusterCmd struct {
+	Yes bool
 }
 
 var editClusterCmd EditClusterCmd
@@ -32,6 +38,8 @@ func init() {
 	}
 
 	editCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&editClusterCmd.Yes, "yes", false, "Save the changes without confirmation")
 }
 
 func (c *EditClusterCmd) Run() error {
@@ -40,42 +48,138 @@ func (c *EditClusterCmd) Run() error {
 		return err
 	}
 
-	//cluster, _, err := api.ReadConfig(stateStore)
-	//if err != nil {
-	//	return fmt.Errorf("error reading configuration: %v", err)
-	//}
-
-	var (
-		edit = editor.NewDefaultEditor(editorEnvs)
-	)
+	lock, err := rootCommand.LockState(stateStore, "edit cluster")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
 
-	ext := "yaml"
+	oldCluster, _, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
 
 	raw, err := stateStore.VFSPath().Join("config").ReadFile()
 	if err != nil {
 		return fmt.Errorf("error reading config file: %v", err)
 	}
 
-	// launch the editor
-	edited, file, err := edit.LaunchTempFile(fmt.Sprintf("%s-edit-", filepath.Base(os.Args[0])), ext, bytes.NewReader(raw))
-	defer func() {
-		if file != "" {
-			os.Remove(file)
+	edited, err := editWithValidation(raw, func(data []byte) error {
+		cluster := &api.Cluster{}
+		err := utils.YamlUnmarshal(data, cluster)
+		if err != nil {
+			return fmt.Errorf("error parsing config: %v", err)
+		}
+		if cluster.Name != oldCluster.Name {
+			return fmt.Errorf("cluster name cannot be changed (was %q, now %q)", oldCluster.Name, cluster.Name)
 		}
-	}()
+		return cluster.Validate(false)
+	})
 	if err != nil {
-		return fmt.Errorf("error launching editor: %v", err)
+		return err
 	}
-
-	if bytes.Equal(edited, raw) {
+	if edited == nil {
 		fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
 		return nil
 	}
 
+	fmt.Print(utils.FormatDiff(string(raw), string(edited)))
+	if !c.Yes {
+		confirmed, err := confirm("Save these changes?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
+			return nil
+		}
+	}
+
+	err = api.EnsureHistory(stateStore)
+	if err != nil {
+		return err
+	}
+
 	err = stateStore.VFSPath().Join("config").WriteFile(edited)
 	if err != nil {
 		return fmt.Errorf("error writing config file: %v", err)
 	}
 
+	revision, err := api.RecordRevision(stateStore)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Saved configuration as revision %d\n", revision.Revision)
+
 	return nil
 }
+
+// editWithValidation opens the data in the editor, and returns the edited data, or nil if there were no changes.
+// If validate rejects the edited data, the editor is re-opened with the errors annotated at the top, as kubectl edit does.
+func editWithValidation(original []byte, validate func([]byte) error) ([]byte, error) {
+	var (
+		edit = editor.NewDefaultEditor(editorEnvs)
+	)
+
+	ext := "yaml"
+
+	buffer := original
+	for {
+		// launch the editor
+		edited, file, err := edit.LaunchTempFile(fmt.Sprintf("%s-edit-", filepath.Base(os.Args[0])), ext, bytes.NewReader(buffer))
+		if file != "" {
+			os.Remove(file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error launching editor: %v", err)
+		}
+
+		if bytes.Equal(edited, buffer) {
+			// Closing the editor without changes cancels the edit, even after an error
+			return nil, nil
+		}
+
+		edited = stripEditHeader(edited)
+		if len(bytes.TrimSpace(edited)) == 0 || bytes.Equal(edited, original) {
+			return nil, nil
+		}
+
+		err = validate(edited)
+		if err == nil {
+			return edited, nil
+		}
+
+		var header bytes.Buffer
+		header.WriteString("# Please edit the object below. The edit was rejected because of the errors below;\n")
+		header.WriteString("# close the editor without making changes to cancel the edit.\n")
+		header.WriteString("#\n")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			header.WriteString("# " + line + "\n")
+		}
+		header.WriteString("#\n")
+		buffer = append(header.Bytes(), edited...)
+	}
+}
+
+// stripEditHeader removes the comment lines that editWithValidation adds at the top of the file
+func stripEditHeader(data []byte) []byte {
+	for bytes.HasPrefix(data, []byte("#")) {
+		i := bytes.IndexByte(data, '\n')
+		if i == -1 {
+			return nil
+		}
+		data = data[i+1:]
+	}
+	return data
+}
+
+// confirm asks the user a yes/no question on the terminal; the default is no
+func confirm(question string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s (y/N): ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("error reading answer: %v", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"os"
+)
+
+type EditInstanceGroupCmd struct {
+}
+
+var editInstanceGroupCmd EditInstanceGroupCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroup NAME",
+		Aliases: []string{"instancegroups", "ig"},
+		Short:   "Edit instancegroup",
+		Long:    `Edit an InstanceGroup configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := editInstanceGroupCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	editCmd.AddCommand(cmd)
+}
+
+func (c *EditInstanceGroupCmd) Run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("Specify the name of the InstanceGroup to edit")
+	}
+	groupName := args[0]
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "edit instancegroup")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+
+	group := findInstanceGroup(instanceGroups, groupName)
+	if group == nil {
+		return fmt.Errorf("InstanceGroup %q not found", groupName)
+	}
+
+	raw, err := utils.YamlMarshal(group)
+	if err != nil {
+		return fmt.Errorf("error serializing InstanceGroup: %v", err)
+	}
+
+	newGroup := &api.InstanceGroup{}
+	edited, err := editWithValidation(raw, func(data []byte) error {
+		*newGroup = api.InstanceGroup{}
+		err := utils.YamlUnmarshal(data, newGroup)
+		if err != nil {
+			return fmt.Errorf("error parsing InstanceGroup: %v", err)
+		}
+		if newGroup.Name != group.Name {
+			return fmt.Errorf("InstanceGroup name cannot be changed (was %q, now %q)", group.Name, newGroup.Name)
+		}
+		return newGroup.CrossValidate(cluster)
+	})
+	if err != nil {
+		return err
+	}
+	if edited == nil {
+		fmt.Fprintln(os.Stderr, "Edit cancelled, no changes made.")
+		return nil
+	}
+
+	for i, g := range instanceGroups {
+		if g == group {
+			instanceGroups[i] = newGroup
+		}
+	}
+
+	if countMasterInstanceGroups(instanceGroups) == 0 {
+		return fmt.Errorf("cannot change the role of InstanceGroup %q: the cluster must have at least one master InstanceGroup", group.Name)
+	}
+
+	err = api.WriteConfig(stateStore, cluster, instanceGroups)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func countMasterInstanceGroups(instanceGroups []*api.InstanceGroup) int {
+	count := 0
+	for _, g := range instanceGroups {
+		if g.Spec.Role == api.InstanceGroupRoleMaster {
+			count++
+		}
+	}
+	return count
+}
@@ -92,7 +92,7 @@ func (c *ExportKubecfgCommand) Run() error {
 		return err
 	}
 
-	if b.CACert, err = c.copyCertificate(fi.CertificateId_CA); err != nil {
+	if b.CACert, err = c.copyCertificatePool(fi.CertificateId_CA); err != nil {
 		return err
 	}
 
@@ -129,6 +129,22 @@ func (c *ExportKubecfgCommand) copyCertificate(id string) (string, error) {
 	return p, nil
 }
 
+// copyCertificatePool writes all the valid certificates for id, so that we trust all of them (e.g. while rotating the CA)
+func (c *ExportKubecfgCommand) copyCertificatePool(id string) (string, error) {
+	p := path.Join(c.tmpdir, id+".crt")
+	pool, err := c.caStore.CertificatePool(id)
+	if err != nil {
+		return "", fmt.Errorf("error fetching certificate pool %q: %v", id, err)
+	}
+
+	_, err = writeFile(p, pool)
+	if err != nil {
+		return "", fmt.Errorf("error writing certificate pool %q: %v", id, err)
+	}
+
+	return p, nil
+}
+
 func (c *ExportKubecfgCommand) copyPrivateKey(id string) (string, error) {
 	p := path.Join(c.tmpdir, id+".key")
 	cert, err := c.caStore.PrivateKey(id)
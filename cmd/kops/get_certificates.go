@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	certificateStatusOK          = "ok"
+	certificateStatusExpiring    = "expiring"
+	certificateStatusExpired     = "expired"
+	certificateStatusNotYetValid = "not-yet-valid"
+)
+
+type GetCertificatesCmd struct {
+	Output   string
+	WarnDays int
+}
+
+var getCertificatesCmd GetCertificatesCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "certificates",
+		Aliases: []string{"certificate", "certs"},
+		Short:   "get certificates, and when they expire",
+		Long: `Lists every certificate in the CA store (including certificates that have been rotated but not yet retired),
+showing the subject, alternate names, issuer, serial, validity period and the number of days until the certificate expires.
+
+Certificates expiring within --warn-days are reported as expiring; use -o json for monitoring.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := getCertificatesCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	getCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&getCertificatesCmd.Output, "output", "o", "table", "Output format - table or json")
+	cmd.Flags().IntVar(&getCertificatesCmd.WarnDays, "warn-days", 30, "Warn about certificates expiring within this many days")
+}
+
+// CertificateInfo is the expiry report for a single certificate
+type CertificateInfo struct {
+	Id             string    `json:"id"`
+	Serial         string    `json:"serial"`
+	Primary        bool      `json:"primary"`
+	CA             bool      `json:"ca"`
+	Subject        string    `json:"subject"`
+	Issuer         string    `json:"issuer"`
+	AlternateNames []string  `json:"alternateNames,omitempty"`
+	NotBefore      time.Time `json:"notBefore"`
+	NotAfter       time.Time `json:"notAfter"`
+	DaysRemaining  int       `json:"daysRemaining"`
+	Status         string    `json:"status"`
+}
+
+func (c *GetCertificatesCmd) Run() error {
+	if c.Output != "table" && c.Output != "json" {
+		return fmt.Errorf("unknown output format %q (expected table or json)", c.Output)
+	}
+
+	caStore, err := rootCommand.CA()
+	if err != nil {
+		return err
+	}
+
+	infos, err := buildCertificateInventory(caStore, time.Now(), c.WarnDays)
+	if err != nil {
+		return err
+	}
+
+	if c.Output == "json" {
+		data, err := json.MarshalIndent(infos, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error serializing certificates: %v", err)
+		}
+		_, err = os.Stdout.Write(append(data, '\n'))
+		if err != nil {
+			return fmt.Errorf("error writing to output: %v", err)
+		}
+	} else if len(infos) != 0 {
+		columns := []string{"ID", "SERIAL", "PRIMARY", "SUBJECT", "ISSUER", "ALTERNATE NAMES", "NOT BEFORE", "NOT AFTER", "DAYS", "STATUS"}
+		fields := []func(*CertificateInfo) string{
+			func(i *CertificateInfo) string {
+				return i.Id
+			},
+			func(i *CertificateInfo) string {
+				return i.Serial
+			},
+			func(i *CertificateInfo) string {
+				return strconv.FormatBool(i.Primary)
+			},
+			func(i *CertificateInfo) string {
+				return i.Subject
+			},
+			func(i *CertificateInfo) string {
+				return i.Issuer
+			},
+			func(i *CertificateInfo) string {
+				return strings.Join(i.AlternateNames, ",")
+			},
+			func(i *CertificateInfo) string {
+				return i.NotBefore.UTC().Format(time.RFC3339)
+			},
+			func(i *CertificateInfo) string {
+				return i.NotAfter.UTC().Format(time.RFC3339)
+			},
+			func(i *CertificateInfo) string {
+				return strconv.Itoa(i.DaysRemaining)
+			},
+			func(i *CertificateInfo) string {
+				return i.Status
+			},
+		}
+		err = WriteTable(infos, columns, fields)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Warnings go to stderr, so that they don't break the json output
+	for _, info := range infos {
+		switch info.Status {
+		case certificateStatusExpired:
+			fmt.Fprintf(os.Stderr, "Warning: certificate %q (serial %s) expired on %s\n", info.Id, info.Serial, info.NotAfter.UTC().Format(time.RFC3339))
+		case certificateStatusExpiring:
+			fmt.Fprintf(os.Stderr, "Warning: certificate %q (serial %s) expires in %d days\n", info.Id, info.Serial, info.DaysRemaining)
+		case certificateStatusNotYetValid:
+			fmt.Fprintf(os.Stderr, "Warning: certificate %q (serial %s) is not valid until %s\n", info.Id, info.Serial, info.NotBefore.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
+
+// buildCertificateInventory returns every certificate in every pool in the CA store, ordered by id and then by serial
+func buildCertificateInventory(caStore fi.CAStore, now time.Time, warnDays int) ([]*CertificateInfo, error) {
+	ids, err := caStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing CA store items %v", err)
+	}
+	sort.Strings(ids)
+
+	var infos []*CertificateInfo
+	for _, id := range ids {
+		pool, err := caStore.CertificatePool(id)
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving certificates %q: %v", id, err)
+		}
+		if pool == nil || pool.Primary == nil {
+			continue
+		}
+
+		certs := append([]*fi.Certificate{pool.Primary}, pool.Secondary...)
+		sort.Sort(bySerial(certs))
+		for _, cert := range certs {
+			info := buildCertificateInfo(id, cert, now, warnDays)
+			info.Primary = cert == pool.Primary
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+func buildCertificateInfo(id string, cert *fi.Certificate, now time.Time, warnDays int) *CertificateInfo {
+	c := cert.Certificate
+
+	var alternateNames []string
+	alternateNames = append(alternateNames, c.DNSNames...)
+	alternateNames = append(alternateNames, c.EmailAddresses...)
+	for _, ip := range c.IPAddresses {
+		alternateNames = append(alternateNames, ip.String())
+	}
+	sort.Strings(alternateNames)
+
+	info := &CertificateInfo{
+		Id:             id,
+		Serial:         c.SerialNumber.Text(10),
+		CA:             cert.IsCA,
+		Subject:        pkixNameToString(&c.Subject),
+		Issuer:         pkixNameToString(&c.Issuer),
+		AlternateNames: alternateNames,
+		NotBefore:      c.NotBefore,
+		NotAfter:       c.NotAfter,
+		DaysRemaining:  int(c.NotAfter.Sub(now).Hours() / 24),
+	}
+
+	if now.After(c.NotAfter) {
+		info.Status = certificateStatusExpired
+	} else if now.Before(c.NotBefore) {
+		info.Status = certificateStatusNotYetValid
+	} else if info.DaysRemaining < warnDays {
+		info.Status = certificateStatusExpiring
+	} else {
+		info.Status = certificateStatusOK
+	}
+	return info
+}
+
+type bySerial []*fi.Certificate
+
+func (a bySerial) Len() int      { return len(a) }
+func (a bySerial) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a bySerial) Less(i, j int) bool {
+	return a[i].Certificate.SerialNumber.Cmp(a[j].Certificate.SerialNumber) < 0
+}
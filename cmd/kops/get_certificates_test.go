@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBuildCertificateInventory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "castore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caStore, err := fi.NewVFSCAStore(vfs.NewFSPath(dir), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kubelet"},
+		DNSNames:    []string{"kubelet.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}
+	oldCert, _, err := caStore.CreateKeypair("kubelet", template)
+	if err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+	newCert, err := caStore.RotateKeypair("kubelet")
+	if err != nil {
+		t.Fatalf("error rotating keypair: %v", err)
+	}
+
+	now := newCert.Certificate.NotBefore.Add(time.Hour)
+	infos, err := buildCertificateInventory(caStore, now, 30)
+	if err != nil {
+		t.Fatalf("error building inventory: %v", err)
+	}
+
+	// Ordered by id, then by serial; the rotated-out certificate is still listed
+	if len(infos) != 3 {
+		t.Fatalf("expected 3 certificates, got %s", fi.DebugAsJsonString(infos))
+	}
+	if infos[0].Id != fi.CertificateId_CA || !infos[0].CA || !infos[0].Primary {
+		t.Errorf("expected the CA first, got %s", fi.DebugAsJsonString(infos[0]))
+	}
+	old, current := infos[1], infos[2]
+	if old.Id != "kubelet" || current.Id != "kubelet" {
+		t.Fatalf("expected kubelet certificates, got %q and %q", old.Id, current.Id)
+	}
+	if old.Serial != oldCert.Certificate.SerialNumber.Text(10) || old.Primary {
+		t.Errorf("expected the old certificate to be listed as secondary, got %s", fi.DebugAsJsonString(old))
+	}
+	if current.Serial != newCert.Certificate.SerialNumber.Text(10) || !current.Primary {
+		t.Errorf("expected the rotated certificate to be primary, got %s", fi.DebugAsJsonString(current))
+	}
+	if current.CA || current.Subject == "" || len(current.AlternateNames) != 1 || current.AlternateNames[0] != "kubelet.example.com" {
+		t.Errorf("unexpected certificate details: %s", fi.DebugAsJsonString(current))
+	}
+	if current.Status != certificateStatusOK {
+		t.Errorf("expected status %q, got %q", certificateStatusOK, current.Status)
+	}
+
+	notAfter := current.NotAfter
+	grid := []struct {
+		now      time.Time
+		warnDays int
+		status   string
+	}{
+		{now: notAfter.Add(-24 * time.Hour), warnDays: 30, status: certificateStatusExpiring},
+		{now: notAfter.Add(time.Hour), warnDays: 30, status: certificateStatusExpired},
+		{now: current.NotBefore.Add(-time.Hour), warnDays: 30, status: certificateStatusNotYetValid},
+	}
+	for _, g := range grid {
+		infos, err := buildCertificateInventory(caStore, g.now, g.warnDays)
+		if err != nil {
+			t.Fatalf("error building inventory: %v", err)
+		}
+		status := infos[2].Status
+		if status != g.status {
+			t.Errorf("at %v: expected status %q, got %q", g.now, g.status, status)
+		}
+	}
+}
@@ -10,10 +10,15 @@ import (
 	"k8s.io/kops/upup/pkg/fi"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 )
 
 type GetClustersCmd struct {
+	History bool
+	Output  string
 }
 
 var getClustersCmd GetClustersCmd
@@ -33,9 +38,25 @@ func init() {
 	}
 
 	getCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&getClustersCmd.History, "history", false, "List the revisions of the configuration of the cluster")
+	cmd.Flags().StringVarP(&getClustersCmd.Output, "output", "o", "", "Output format: empty for a table, or yaml for a manifest that can be used with create -f and replace -f")
 }
 
 func (c *GetClustersCmd) Run() error {
+	if c.History {
+		return c.runHistory()
+	}
+
+	switch c.Output {
+	case "":
+		// Table
+	case "yaml":
+		return c.runManifest()
+	default:
+		return fmt.Errorf("unknown output format %q (must be yaml)", c.Output)
+	}
+
 	clusterNames, err := rootCommand.ListClusters()
 	if err != nil {
 		return err
@@ -68,6 +89,91 @@ func (c *GetClustersCmd) Run() error {
 	return WriteTable(clusters, columns, fields)
 }
 
+// runManifest writes the configuration of the clusters as YAML manifests
+func (c *GetClustersCmd) runManifest() error {
+	clusterNames := []string{rootCommand.clusterName}
+	if rootCommand.clusterName == "" {
+		var err error
+		clusterNames, err = rootCommand.ListClusters()
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, clusterName := range clusterNames {
+		stateStore, err := rootCommand.StateStoreForCluster(clusterName)
+		if err != nil {
+			return err
+		}
+
+		cluster, instanceGroups, err := api.ReadConfig(stateStore)
+		if err != nil {
+			return err
+		}
+		if cluster.Name == "" {
+			return fmt.Errorf("cluster %q not found", clusterName)
+		}
+
+		manifest, err := api.WriteManifest(cluster, instanceGroups)
+		if err != nil {
+			return err
+		}
+
+		if i != 0 {
+			fmt.Print("\n---\n\n")
+		}
+		_, err = os.Stdout.Write(manifest)
+		if err != nil {
+			return fmt.Errorf("error writing to output: %v", err)
+		}
+	}
+	return nil
+}
+
+// runHistory lists the saved revisions of the cluster configuration
+func (c *GetClustersCmd) runHistory() error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	revisionNumbers, err := api.ListRevisions(stateStore)
+	if err != nil {
+		return err
+	}
+
+	var revisions []*api.ConfigRevision
+	for _, n := range revisionNumbers {
+		r, err := api.ReadRevision(stateStore, n)
+		if err != nil {
+			return err
+		}
+		revisions = append(revisions, r)
+	}
+	if len(revisions) == 0 {
+		fmt.Printf("No history found\n")
+		return nil
+	}
+
+	columns := []string{"REVISION", "TIMESTAMP", "INSTANCEGROUPS"}
+	fields := []func(*api.ConfigRevision) string{
+		func(r *api.ConfigRevision) string {
+			return strconv.Itoa(r.Revision)
+		},
+		func(r *api.ConfigRevision) string {
+			return r.Timestamp.Format(time.RFC3339)
+		},
+		func(r *api.ConfigRevision) string {
+			var names []string
+			for _, g := range r.InstanceGroups {
+				names = append(names, g.Name)
+			}
+			return strings.Join(names, ",")
+		},
+	}
+	return WriteTable(revisions, columns, fields)
+}
+
 func WriteTable(items interface{}, columns []string, fields interface{}) error {
 	itemsValue := reflect.ValueOf(items)
 	if itemsValue.Kind() != reflect.Slice {
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"strconv"
+	"strings"
+)
+
+type GetInstanceGroupsCmd struct {
+}
+
+var getInstanceGroupsCmd GetInstanceGroupsCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:     "instancegroups",
+		Aliases: []string{"instancegroup", "ig"},
+		Short:   "get instancegroups",
+		Long:    `List or get InstanceGroups.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := getInstanceGroupsCmd.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	getCmd.AddCommand(cmd)
+}
+
+func (c *GetInstanceGroupsCmd) Run(args []string) error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	_, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 0 {
+		var selected []*api.InstanceGroup
+		for _, name := range args {
+			g := findInstanceGroup(instanceGroups, name)
+			if g == nil {
+				return fmt.Errorf("InstanceGroup %q not found", name)
+			}
+			selected = append(selected, g)
+		}
+		instanceGroups = selected
+	}
+
+	if len(instanceGroups) == 0 {
+		fmt.Printf("No InstanceGroups found\n")
+		return nil
+	}
+
+	columns := []string{"NAME", "ROLE", "MACHINETYPE", "MIN", "MAX", "ZONES"}
+	fields := []func(*api.InstanceGroup) string{
+		func(g *api.InstanceGroup) string {
+			return g.Name
+		},
+		func(g *api.InstanceGroup) string {
+			return string(g.Spec.Role)
+		},
+		func(g *api.InstanceGroup) string {
+			return g.Spec.MachineType
+		},
+		func(g *api.InstanceGroup) string {
+			return intPointerToString(g.Spec.MinSize)
+		},
+		func(g *api.InstanceGroup) string {
+			return intPointerToString(g.Spec.MaxSize)
+		},
+		func(g *api.InstanceGroup) string {
+			return strings.Join(g.Spec.Zones, ",")
+		},
+	}
+	return WriteTable(instanceGroups, columns, fields)
+}
+
+// findInstanceGroup returns the InstanceGroup with the specified name, or nil if not found
+func findInstanceGroup(instanceGroups []*api.InstanceGroup, name string) *api.InstanceGroup {
+	for _, g := range instanceGroups {
+		if g.Name == name {
+			return g
+		}
+	}
+	return nil
+}
+
+func intPointerToString(v *int) string {
+	if v == nil {
+		return "-"
+	}
+	return strconv.Itoa(*v)
+}
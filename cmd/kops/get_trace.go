@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"os"
+	"time"
+)
+
+type GetTraceCmd struct {
+	File string
+	All  bool
+}
+
+var getTraceCmd GetTraceCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "trace",
+		Short: "get the execution trace of the last update",
+		Long:  `Shows the critical path (and any retried tasks) from the execution trace of the last update of the cluster.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := getTraceCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	getCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&getTraceCmd.File, "file", "", "Read the trace from a local file, instead of the state store")
+	cmd.Flags().BoolVar(&getTraceCmd.All, "all", false, "Show all tasks, not just the critical path")
+}
+
+func (c *GetTraceCmd) Run() error {
+	var data []byte
+	if c.File != "" {
+		b, err := ioutil.ReadFile(c.File)
+		if err != nil {
+			return fmt.Errorf("error reading trace file %q: %v", c.File, err)
+		}
+		data = b
+	} else {
+		stateStore, err := rootCommand.StateStore()
+		if err != nil {
+			return err
+		}
+		p := stateStore.VFSPath().Join(cloudup.PathExecutionTrace)
+		b, err := p.ReadFile()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("no execution trace found for cluster (has it been updated?)")
+			}
+			return fmt.Errorf("error reading trace %s: %v", p, err)
+		}
+		data = b
+	}
+
+	trace, err := fi.ParseExecutionTrace(data)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Run took %v", trace.End.Sub(trace.Start))
+	if trace.Error != "" {
+		fmt.Printf(" and failed: %s", trace.Error)
+	}
+	fmt.Printf("\n\n")
+
+	tasks := trace.CriticalPath()
+	if c.All {
+		tasks = trace.Tasks
+	} else {
+		fmt.Printf("Critical path:\n")
+	}
+	err = writeTaskTraces(trace, tasks)
+	if err != nil {
+		return err
+	}
+
+	var retried []*fi.TaskTrace
+	for _, t := range trace.Tasks {
+		if t.FailedAttempts() != 0 {
+			retried = append(retried, t)
+		}
+	}
+	if len(retried) != 0 && !c.All {
+		fmt.Printf("\nTasks with errors:\n")
+		err = writeTaskTraces(trace, retried)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, t := range retried {
+		fmt.Printf("\n%s:\n", t.Key)
+		for i, a := range t.Attempts {
+			if a.Error != "" {
+				fmt.Printf("  attempt %d: %s\n", i+1, a.Error)
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeTaskTraces(trace *fi.ExecutionTrace, tasks []*fi.TaskTrace) error {
+	// Times are relative to the start of the run, which makes it much easier to read
+	offset := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Sub(trace.Start).String()
+	}
+
+	columns := []string{"TASK", "TYPE", "READY", "START", "DURATION", "ATTEMPTS", "BLOCKED BY", "STATUS"}
+	fields := []func(*fi.TaskTrace) string{
+		func(t *fi.TaskTrace) string {
+			return t.Key
+		},
+		func(t *fi.TaskTrace) string {
+			return t.Type
+		},
+		func(t *fi.TaskTrace) string {
+			return offset(t.Ready)
+		},
+		func(t *fi.TaskTrace) string {
+			return offset(t.StartTime())
+		},
+		func(t *fi.TaskTrace) string {
+			return t.Duration().String()
+		},
+		func(t *fi.TaskTrace) string {
+			return fmt.Sprintf("%d", len(t.Attempts))
+		},
+		func(t *fi.TaskTrace) string {
+			return t.BlockedBy
+		},
+		func(t *fi.TaskTrace) string {
+			if t.Done {
+				return "done"
+			}
+			if len(t.Attempts) == 0 {
+				return "not run"
+			}
+			return "failed"
+		},
+	}
+	return WriteTable(tasks, columns, fields)
+}
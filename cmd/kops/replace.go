@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+)
+
+type ReplaceCmd struct {
+	Filename string
+}
+
+var replaceCmd ReplaceCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "replace",
+		Short: "Replace cluster configuration",
+		Long: `Replaces the configuration of an existing cluster with the Cluster and InstanceGroups from a YAML manifest.
+
+InstanceGroups that are not in the manifest are removed from the configuration (but their cloud resources are
+not deleted).  This changes only the configuration in the state store; run update cluster to apply it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := replaceCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	rootCommand.AddCommand(cmd)
+
+	cmd.Flags().StringVarP(&replaceCmd.Filename, "filename", "f", "", "YAML manifest containing the Cluster and its InstanceGroups")
+}
+
+func (c *ReplaceCmd) Run() error {
+	if c.Filename == "" {
+		return fmt.Errorf("-f is required")
+	}
+
+	cluster, instanceGroups, err := readManifest(c.Filename)
+	if err != nil {
+		return err
+	}
+
+	stateStore, err := rootCommand.StateStoreForCluster(cluster.Name)
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "replace")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	existing, existingGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+	if existing.Name == "" {
+		return fmt.Errorf("cluster %q not found; use create to create it", cluster.Name)
+	}
+
+	// Keep the original creation timestamps, which are not significant in the manifest
+	cluster.CreationTimestamp = existing.CreationTimestamp
+	for _, g := range instanceGroups {
+		if e := findInstanceGroup(existingGroups, g.Name); e != nil {
+			g.CreationTimestamp = e.CreationTimestamp
+		}
+	}
+
+	err = api.ReplaceConfig(stateStore, cluster, instanceGroups)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Replaced configuration of cluster %q; run update cluster --name %s to apply it\n", cluster.Name, cluster.Name)
+	return nil
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd represents the restore command
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore from backups",
+	Long:  `restore from backups`,
+}
+
+func init() {
+	rootCommand.AddCommand(restoreCmd)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/protokube/pkg/protokube"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"strings"
+	"time"
+)
+
+type RestoreEtcdCmd struct {
+	From        string
+	EtcdCluster string
+	Yes         bool
+}
+
+var restoreEtcdCmd RestoreEtcdCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "etcd",
+		Short: "Restore etcd from a snapshot",
+		Long: `Rebuilds an etcd cluster from a snapshot taken by protokube.
+
+Without --from, lists the available snapshots.  The restore is carried out by protokube on the masters:
+each member stops etcd and moves its data aside, then the cluster is rebuilt from the snapshot.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := restoreEtcdCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	restoreCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&restoreEtcdCmd.From, "from", "", "Snapshot to restore: either the name of a snapshot, or its full path")
+	cmd.Flags().StringVar(&restoreEtcdCmd.EtcdCluster, "etcd-cluster", "main", "Name of the etcd cluster to restore")
+	cmd.Flags().BoolVar(&restoreEtcdCmd.Yes, "yes", false, "Restore the snapshot (otherwise just check it)")
+}
+
+func (c *RestoreEtcdCmd) Run() error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	cluster, _, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	var etcdClusterNames []string
+	found := false
+	for _, etcdCluster := range cluster.Spec.EtcdClusters {
+		etcdClusterNames = append(etcdClusterNames, etcdCluster.Name)
+		if etcdCluster.Name == c.EtcdCluster {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("etcd cluster %q not found (valid names: %s)", c.EtcdCluster, strings.Join(etcdClusterNames, ","))
+	}
+
+	store, err := cloudup.EtcdBackupStore(stateStore, cluster)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := protokube.ListEtcdSnapshots(store, c.EtcdCluster)
+	if err != nil {
+		return err
+	}
+
+	if c.From == "" {
+		if len(snapshots) == 0 {
+			return fmt.Errorf("no snapshots found in %s", store.Join(c.EtcdCluster))
+		}
+		fmt.Printf("Snapshots of etcd cluster %q:\n", c.EtcdCluster)
+		for _, s := range snapshots {
+			fmt.Printf("  %s\n", s.Base())
+		}
+		fmt.Printf("\nSpecify the snapshot to restore with --from\n")
+		return nil
+	}
+
+	var snapshot vfs.Path
+	if strings.Contains(c.From, "://") || strings.HasPrefix(c.From, "/") {
+		snapshot, err = vfs.Context.BuildVfsPath(c.From)
+		if err != nil {
+			return fmt.Errorf("error parsing snapshot path %q: %v", c.From, err)
+		}
+	} else {
+		for _, s := range snapshots {
+			if s.Base() == c.From {
+				snapshot = s
+			}
+		}
+		if snapshot == nil {
+			return fmt.Errorf("snapshot %q not found in %s (run without --from to list snapshots)", c.From, store.Join(c.EtcdCluster))
+		}
+	}
+
+	// Check the snapshot is readable now, rather than when etcd has already been stopped
+	if _, err := snapshot.ReadFile(); err != nil {
+		return fmt.Errorf("error reading snapshot %s: %v", snapshot, err)
+	}
+
+	if !c.Yes {
+		fmt.Printf("Would restore etcd cluster %q from %s\n", c.EtcdCluster, snapshot)
+		fmt.Printf("\nMust specify --yes to restore\n")
+		return nil
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "restore etcd")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	request := &protokube.EtcdRestoreRequest{
+		ID:       time.Now().UTC().Format("20060102T150405Z"),
+		Snapshot: snapshot.Path(),
+	}
+	err = protokube.WriteEtcdRestoreRequest(store, c.EtcdCluster, request)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Requested restore of etcd cluster %q from %s\n", c.EtcdCluster, snapshot)
+	fmt.Fprintf(os.Stderr, "protokube on the masters will rebuild the cluster within a few minutes; the previous data is kept on the volumes\n")
+	return nil
+}
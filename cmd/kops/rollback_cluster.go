@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+)
+
+type RollbackClusterCmd struct {
+	To int
+}
+
+var rollbackClusterCmd RollbackClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Rollback cluster configuration",
+		Long: `Restores the cluster configuration from a previous revision.
+
+This changes only the configuration in the state store; run update cluster to apply it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rollbackClusterCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	rollbackCmd.AddCommand(cmd)
+
+	cmd.Flags().IntVar(&rollbackClusterCmd.To, "to", 0, "Revision to restore (see get cluster --history)")
+}
+
+func (c *RollbackClusterCmd) Run() error {
+	if c.To <= 0 {
+		return fmt.Errorf("--to is required")
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "rollback cluster")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	err = api.RollbackConfig(stateStore, c.To)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := api.ListRevisions(stateStore)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored configuration from revision %d (as revision %d)\n", c.To, revisions[len(revisions)-1])
+	return nil
+}
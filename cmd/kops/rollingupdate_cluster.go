@@ -10,12 +10,21 @@ import (
 	"k8s.io/kops/upup/pkg/kutil"
 	"os"
 	"text/tabwriter"
+	"time"
 )
 
 type RollingUpdateClusterCmd struct {
 	Yes    bool
 	Region string
 
+	BatchSize        int
+	Surge            bool
+	CloudOnly        bool
+	NodeReadyTimeout time.Duration
+	MastersFirst     bool
+	ValidateTimeout  time.Duration
+	Force            bool
+
 	cobraCommand *cobra.Command
 }
 
@@ -35,6 +44,14 @@ func init() {
 
 	cmd.Flags().StringVar(&rollingupdateCluster.Region, "region", "", "region")
 
+	cmd.Flags().IntVar(&rollingupdateCluster.BatchSize, "batch-size", 1, "Number of instances in each group to replace at the same time")
+	cmd.Flags().BoolVar(&rollingupdateCluster.Surge, "surge", false, "Temporarily increase the size of each group, so replacements are running before instances are stopped")
+	cmd.Flags().BoolVar(&rollingupdateCluster.CloudOnly, "cloudonly", false, "Perform rolling update without using the kubernetes API (nodes are not drained)")
+	cmd.Flags().DurationVar(&rollingupdateCluster.NodeReadyTimeout, "node-ready-timeout", kutil.DefaultNodeReadyTimeout, "Maximum time to wait for replacement nodes to become Ready")
+	cmd.Flags().BoolVar(&rollingupdateCluster.MastersFirst, "masters-first", false, "Update masters before nodes, one group at a time, validating the cluster after each step")
+	cmd.Flags().DurationVar(&rollingupdateCluster.ValidateTimeout, "validate-timeout", kutil.DefaultValidateTimeout, "Maximum time to wait for the cluster to pass validation after each step (with --masters-first)")
+	cmd.Flags().BoolVar(&rollingupdateCluster.Force, "force", false, "Replace all instances, even if they are running the current configuration (e.g. after rotating keys)")
+
 	cmd.Run = func(cmd *cobra.Command, args []string) {
 		err := rollingupdateCluster.Run()
 		if err != nil {
@@ -63,6 +80,14 @@ func (c *RollingUpdateClusterCmd) Run() error {
 	d.ClusterName = clusterName
 	d.Region = c.Region
 	d.Cloud = cloud
+	d.BatchSize = c.BatchSize
+	d.Surge = c.Surge
+	d.CloudOnly = c.CloudOnly
+	d.NodeReadyTimeout = c.NodeReadyTimeout
+	d.Kubectl = &kutil.Kubectl{Context: clusterName}
+	d.MastersFirst = c.MastersFirst
+	d.ValidateTimeout = c.ValidateTimeout
+	d.Force = c.Force
 
 	nodesets, err := d.ListNodesets()
 	if err != nil {
@@ -78,6 +103,20 @@ func (c *RollingUpdateClusterCmd) Run() error {
 		return fmt.Errorf("Must specify --yes to rolling-update")
 	}
 
+	// Rolling-update does not need the state store, but if we have one we lock it,
+	// so that the configuration is not updated while we are replacing instances
+	if rootCommand.stateLocation != "" {
+		stateStore, err := rootCommand.StateStore()
+		if err != nil {
+			return err
+		}
+		lock, err := rootCommand.LockState(stateStore, "rolling-update cluster")
+		if err != nil {
+			return err
+		}
+		defer releaseStateLock(lock, stateStore)
+	}
+
 	return d.RollingUpdateNodesets(nodesets)
 }
 
@@ -93,6 +132,10 @@ func (c *RollingUpdateClusterCmd) printNodesets(nodesets map[string]*kutil.Nodes
 		b.WriteByte(tabwriter.Escape)
 		b.WriteByte('\t')
 		b.WriteByte(tabwriter.Escape)
+		b.WriteString(n.Role)
+		b.WriteByte(tabwriter.Escape)
+		b.WriteByte('\t')
+		b.WriteByte(tabwriter.Escape)
 		b.WriteString(n.Status)
 		b.WriteByte(tabwriter.Escape)
 		b.WriteByte('\t')
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/golang/glog"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"k8s.io/kops/upup/pkg/fi"
@@ -19,6 +20,13 @@ type RootCmd struct {
 	stateLocation string
 	clusterName   string
 
+	// forceUnlock removes any existing lock on the state store before taking our own
+	forceUnlock bool
+
+	// encryptionKeyFile is the key file used to encrypt secrets & private keys in the state store
+	encryptionKeyFile string
+	keyProvider       fi.KeyProvider
+
 	cobraCommand *cobra.Command
 }
 
@@ -52,6 +60,11 @@ func init() {
 	cmd.PersistentFlags().StringVarP(&rootCommand.stateLocation, "state", "", defaultStateStore, "Location of state storage")
 
 	cmd.PersistentFlags().StringVarP(&rootCommand.clusterName, "name", "", "", "Name of cluster")
+
+	defaultEncryptionKeyFile := os.Getenv("KOPS_ENCRYPTION_KEY_FILE")
+	cmd.PersistentFlags().StringVar(&rootCommand.encryptionKeyFile, "encryption-key-file", defaultEncryptionKeyFile, "File holding the key used to encrypt secrets in the state store (or set "+envEncryptionPassphrase+")")
+
+	cmd.PersistentFlags().BoolVar(&rootCommand.forceUnlock, "force-unlock", false, "Remove any existing lock on the cluster state before running (use only if no other operation is running)")
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -71,6 +84,37 @@ func initConfig() {
 	}
 }
 
+// envEncryptionPassphrase is the environment variable from which we read the encryption passphrase;
+// we don't accept the passphrase as a flag, so that it doesn't appear in the process list
+const envEncryptionPassphrase = "KOPS_ENCRYPTION_PASSPHRASE"
+
+// KeyProvider returns the KeyProvider for encrypting the state store, or nil if encryption is not configured
+func (c *RootCmd) KeyProvider() (fi.KeyProvider, error) {
+	if c.keyProvider != nil {
+		return c.keyProvider, nil
+	}
+
+	passphrase := os.Getenv(envEncryptionPassphrase)
+	if c.encryptionKeyFile != "" && passphrase != "" {
+		return nil, fmt.Errorf("cannot specify both --encryption-key-file and %s", envEncryptionPassphrase)
+	}
+
+	if c.encryptionKeyFile != "" {
+		keyProvider, err := fi.NewLocalKeyProviderFromFile(c.encryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		c.keyProvider = keyProvider
+	} else if passphrase != "" {
+		keyProvider, err := fi.NewLocalKeyProvider([]byte(passphrase))
+		if err != nil {
+			return nil, err
+		}
+		c.keyProvider = keyProvider
+	}
+	return c.keyProvider, nil
+}
+
 func (c *RootCmd) AddCommand(cmd *cobra.Command) {
 	c.cobraCommand.AddCommand(cmd)
 }
@@ -92,6 +136,15 @@ func (c *RootCmd) StateStore() (fi.StateStore, error) {
 }
 
 func (c *RootCmd) StateStoreForCluster(clusterName string) (fi.StateStore, error) {
+	keyProvider, err := c.KeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	return c.stateStoreWithKeyProvider(clusterName, keyProvider)
+}
+
+// stateStoreWithKeyProvider builds the state store using a specific KeyProvider (e.g. when changing keys)
+func (c *RootCmd) stateStoreWithKeyProvider(clusterName string, keyProvider fi.KeyProvider) (fi.StateStore, error) {
 	if c.stateLocation == "" {
 		return nil, fmt.Errorf("--state is required")
 	}
@@ -105,13 +158,33 @@ func (c *RootCmd) StateStoreForCluster(clusterName string) (fi.StateStore, error
 	}
 
 	isDryrun := false
-	stateStore, err := fi.NewVFSStateStore(statePath, clusterName, isDryrun)
+	stateStore, err := fi.NewVFSStateStore(statePath, clusterName, isDryrun, keyProvider)
 	if err != nil {
 		return nil, fmt.Errorf("error building state store: %v", err)
 	}
 	return stateStore, nil
 }
 
+// LockState takes the advisory lock on the cluster state, for the duration of a mutating operation.
+// The caller must call Release on the returned lock when done.
+func (c *RootCmd) LockState(stateStore fi.StateStore, operation string) (*fi.StateLock, error) {
+	if c.forceUnlock {
+		err := fi.ForceUnlock(stateStore)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return fi.AcquireStateLock(stateStore, operation, fi.DefaultStateLockTTL)
+}
+
+// releaseStateLock releases the lock, logging any error (we don't want to mask the result of the operation)
+func releaseStateLock(lock *fi.StateLock, stateStore fi.StateStore) {
+	err := lock.Release(stateStore)
+	if err != nil {
+		glog.Warningf("error releasing lock on cluster state: %v", err)
+	}
+}
+
 func (c *RootCmd) ListClusters() ([]string, error) {
 	if c.stateLocation == "" {
 		return nil, fmt.Errorf("--state is required")
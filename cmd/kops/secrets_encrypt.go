@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+)
+
+type EncryptSecretsCommand struct {
+	OldKeyFile  string
+	NodeKeyFile string
+	Decrypt     bool
+}
+
+var encryptSecretsCommand EncryptSecretsCommand
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "encrypt",
+		Short: "Encrypt secrets & private keys in the state store",
+		Long: `Encrypts (or re-encrypts) the secrets and private keys in the state store.
+
+The new key is specified with --encryption-key-file or the ` + envEncryptionPassphrase + ` environment variable.
+Use --old-key-file when changing keys, and --decrypt to store the secrets in plain text again.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := encryptSecretsCommand.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	secretsCmd.AddCommand(cmd)
+
+	cmd.Flags().StringVar(&encryptSecretsCommand.OldKeyFile, "old-key-file", "", "Key file the secrets are currently encrypted with (defaults to the new key)")
+	cmd.Flags().StringVar(&encryptSecretsCommand.NodeKeyFile, "node-key-file", "", "Path on the nodes where the encryption key will be installed")
+	cmd.Flags().BoolVar(&encryptSecretsCommand.Decrypt, "decrypt", false, "Decrypt the secrets, storing them in plain text")
+}
+
+func (c *EncryptSecretsCommand) Run() error {
+	newKey, err := rootCommand.KeyProvider()
+	if err != nil {
+		return err
+	}
+	if c.Decrypt {
+		if c.OldKeyFile == "" && newKey == nil {
+			return fmt.Errorf("must specify the current key (--old-key-file, --encryption-key-file or %s) to decrypt", envEncryptionPassphrase)
+		}
+	} else if newKey == nil {
+		return fmt.Errorf("must specify the encryption key with --encryption-key-file or %s", envEncryptionPassphrase)
+	}
+
+	oldKey := newKey
+	if c.OldKeyFile != "" {
+		oldKey, err = fi.NewLocalKeyProviderFromFile(c.OldKeyFile)
+		if err != nil {
+			return err
+		}
+	}
+	if c.Decrypt {
+		newKey = nil
+	}
+
+	stateStore, err := rootCommand.stateStoreWithKeyProvider(rootCommand.clusterName, oldKey)
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "encrypt secrets")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	count, err := fi.ReencryptStateStore(stateStore, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("error re-encrypting state store (%d files were rewritten): %v", count, err)
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return err
+	}
+	if c.Decrypt {
+		cluster.Spec.SecretsEncryption = nil
+	} else if c.NodeKeyFile != "" {
+		cluster.Spec.SecretsEncryption = &api.SecretsEncryptionSpec{KeyFile: c.NodeKeyFile}
+	}
+	err = api.WriteConfig(stateStore, cluster, instanceGroups)
+	if err != nil {
+		return fmt.Errorf("error writing updated configuration: %v", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Rewrote %d files\n", count)
+	if !c.Decrypt && (cluster.Spec.SecretsEncryption == nil || cluster.Spec.SecretsEncryption.KeyFile == "") {
+		fmt.Fprintf(os.Stderr, "Warning: secretsEncryption.keyFile is not set, so the nodes will not be able to read the secrets; use --node-key-file\n")
+	} else {
+		fmt.Fprintf(os.Stderr, "Run kops create cluster to apply the configuration to the nodes\n")
+	}
+	return nil
+}
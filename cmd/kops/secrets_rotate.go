@@ -0,0 +1,20 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// secretsRotateCmd represents the secrets rotate command
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Rotate keypairs",
+	Long: `Rotate keypairs.
+
+Rotation happens in two steps: first new keypairs are issued alongside the old ones, and the nodes are replaced
+(with rolling-update cluster --force) so that they pick up the new keypairs.  Then the old keypairs are retired
+by repeating the rotate command with --retire.`,
+}
+
+func init() {
+	secretsCmd.AddCommand(secretsRotateCmd)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"strings"
+)
+
+type RotateCACommand struct {
+	Reissue bool
+	Retire  bool
+}
+
+var rotateCACommand RotateCACommand
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "Rotate the cluster CA",
+		Long: `Rotates the cluster CA in three stages, with a rolling update after each of the first two:
+the new CA is first added to the trusted CA pool; --reissue then re-issues every keypair signed by the new CA;
+--retire finally removes the old CA and keypairs.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rotateCACommand.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	secretsRotateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&rotateCACommand.Reissue, "reissue", false, "Re-issue every keypair signed by the new CA (after the nodes trust it)")
+	cmd.Flags().BoolVar(&rotateCACommand.Retire, "retire", false, "Remove the old CA and keypairs (after the keypairs have been re-issued)")
+}
+
+func (c *RotateCACommand) Run() error {
+	if c.Reissue && c.Retire {
+		return fmt.Errorf("--reissue and --retire cannot be used together")
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "rotate ca")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	caStore := stateStore.CA()
+
+	ids, err := rotatableKeypairs(caStore)
+	if err != nil {
+		return err
+	}
+
+	if c.Retire {
+		return c.retire(caStore, ids)
+	}
+	if c.Reissue {
+		return c.reissue(caStore, ids)
+	}
+	return c.publish(caStore)
+}
+
+// publish creates the new CA, without signing anything with it yet, so that it can be added to every node's trust pool
+func (c *RotateCACommand) publish(caStore fi.CAStore) error {
+	pool, err := caStore.CertificatePool(fi.CertificateId_CA)
+	if err != nil {
+		return err
+	}
+	if len(pool.Secondary) != 0 {
+		return fmt.Errorf("a CA rotation is already in progress (there are %d CA certificates); continue it with rotate ca --reissue or rotate ca --retire", len(pool.Secondary)+1)
+	}
+
+	ca, err := caStore.RotateCA()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Created CA with serial %s\n", ca.Certificate.SerialNumber.Text(10))
+	fmt.Fprintf(os.Stderr, "Export kubecfg now, so that it trusts both CAs, then run rolling-update cluster --force so every node trusts the new CA\n")
+	fmt.Fprintf(os.Stderr, "Once every node has been replaced, re-issue the keypairs with rotate ca --reissue\n")
+	return nil
+}
+
+// reissue re-issues every keypair that is not yet signed by the new CA
+func (c *RotateCACommand) reissue(caStore fi.CAStore, ids []string) error {
+	pool, err := caStore.CertificatePool(fi.CertificateId_CA)
+	if err != nil {
+		return err
+	}
+	if len(pool.Secondary) == 0 {
+		return fmt.Errorf("there is no CA rotation in progress; start one with rotate ca")
+	}
+
+	for _, id := range ids {
+		cert, err := caStore.Cert(id)
+		if err != nil {
+			return err
+		}
+		if cert.Certificate.CheckSignatureFrom(pool.Primary.Certificate) == nil {
+			glog.V(2).Infof("Keypair %q is already signed by the new CA", id)
+			continue
+		}
+		cert, err = caStore.RotateKeypair(id)
+		if err != nil {
+			return fmt.Errorf("error re-issuing keypair %q: %v", id, err)
+		}
+		fmt.Fprintf(os.Stderr, "Issued keypair %q with serial %s\n", id, cert.Certificate.SerialNumber.Text(10))
+	}
+
+	fmt.Fprintf(os.Stderr, "Run rolling-update cluster --force so the nodes use the new keypairs, then retire the old CA with rotate ca --retire\n")
+	return nil
+}
+
+// retire removes the old CA and keypairs, after checking that every keypair has been re-issued by the current CA
+func (c *RotateCACommand) retire(caStore fi.CAStore, ids []string) error {
+	ca, err := caStore.Cert(fi.CertificateId_CA)
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, id := range ids {
+		cert, err := caStore.Cert(id)
+		if err != nil {
+			return err
+		}
+		if err := cert.Certificate.CheckSignatureFrom(ca.Certificate); err != nil {
+			stale = append(stale, id)
+		}
+	}
+	if len(stale) != 0 {
+		return fmt.Errorf("keypairs %s are not signed by the new CA; re-issue them with rotate ca --reissue before retiring the old CA", strings.Join(stale, ", "))
+	}
+
+	for _, id := range append([]string{fi.CertificateId_CA}, ids...) {
+		retired, err := caStore.RetireKeypair(id)
+		if err != nil {
+			return fmt.Errorf("error retiring keypair %q: %v", id, err)
+		}
+		if len(retired) != 0 {
+			fmt.Fprintf(os.Stderr, "Retired keypair %q serials %s\n", id, strings.Join(retired, ", "))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Export kubecfg again so that it only trusts the new CA; the nodes stop trusting the old CA when they are next replaced\n")
+	return nil
+}
+
+// rotatableKeypairs returns the ids of the keypairs that are signed by the CA (and so must be re-issued when it changes)
+func rotatableKeypairs(caStore fi.CAStore) ([]string, error) {
+	ids, err := caStore.List()
+	if err != nil {
+		return nil, fmt.Errorf("error listing keypairs: %v", err)
+	}
+
+	var keypairs []string
+	for _, id := range ids {
+		if id == fi.CertificateId_CA {
+			continue
+		}
+		cert, err := caStore.FindCert(id)
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil || cert.IsCA {
+			continue
+		}
+		key, err := caStore.FindPrivateKey(id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			glog.Warningf("Skipping keypair %q, as it has no private key", id)
+			continue
+		}
+		keypairs = append(keypairs, id)
+	}
+	return keypairs, nil
+}
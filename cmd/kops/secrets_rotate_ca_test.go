@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"testing"
+)
+
+func TestRotateCAInStages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "castore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caStore, err := fi.NewVFSCAStore(vfs.NewFSPath(dir), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kubelet"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}
+	oldCert, _, err := caStore.CreateKeypair("kubelet", template)
+	if err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+	oldCA, err := caStore.Cert(fi.CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error getting CA: %v", err)
+	}
+
+	c := &RotateCACommand{}
+	ids, err := rotatableKeypairs(caStore)
+	if err != nil {
+		t.Fatalf("error listing keypairs: %v", err)
+	}
+	if err := c.reissue(caStore, ids); err == nil {
+		t.Errorf("expected reissue to fail before the new CA is published")
+	}
+
+	// Stage 1: the new CA is trusted, but the keypairs are still signed by the old one
+	if err := c.publish(caStore); err != nil {
+		t.Fatalf("error publishing new CA: %v", err)
+	}
+	pool, err := caStore.CertificatePool(fi.CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error getting CA pool: %v", err)
+	}
+	if len(pool.Secondary) != 1 || pool.Secondary[0].Certificate.SerialNumber.Cmp(oldCA.Certificate.SerialNumber) != 0 {
+		t.Fatalf("expected the old CA to remain in the pool")
+	}
+	cert, err := caStore.Cert("kubelet")
+	if err != nil {
+		t.Fatalf("error getting keypair: %v", err)
+	}
+	if cert.Certificate.SerialNumber.Cmp(oldCert.Certificate.SerialNumber) != 0 {
+		t.Errorf("expected the keypair not to be re-issued when the CA is published")
+	}
+	if err := c.publish(caStore); err == nil {
+		t.Errorf("expected a second publish to fail while a rotation is in progress")
+	}
+	if err := c.retire(caStore, ids); err == nil {
+		t.Errorf("expected retire to fail before the keypairs are re-issued")
+	}
+
+	// Stage 2: the keypairs are re-issued by the new CA; running it again does nothing
+	if err := c.reissue(caStore, ids); err != nil {
+		t.Fatalf("error re-issuing keypairs: %v", err)
+	}
+	cert, err = caStore.Cert("kubelet")
+	if err != nil {
+		t.Fatalf("error getting keypair: %v", err)
+	}
+	if err := cert.Certificate.CheckSignatureFrom(pool.Primary.Certificate); err != nil {
+		t.Fatalf("expected keypair to be signed by the new CA: %v", err)
+	}
+	if err := c.reissue(caStore, ids); err != nil {
+		t.Fatalf("error re-issuing keypairs again: %v", err)
+	}
+	again, err := caStore.Cert("kubelet")
+	if err != nil {
+		t.Fatalf("error getting keypair: %v", err)
+	}
+	if again.Certificate.SerialNumber.Cmp(cert.Certificate.SerialNumber) != 0 {
+		t.Errorf("expected a keypair signed by the new CA not to be re-issued again")
+	}
+
+	// Stage 3: only the new CA remains
+	if err := c.retire(caStore, ids); err != nil {
+		t.Fatalf("error retiring old CA: %v", err)
+	}
+	pool, err = caStore.CertificatePool(fi.CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error getting CA pool: %v", err)
+	}
+	if len(pool.Secondary) != 0 {
+		t.Errorf("expected the old CA to be retired, found %d secondary CAs", len(pool.Secondary))
+	}
+}
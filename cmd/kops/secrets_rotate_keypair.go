@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"strings"
+)
+
+type RotateKeypairCommand struct {
+	Retire bool
+}
+
+var rotateKeypairCommand RotateKeypairCommand
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "keypair <id>",
+		Short: "Rotate a keypair",
+		Long:  `Issues a new keypair (with a new private key) signed by the current CA, or with --retire removes the old keypairs.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := rotateKeypairCommand.Run(args)
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	secretsRotateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&rotateKeypairCommand.Retire, "retire", false, "Remove the old keypairs (after the rolling update)")
+}
+
+func (c *RotateKeypairCommand) Run(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("must specify the id of the keypair to rotate")
+	}
+	id := args[0]
+	if id == fi.CertificateId_CA {
+		return fmt.Errorf("use rotate ca to rotate the CA")
+	}
+
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	lock, err := rootCommand.LockState(stateStore, "rotate keypair")
+	if err != nil {
+		return err
+	}
+	defer releaseStateLock(lock, stateStore)
+
+	caStore := stateStore.CA()
+
+	if c.Retire {
+		retired, err := caStore.RetireKeypair(id)
+		if err != nil {
+			return err
+		}
+		if len(retired) == 0 {
+			fmt.Fprintf(os.Stderr, "No old keypairs found for %q\n", id)
+		} else {
+			fmt.Fprintf(os.Stderr, "Retired keypair %q serials %s\n", id, strings.Join(retired, ", "))
+		}
+		return nil
+	}
+
+	cert, err := caStore.RotateKeypair(id)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Issued keypair %q with serial %s\n", id, cert.Certificate.SerialNumber.Text(10))
+	fmt.Fprintf(os.Stderr, "Run rolling-update cluster --force so the nodes use the new keypair, then retire the old keypair with rotate keypair %s --retire\n", id)
+	return nil
+}
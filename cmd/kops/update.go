@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// updateCmd represents the update command
+var updateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "update clusters",
+	Long:  `Update clusters to match their configuration`,
+}
+
+func init() {
+	rootCommand.AddCommand(updateCmd)
+}
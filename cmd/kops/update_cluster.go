@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"strings"
+	"time"
+)
+
+type UpdateClusterCmd struct {
+	Yes             bool
+	ModelsBaseDir   string
+	Models          string
+	NodeModel       string
+	SSHPublicKey    string
+	OutDir          string
+	PlanFormat      string
+	MaxConcurrency  int
+	TaskTimeout     time.Duration
+	MaxTaskAttempts int
+	TraceFile       string
+}
+
+var updateCluster UpdateClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Update cluster",
+		Long: `Updates the cloud resources of an existing cluster to match the configuration in the state store.
+
+Without --yes, shows the changes that would be made.  Instance groups whose launch configuration changes
+must then be replaced with rolling-update cluster, so that their instances pick up the new configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := updateCluster.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	updateCmd.AddCommand(cmd)
+
+	cmd.Flags().BoolVar(&updateCluster.Yes, "yes", false, "Apply the changes; without this only the changes that would be made are shown")
+	cmd.Flags().StringVar(&updateCluster.ModelsBaseDir, "modeldir", defaultModelsBaseDir(), "Source directory where models are stored")
+	cmd.Flags().StringVar(&updateCluster.Models, "model", "config,proto,cloudup", "Models to apply (separate multiple models with commas)")
+	cmd.Flags().StringVar(&updateCluster.NodeModel, "nodemodel", "nodeup", "Model to use for node configuration")
+	cmd.Flags().StringVar(&updateCluster.SSHPublicKey, "ssh-public-key", "~/.ssh/id_rsa.pub", "SSH public key to use")
+	cmd.Flags().StringVar(&updateCluster.OutDir, "out", "out", "Path to write any local output")
+	cmd.Flags().StringVar(&updateCluster.PlanFormat, "plan-format", "", "Format of the dry-run output - text (default), json, yaml")
+
+	cmd.Flags().IntVar(&updateCluster.MaxConcurrency, "max-concurrency", 10, "Maximum number of tasks to run at the same time")
+	cmd.Flags().DurationVar(&updateCluster.TaskTimeout, "task-timeout", 0, "Time after which a task is abandoned, failing the update (0 for no timeout)")
+	cmd.Flags().IntVar(&updateCluster.MaxTaskAttempts, "max-task-attempts", 8, "Number of times to try a task before giving up")
+	cmd.Flags().StringVar(&updateCluster.TraceFile, "trace-file", "", "Also write the execution trace (JSON) to this local file")
+}
+
+func (c *UpdateClusterCmd) Run() error {
+	stateStoreLocation := rootCommand.stateLocation
+	if stateStoreLocation == "" {
+		return fmt.Errorf("--state is required")
+	}
+
+	clusterName := rootCommand.clusterName
+	if clusterName == "" {
+		return fmt.Errorf("--name is required")
+	}
+
+	statePath, err := vfs.Context.BuildVfsPath(stateStoreLocation)
+	if err != nil {
+		return fmt.Errorf("error building state location: %v", err)
+	}
+
+	keyProvider, err := rootCommand.KeyProvider()
+	if err != nil {
+		return err
+	}
+
+	if c.SSHPublicKey != "" {
+		c.SSHPublicKey = utils.ExpandPath(c.SSHPublicKey)
+	}
+
+	var stateStore fi.StateStore
+	if c.Yes {
+		stateStore, err = fi.NewVFSStateStore(statePath, clusterName, false, keyProvider)
+		if err != nil {
+			return fmt.Errorf("error building state store: %v", err)
+		}
+
+		lock, err := rootCommand.LockState(stateStore, "update cluster")
+		if err != nil {
+			return err
+		}
+		defer releaseStateLock(lock, stateStore)
+	}
+
+	// We always compute the changes first, so we can report them and which instance groups must be replaced
+	dryRunStateStore, err := fi.NewVFSStateStore(statePath, clusterName, true, keyProvider)
+	if err != nil {
+		return fmt.Errorf("error building state store: %v", err)
+	}
+
+	cluster, instanceGroups, err := c.readConfig(dryRunStateStore)
+	if err != nil {
+		return err
+	}
+
+	preview := c.buildCmd(cluster, instanceGroups, dryRunStateStore, "dryrun")
+	err = preview.Run()
+	if err != nil {
+		return err
+	}
+
+	if !preview.Plan.HasChanges() {
+		fmt.Printf("\nNo changes need to be applied to cluster %q\n", clusterName)
+		return nil
+	}
+
+	needUpdate := cloudup.InstanceGroupsNeedingRollingUpdate(preview.Plan, cluster, instanceGroups)
+
+	if !c.Yes {
+		fmt.Printf("\nMust specify --yes to apply changes\n")
+		printRollingUpdateNeeded(clusterName, needUpdate)
+		return nil
+	}
+
+	cluster, instanceGroups, err = c.readConfig(stateStore)
+	if err != nil {
+		return err
+	}
+
+	err = c.buildCmd(cluster, instanceGroups, stateStore, "direct").Run()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\nUpdated cluster %q\n", clusterName)
+	printRollingUpdateNeeded(clusterName, needUpdate)
+	return nil
+}
+
+// readConfig reads the configuration of an existing cluster from the state store
+func (c *UpdateClusterCmd) readConfig(stateStore fi.StateStore) (*api.Cluster, []*api.InstanceGroup, error) {
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error loading configuration: %v", err)
+	}
+	if cluster.Name == "" {
+		return nil, nil, fmt.Errorf("cluster %q not found; use create cluster to create it", rootCommand.clusterName)
+	}
+	return cluster, instanceGroups, nil
+}
+
+func (c *UpdateClusterCmd) buildCmd(cluster *api.Cluster, instanceGroups []*api.InstanceGroup, stateStore fi.StateStore, target string) *cloudup.CreateClusterCmd {
+	cmd := &cloudup.CreateClusterCmd{
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		ModelStore:     c.ModelsBaseDir,
+		Models:         strings.Split(c.Models, ","),
+		StateStore:     stateStore,
+		Target:         target,
+		NodeModel:      c.NodeModel,
+		SSHPublicKey:   c.SSHPublicKey,
+		OutDir:         c.OutDir,
+		RunTasksOptions: fi.RunTasksOptions{
+			MaxConcurrency:  c.MaxConcurrency,
+			TaskTimeout:     c.TaskTimeout,
+			MaxTaskAttempts: c.MaxTaskAttempts,
+		},
+		TraceFile: c.TraceFile,
+	}
+	if target == "dryrun" {
+		cmd.PlanFormat = c.PlanFormat
+	}
+	return cmd
+}
+
+// printRollingUpdateNeeded reports the instance groups whose instances are running a previous launch configuration
+func printRollingUpdateNeeded(clusterName string, instanceGroups []*api.InstanceGroup) {
+	if len(instanceGroups) == 0 {
+		return
+	}
+
+	fmt.Printf("\nThe launch configuration of these instance groups changes; their existing instances must be replaced:\n")
+	for _, g := range instanceGroups {
+		fmt.Printf("  %s\n", g.Name)
+	}
+	fmt.Printf("Use kops rolling-update cluster --name %s to replace them\n", clusterName)
+}
@@ -44,10 +44,26 @@ func (c *UpgradeClusterCmd) Run() error {
 		return err
 	}
 
-	newStateStore, err := rootCommand.StateStoreForCluster(c.NewClusterName)
+	oldLock, err := rootCommand.LockState(oldStateStore, "upgrade cluster")
 	if err != nil {
 		return err
 	}
+	defer releaseStateLock(oldLock, oldStateStore)
+
+	// When upgrading in place, the old and new state are the same, and we already hold the lock
+	newStateStore := oldStateStore
+	if c.NewClusterName != rootCommand.clusterName {
+		newStateStore, err = rootCommand.StateStoreForCluster(c.NewClusterName)
+		if err != nil {
+			return err
+		}
+
+		newLock, err := rootCommand.LockState(newStateStore, "upgrade cluster")
+		if err != nil {
+			return err
+		}
+		defer releaseStateLock(newLock, newStateStore)
+	}
 
 	cluster, instanceGroups, err := api.ReadConfig(oldStateStore)
 	if err != nil {
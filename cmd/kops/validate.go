@@ -0,0 +1,16 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "validate clusters",
+	Long:  `Validate clusters`,
+}
+
+func init() {
+	rootCommand.AddCommand(validateCmd)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	"github.com/spf13/cobra"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/kutil"
+)
+
+type ValidateClusterCmd struct {
+}
+
+var validateClusterCmd ValidateClusterCmd
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "Validate cluster",
+		Long:  `Validate a running cluster against its configuration.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := validateClusterCmd.Run()
+			if err != nil {
+				glog.Exitf("%v", err)
+			}
+		},
+	}
+
+	validateCmd.AddCommand(cmd)
+}
+
+func (c *ValidateClusterCmd) Run() error {
+	stateStore, err := rootCommand.StateStore()
+	if err != nil {
+		return err
+	}
+
+	cluster, instanceGroups, err := api.ReadConfig(stateStore)
+	if err != nil {
+		return fmt.Errorf("error reading configuration: %v", err)
+	}
+
+	if cluster.Name == "" {
+		return fmt.Errorf("ClusterName must be set in config")
+	}
+
+	// The DNS names are only populated in the completed spec
+	completed := &api.Cluster{}
+	err = stateStore.ReadConfig(cloudup.PathClusterCompleted, completed)
+	if err != nil {
+		return fmt.Errorf("error reading completed cluster configuration: %v", err)
+	}
+	if cluster.Spec.MasterPublicName == "" {
+		cluster.Spec.MasterPublicName = completed.Spec.MasterPublicName
+	}
+	if cluster.Spec.MasterInternalName == "" {
+		cluster.Spec.MasterInternalName = completed.Spec.MasterInternalName
+	}
+
+	cloud, err := cloudup.BuildCloud(cluster)
+	if err != nil {
+		return err
+	}
+
+	v := &kutil.ValidateCluster{
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		Cloud:          cloud,
+		Kubectl:        &kutil.Kubectl{Context: cluster.Name},
+	}
+
+	results, err := v.Validate()
+	if err != nil {
+		return err
+	}
+
+	columns := []string{"KIND", "NAME", "STATUS", "MESSAGE"}
+	fields := []func(*kutil.ValidationResult) string{
+		func(r *kutil.ValidationResult) string {
+			return r.Kind
+		},
+		func(r *kutil.ValidationResult) string {
+			return r.Name
+		},
+		func(r *kutil.ValidationResult) string {
+			return r.Status
+		},
+		func(r *kutil.ValidationResult) string {
+			return r.Message
+		},
+	}
+	err = WriteTable(results, columns, fields)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.IsOK() {
+			failed++
+		}
+	}
+	if failed != 0 {
+		return fmt.Errorf("cluster %q failed validation: %d of %d checks failed", cluster.Name, failed, len(results))
+	}
+
+	fmt.Printf("\nCluster %q is valid\n", cluster.Name)
+	return nil
+}
@@ -5,15 +5,21 @@ import (
 	"fmt"
 	"github.com/golang/glog"
 	"k8s.io/kops/protokube/pkg/protokube"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
 	"net"
 	"os"
 	"strings"
+	"time"
 )
 
 func main() {
 	master := false
 	flag.BoolVar(&master, "master", master, "Act as master")
 
+	cloud := "aws"
+	flag.StringVar(&cloud, "cloud", cloud, "CloudProvider we are using (aws,gce)")
+
 	containerized := false
 	flag.BoolVar(&containerized, "containerized", containerized, "Set if we are running containerized.")
 
@@ -26,17 +32,73 @@ func main() {
 	clusterID := ""
 	flag.StringVar(&clusterID, "cluster-id", clusterID, "Cluster ID")
 
+	dnsProvider := ""
+	flag.StringVar(&dnsProvider, "dns-provider", dnsProvider, "DNS provider (route53, google-clouddns, rfc2136, hosts); defaults to route53 on aws and google-clouddns on gce")
+
+	dnsServer := ""
+	flag.StringVar(&dnsServer, "dns-server", dnsServer, "DNS server to send dynamic updates to, for rfc2136")
+
+	dnsTSIGKeyName := ""
+	flag.StringVar(&dnsTSIGKeyName, "dns-tsig-key-name", dnsTSIGKeyName, "Name of the TSIG key used to sign dynamic updates, for rfc2136")
+
+	dnsTSIGSecretFile := ""
+	flag.StringVar(&dnsTSIGSecretFile, "dns-tsig-secret-file", dnsTSIGSecretFile, "File holding the TSIG secret, for rfc2136")
+
+	dnsTSIGAlgorithm := ""
+	flag.StringVar(&dnsTSIGAlgorithm, "dns-tsig-algorithm", dnsTSIGAlgorithm, "TSIG algorithm, for rfc2136 (default hmac-sha256)")
+
+	dnsHostsStore := ""
+	flag.StringVar(&dnsHostsStore, "dns-hosts-store", dnsHostsStore, "VFS path where the host records are shared between machines, for hosts")
+
+	masterInternalName := ""
+	flag.StringVar(&masterInternalName, "master-internal-name", masterInternalName, "Internal DNS name of the API, which masters publish")
+
+	etcdBackupStore := ""
+	flag.StringVar(&etcdBackupStore, "etcd-backup-store", etcdBackupStore, "VFS path where snapshots of etcd are stored; snapshots are disabled if not set")
+
+	etcdBackupInterval := time.Hour
+	flag.DurationVar(&etcdBackupInterval, "etcd-backup-interval", etcdBackupInterval, "How often to take a snapshot of etcd")
+
+	etcdBackupRetention := 24
+	flag.IntVar(&etcdBackupRetention, "etcd-backup-retention", etcdBackupRetention, "Number of snapshots of each etcd cluster to keep")
+
+	encryptionKeyFile := ""
+	flag.StringVar(&encryptionKeyFile, "encryption-key-file", encryptionKeyFile, "File holding the key used to encrypt the secrets in the state store; etcd snapshots are encrypted with it")
+
 	flag.Set("logtostderr", "true")
 	flag.Parse()
 
-	volumes, err := protokube.NewAWSVolumes()
-	if err != nil {
-		glog.Errorf("Error initializing AWS: %q", err)
+	var volumes protokube.Volumes
+	var internalIP net.IP
+	var cloudClusterID string
+	switch cloud {
+	case "aws":
+		awsVolumes, err := protokube.NewAWSVolumes()
+		if err != nil {
+			glog.Errorf("Error initializing AWS: %q", err)
+			os.Exit(1)
+		}
+		volumes = awsVolumes
+		internalIP = awsVolumes.InternalIP()
+		cloudClusterID = awsVolumes.ClusterID()
+
+	case "gce":
+		gceVolumes, err := protokube.NewGCEVolumes()
+		if err != nil {
+			glog.Errorf("Error initializing GCE: %q", err)
+			os.Exit(1)
+		}
+		volumes = gceVolumes
+		internalIP = gceVolumes.InternalIP()
+		cloudClusterID = gceVolumes.ClusterID()
+
+	default:
+		glog.Errorf("Unknown cloud %q (expected aws or gce)", cloud)
 		os.Exit(1)
 	}
 
 	if clusterID == "" {
-		clusterID = volumes.ClusterID()
+		clusterID = cloudClusterID
 		if clusterID == "" {
 			glog.Errorf("cluster-id is required (cannot be determined from cloud)")
 			os.Exit(1)
@@ -68,13 +130,6 @@ func main() {
 	//	glog.Errorf("Error finding internal IP: %q", err)
 	//	os.Exit(1)
 	//}
-	internalIP := volumes.InternalIP()
-
-	dns, err := protokube.NewRoute53DNSProvider(dnsZoneName)
-	if err != nil {
-		glog.Errorf("Error initializing DNS: %q", err)
-		os.Exit(1)
-	}
 
 	rootfs := "/"
 	if containerized {
@@ -83,6 +138,42 @@ func main() {
 	protokube.RootFS = rootfs
 	protokube.Containerized = containerized
 
+	if dnsProvider == "" {
+		switch cloud {
+		case "gce":
+			dnsProvider = "google-clouddns"
+		default:
+			dnsProvider = "route53"
+		}
+	}
+
+	var dns protokube.DNSProvider
+	var err error
+	switch dnsProvider {
+	case "route53":
+		dns, err = protokube.NewRoute53DNSProvider(dnsZoneName)
+	case "google-clouddns":
+		dns, err = protokube.NewGoogleCloudDNSProvider("", dnsZoneName)
+	case "rfc2136":
+		dns, err = protokube.NewRFC2136DNSProvider(dnsServer, dnsZoneName, dnsTSIGKeyName, dnsTSIGSecretFile, dnsTSIGAlgorithm)
+	case "hosts":
+		var store vfs.Path
+		if dnsHostsStore != "" {
+			store, err = vfs.Context.BuildVfsPath(dnsHostsStore)
+		}
+		if err == nil {
+			hostsProvider := protokube.NewHostsFileDNSProvider(store, internalIP.String())
+			go hostsProvider.RunSyncLoop()
+			dns = hostsProvider
+		}
+	default:
+		err = fmt.Errorf("unknown dns-provider %q", dnsProvider)
+	}
+	if err != nil {
+		glog.Errorf("Error initializing DNS: %q", err)
+		os.Exit(1)
+	}
+
 	modelDir := "model/etcd"
 
 	k := &protokube.KubeBoot{
@@ -92,9 +183,31 @@ func main() {
 		//MasterID          : fromVolume
 		//EtcdClusters   : fromVolume
 
-		ModelDir: modelDir,
-		DNS:      dns,
+		ModelDir:           modelDir,
+		DNS:                dns,
+		MasterInternalName: masterInternalName,
 	}
+	if etcdBackupStore != "" {
+		p, err := vfs.Context.BuildVfsPath(etcdBackupStore)
+		if err != nil {
+			glog.Errorf("Error parsing etcd-backup-store %q: %v", etcdBackupStore, err)
+			os.Exit(1)
+		}
+		k.EtcdBackup = &protokube.EtcdBackupConfig{
+			Store:     p,
+			Interval:  etcdBackupInterval,
+			Retention: etcdBackupRetention,
+		}
+		if encryptionKeyFile != "" {
+			keyProvider, err := fi.NewLocalKeyProviderFromFile(protokube.PathFor(encryptionKeyFile))
+			if err != nil {
+				glog.Errorf("Error reading encryption key: %v", err)
+				os.Exit(1)
+			}
+			k.EtcdBackup.KeyProvider = keyProvider
+		}
+	}
+
 	k.Init(volumes)
 
 	k.RunSyncLoop()
@@ -0,0 +1,356 @@
+package protokube
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EtcdBackupConfig configures the snapshots of etcd that we take on the masters
+type EtcdBackupConfig struct {
+	// Store is the base path for snapshots; each etcd cluster is stored under <Store>/<ClusterKey>/
+	Store vfs.Path
+	// Interval is how often we take a snapshot
+	Interval time.Duration
+	// Retention is the number of snapshots we keep for each etcd cluster
+	Retention int
+	// KeyProvider encrypts the snapshots, in the same way as the secrets in the state store; if nil they are not encrypted
+	KeyProvider fi.KeyProvider
+}
+
+const etcdSnapshotSuffix = ".tar.gz"
+
+// etcdSnapshotTimeFormat is used to name snapshots, so that they sort in chronological order
+const etcdSnapshotTimeFormat = "20060102T150405Z"
+
+// ListEtcdSnapshots returns the snapshots of the etcd cluster, oldest first
+func ListEtcdSnapshots(store vfs.Path, clusterKey string) ([]vfs.Path, error) {
+	files, err := store.Join(clusterKey).ReadDir()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing snapshots in %s: %v", store.Join(clusterKey), err)
+	}
+
+	var snapshots []vfs.Path
+	for _, f := range files {
+		if _, err := parseEtcdSnapshotTime(f.Base()); err != nil {
+			continue
+		}
+		snapshots = append(snapshots, f)
+	}
+	sort.Sort(byBase(snapshots))
+	return snapshots, nil
+}
+
+func parseEtcdSnapshotTime(name string) (time.Time, error) {
+	if !strings.HasSuffix(name, etcdSnapshotSuffix) {
+		return time.Time{}, fmt.Errorf("not a snapshot: %q", name)
+	}
+	return time.Parse(etcdSnapshotTimeFormat, strings.TrimSuffix(name, etcdSnapshotSuffix))
+}
+
+type byBase []vfs.Path
+
+func (a byBase) Len() int           { return len(a) }
+func (a byBase) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byBase) Less(i, j int) bool { return a[i].Base() < a[j].Base() }
+
+// backupIfDue takes a snapshot if we are the etcd leader, and the latest snapshot is older than the interval.
+// Only the leader takes snapshots, so that we only take one snapshot per cluster.
+func (k *EtcdController) backupIfDue() error {
+	b := k.kubeBoot.EtcdBackup
+	c := k.cluster
+
+	leader, err := c.isLeader(k.kubeBoot)
+	if err != nil {
+		// etcd may not have started yet
+		glog.V(2).Infof("unable to determine if %s is the etcd leader: %v", c.Me.Name, err)
+		return nil
+	}
+	if !leader {
+		return nil
+	}
+
+	snapshots, err := ListEtcdSnapshots(b.Store, c.Spec.ClusterKey)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) != 0 {
+		latest, err := parseEtcdSnapshotTime(snapshots[len(snapshots)-1].Base())
+		if err != nil {
+			return err
+		}
+		if time.Since(latest) < b.Interval {
+			return nil
+		}
+	}
+
+	p := b.Store.Join(c.Spec.ClusterKey, time.Now().UTC().Format(etcdSnapshotTimeFormat)+etcdSnapshotSuffix)
+	err = c.snapshot(p, b.KeyProvider)
+	if err != nil {
+		return err
+	}
+	snapshots = append(snapshots, p)
+
+	return pruneEtcdSnapshots(snapshots, b.Retention)
+}
+
+// pruneEtcdSnapshots removes the oldest snapshots, keeping the most recent retention snapshots.
+// snapshots must be ordered oldest first, as returned by ListEtcdSnapshots; if retention is not positive we keep everything.
+func pruneEtcdSnapshots(snapshots []vfs.Path, retention int) error {
+	if retention <= 0 || len(snapshots) <= retention {
+		return nil
+	}
+	for _, old := range snapshots[:len(snapshots)-retention] {
+		glog.Infof("Removing old etcd snapshot %s", old)
+		err := old.Remove()
+		if err != nil {
+			return fmt.Errorf("error removing old etcd snapshot %s: %v", old, err)
+		}
+	}
+	return nil
+}
+
+// snapshot takes a backup of the etcd data directory (using etcdctl backup), and writes it as a tar.gz to p,
+// encrypted with keyProvider (if it is not nil)
+func (c *EtcdCluster) snapshot(p vfs.Path, keyProvider fi.KeyProvider) error {
+	tmpDir, err := ioutil.TempDir("", "etcd-backup")
+	if err != nil {
+		return fmt.Errorf("error creating temp directory: %v", err)
+	}
+	defer func() {
+		err := os.RemoveAll(tmpDir)
+		if err != nil {
+			glog.Warningf("error removing temp directory %q: %v", tmpDir, err)
+		}
+	}()
+
+	backupDir := path.Join(tmpDir, "backup")
+	glog.Infof("Taking snapshot of etcd cluster %s", c.ClusterName)
+	cmd := exec.Command("etcdctl", "backup", "--data-dir", c.dataDir(), "--backup-dir", backupDir)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running etcdctl backup: %v: %s", err, string(output))
+	}
+
+	data, err := tarDirectory(backupDir)
+	if err != nil {
+		return err
+	}
+	data, err = fi.EncryptData(keyProvider, data)
+	if err != nil {
+		return fmt.Errorf("error encrypting etcd snapshot: %v", err)
+	}
+
+	err = p.WriteFile(data)
+	if err != nil {
+		return fmt.Errorf("error writing etcd snapshot to %s: %v", p, err)
+	}
+	glog.Infof("Wrote etcd snapshot %s", p)
+	return nil
+}
+
+// tarDirectory builds a tar.gz of the contents of dir
+func tarDirectory(dir string) ([]byte, error) {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error building archive of %q: %v", dir, err)
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("error building archive of %q: %v", dir, err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("error building archive of %q: %v", dir, err)
+	}
+	return b.Bytes(), nil
+}
+
+// untarDirectory extracts a tar.gz built by tarDirectory into dir
+func untarDirectory(data []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error reading archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid path in archive: %q", header.Name)
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(dest, 0700)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFileFrom(dest, tr, os.FileMode(header.Mode))
+		default:
+			err = fmt.Errorf("unexpected type %v for %q", header.Typeflag, header.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("error extracting %q: %v", header.Name, err)
+		}
+	}
+}
+
+func writeFileFrom(dest string, r io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(dest), 0700)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// etcdMember is a member, as returned by the etcd (v2) members API
+type etcdMember struct {
+	ID         string   `json:"id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	PeerURLs   []string `json:"peerURLs"`
+	ClientURLs []string `json:"clientURLs,omitempty"`
+}
+
+type etcdMemberList struct {
+	Members []*etcdMember `json:"members"`
+}
+
+// clientURL is the URL we use to talk to our etcd member; we don't run with the host network, so we use the internal IP
+func (c *EtcdCluster) clientURL(k *KubeBoot) string {
+	return fmt.Sprintf("http://%s:%d", k.InternalIP, c.ClientPort)
+}
+
+func (c *EtcdCluster) peerURL(node *EtcdNode) string {
+	return fmt.Sprintf("http://%s:%d", node.InternalName, c.PeerPort)
+}
+
+var etcdHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func etcdGet(url string, dest interface{}) error {
+	response, err := etcdHTTPClient.Get(url)
+	if err != nil {
+		return fmt.Errorf("error querying etcd %q: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response querying etcd %q: %s", url, response.Status)
+	}
+	if dest == nil {
+		return nil
+	}
+	err = json.NewDecoder(response.Body).Decode(dest)
+	if err != nil {
+		return fmt.Errorf("error parsing etcd response from %q: %v", url, err)
+	}
+	return nil
+}
+
+// isLeader returns true if our etcd member is the leader of the cluster
+func (c *EtcdCluster) isLeader(k *KubeBoot) (bool, error) {
+	stats := struct {
+		State string `json:"state"`
+	}{}
+	err := etcdGet(c.clientURL(k)+"/v2/stats/self", &stats)
+	if err != nil {
+		return false, err
+	}
+	return stats.State == "StateLeader", nil
+}
+
+// isHealthy returns true if the etcd member at clientURL reports it is healthy
+func isEtcdHealthy(clientURL string) bool {
+	err := etcdGet(clientURL+"/health", nil)
+	return err == nil
+}
+
+func listEtcdMembers(clientURL string) ([]*etcdMember, error) {
+	members := &etcdMemberList{}
+	err := etcdGet(clientURL+"/v2/members", members)
+	if err != nil {
+		return nil, err
+	}
+	return members.Members, nil
+}
+
+func addEtcdMember(clientURL string, peerURL string) error {
+	body, err := json.Marshal(&etcdMember{PeerURLs: []string{peerURL}})
+	if err != nil {
+		return fmt.Errorf("error serializing etcd member: %v", err)
+	}
+	response, err := etcdHTTPClient.Post(clientURL+"/v2/members", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error adding etcd member %q: %v", peerURL, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusCreated && response.StatusCode != http.StatusConflict {
+		return fmt.Errorf("unexpected response adding etcd member %q: %s", peerURL, response.Status)
+	}
+	return nil
+}
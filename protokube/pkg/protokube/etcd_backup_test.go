@@ -0,0 +1,234 @@
+package protokube
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTarUntarDirectory(t *testing.T) {
+	src, err := ioutil.TempDir("", "etcd-backup")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(src)
+
+	files := map[string]string{
+		"member/snap/db":          "snapshot data",
+		"member/wal/0000-00.wal":  "wal data",
+		"member/wal/empty-file":   "",
+		"top-level-file":          "top",
+		"member/snap/nested/leaf": "leaf",
+	}
+	for name, contents := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("error creating directory: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(contents), 0600); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+	}
+
+	data, err := tarDirectory(src)
+	if err != nil {
+		t.Fatalf("error building archive: %v", err)
+	}
+
+	dest, err := ioutil.TempDir("", "etcd-restore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dest)
+
+	err = untarDirectory(data, filepath.Join(dest, "data"))
+	if err != nil {
+		t.Fatalf("error extracting archive: %v", err)
+	}
+
+	for name, contents := range files {
+		p := filepath.Join(dest, "data", filepath.FromSlash(name))
+		actual, err := ioutil.ReadFile(p)
+		if err != nil {
+			t.Errorf("error reading extracted file %q: %v", name, err)
+			continue
+		}
+		if string(actual) != contents {
+			t.Errorf("unexpected contents of %q: %q", name, string(actual))
+		}
+		stat, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("error getting state of %q: %v", name, err)
+		}
+		if stat.Mode().Perm() != 0600 {
+			t.Errorf("unexpected mode of %q: %v", name, stat.Mode())
+		}
+	}
+}
+
+// buildTestArchive builds a tar.gz holding a single file with the specified name
+func buildTestArchive(t *testing.T, name string) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	contents := []byte("evil")
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+	if err != nil {
+		t.Fatalf("error writing header: %v", err)
+	}
+	if _, err := tw.Write(contents); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("error closing archive: %v", err)
+	}
+	return b.Bytes()
+}
+
+func TestUntarDirectoryRejectsEscapingPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-restore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	dest := filepath.Join(dir, "data")
+	for _, name := range []string{"../evil", "member/../../evil", "..", "/etc/evil"} {
+		err := untarDirectory(buildTestArchive(t, name), dest)
+		if err == nil || !strings.Contains(err.Error(), "invalid path") {
+			t.Errorf("expected %q to be rejected, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+		t.Fatalf("file was written outside the destination directory")
+	}
+
+	// A path that only appears to escape is fine
+	err = untarDirectory(buildTestArchive(t, "member/../ok"), dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "ok")); err != nil {
+		t.Fatalf("expected file to be extracted: %v", err)
+	}
+}
+
+func TestListAndPruneEtcdSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-snapshots")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	store := vfs.NewFSPath(dir)
+
+	// No snapshots yet
+	snapshots, err := ListEtcdSnapshots(store, "main")
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+	if len(snapshots) != 0 {
+		t.Fatalf("expected no snapshots, got %v", snapshots)
+	}
+
+	names := []string{
+		"20161017T130000Z.tar.gz",
+		"20161017T110000Z.tar.gz",
+		"20161017T120000Z.tar.gz",
+		"20161016T230000Z.tar.gz",
+	}
+	for _, name := range append(names, EtcdRestoreRequestFile, "not-a-snapshot.tar.gz", "20161017T140000Z.tar") {
+		if err := store.Join("main", name).WriteFile([]byte(name)); err != nil {
+			t.Fatalf("error writing %q: %v", name, err)
+		}
+	}
+	// Another etcd cluster is listed separately
+	if err := store.Join("events", "20161017T150000Z.tar.gz").WriteFile(nil); err != nil {
+		t.Fatalf("error writing snapshot: %v", err)
+	}
+
+	snapshots, err = ListEtcdSnapshots(store, "main")
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+	expected := []string{
+		"20161016T230000Z.tar.gz",
+		"20161017T110000Z.tar.gz",
+		"20161017T120000Z.tar.gz",
+		"20161017T130000Z.tar.gz",
+	}
+	if actual := snapshotNames(snapshots); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("unexpected snapshots (expected oldest first): %v", actual)
+	}
+
+	// Retention of zero keeps everything
+	if err := pruneEtcdSnapshots(snapshots, 0); err != nil {
+		t.Fatalf("error pruning snapshots: %v", err)
+	}
+	if err := pruneEtcdSnapshots(snapshots, 2); err != nil {
+		t.Fatalf("error pruning snapshots: %v", err)
+	}
+
+	snapshots, err = ListEtcdSnapshots(store, "main")
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+	if actual := snapshotNames(snapshots); !reflect.DeepEqual(actual, expected[2:]) {
+		t.Fatalf("expected the newest snapshots to be kept, got %v", actual)
+	}
+
+	// Other files are never removed
+	for _, name := range []string{EtcdRestoreRequestFile, "not-a-snapshot.tar.gz"} {
+		if _, err := os.Stat(path.Join(dir, "main", name)); err != nil {
+			t.Errorf("expected %q to be kept: %v", name, err)
+		}
+	}
+}
+
+func snapshotNames(snapshots []vfs.Path) []string {
+	var names []string
+	for _, s := range snapshots {
+		names = append(names, s.Base())
+	}
+	return names
+}
+
+func TestEtcdRestoreProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "etcd-restore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "data.restore")
+	progress, err := readEtcdRestoreProgress(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if progress != nil {
+		t.Fatalf("expected no progress, got %v", progress)
+	}
+
+	err = writeEtcdRestoreProgress(p, &etcdRestoreProgress{ID: "abc", Phase: etcdRestorePhaseDataReplaced})
+	if err != nil {
+		t.Fatalf("error writing progress: %v", err)
+	}
+	progress, err = readEtcdRestoreProgress(p)
+	if err != nil {
+		t.Fatalf("error reading progress: %v", err)
+	}
+	if progress == nil || progress.ID != "abc" || progress.Phase != etcdRestorePhaseDataReplaced {
+		t.Fatalf("unexpected progress: %v", progress)
+	}
+}
@@ -25,6 +25,13 @@ type EtcdCluster struct {
 	Spec *EtcdClusterSpec
 
 	VolumeMountPath string
+
+	// InitialClusterState is "new" unless we are joining a cluster that was restored from a snapshot
+	InitialClusterState string
+	// ForceNewCluster is set on the seed node while restoring from a snapshot
+	ForceNewCluster bool
+	// InitialCluster overrides the initial cluster (which is otherwise all the Nodes), when joining a restored cluster
+	InitialCluster string
 }
 
 func (e *EtcdCluster) String() string {
@@ -89,10 +96,54 @@ func (k *EtcdController) RunSyncLoop() {
 }
 
 func (k *EtcdController) syncOnce() error {
-	return k.cluster.configure(k.kubeBoot)
+	err := k.cluster.prepare(k.kubeBoot)
+	if err != nil {
+		return err
+	}
+
+	// We restore before writing the manifest, so that we never start etcd on the data we are about to replace
+	if k.kubeBoot.EtcdBackup != nil {
+		err = k.restoreIfRequested()
+		if err != nil {
+			return fmt.Errorf("error restoring etcd: %v", err)
+		}
+	}
+
+	err = k.cluster.writeManifest()
+	if err != nil {
+		return err
+	}
+
+	if k.kubeBoot.EtcdBackup != nil {
+		err = k.backupIfDue()
+		if err != nil {
+			return fmt.Errorf("error taking etcd snapshot: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// dataDir returns the path of the etcd data directory, on the mounted volume
+func (c *EtcdCluster) dataDir() string {
+	return PathFor(c.VolumeMountPath + "/var/etcd/" + c.DataDirName)
 }
 
+func (c *EtcdCluster) manifestPath() string {
+	return "/etc/kubernetes/manifests/" + c.ClusterName + ".manifest"
+}
+
+// configure builds the cluster membership and writes the etcd manifest
 func (c *EtcdCluster) configure(k *KubeBoot) error {
+	err := c.prepare(k)
+	if err != nil {
+		return err
+	}
+	return c.writeManifest()
+}
+
+// prepare fills in the defaults and builds the cluster membership (including our own DNS record)
+func (c *EtcdCluster) prepare(k *KubeBoot) error {
 	name := c.ClusterName
 	if !strings.HasPrefix(name, "etcd") {
 		// For sanity, and to avoid collisions in directories / dns
@@ -115,6 +166,10 @@ func (c *EtcdCluster) configure(k *KubeBoot) error {
 		c.ClusterToken = "etcd-cluster-token-" + name
 	}
 
+	if c.InitialClusterState == "" {
+		c.InitialClusterState = "new"
+	}
+
 	var nodes []*EtcdNode
 	for _, nodeName := range c.Spec.NodeNames {
 		name := name + "-" + nodeName
@@ -141,6 +196,11 @@ func (c *EtcdCluster) configure(k *KubeBoot) error {
 		return fmt.Errorf("my node name %s not found in cluster %v", c.Spec.NodeName, strings.Join(c.Spec.NodeNames, ","))
 	}
 
+	return nil
+}
+
+// writeManifest writes the etcd manifest, from which kubelet runs etcd
+func (c *EtcdCluster) writeManifest() error {
 	manifestTemplatePath := "templates/etcd/manifest.template"
 	manifestTemplate, err := ioutil.ReadFile(manifestTemplatePath)
 	if err != nil {
@@ -151,7 +211,7 @@ func (c *EtcdCluster) configure(k *KubeBoot) error {
 		return fmt.Errorf("error executing etcd manifest template: %v", err)
 	}
 
-	manifestPath := "/etc/kubernetes/manifests/" + name + ".manifest"
+	manifestPath := c.manifestPath()
 	err = ioutil.WriteFile(PathFor(manifestPath), []byte(manifest), 0644)
 	if err != nil {
 		return fmt.Errorf("error writing etcd manifest %q: %v", manifestPath, err)
@@ -0,0 +1,411 @@
+package protokube
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// EtcdRestoreRequestFile is the name of the file (under <Store>/<ClusterKey>/) that requests a restore
+const EtcdRestoreRequestFile = "restore.json"
+
+// EtcdRestoreRequest asks protokube to rebuild an etcd cluster from a snapshot
+type EtcdRestoreRequest struct {
+	// ID identifies the request, so that each member only applies it once
+	ID string `json:"id"`
+	// Snapshot is the VFS path of the snapshot to restore
+	Snapshot string `json:"snapshot"`
+}
+
+// ReadEtcdRestoreRequest returns the current restore request for the etcd cluster, or nil if there is none
+func ReadEtcdRestoreRequest(store vfs.Path, clusterKey string) (*EtcdRestoreRequest, error) {
+	p := store.Join(clusterKey, EtcdRestoreRequestFile)
+	data, err := p.ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading restore request %s: %v", p, err)
+	}
+	request := &EtcdRestoreRequest{}
+	err = json.Unmarshal(data, request)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing restore request %s: %v", p, err)
+	}
+	return request, nil
+}
+
+// WriteEtcdRestoreRequest records a request to restore the etcd cluster; it is picked up by protokube on the masters
+func WriteEtcdRestoreRequest(store vfs.Path, clusterKey string, request *EtcdRestoreRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error serializing restore request: %v", err)
+	}
+	p := store.Join(clusterKey, EtcdRestoreRequestFile)
+	err = p.WriteFile(data)
+	if err != nil {
+		return fmt.Errorf("error writing restore request %s: %v", p, err)
+	}
+	return nil
+}
+
+const (
+	etcdStopTimeout  = 5 * time.Minute
+	etcdStartTimeout = 10 * time.Minute
+	etcdJoinTimeout  = 20 * time.Minute
+)
+
+// etcdPollInterval is how often we check on etcd while waiting during a restore
+var etcdPollInterval = 10 * time.Second
+
+// etcdRestoreProgress records how far we got with a restore (in a file next to the data directory),
+// so that if protokube restarts part way through, we resume the restore rather than starting it again
+type etcdRestoreProgress struct {
+	// ID is the ID of the restore request
+	ID string `json:"id"`
+	// Phase is the last step we completed
+	Phase string `json:"phase"`
+	// InitialCluster is the initial cluster a joining node starts with: the members that had joined before it, and itself
+	InitialCluster string `json:"initialCluster,omitempty"`
+}
+
+const (
+	// etcdRestorePhaseDataReplaced means the previous data directory was moved aside (and, on the seed, the snapshot extracted)
+	etcdRestorePhaseDataReplaced = "data-replaced"
+	// etcdRestorePhaseSeedStarted means the seed has started etcd from the snapshot (with --force-new-cluster)
+	etcdRestorePhaseSeedStarted = "seed-started"
+	// etcdRestorePhaseMembersAdded means the seed has added every other node as a member, or (on the other nodes)
+	// that the seed has added this node
+	etcdRestorePhaseMembersAdded = "members-added"
+	// etcdRestorePhaseDone means the restore is complete on this node
+	etcdRestorePhaseDone = "done"
+)
+
+// readEtcdRestoreProgress reads the restore progress, returning nil if no restore has been started
+func readEtcdRestoreProgress(p string) (*etcdRestoreProgress, error) {
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading %q: %v", p, err)
+	}
+	progress := &etcdRestoreProgress{}
+	err = json.Unmarshal(data, progress)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %q: %v", p, err)
+	}
+	return progress, nil
+}
+
+func writeEtcdRestoreProgress(p string, progress *etcdRestoreProgress) error {
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return fmt.Errorf("error serializing restore progress: %v", err)
+	}
+	err = ioutil.WriteFile(p, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing %q: %v", p, err)
+	}
+	return nil
+}
+
+// restoreIfRequested rebuilds our member of the etcd cluster, if there is a restore request we have not yet applied.
+//
+// The first node (by name) is the seed: it restores the snapshot into its data directory and starts with
+// --force-new-cluster, and then adds the other nodes as members, one at a time.  The other nodes start with an empty
+// data directory, and join the (existing) cluster once the seed has added them.  The previous data directories are
+// kept on the volumes.  Each step is recorded, so a restore that is interrupted resumes from the last completed step.
+func (k *EtcdController) restoreIfRequested() error {
+	c := k.cluster
+	b := k.kubeBoot.EtcdBackup
+	request, err := ReadEtcdRestoreRequest(b.Store, c.Spec.ClusterKey)
+	if err != nil {
+		return err
+	}
+	if request == nil {
+		return nil
+	}
+
+	dataDir := c.dataDir()
+	progressPath := dataDir + ".restore"
+	progress, err := readEtcdRestoreProgress(progressPath)
+	if err != nil {
+		return err
+	}
+	if progress == nil || progress.ID != request.ID {
+		progress = &etcdRestoreProgress{ID: request.ID}
+	}
+	if progress.Phase == etcdRestorePhaseDone {
+		return nil
+	}
+
+	isSeed := c.seedNode() == c.Me
+
+	if progress.Phase == "" {
+		glog.Infof("Restoring etcd cluster %s from %s (request %s)", c.ClusterName, request.Snapshot, request.ID)
+
+		var snapshot []byte
+		if isSeed {
+			p, err := vfs.Context.BuildVfsPath(request.Snapshot)
+			if err != nil {
+				return fmt.Errorf("error parsing snapshot path %q: %v", request.Snapshot, err)
+			}
+			// Read the snapshot before we stop etcd, so a bad path (or key) doesn't cause an outage
+			data, err := p.ReadFile()
+			if err != nil {
+				return fmt.Errorf("error reading snapshot %s: %v", p, err)
+			}
+			snapshot, err = fi.DecryptData(b.KeyProvider, data)
+			if err != nil {
+				return fmt.Errorf("error decrypting snapshot %s: %v", p, err)
+			}
+		}
+
+		err = c.stop(k.kubeBoot)
+		if err != nil {
+			return err
+		}
+
+		aside := dataDir + ".pre-restore-" + request.ID
+		if _, err := os.Stat(aside); err == nil {
+			// We already moved the original data aside; anything in the data directory is from an interrupted extraction
+			err := os.RemoveAll(dataDir)
+			if err != nil {
+				return fmt.Errorf("error removing partially restored data %q: %v", dataDir, err)
+			}
+		} else if _, err := os.Stat(dataDir); err == nil {
+			glog.Infof("Moving existing etcd data %q to %q", dataDir, aside)
+			err := os.Rename(dataDir, aside)
+			if err != nil {
+				return fmt.Errorf("error moving etcd data directory %q: %v", dataDir, err)
+			}
+		}
+
+		if isSeed {
+			err = untarDirectory(snapshot, dataDir)
+			if err != nil {
+				return fmt.Errorf("error extracting snapshot into %q: %v", dataDir, err)
+			}
+		}
+
+		progress.Phase = etcdRestorePhaseDataReplaced
+		err = writeEtcdRestoreProgress(progressPath, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if progress.Phase == etcdRestorePhaseDataReplaced && isSeed {
+		c.ForceNewCluster = true
+		err = c.configure(k.kubeBoot)
+		if err != nil {
+			return err
+		}
+
+		clientURL := c.clientURL(k.kubeBoot)
+		err = waitFor("etcd to start from snapshot", etcdStartTimeout, func() (bool, error) {
+			return isEtcdHealthy(clientURL), nil
+		})
+		if err != nil {
+			return err
+		}
+
+		progress.Phase = etcdRestorePhaseSeedStarted
+		err = writeEtcdRestoreProgress(progressPath, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if progress.Phase == etcdRestorePhaseDataReplaced || progress.Phase == etcdRestorePhaseSeedStarted {
+		if isSeed {
+			// We must not keep --force-new-cluster, or we would reset the membership every time etcd restarts;
+			// we restart etcd without it before adding any members
+			c.ForceNewCluster = false
+			err = c.configure(k.kubeBoot)
+			if err != nil {
+				return err
+			}
+
+			clientURL := c.clientURL(k.kubeBoot)
+			err = waitFor("etcd to restart", etcdStartTimeout, func() (bool, error) {
+				return isEtcdHealthy(clientURL), nil
+			})
+			if err != nil {
+				return err
+			}
+
+			var peerURLs []string
+			for _, node := range c.Nodes {
+				if node != c.Me {
+					peerURLs = append(peerURLs, c.peerURL(node))
+				}
+			}
+			sort.Strings(peerURLs)
+			err = addEtcdMembersInTurn(clientURL, peerURLs, etcdJoinTimeout)
+			if err != nil {
+				return err
+			}
+		} else {
+			seed := c.seedNode()
+			seedURL := fmt.Sprintf("http://%s:%d", seed.InternalName, c.ClientPort)
+			initialCluster, err := waitForEtcdMembership(seedURL, c.Me.Name, c.peerURL(c.Me), etcdJoinTimeout)
+			if err != nil {
+				return err
+			}
+			progress.InitialCluster = initialCluster
+		}
+
+		progress.Phase = etcdRestorePhaseMembersAdded
+		err = writeEtcdRestoreProgress(progressPath, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !isSeed {
+		c.InitialClusterState = "existing"
+		c.InitialCluster = progress.InitialCluster
+	}
+
+	err = c.configure(k.kubeBoot)
+	if err != nil {
+		return err
+	}
+
+	progress.Phase = etcdRestorePhaseDone
+	err = writeEtcdRestoreProgress(progressPath, progress)
+	if err != nil {
+		return err
+	}
+	glog.Infof("Restored etcd cluster %s member %s", c.ClusterName, c.Me.Name)
+	return nil
+}
+
+// addEtcdMembersInTurn adds the peers to the restored cluster one at a time.  An added member counts towards quorum
+// before it has started, so we wait for each member to join (and the cluster to be healthy) before adding the next.
+// Adding a member that is already in the cluster is not an error, so this can be repeated.
+func addEtcdMembersInTurn(clientURL string, peerURLs []string, timeout time.Duration) error {
+	for _, peerURL := range peerURLs {
+		glog.Infof("Adding etcd member %s", peerURL)
+		err := addEtcdMember(clientURL, peerURL)
+		if err != nil {
+			return err
+		}
+
+		err = waitFor("etcd member "+peerURL+" to join", timeout, func() (bool, error) {
+			members, err := listEtcdMembers(clientURL)
+			if err != nil {
+				glog.V(2).Infof("error listing etcd members: %v", err)
+				return false, nil
+			}
+			m := findEtcdMember(members, peerURL)
+			// A member that has been added but has not yet started has no name
+			return m != nil && m.Name != "" && isEtcdHealthy(clientURL), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForEtcdMembership waits until the seed has added us to the restored cluster, and returns the initial cluster
+// we must start with: the members that have already joined, and ourselves
+func waitForEtcdMembership(seedURL string, myName string, myPeerURL string, timeout time.Duration) (string, error) {
+	var initialCluster string
+	err := waitFor("seed to add "+myName+" to the restored cluster", timeout, func() (bool, error) {
+		members, err := listEtcdMembers(seedURL)
+		if err != nil {
+			glog.V(2).Infof("error listing members from seed: %v", err)
+			return false, nil
+		}
+		if findEtcdMember(members, myPeerURL) == nil {
+			return false, nil
+		}
+
+		var tokens []string
+		for _, m := range members {
+			for _, u := range m.PeerURLs {
+				if u == myPeerURL {
+					tokens = append(tokens, myName+"="+u)
+				} else if m.Name != "" {
+					tokens = append(tokens, m.Name+"="+u)
+				}
+			}
+		}
+		initialCluster = strings.Join(tokens, ",")
+		return true, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return initialCluster, nil
+}
+
+// findEtcdMember returns the member with the peer URL, or nil if there is none
+func findEtcdMember(members []*etcdMember, peerURL string) *etcdMember {
+	for _, m := range members {
+		for _, u := range m.PeerURLs {
+			if u == peerURL {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// seedNode returns the node that restores the snapshot; the other nodes join it
+func (c *EtcdCluster) seedNode() *EtcdNode {
+	nodes := make([]*EtcdNode, len(c.Nodes))
+	copy(nodes, c.Nodes)
+	sort.Sort(byNodeName(nodes))
+	return nodes[0]
+}
+
+type byNodeName []*EtcdNode
+
+func (a byNodeName) Len() int           { return len(a) }
+func (a byNodeName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byNodeName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// stop removes the etcd manifest (so kubelet stops etcd), and waits for etcd to stop responding
+func (c *EtcdCluster) stop(k *KubeBoot) error {
+	manifestPath := PathFor(c.manifestPath())
+	err := os.Remove(manifestPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing etcd manifest %q: %v", manifestPath, err)
+	}
+
+	clientURL := c.clientURL(k)
+	return waitFor("etcd to stop", etcdStopTimeout, func() (bool, error) {
+		return !isEtcdHealthy(clientURL), nil
+	})
+}
+
+// waitFor polls fn until it returns true, or the timeout expires
+func waitFor(description string, timeout time.Duration, fn func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := fn()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for %s", description)
+		}
+		glog.V(2).Infof("Waiting for %s", description)
+		time.Sleep(etcdPollInterval)
+	}
+}
@@ -0,0 +1,170 @@
+package protokube
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEtcdSeed implements enough of the etcd v2 members API to simulate the seed of a restored cluster.
+// Like etcd, it counts a member towards quorum as soon as it is added, even before it has started.
+type fakeEtcdSeed struct {
+	mutex   sync.Mutex
+	members []*etcdMember
+	// quorumLost is set if a member was added while another added member had not yet started:
+	// the new member could not then restore quorum by starting (e.g. 1 of 3 members running)
+	quorumLost bool
+}
+
+func (f *fakeEtcdSeed) hasQuorum() bool {
+	started := 0
+	for _, m := range f.members {
+		if m.Name != "" {
+			started++
+		}
+	}
+	return started > len(f.members)/2
+}
+
+// start simulates the member with the peer URL starting etcd and joining the cluster
+func (f *fakeEtcdSeed) start(name string, peerURL string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	m := findEtcdMember(f.members, peerURL)
+	if m == nil {
+		return fmt.Errorf("member %s started before it was added", peerURL)
+	}
+	m.Name = name
+	return nil
+}
+
+func (f *fakeEtcdSeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	switch {
+	case r.URL.Path == "/health":
+		if !f.hasQuorum() {
+			http.Error(w, "no quorum", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"health": "true"}`))
+
+	case r.URL.Path == "/v2/members" && r.Method == "GET":
+		json.NewEncoder(w).Encode(&etcdMemberList{Members: f.members})
+
+	case r.URL.Path == "/v2/members" && r.Method == "POST":
+		m := &etcdMember{}
+		if err := json.NewDecoder(r.Body).Decode(m); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if findEtcdMember(f.members, m.PeerURLs[0]) != nil {
+			http.Error(w, "member exists", http.StatusConflict)
+			return
+		}
+		for _, existing := range f.members {
+			if existing.Name == "" {
+				f.quorumLost = true
+			}
+		}
+		m.ID = fmt.Sprintf("%x", len(f.members)+1)
+		f.members = append(f.members, m)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(m)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRestoreAddsEtcdMembersInTurn(t *testing.T) {
+	defer func(interval time.Duration) { etcdPollInterval = interval }(etcdPollInterval)
+	etcdPollInterval = 10 * time.Millisecond
+
+	seed := &fakeEtcdSeed{
+		members: []*etcdMember{{ID: "1", Name: "etcd-a", PeerURLs: []string{"http://etcd-a:2380"}}},
+	}
+	server := httptest.NewServer(seed)
+	defer server.Close()
+
+	joiners := []struct {
+		name    string
+		peerURL string
+	}{
+		{"etcd-b", "http://etcd-b:2380"},
+		{"etcd-c", "http://etcd-c:2380"},
+		{"etcd-d", "http://etcd-d:2380"},
+		{"etcd-e", "http://etcd-e:2380"},
+	}
+
+	// The other nodes wait to be added, and then start etcd with the initial cluster they were given
+	initialClusters := make([]string, len(joiners))
+	errors := make(chan error, len(joiners))
+	var wg sync.WaitGroup
+	for i := range joiners {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			j := joiners[i]
+			initialCluster, err := waitForEtcdMembership(server.URL, j.name, j.peerURL, 10*time.Second)
+			if err != nil {
+				errors <- err
+				return
+			}
+			initialClusters[i] = initialCluster
+			// Take a moment to start, as etcd would
+			time.Sleep(20 * time.Millisecond)
+			if err := seed.start(j.name, j.peerURL); err != nil {
+				errors <- err
+			}
+		}(i)
+	}
+
+	var peerURLs []string
+	for _, j := range joiners {
+		peerURLs = append(peerURLs, j.peerURL)
+	}
+	err := addEtcdMembersInTurn(server.URL, peerURLs, 10*time.Second)
+	wg.Wait()
+	close(errors)
+	for err := range errors {
+		t.Errorf("error from joining node: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("error adding members: %v", err)
+	}
+
+	if seed.quorumLost {
+		t.Errorf("quorum was lost while adding members")
+	}
+	if len(seed.members) != 5 || !seed.hasQuorum() {
+		t.Errorf("expected all members to have joined, got %s", DebugString(seed.members))
+	}
+
+	// Each node starts with the members that joined before it, and itself
+	expected := []string{
+		"etcd-a=http://etcd-a:2380,etcd-b=http://etcd-b:2380",
+		"etcd-a=http://etcd-a:2380,etcd-b=http://etcd-b:2380,etcd-c=http://etcd-c:2380",
+		"etcd-a=http://etcd-a:2380,etcd-b=http://etcd-b:2380,etcd-c=http://etcd-c:2380,etcd-d=http://etcd-d:2380",
+		"etcd-a=http://etcd-a:2380,etcd-b=http://etcd-b:2380,etcd-c=http://etcd-c:2380,etcd-d=http://etcd-d:2380,etcd-e=http://etcd-e:2380",
+	}
+	for i, j := range joiners {
+		if initialClusters[i] != expected[i] {
+			t.Errorf("unexpected initial cluster for %s: %q (expected %q)", j.name, initialClusters[i], expected[i])
+		}
+	}
+
+	// Repeating the adds (e.g. after protokube restarts) is harmless
+	if err := addEtcdMembersInTurn(server.URL, peerURLs, time.Second); err != nil {
+		t.Errorf("unexpected error repeating adds: %v", err)
+	}
+	if len(seed.members) != 5 {
+		t.Errorf("expected members not to be added twice, got %s", DebugString(seed.members))
+	}
+}
@@ -0,0 +1,137 @@
+package protokube
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/cloud/compute/metadata"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// GoogleCloudDNSProvider sets records in a Google Cloud DNS managed zone
+type GoogleCloudDNSProvider struct {
+	service *dns.Service
+
+	project  string
+	zoneName string
+	zone     *dns.ManagedZone
+}
+
+var _ DNSProvider = &GoogleCloudDNSProvider{}
+
+// NewGoogleCloudDNSProvider builds a GoogleCloudDNSProvider; if project is not specified, we use the project we are running in
+func NewGoogleCloudDNSProvider(project string, zoneName string) (*GoogleCloudDNSProvider, error) {
+	if zoneName == "" {
+		return nil, fmt.Errorf("zone name is required")
+	}
+
+	if project == "" {
+		p, err := metadata.ProjectID()
+		if err != nil {
+			return nil, fmt.Errorf("error querying GCE metadata service (for project): %v", err)
+		}
+		project = p
+	}
+
+	ctx := context.Background()
+	client, err := google.DefaultClient(ctx, dns.NdevClouddnsReadwriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	service, err := dns.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building DNS API client: %v", err)
+	}
+
+	p := &GoogleCloudDNSProvider{
+		service:  service,
+		project:  project,
+		zoneName: zoneName,
+	}
+	return p, nil
+}
+
+func (p *GoogleCloudDNSProvider) getZone() (*dns.ManagedZone, error) {
+	if p.zone != nil {
+		return p.zone, nil
+	}
+
+	findZone := p.zoneName
+	if !strings.HasSuffix(findZone, ".") {
+		findZone += "."
+	}
+
+	response, err := p.service.ManagedZones.List(p.project).DnsName(findZone).Do()
+	if err != nil {
+		return nil, fmt.Errorf("error querying for DNS ManagedZones %q: %v", findZone, err)
+	}
+
+	var zones []*dns.ManagedZone
+	for _, zone := range response.ManagedZones {
+		if zone.DnsName == findZone {
+			zones = append(zones, zone)
+		}
+	}
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("DNS ManagedZone %q not found in project %q", findZone, p.project)
+	}
+	if len(zones) != 1 {
+		return nil, fmt.Errorf("found multiple managed zones matched name %q", findZone)
+	}
+
+	p.zone = zones[0]
+
+	return p.zone, nil
+}
+
+func (p *GoogleCloudDNSProvider) Set(fqdn string, recordType string, value string, ttl time.Duration) error {
+	zone, err := p.getZone()
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+
+	response, err := p.service.ResourceRecordSets.List(p.project, zone.Name).Name(fqdn).Type(recordType).Do()
+	if err != nil {
+		return fmt.Errorf("error listing DNS ResourceRecordSets: %v", err)
+	}
+
+	rrs := &dns.ResourceRecordSet{
+		Kind:    "dns#resourceRecordSet",
+		Name:    fqdn,
+		Type:    recordType,
+		Ttl:     int64(ttl.Seconds()),
+		Rrdatas: []string{value},
+	}
+
+	change := &dns.Change{
+		Additions: []*dns.ResourceRecordSet{rrs},
+	}
+	for _, existing := range response.Rrsets {
+		if reflect.DeepEqual(rrs, existing) {
+			glog.V(2).Infof("DNS %q %s record already set to %q", fqdn, recordType, value)
+			return nil
+		}
+		glog.Infof("ResourceRecordSet change:")
+		glog.Infof("Existing: %v", DebugString(existing))
+		glog.Infof("Desired:  %v", DebugString(rrs))
+		change.Deletions = append(change.Deletions, existing)
+	}
+
+	glog.V(2).Infof("Updating DNS record %q", fqdn)
+	result, err := p.service.Changes.Create(p.project, zone.Name, change).Do()
+	if err != nil {
+		return fmt.Errorf("error creating ResourceRecordSets: %v", err)
+	}
+
+	glog.V(2).Infof("Change id is %q", result.Id)
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package protokube
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/cloud/compute/metadata"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCE labels only allow lowercase letters, digits, _ and -, so we can't use the same tags as on AWS
+
+// The label we use to differentiate multiple logically independent clusters running in the same project
+const GCELabelClusterName = "k8s-io-cluster-name"
+
+// The label we use for specifying that a disk is in the master role
+const GCELabelRoleMaster = "k8s-io-role-master"
+
+const GCELabelEtcdClusterPrefix = "k8s-io-etcd-"
+
+const GCELabelMasterId = "k8s-io-master-id"
+
+// The prefix of the device path for an attached disk; the suffix is the device name, which we set to the disk name
+const gceDiskByIDPrefix = "/dev/disk/by-id/google-"
+
+type GCEVolumes struct {
+	compute *compute.Service
+
+	project      string
+	zone         string
+	clusterName  string
+	instanceName string
+	internalIP   net.IP
+}
+
+var _ Volumes = &GCEVolumes{}
+
+func NewGCEVolumes() (*GCEVolumes, error) {
+	a := &GCEVolumes{}
+
+	ctx := context.Background()
+	client, err := google.DefaultClient(ctx, compute.ComputeScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	a.compute, err = compute.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("error building compute API client: %v", err)
+	}
+
+	err = a.discoverTags()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+func (a *GCEVolumes) ClusterID() string {
+	return a.clusterName
+}
+
+func (a *GCEVolumes) InternalIP() net.IP {
+	return a.internalIP
+}
+
+func (a *GCEVolumes) discoverTags() error {
+	var err error
+
+	a.project, err = metadata.ProjectID()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for project): %v", err)
+	}
+
+	a.zone, err = metadata.Zone()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for zone): %v", err)
+	}
+
+	a.instanceName, err = metadata.InstanceName()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for instance name): %v", err)
+	}
+
+	clusterName, err := metadata.InstanceAttributeValue("cluster-name")
+	if err != nil {
+		return fmt.Errorf("Cluster name metadata %q not found on this instance (%q): %v", "cluster-name", a.instanceName, err)
+	}
+	a.clusterName = strings.TrimSpace(clusterName)
+	if a.clusterName == "" {
+		return fmt.Errorf("Cluster name metadata %q is empty on this instance (%q)", "cluster-name", a.instanceName)
+	}
+
+	internalIP, err := metadata.InternalIP()
+	if err != nil {
+		return fmt.Errorf("error querying GCE metadata service (for internal IP): %v", err)
+	}
+	a.internalIP = net.ParseIP(internalIP)
+	if a.internalIP == nil {
+		return fmt.Errorf("Internal IP not found on this instance (%q)", a.instanceName)
+	}
+
+	return nil
+}
+
+// buildVolume maps a GCE disk to a Volume, returning nil if the disk is not a master volume for our cluster
+func (a *GCEVolumes) buildVolume(disk *compute.Disk) (*Volume, error) {
+	if disk.Labels[GCELabelClusterName] != gce.EncodeLabelValue(a.clusterName) {
+		return nil, nil
+	}
+	if _, found := disk.Labels[GCELabelRoleMaster]; !found {
+		return nil, nil
+	}
+
+	vol := &Volume{
+		ID: disk.Name,
+		Info: VolumeInfo{
+			Description: disk.Name,
+		},
+		Status: disk.Status,
+	}
+
+	for _, user := range disk.Users {
+		instanceName := gce.LastComponent(user)
+		vol.AttachedTo = instanceName
+		if instanceName == a.instanceName {
+			vol.LocalDevice = gceDiskByIDPrefix + disk.Name
+		}
+	}
+
+	for k, v := range disk.Labels {
+		switch k {
+		case GCELabelClusterName, GCELabelRoleMaster:
+		// Ignore
+		case GCELabelMasterId:
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				glog.Warningf("error parsing master-id label on disk %q %s=%s; skipping disk", disk.Name, k, v)
+				return nil, nil
+			}
+			vol.Info.MasterID = id
+		default:
+			if strings.HasPrefix(k, GCELabelEtcdClusterPrefix) {
+				etcdClusterName := k[len(GCELabelEtcdClusterPrefix):]
+				value, err := gce.DecodeLabelValue(v)
+				if err != nil {
+					glog.Warningf("error decoding etcd cluster label %q on disk %q; skipping disk: %v", v, disk.Name, err)
+					return nil, nil
+				}
+				spec, err := ParseEtcdClusterSpec(etcdClusterName, value)
+				if err != nil {
+					// Fail safe
+					glog.Warningf("error parsing etcd cluster label %q on disk %q; skipping disk: %v", v, disk.Name, err)
+					return nil, nil
+				}
+				vol.Info.EtcdClusters = append(vol.Info.EtcdClusters, spec)
+			} else {
+				glog.Warningf("unknown label on disk %q: %s=%s", disk.Name, k, v)
+			}
+		}
+	}
+
+	return vol, nil
+}
+
+func (a *GCEVolumes) FindVolumes() ([]*Volume, error) {
+	var volumes []*Volume
+
+	ctx := context.Background()
+	// We could use a filter, but filters on labels are not well supported; there shouldn't be many disks in a zone
+	err := a.compute.Disks.List(a.project, a.zone).Pages(ctx, func(page *compute.DiskList) error {
+		for _, disk := range page.Items {
+			vol, err := a.buildVolume(disk)
+			if err != nil {
+				return err
+			}
+			if vol != nil {
+				volumes = append(volumes, vol)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error querying for GCE disks: %v", err)
+	}
+	return volumes, nil
+}
+
+// AttachVolume attaches the specified volume to this instance, setting LocalDevice if successful
+func (a *GCEVolumes) AttachVolume(volume *Volume) error {
+	diskName := volume.ID
+
+	if volume.LocalDevice == "" {
+		if volume.AttachedTo != "" && volume.AttachedTo != a.instanceName {
+			return fmt.Errorf("Unable to attach disk %q, was attached to %q", diskName, volume.AttachedTo)
+		}
+
+		diskURL := fmt.Sprintf("https://www.googleapis.com/compute/v1/projects/%s/zones/%s/disks/%s", a.project, a.zone, diskName)
+		attachedDisk := &compute.AttachedDisk{
+			Source: diskURL,
+			// The device name determines the path under /dev/disk/by-id/
+			DeviceName: diskName,
+			Mode:       "READ_WRITE",
+			Type:       "PERSISTENT",
+		}
+
+		op, err := a.compute.Instances.AttachDisk(a.project, a.zone, a.instanceName, attachedDisk).Do()
+		if err != nil {
+			return fmt.Errorf("Error attaching disk %q: %v", diskName, err)
+		}
+
+		err = a.waitForOp(op)
+		if err != nil {
+			return fmt.Errorf("Error attaching disk %q: %v", diskName, err)
+		}
+	}
+
+	volume.LocalDevice = gceDiskByIDPrefix + diskName
+	return nil
+}
+
+// waitForOp waits (forever) for a zone operation to complete
+func (a *GCEVolumes) waitForOp(op *compute.Operation) error {
+	for {
+		if op.Status == "DONE" {
+			if op.Error != nil && len(op.Error.Errors) != 0 {
+				var messages []string
+				for _, e := range op.Error.Errors {
+					messages = append(messages, e.Message)
+				}
+				return fmt.Errorf("operation failed: %s", strings.Join(messages, "; "))
+			}
+			return nil
+		}
+
+		glog.V(2).Infof("Waiting for operation %q (currently %q)", op.Name, op.Status)
+		time.Sleep(2 * time.Second)
+
+		latest, err := a.compute.ZoneOperations.Get(a.project, a.zone, op.Name).Do()
+		if err != nil {
+			return fmt.Errorf("error querying operation %q: %v", op.Name, err)
+		}
+		op = latest
+	}
+}
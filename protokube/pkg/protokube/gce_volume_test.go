@@ -0,0 +1,76 @@
+package protokube
+
+import (
+	"google.golang.org/api/compute/v1"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"reflect"
+	"testing"
+)
+
+func TestGCEBuildVolume(t *testing.T) {
+	a := &GCEVolumes{
+		clusterName:  "kubernetes.example.com",
+		instanceName: "master-1",
+	}
+
+	disk := &compute.Disk{
+		Name:   "etcd-main-a-kubernetes-example-com",
+		Status: "READY",
+		Users:  []string{"https://www.googleapis.com/compute/v1/projects/p/zones/us-central1-a/instances/master-1"},
+		Labels: map[string]string{
+			GCELabelClusterName:                  gce.EncodeLabelValue("kubernetes.example.com"),
+			GCELabelRoleMaster:                   "1",
+			GCELabelMasterId:                     "2",
+			GCELabelEtcdClusterPrefix + "main":   gce.EncodeLabelValue("a/a,b,c"),
+			GCELabelEtcdClusterPrefix + "events": gce.EncodeLabelValue("a/a,b,c"),
+		},
+	}
+
+	vol, err := a.buildVolume(disk)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vol == nil {
+		t.Fatalf("expected disk to be recognized as a master volume")
+	}
+	if vol.ID != disk.Name || vol.AttachedTo != "master-1" || vol.LocalDevice != gceDiskByIDPrefix+disk.Name || vol.Info.MasterID != 2 {
+		t.Fatalf("unexpected volume: %v", vol)
+	}
+	if len(vol.Info.EtcdClusters) != 2 {
+		t.Fatalf("expected two etcd clusters, got %v", vol.Info.EtcdClusters)
+	}
+	for _, spec := range vol.Info.EtcdClusters {
+		if spec.NodeName != "a" || !reflect.DeepEqual(spec.NodeNames, []string{"a", "b", "c"}) {
+			t.Errorf("unexpected etcd cluster spec: %v", spec)
+		}
+	}
+
+	// Disks belonging to another cluster are ignored, even if the names only differ in punctuation
+	other := *disk
+	other.Labels = map[string]string{
+		GCELabelClusterName: gce.EncodeLabelValue("kubernetes-example.com"),
+		GCELabelRoleMaster:  "1",
+	}
+	vol, err = a.buildVolume(&other)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vol != nil {
+		t.Fatalf("expected disk from another cluster to be ignored, got %v", vol)
+	}
+
+	// Disks with a label we can't decode are skipped
+	invalid := *disk
+	invalid.Labels = map[string]string{
+		GCELabelClusterName:                gce.EncodeLabelValue("kubernetes.example.com"),
+		GCELabelRoleMaster:                 "1",
+		GCELabelEtcdClusterPrefix + "main": "a_2",
+	}
+	vol, err = a.buildVolume(&invalid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if vol != nil {
+		t.Fatalf("expected disk with an invalid label to be skipped, got %v", vol)
+	}
+}
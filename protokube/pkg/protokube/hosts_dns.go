@@ -0,0 +1,206 @@
+package protokube
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const hostsFileBeginMarker = "# Begin host entries managed by kops - do not edit"
+const hostsFileEndMarker = "# End host entries managed by kops"
+
+// We rewrite our records periodically, so that records from machines that have gone away eventually expire
+const hostRecordsRefreshInterval = 5 * time.Minute
+const hostRecordsExpiry = 30 * time.Minute
+
+// hostRecords is the (JSON) format of the records a machine writes to the store
+type hostRecords struct {
+	Updated time.Time         `json:"updated"`
+	Records map[string]string `json:"records"`
+}
+
+// HostsFileDNSProvider writes records into /etc/hosts, so that we don't need any external DNS.
+// Records are shared with the other machines in the cluster through a VFS path (in the state store):
+// each machine writes the records it owns to <store>/<id>, and merges the records from every machine into /etc/hosts.
+type HostsFileDNSProvider struct {
+	store vfs.Path
+	id    string
+
+	mutex       sync.Mutex
+	records     map[string]string
+	lastWritten time.Time
+}
+
+var _ DNSProvider = &HostsFileDNSProvider{}
+
+// NewHostsFileDNSProvider builds a HostsFileDNSProvider; id must be unique to this machine (e.g. the internal IP).
+// If store is nil, only records set on this machine are written.
+func NewHostsFileDNSProvider(store vfs.Path, id string) *HostsFileDNSProvider {
+	return &HostsFileDNSProvider{
+		store:   store,
+		id:      id,
+		records: make(map[string]string),
+	}
+}
+
+func (p *HostsFileDNSProvider) Set(fqdn string, recordType string, value string, ttl time.Duration) error {
+	if recordType != "A" {
+		return fmt.Errorf("hosts file only supports A records, not %q", recordType)
+	}
+	fqdn = strings.TrimSuffix(fqdn, ".")
+
+	p.mutex.Lock()
+	changed := p.records[fqdn] != value
+	p.records[fqdn] = value
+	records := &hostRecords{
+		Updated: time.Now().UTC(),
+		Records: make(map[string]string),
+	}
+	for k, v := range p.records {
+		records.Records[k] = v
+	}
+	write := changed || time.Since(p.lastWritten) > hostRecordsRefreshInterval
+	p.mutex.Unlock()
+
+	if write && p.store != nil {
+		data, err := json.Marshal(records)
+		if err != nil {
+			return fmt.Errorf("error serializing host records: %v", err)
+		}
+		path := p.store.Join(p.id)
+		err = path.WriteFile(data)
+		if err != nil {
+			return fmt.Errorf("error writing host records to %s: %v", path, err)
+		}
+
+		p.mutex.Lock()
+		p.lastWritten = records.Updated
+		p.mutex.Unlock()
+	}
+
+	return p.sync()
+}
+
+// RunSyncLoop periodically merges the records from the other machines into /etc/hosts
+func (p *HostsFileDNSProvider) RunSyncLoop() {
+	for {
+		err := p.sync()
+		if err != nil {
+			glog.Warningf("error updating hosts file (will sleep and retry): %v", err)
+		}
+
+		time.Sleep(1 * time.Minute)
+	}
+}
+
+func (p *HostsFileDNSProvider) sync() error {
+	// name -> addresses
+	hosts := make(map[string][]string)
+
+	p.mutex.Lock()
+	for name, address := range p.records {
+		hosts[name] = append(hosts[name], address)
+	}
+	p.mutex.Unlock()
+
+	if p.store != nil {
+		files, err := p.store.ReadDir()
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error listing host records in %s: %v", p.store, err)
+		}
+		for _, f := range files {
+			if f.Base() == p.id {
+				continue
+			}
+			data, err := f.ReadFile()
+			if err != nil {
+				return fmt.Errorf("error reading host records %s: %v", f, err)
+			}
+			records := &hostRecords{}
+			err = json.Unmarshal(data, records)
+			if err != nil {
+				glog.Warningf("ignoring invalid host records %s: %v", f, err)
+				continue
+			}
+			if time.Since(records.Updated) > hostRecordsExpiry {
+				glog.V(2).Infof("ignoring expired host records %s (updated %s)", f, records.Updated)
+				continue
+			}
+			for name, address := range records.Records {
+				hosts[name] = append(hosts[name], address)
+			}
+		}
+	}
+
+	return updateHostsFile(PathFor("/etc/hosts"), hosts)
+}
+
+// updateHostsFile replaces the block of entries we manage in the hosts file, leaving any other entries alone
+func updateHostsFile(hostsPath string, hosts map[string][]string) error {
+	existing, err := ioutil.ReadFile(hostsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading hosts file %q: %v", hostsPath, err)
+	}
+
+	var lines []string
+	inBlock := false
+	for _, line := range strings.Split(string(existing), "\n") {
+		switch strings.TrimSpace(line) {
+		case hostsFileBeginMarker:
+			inBlock = true
+			continue
+		case hostsFileEndMarker:
+			inBlock = false
+			continue
+		}
+		if !inBlock {
+			lines = append(lines, line)
+		}
+	}
+
+	var b bytes.Buffer
+	// Avoid accumulating blank lines at the end of the file
+	b.WriteString(strings.TrimRight(strings.Join(lines, "\n"), "\n"))
+	b.WriteString("\n\n")
+
+	var names []string
+	for name := range hosts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.WriteString(hostsFileBeginMarker + "\n")
+	for _, name := range names {
+		addresses := hosts[name]
+		sort.Strings(addresses)
+		last := ""
+		for _, address := range addresses {
+			if address == last {
+				continue
+			}
+			last = address
+			b.WriteString(address + "\t" + name + "\n")
+		}
+	}
+	b.WriteString(hostsFileEndMarker + "\n")
+
+	if bytes.Equal(existing, b.Bytes()) {
+		return nil
+	}
+
+	// We write in place, rather than renaming, because /etc/hosts is often bind-mounted into containers
+	glog.V(2).Infof("Updating hosts file %q", hostsPath)
+	err = ioutil.WriteFile(hostsPath, b.Bytes(), 0644)
+	if err != nil {
+		return fmt.Errorf("error writing hosts file %q: %v", hostsPath, err)
+	}
+	return nil
+}
@@ -17,8 +17,13 @@ type KubeBoot struct {
 	etcdControllers map[string]*EtcdController
 
 	DNS DNSProvider
+	// MasterInternalName is published (as our internal IP) by masters, if set; used where cloudup doesn't manage the API DNS name
+	MasterInternalName string
 
 	ModelDir string
+
+	// EtcdBackup configures snapshots of etcd; snapshots & restores are disabled if nil
+	EtcdBackup *EtcdBackupConfig
 }
 
 func (k *KubeBoot) Init(volumesProvider Volumes) {
@@ -53,6 +58,13 @@ func (k *KubeBoot) RunSyncLoop() {
 
 func (k *KubeBoot) syncOnce() error {
 	if k.Master {
+		if k.MasterInternalName != "" {
+			err := k.CreateInternalDNSNameRecord(k.MasterInternalName)
+			if err != nil {
+				return err
+			}
+		}
+
 		volumes, err := k.volumeMounter.mountMasterVolumes()
 		if err != nil {
 			return err
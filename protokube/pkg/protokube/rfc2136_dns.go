@@ -0,0 +1,102 @@
+package protokube
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"github.com/miekg/dns"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+// RFC2136DNSProvider sets records using RFC 2136 dynamic updates, e.g. against an on-premises BIND server
+type RFC2136DNSProvider struct {
+	server   string
+	zoneName string
+
+	// TSIG is optional, but strongly recommended
+	tsigKeyName   string
+	tsigSecret    string
+	tsigAlgorithm string
+}
+
+var _ DNSProvider = &RFC2136DNSProvider{}
+
+// NewRFC2136DNSProvider builds an RFC2136DNSProvider.
+// tsigSecretFile holds the (base64) TSIG secret; it is read from a file so the secret doesn't appear in the flags.
+func NewRFC2136DNSProvider(server string, zoneName string, tsigKeyName string, tsigSecretFile string, tsigAlgorithm string) (*RFC2136DNSProvider, error) {
+	if server == "" {
+		return nil, fmt.Errorf("DNS server is required")
+	}
+	if zoneName == "" {
+		return nil, fmt.Errorf("zone name is required")
+	}
+
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	p := &RFC2136DNSProvider{
+		server:   server,
+		zoneName: dns.Fqdn(zoneName),
+	}
+
+	if tsigKeyName != "" {
+		if tsigSecretFile == "" {
+			return nil, fmt.Errorf("TSIG secret file is required with TSIG key name")
+		}
+		data, err := ioutil.ReadFile(PathFor(tsigSecretFile))
+		if err != nil {
+			return nil, fmt.Errorf("error reading TSIG secret file %q: %v", tsigSecretFile, err)
+		}
+		p.tsigKeyName = dns.Fqdn(strings.ToLower(tsigKeyName))
+		p.tsigSecret = strings.TrimSpace(string(data))
+
+		switch strings.ToLower(strings.TrimSuffix(tsigAlgorithm, ".")) {
+		case "", "hmac-sha256":
+			p.tsigAlgorithm = dns.HmacSHA256
+		case "hmac-sha512":
+			p.tsigAlgorithm = dns.HmacSHA512
+		case "hmac-sha1":
+			p.tsigAlgorithm = dns.HmacSHA1
+		case "hmac-md5", "hmac-md5.sig-alg.reg.int":
+			p.tsigAlgorithm = dns.HmacMD5
+		default:
+			return nil, fmt.Errorf("unsupported TSIG algorithm %q", tsigAlgorithm)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *RFC2136DNSProvider) Set(fqdn string, recordType string, value string, ttl time.Duration) error {
+	rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dns.Fqdn(fqdn), int64(ttl.Seconds()), recordType, value))
+	if err != nil {
+		return fmt.Errorf("error building DNS record for %q: %v", fqdn, err)
+	}
+
+	// Replace any existing records of the same type
+	m := new(dns.Msg)
+	m.SetUpdate(p.zoneName)
+	m.RemoveRRset([]dns.RR{rr})
+	m.Insert([]dns.RR{rr})
+
+	c := new(dns.Client)
+	c.Net = "tcp"
+	if p.tsigKeyName != "" {
+		c.TsigSecret = map[string]string{p.tsigKeyName: p.tsigSecret}
+		m.SetTsig(p.tsigKeyName, p.tsigAlgorithm, 300, time.Now().Unix())
+	}
+
+	glog.V(2).Infof("Updating DNS record %q via %s", fqdn, p.server)
+	response, _, err := c.Exchange(m, p.server)
+	if err != nil {
+		return fmt.Errorf("error sending DNS update for %q to %s: %v", fqdn, p.server, err)
+	}
+	if response.Rcode != dns.RcodeSuccess {
+		return fmt.Errorf("DNS update for %q was rejected by %s: %s", fqdn, p.server, dns.RcodeToString[response.Rcode])
+	}
+
+	return nil
+}
@@ -59,12 +59,16 @@ type ClusterSpec struct {
 	KeyStore string `json:"keyStore,omitempty"`
 	// ConfigStore is the VFS path to where the configuration (CloudConfig, NodeSetConfig etc) is stored
 	ConfigStore string `json:"configStore,omitempty"`
+	// SecretsEncryption configures how the nodes decrypt the (encrypted) SecretStore and KeyStore
+	SecretsEncryption *SecretsEncryptionSpec `json:"secretsEncryption,omitempty"`
 
 	// DNSZone is the DNS zone we should use when configuring DNS
 	// This is because some clouds let us define a managed zone foo.bar, and then have
 	// kubernetes.dev.foo.bar, without needing to define dev.foo.bar as a hosted zone.
 	// DNSZone will probably be a suffix of the MasterPublicName and MasterInternalName
 	DNSZone string `json:"dnsZone,omitempty"`
+	// DNS configures how protokube publishes the internal DNS names (for etcd, and for the API with some providers)
+	DNS *DNSSpec `json:"dns,omitempty"`
 
 	// ClusterDNSDomain is the suffix we use for internal DNS names (normally cluster.local)
 	ClusterDNSDomain string `json:"clusterDNSDomain,omitempty"`
@@ -184,6 +188,8 @@ type ClusterSpec struct {
 
 	// EtcdClusters stores the configuration for each cluster
 	EtcdClusters []*EtcdClusterSpec `json:"etcdClusters,omitempty"`
+	// EtcdBackup configures the snapshots of etcd that are taken on the masters
+	EtcdBackup *EtcdBackupSpec `json:"etcdBackup,omitempty"`
 
 	// Component configurations
 	Docker                *DockerConfig                `json:"docker,omitempty"`
@@ -196,6 +202,12 @@ type ClusterSpec struct {
 	MasterKubelet         *KubeletConfig               `json:"masterKubelet,omitempty"`
 }
 
+type SecretsEncryptionSpec struct {
+	// KeyFile is the path on each node to the file holding the encryption key.
+	// The key is deliberately not stored in the state store; it must be installed on the nodes separately.
+	KeyFile string `json:"keyFile,omitempty"`
+}
+
 type KubeDNSConfig struct {
 	Replicas int    `json:"replicas,omitempty"`
 	Domain   string `json:"domain,omitempty"`
@@ -220,6 +232,32 @@ type EtcdClusterSpec struct {
 	Members []*EtcdMemberSpec `json:"etcdMembers,omitempty"`
 }
 
+type DNSSpec struct {
+	// Provider is route53, google-clouddns, rfc2136 or hosts; it defaults to the DNS service of the cloud.
+	// With hosts, no DNS server is needed: names are written to /etc/hosts on every machine.
+	Provider string `json:"provider,omitempty"`
+	// Server is the DNS server (host:port) that dynamic updates are sent to, for rfc2136
+	Server string `json:"server,omitempty"`
+	// TSIGKeyName is the name of the key used to sign dynamic updates, for rfc2136
+	TSIGKeyName string `json:"tsigKeyName,omitempty"`
+	// TSIGSecretFile is the path on each master to the file holding the TSIG secret.
+	// The secret is deliberately not stored in the state store; it must be installed on the masters separately.
+	TSIGSecretFile string `json:"tsigSecretFile,omitempty"`
+	// TSIGAlgorithm is the TSIG algorithm (default hmac-sha256)
+	TSIGAlgorithm string `json:"tsigAlgorithm,omitempty"`
+	// HostsStore is the VFS path where machines share their host records, for hosts; it defaults to dns/hosts in the state store
+	HostsStore string `json:"hostsStore,omitempty"`
+}
+
+type EtcdBackupSpec struct {
+	// Store is the VFS path where snapshots are stored; it defaults to backups/etcd in the state store
+	Store string `json:"store,omitempty"`
+	// Interval is how often a snapshot is taken (e.g. 1h)
+	Interval string `json:"interval,omitempty"`
+	// Retention is the number of snapshots to keep for each etcd cluster
+	Retention int `json:"retention,omitempty"`
+}
+
 type EtcdMemberSpec struct {
 	// Name is the name of the member within the etcd cluster
 	Name string `json:"name,omitempty"`
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/fi"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Path in the state store under which we keep the history of the configuration
+const PathHistory = "history"
+
+// ConfigRevision is a snapshot of the cluster configuration, taken every time it is written
+type ConfigRevision struct {
+	Revision  int       `json:"revision"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Cluster        *Cluster         `json:"cluster"`
+	InstanceGroups []*InstanceGroup `json:"instanceGroups,omitempty"`
+}
+
+func revisionKey(revision int) string {
+	// Zero-pad so that the revisions sort naturally when listed
+	return fmt.Sprintf("%s/%06d", PathHistory, revision)
+}
+
+// ListRevisions returns the numbers of all the saved revisions, in ascending order
+func ListRevisions(stateStore fi.StateStore) ([]int, error) {
+	keys, err := stateStore.ListChildren(PathHistory)
+	if err != nil {
+		return nil, fmt.Errorf("error listing configuration history: %v", err)
+	}
+
+	var revisions []int
+	for _, key := range keys {
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			// Ignore unknown files
+			continue
+		}
+		revisions = append(revisions, n)
+	}
+	sort.Ints(revisions)
+	return revisions, nil
+}
+
+// ReadRevision reads a saved revision of the configuration
+func ReadRevision(stateStore fi.StateStore, revision int) (*ConfigRevision, error) {
+	r := &ConfigRevision{}
+	err := stateStore.ReadConfig(revisionKey(revision), r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading configuration revision %d: %v", revision, err)
+	}
+	if r.Cluster == nil {
+		return nil, fmt.Errorf("configuration revision %d not found", revision)
+	}
+	return r, nil
+}
+
+// RecordRevision saves the current configuration as a new revision.
+// It is called whenever the configuration is written.
+func RecordRevision(stateStore fi.StateStore) (*ConfigRevision, error) {
+	cluster, groups, err := ReadConfig(stateStore)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := ListRevisions(stateStore)
+	if err != nil {
+		return nil, err
+	}
+	next := 1
+	if len(revisions) != 0 {
+		next = revisions[len(revisions)-1] + 1
+	}
+
+	r := &ConfigRevision{
+		Revision:       next,
+		Timestamp:      time.Now().UTC(),
+		Cluster:        cluster,
+		InstanceGroups: groups,
+	}
+	err = stateStore.WriteConfig(revisionKey(next), r)
+	if err != nil {
+		return nil, fmt.Errorf("error writing configuration revision: %v", err)
+	}
+	return r, nil
+}
+
+// EnsureHistory records the current configuration as the first revision, if there is no history yet
+// (e.g. for clusters created before we kept history), so that the first change can be rolled back.
+func EnsureHistory(stateStore fi.StateStore) error {
+	revisions, err := ListRevisions(stateStore)
+	if err != nil {
+		return err
+	}
+	if len(revisions) != 0 {
+		return nil
+	}
+
+	cluster := &Cluster{}
+	err = stateStore.ReadConfig("config", cluster)
+	if err != nil {
+		return fmt.Errorf("error reading cluster configuration: %v", err)
+	}
+	if cluster.Name == "" && cluster.CreationTimestamp.IsZero() {
+		// No configuration yet
+		return nil
+	}
+
+	_, err = RecordRevision(stateStore)
+	return err
+}
+
+// RollbackConfig restores the configuration from the specified revision.
+// The rollback is itself recorded as a new revision, so it can be undone.
+func RollbackConfig(stateStore fi.StateStore, revision int) error {
+	r, err := ReadRevision(stateStore, revision)
+	if err != nil {
+		return err
+	}
+
+	// Instance groups that were created after the revision are removed
+	err = replaceConfig(stateStore, r.Cluster, r.InstanceGroups)
+	if err != nil {
+		return err
+	}
+
+	_, err = RecordRevision(stateStore)
+	return err
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func newTestStateStore(t *testing.T) (fi.StateStore, func()) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	stateStore, err := fi.NewVFSStateStore(vfs.NewFSPath(dir), "kubernetes.example.com", false, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("error building state store: %v", err)
+	}
+	return stateStore, func() { os.RemoveAll(dir) }
+}
+
+func listRevisions(t *testing.T, stateStore fi.StateStore) []int {
+	revisions, err := ListRevisions(stateStore)
+	if err != nil {
+		t.Fatalf("error listing revisions: %v", err)
+	}
+	return revisions
+}
+
+func groupNames(groups []*InstanceGroup) []string {
+	var names []string
+	for _, g := range groups {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+func TestEnsureHistory(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	// No configuration, so nothing to record
+	if err := EnsureHistory(stateStore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revisions := listRevisions(t, stateStore); len(revisions) != 0 {
+		t.Fatalf("expected no revisions, got %v", revisions)
+	}
+
+	// A cluster written before we kept history
+	cluster := &Cluster{}
+	cluster.Name = "kubernetes.example.com"
+	nodes := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleNode}}
+	nodes.Name = "nodes"
+	if err := writeConfig(stateStore, cluster, []*InstanceGroup{nodes}); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	if err := EnsureHistory(stateStore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revisions := listRevisions(t, stateStore); !reflect.DeepEqual(revisions, []int{1}) {
+		t.Fatalf("expected the existing configuration to be recorded, got %v", revisions)
+	}
+
+	// Once there is history, EnsureHistory does nothing
+	if err := EnsureHistory(stateStore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if revisions := listRevisions(t, stateStore); !reflect.DeepEqual(revisions, []int{1}) {
+		t.Fatalf("expected a single revision, got %v", revisions)
+	}
+
+	r, err := ReadRevision(stateStore, 1)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+	if r.Revision != 1 || r.Cluster.Name != cluster.Name || !reflect.DeepEqual(groupNames(r.InstanceGroups), []string{"nodes"}) {
+		t.Fatalf("unexpected revision: %v", fi.DebugAsJsonString(r))
+	}
+}
+
+func TestRecordRevision(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	cluster := &Cluster{}
+	cluster.Name = "kubernetes.example.com"
+	cluster.Spec.KubernetesVersion = "1.2.0"
+	if err := WriteConfig(stateStore, cluster, nil); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	cluster.Spec.KubernetesVersion = "1.3.0"
+	if err := WriteConfig(stateStore, cluster, nil); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	if revisions := listRevisions(t, stateStore); !reflect.DeepEqual(revisions, []int{1, 2}) {
+		t.Fatalf("unexpected revisions: %v", revisions)
+	}
+
+	for revision, version := range map[int]string{1: "1.2.0", 2: "1.3.0"} {
+		r, err := ReadRevision(stateStore, revision)
+		if err != nil {
+			t.Fatalf("error reading revision %d: %v", revision, err)
+		}
+		if r.Cluster.Spec.KubernetesVersion != version {
+			t.Errorf("revision %d has version %q, expected %q", revision, r.Cluster.Spec.KubernetesVersion, version)
+		}
+	}
+
+	if _, err := ReadRevision(stateStore, 3); err == nil {
+		t.Fatalf("expected error reading a revision that does not exist")
+	}
+}
+
+func TestRollbackConfig(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	cluster := &Cluster{}
+	cluster.Name = "kubernetes.example.com"
+	cluster.Spec.KubernetesVersion = "1.2.0"
+	nodes := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleNode}}
+	nodes.Name = "nodes"
+	if err := WriteConfig(stateStore, cluster, []*InstanceGroup{nodes}); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	cluster.Spec.KubernetesVersion = "1.3.0"
+	extra := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleNode}}
+	extra.Name = "extra"
+	if err := WriteConfig(stateStore, cluster, []*InstanceGroup{nodes, extra}); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	if err := RollbackConfig(stateStore, 1); err != nil {
+		t.Fatalf("error rolling back: %v", err)
+	}
+
+	actual, groups, err := ReadConfig(stateStore)
+	if err != nil {
+		t.Fatalf("error reading configuration: %v", err)
+	}
+	if actual.Spec.KubernetesVersion != "1.2.0" {
+		t.Errorf("expected version to be rolled back, got %q", actual.Spec.KubernetesVersion)
+	}
+	if names := groupNames(groups); !reflect.DeepEqual(names, []string{"nodes"}) {
+		t.Errorf("expected instance groups created after the revision to be removed, got %v", names)
+	}
+
+	// The rollback is itself recorded, so it can be undone
+	if revisions := listRevisions(t, stateStore); !reflect.DeepEqual(revisions, []int{1, 2, 3}) {
+		t.Fatalf("unexpected revisions: %v", revisions)
+	}
+	r, err := ReadRevision(stateStore, 3)
+	if err != nil {
+		t.Fatalf("error reading revision: %v", err)
+	}
+	if r.Cluster.Spec.KubernetesVersion != "1.2.0" {
+		t.Errorf("expected rollback to be recorded, got version %q", r.Cluster.Spec.KubernetesVersion)
+	}
+
+	if err := RollbackConfig(stateStore, 99); err == nil {
+		t.Fatalf("expected error rolling back to a revision that does not exist")
+	}
+}
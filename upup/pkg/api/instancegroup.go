@@ -5,6 +5,7 @@ import (
 	"github.com/golang/glog"
 	k8sapi "k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/api/unversioned"
+	"strings"
 )
 
 // InstanceGroup represents a group of instances (either nodes or masters) with the same configuration
@@ -77,3 +78,55 @@ func (g *InstanceGroup) IsMaster() bool {
 		return false
 	}
 }
+
+// Validate checks that the InstanceGroup is well-formed
+func (g *InstanceGroup) Validate() error {
+	if g.Name == "" {
+		return fmt.Errorf("InstanceGroup did not have a Name")
+	}
+	if strings.Contains(g.Name, "/") {
+		return fmt.Errorf("InstanceGroup Name %q must not contain '/'", g.Name)
+	}
+
+	switch g.Spec.Role {
+	case InstanceGroupRoleMaster, InstanceGroupRoleNode:
+		// Valid
+	case "":
+		return fmt.Errorf("InstanceGroup %q Role not set", g.Name)
+	default:
+		return fmt.Errorf("InstanceGroup %q has unknown Role %q (must be %s or %s)", g.Name, g.Spec.Role, InstanceGroupRoleMaster, InstanceGroupRoleNode)
+	}
+
+	if g.Spec.MinSize != nil && *g.Spec.MinSize < 0 {
+		return fmt.Errorf("InstanceGroup %q MinSize must not be negative", g.Name)
+	}
+	if g.Spec.MinSize != nil && g.Spec.MaxSize != nil && *g.Spec.MinSize > *g.Spec.MaxSize {
+		return fmt.Errorf("InstanceGroup %q MinSize (%d) must not be greater than MaxSize (%d)", g.Name, *g.Spec.MinSize, *g.Spec.MaxSize)
+	}
+
+	if g.Spec.Role == InstanceGroupRoleMaster && len(g.Spec.Zones) == 0 {
+		return fmt.Errorf("Master InstanceGroup %q did not specify any Zones", g.Name)
+	}
+
+	return nil
+}
+
+// CrossValidate checks that the InstanceGroup is consistent with the Cluster
+func (g *InstanceGroup) CrossValidate(cluster *Cluster) error {
+	err := g.Validate()
+	if err != nil {
+		return err
+	}
+
+	clusterZones := make(map[string]bool)
+	for _, z := range cluster.Spec.Zones {
+		clusterZones[z.Name] = true
+	}
+	for _, z := range g.Spec.Zones {
+		if !clusterZones[z] {
+			return fmt.Errorf("InstanceGroup %q is configured in %q, but this is not configured as a Zone in the cluster", g.Name, z)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestInstanceGroupCrossValidate(t *testing.T) {
+	cluster := &Cluster{}
+	cluster.Spec.Zones = []*ClusterZoneSpec{{Name: "us-east-1a"}, {Name: "us-east-1b"}}
+
+	two := 2
+	three := 3
+
+	grid := []struct {
+		name  string
+		spec  InstanceGroupSpec
+		valid bool
+	}{
+		{"", InstanceGroupSpec{Role: InstanceGroupRoleNode}, false},
+		{"group", InstanceGroupSpec{Role: "Bastion"}, false},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleNode, Zones: []string{"us-east-1a"}}, true},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleNode, Zones: []string{"us-east-1c"}}, false},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleNode, MinSize: &three, MaxSize: &two}, false},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleNode, MinSize: &two, MaxSize: &three}, true},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleMaster}, false},
+		{"group", InstanceGroupSpec{Role: InstanceGroupRoleMaster, Zones: []string{"us-east-1b"}}, true},
+	}
+	for _, g := range grid {
+		group := &InstanceGroup{Spec: g.spec}
+		group.Name = g.name
+		err := group.CrossValidate(cluster)
+		if g.valid && err != nil {
+			t.Errorf("unexpected error for %q %v: %v", g.name, g.spec, err)
+		}
+		if !g.valid && err == nil {
+			t.Errorf("expected error for %q %v", g.name, g.spec)
+		}
+	}
+}
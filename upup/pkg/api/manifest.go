@@ -0,0 +1,143 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"sort"
+	"strings"
+)
+
+// APIVersion is the apiVersion we write in manifests
+const APIVersion = "kops/v1alpha1"
+
+const (
+	KindCluster       = "Cluster"
+	KindInstanceGroup = "InstanceGroup"
+)
+
+// ParseManifest parses a multi-document YAML manifest, holding a Cluster and its InstanceGroups
+func ParseManifest(data []byte) (*Cluster, []*InstanceGroup, error) {
+	var cluster *Cluster
+	var groups []*InstanceGroup
+
+	for i, doc := range splitYamlDocuments(data) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		typeMeta := &unversioned.TypeMeta{}
+		err := utils.YamlUnmarshal(doc, typeMeta)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error parsing document #%d of manifest: %v", i+1, err)
+		}
+
+		switch typeMeta.Kind {
+		case KindCluster:
+			if cluster != nil {
+				return nil, nil, fmt.Errorf("manifest contains more than one Cluster")
+			}
+			cluster = &Cluster{}
+			err = utils.YamlUnmarshal(doc, cluster)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error parsing Cluster in manifest: %v", err)
+			}
+
+		case KindInstanceGroup:
+			group := &InstanceGroup{}
+			err = utils.YamlUnmarshal(doc, group)
+			if err != nil {
+				return nil, nil, fmt.Errorf("error parsing InstanceGroup in manifest: %v", err)
+			}
+			groups = append(groups, group)
+
+		case "":
+			return nil, nil, fmt.Errorf("document #%d of manifest did not specify a kind", i+1)
+
+		default:
+			return nil, nil, fmt.Errorf("document #%d of manifest has unknown kind %q", i+1, typeMeta.Kind)
+		}
+	}
+
+	if cluster == nil {
+		return nil, nil, fmt.Errorf("manifest did not contain a Cluster")
+	}
+
+	// The state store holds the objects without the type information, as ReadConfig returns them
+	cluster.TypeMeta = unversioned.TypeMeta{}
+	for _, g := range groups {
+		g.TypeMeta = unversioned.TypeMeta{}
+	}
+
+	names := make(map[string]bool)
+	for _, g := range groups {
+		if g.Name == "" {
+			return nil, nil, fmt.Errorf("InstanceGroup in manifest did not have a Name")
+		}
+		if names[g.Name] {
+			return nil, nil, fmt.Errorf("manifest contains InstanceGroup %q more than once", g.Name)
+		}
+		names[g.Name] = true
+	}
+
+	return cluster, groups, nil
+}
+
+// WriteManifest serializes the Cluster and its InstanceGroups (sorted by name) as a multi-document YAML manifest,
+// in the format read by ParseManifest
+func WriteManifest(cluster *Cluster, groups []*InstanceGroup) ([]byte, error) {
+	var objects []interface{}
+
+	c := &Cluster{}
+	*c = *cluster
+	c.APIVersion = APIVersion
+	c.Kind = KindCluster
+	objects = append(objects, c)
+
+	sorted := make([]*InstanceGroup, len(groups))
+	copy(sorted, groups)
+	sort.Sort(instanceGroupsByName(sorted))
+	for _, group := range sorted {
+		g := &InstanceGroup{}
+		*g = *group
+		g.APIVersion = APIVersion
+		g.Kind = KindInstanceGroup
+		objects = append(objects, g)
+	}
+
+	var b bytes.Buffer
+	for i, o := range objects {
+		if i != 0 {
+			b.WriteString("\n---\n\n")
+		}
+		data, err := utils.YamlMarshal(o)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing manifest: %v", err)
+		}
+		b.Write(data)
+	}
+	return b.Bytes(), nil
+}
+
+// splitYamlDocuments splits a YAML stream on the --- document separators
+func splitYamlDocuments(data []byte) [][]byte {
+	var docs [][]byte
+	var current bytes.Buffer
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.TrimRight(line, " \t\r\n") == "---" {
+			docs = append(docs, current.Bytes())
+			current = bytes.Buffer{}
+			continue
+		}
+		current.WriteString(line)
+	}
+	docs = append(docs, current.Bytes())
+	return docs
+}
+
+type instanceGroupsByName []*InstanceGroup
+
+func (a instanceGroupsByName) Len() int           { return len(a) }
+func (a instanceGroupsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a instanceGroupsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestRoundTrip(t *testing.T) {
+	one := 1
+
+	cluster := &Cluster{}
+	cluster.Name = "kubernetes.example.com"
+	cluster.Spec.CloudProvider = "aws"
+	cluster.Spec.Zones = []*ClusterZoneSpec{{Name: "us-east-1a", CIDR: "172.20.32.0/19"}}
+
+	nodes := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleNode, MinSize: &one, Zones: []string{"us-east-1a"}}}
+	nodes.Name = "nodes"
+	master := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleMaster, Zones: []string{"us-east-1a"}}}
+	master.Name = "master-us-east-1a"
+
+	data, err := WriteManifest(cluster, []*InstanceGroup{nodes, master})
+	if err != nil {
+		t.Fatalf("error writing manifest: %v", err)
+	}
+	if !strings.Contains(string(data), "kind: Cluster") || !strings.Contains(string(data), "apiVersion: "+APIVersion) {
+		t.Errorf("manifest does not contain type information:\n%s", data)
+	}
+
+	parsedCluster, parsedGroups, err := ParseManifest(data)
+	if err != nil {
+		t.Fatalf("error parsing manifest: %v\n%s", err, data)
+	}
+	if !reflect.DeepEqual(parsedCluster, cluster) {
+		t.Errorf("cluster did not round-trip: %v", parsedCluster)
+	}
+	// Groups are written in order of name
+	if !reflect.DeepEqual(parsedGroups, []*InstanceGroup{master, nodes}) {
+		t.Errorf("instance groups did not round-trip:\n%s", data)
+	}
+
+	again, err := WriteManifest(parsedCluster, parsedGroups)
+	if err != nil {
+		t.Fatalf("error writing manifest: %v", err)
+	}
+	if string(again) != string(data) {
+		t.Errorf("manifest was not stable:\n%s\n\n%s", data, again)
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	grid := []string{
+		"",
+		"kind: InstanceGroup\nmetadata:\n  name: nodes\n",
+		"kind: Cluster\n---\nkind: Cluster\n",
+		"kind: Cluster\n---\nkind: Pod\n",
+		"kind: Cluster\n---\nmetadata:\n  name: nodes\n",
+		"kind: Cluster\n---\nkind: InstanceGroup\nmetadata:\n  name: nodes\n---\nkind: InstanceGroup\nmetadata:\n  name: nodes\n",
+	}
+	for _, g := range grid {
+		_, _, err := ParseManifest([]byte(g))
+		if err == nil {
+			t.Errorf("expected error parsing manifest %q", g)
+		}
+	}
+}
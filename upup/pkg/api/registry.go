@@ -9,7 +9,26 @@ import (
 	"time"
 )
 
+// WriteConfig writes the cluster configuration and instance groups, and records the result as a new revision in the history
 func WriteConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceGroup) error {
+	err := EnsureHistory(stateStore)
+	if err != nil {
+		return err
+	}
+
+	err = writeConfig(stateStore, cluster, groups)
+	if err != nil {
+		return err
+	}
+
+	_, err = RecordRevision(stateStore)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func writeConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceGroup) error {
 	// Check for instancegroup Name duplicates before writing
 	{
 		names := map[string]bool{}
@@ -44,6 +63,51 @@ func WriteConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceG
 	return nil
 }
 
+// ReplaceConfig writes the cluster configuration and instance groups, removing any other instance groups,
+// and records the result as a new revision in the history
+func ReplaceConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceGroup) error {
+	err := EnsureHistory(stateStore)
+	if err != nil {
+		return err
+	}
+
+	err = replaceConfig(stateStore, cluster, groups)
+	if err != nil {
+		return err
+	}
+
+	_, err = RecordRevision(stateStore)
+	return err
+}
+
+func replaceConfig(stateStore fi.StateStore, cluster *Cluster, groups []*InstanceGroup) error {
+	err := writeConfig(stateStore, cluster, groups)
+	if err != nil {
+		return err
+	}
+
+	keep := make(map[string]bool)
+	for _, g := range groups {
+		keep[g.Name] = true
+	}
+	keys, err := stateStore.ListChildren("instancegroup")
+	if err != nil {
+		return fmt.Errorf("error listing instancegroups in state store: %v", err)
+	}
+	for _, key := range keys {
+		if keep[key] {
+			continue
+		}
+		p := stateStore.VFSPath().Join("instancegroup", key)
+		err = p.Remove()
+		if err != nil {
+			return fmt.Errorf("error removing instancegroup %q: %v", key, err)
+		}
+	}
+
+	return nil
+}
+
 func ReadConfig(stateStore fi.StateStore) (*Cluster, []*InstanceGroup, error) {
 	cluster := &Cluster{}
 	err := stateStore.ReadConfig("config", cluster)
@@ -68,18 +132,43 @@ func ReadConfig(stateStore fi.StateStore) (*Cluster, []*InstanceGroup, error) {
 	return cluster, instanceGroups, nil
 }
 
+// DeleteInstanceGroup removes the InstanceGroup from the configuration, and records the result as a new revision
+func DeleteInstanceGroup(stateStore fi.StateStore, name string) error {
+	err := EnsureHistory(stateStore)
+	if err != nil {
+		return err
+	}
+
+	p := stateStore.VFSPath().Join("instancegroup", name)
+	err = p.Remove()
+	if err != nil {
+		return fmt.Errorf("error removing instancegroup %q: %v", name, err)
+	}
+
+	_, err = RecordRevision(stateStore)
+	return err
+}
+
 func DeleteConfig(stateStore fi.StateStore) error {
 	paths, err := stateStore.VFSPath().ReadTree()
 	if err != nil {
 		return fmt.Errorf("error listing files in state store: %v", err)
 	}
 
+	var directories []vfs.Path
+	var files []vfs.Path
 	for _, path := range paths {
+		if fi.IsLocalDirectory(path) {
+			directories = append(directories, path)
+			continue
+		}
+		files = append(files, path)
+
 		relativePath, err := vfs.RelativePath(stateStore.VFSPath(), path)
 		if err != nil {
 			return err
 		}
-		if relativePath == "config" || relativePath == "cluster.spec" {
+		if relativePath == "config" || relativePath == "cluster.spec" || relativePath == "trace.json" || relativePath == "lock.json" {
 			continue
 		}
 		if strings.HasPrefix(relativePath, "pki/") {
@@ -91,16 +180,35 @@ func DeleteConfig(stateStore fi.StateStore) error {
 		if strings.HasPrefix(relativePath, "instancegroup/") {
 			continue
 		}
+		if strings.HasPrefix(relativePath, PathHistory+"/") {
+			continue
+		}
+		// etcd snapshots & restore requests, when etcdBackup.store is not set
+		if strings.HasPrefix(relativePath, "backups/") {
+			continue
+		}
+		// host records, for the hosts DNS provider when dns.hostsStore is not set
+		if strings.HasPrefix(relativePath, "dns/") {
+			continue
+		}
 
 		return fmt.Errorf("refusing to delete: unknown file found: %s", path)
 	}
 
-	for _, path := range paths {
+	for _, path := range files {
 		err = path.Remove()
 		if err != nil {
 			return fmt.Errorf("error deleting cluster file %s: %v", path, err)
 		}
 	}
 
+	// Directories are listed before their contents, so we remove them in reverse order
+	for i := len(directories) - 1; i >= 0; i-- {
+		err = directories[i].Remove()
+		if err != nil {
+			return fmt.Errorf("error deleting cluster directory %s: %v", directories[i], err)
+		}
+	}
+
 	return nil
 }
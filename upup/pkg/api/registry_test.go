@@ -0,0 +1,65 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeleteConfig(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	cluster := &Cluster{}
+	cluster.Name = "kubernetes.example.com"
+	nodes := &InstanceGroup{Spec: InstanceGroupSpec{Role: InstanceGroupRoleNode}}
+	nodes.Name = "nodes"
+	if err := WriteConfig(stateStore, cluster, []*InstanceGroup{nodes}); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	base := stateStore.VFSPath()
+	for _, p := range []string{
+		"pki/issued/ca/1234.crt",
+		"secrets/kube",
+		"backups/etcd/main/20161017T130000Z.tar.gz",
+		"backups/etcd/main/restore.json",
+		"dns/hosts/172.20.32.10",
+	} {
+		if err := base.Join(p).WriteFile([]byte("data")); err != nil {
+			t.Fatalf("error writing %s: %v", p, err)
+		}
+	}
+
+	if err := DeleteConfig(stateStore); err != nil {
+		t.Fatalf("error deleting configuration: %v", err)
+	}
+
+	paths, err := base.ReadTree()
+	if err != nil {
+		t.Fatalf("error listing state store: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Fatalf("expected state store to be empty, found %v", paths)
+	}
+}
+
+func TestDeleteConfigRefusesUnknownFiles(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	base := stateStore.VFSPath()
+	if err := base.Join("config").WriteFile([]byte("data")); err != nil {
+		t.Fatalf("error writing config: %v", err)
+	}
+	if err := base.Join("unexpected/file").WriteFile([]byte("data")); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	err := DeleteConfig(stateStore)
+	if err == nil || !strings.Contains(err.Error(), "unknown file found") {
+		t.Fatalf("expected DeleteConfig to refuse to delete an unknown file, got %v", err)
+	}
+	if _, err := base.Join("config").ReadFile(); err != nil {
+		t.Fatalf("expected nothing to be deleted: %v", err)
+	}
+}
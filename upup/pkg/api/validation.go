@@ -5,43 +5,49 @@ import (
 	"net"
 )
 
-func (c *Cluster) Validate() error {
+// Validate checks the cluster configuration.
+// If strict is true, the cluster must be the completed spec: every field must be set, and the component configurations
+// must be consistent with the cluster.  Otherwise (e.g. for the configuration as the user wrote it), we only check the
+// fields that are set.
+func (c *Cluster) Validate(strict bool) error {
 	var err error
 
-	if c.Spec.Kubelet == nil {
-		return fmt.Errorf("Kubelet not configured")
-	}
-	if c.Spec.MasterKubelet == nil {
-		return fmt.Errorf("MasterKubelet not configured")
-	}
-	if c.Spec.KubeControllerManager == nil {
-		return fmt.Errorf("KubeControllerManager not configured")
-	}
-	if c.Spec.KubeDNS == nil {
-		return fmt.Errorf("KubeDNS not configured")
-	}
-	if c.Spec.Kubelet == nil {
-		return fmt.Errorf("Kubelet not configured")
-	}
-	if c.Spec.KubeAPIServer == nil {
-		return fmt.Errorf("KubeAPIServer not configured")
-	}
-	if c.Spec.KubeProxy == nil {
-		return fmt.Errorf("KubeProxy not configured")
-	}
-	if c.Spec.Docker == nil {
-		return fmt.Errorf("Docker not configured")
+	if strict {
+		if c.Spec.Kubelet == nil {
+			return fmt.Errorf("Kubelet not configured")
+		}
+		if c.Spec.MasterKubelet == nil {
+			return fmt.Errorf("MasterKubelet not configured")
+		}
+		if c.Spec.KubeControllerManager == nil {
+			return fmt.Errorf("KubeControllerManager not configured")
+		}
+		if c.Spec.KubeDNS == nil {
+			return fmt.Errorf("KubeDNS not configured")
+		}
+		if c.Spec.KubeAPIServer == nil {
+			return fmt.Errorf("KubeAPIServer not configured")
+		}
+		if c.Spec.KubeProxy == nil {
+			return fmt.Errorf("KubeProxy not configured")
+		}
+		if c.Spec.Docker == nil {
+			return fmt.Errorf("Docker not configured")
+		}
 	}
 
 	// Check NetworkCIDR
 	var networkCIDR *net.IPNet
 	{
 		if c.Spec.NetworkCIDR == "" {
-			return fmt.Errorf("Cluster did not have NetworkCIDR set")
-		}
-		_, networkCIDR, err = net.ParseCIDR(c.Spec.NetworkCIDR)
-		if err != nil {
-			return fmt.Errorf("Cluster had an invalid NetworkCIDR: %q", c.Spec.NetworkCIDR)
+			if strict {
+				return fmt.Errorf("Cluster did not have NetworkCIDR set")
+			}
+		} else {
+			_, networkCIDR, err = net.ParseCIDR(c.Spec.NetworkCIDR)
+			if err != nil {
+				return fmt.Errorf("Cluster had an invalid NetworkCIDR: %q", c.Spec.NetworkCIDR)
+			}
 		}
 	}
 
@@ -49,22 +55,25 @@ func (c *Cluster) Validate() error {
 	var nonMasqueradeCIDR *net.IPNet
 	{
 		if c.Spec.NonMasqueradeCIDR == "" {
-			return fmt.Errorf("Cluster did not have NonMasqueradeCIDR set")
-		}
-		_, nonMasqueradeCIDR, err = net.ParseCIDR(c.Spec.NonMasqueradeCIDR)
-		if err != nil {
-			return fmt.Errorf("Cluster had an invalid NonMasqueradeCIDR: %q", c.Spec.NonMasqueradeCIDR)
-		}
+			if strict {
+				return fmt.Errorf("Cluster did not have NonMasqueradeCIDR set")
+			}
+		} else {
+			_, nonMasqueradeCIDR, err = net.ParseCIDR(c.Spec.NonMasqueradeCIDR)
+			if err != nil {
+				return fmt.Errorf("Cluster had an invalid NonMasqueradeCIDR: %q", c.Spec.NonMasqueradeCIDR)
+			}
 
-		if subnetsOverlap(nonMasqueradeCIDR, networkCIDR) {
-			return fmt.Errorf("NonMasqueradeCIDR %q cannot overlap with NetworkCIDR %q", c.Spec.NonMasqueradeCIDR, c.Spec.NetworkCIDR)
-		}
+			if networkCIDR != nil && subnetsOverlap(nonMasqueradeCIDR, networkCIDR) {
+				return fmt.Errorf("NonMasqueradeCIDR %q cannot overlap with NetworkCIDR %q", c.Spec.NonMasqueradeCIDR, c.Spec.NetworkCIDR)
+			}
 
-		if c.Spec.Kubelet.NonMasqueradeCIDR != c.Spec.NonMasqueradeCIDR {
-			return fmt.Errorf("Kubelet NonMasqueradeCIDR did not match cluster NonMasqueradeCIDR")
-		}
-		if c.Spec.MasterKubelet.NonMasqueradeCIDR != c.Spec.NonMasqueradeCIDR {
-			return fmt.Errorf("MasterKubelet NonMasqueradeCIDR did not match cluster NonMasqueradeCIDR")
+			if c.Spec.Kubelet != nil && mismatched(strict, c.Spec.Kubelet.NonMasqueradeCIDR, c.Spec.NonMasqueradeCIDR) {
+				return fmt.Errorf("Kubelet NonMasqueradeCIDR did not match cluster NonMasqueradeCIDR")
+			}
+			if c.Spec.MasterKubelet != nil && mismatched(strict, c.Spec.MasterKubelet.NonMasqueradeCIDR, c.Spec.NonMasqueradeCIDR) {
+				return fmt.Errorf("MasterKubelet NonMasqueradeCIDR did not match cluster NonMasqueradeCIDR")
+			}
 		}
 	}
 
@@ -72,86 +81,104 @@ func (c *Cluster) Validate() error {
 	var serviceClusterIPRange *net.IPNet
 	{
 		if c.Spec.ServiceClusterIPRange == "" {
-			return fmt.Errorf("Cluster did not have ServiceClusterIPRange set")
-		}
-		_, serviceClusterIPRange, err = net.ParseCIDR(c.Spec.ServiceClusterIPRange)
-		if err != nil {
-			return fmt.Errorf("Cluster had an invalid ServiceClusterIPRange: %q", c.Spec.ServiceClusterIPRange)
-		}
+			if strict {
+				return fmt.Errorf("Cluster did not have ServiceClusterIPRange set")
+			}
+		} else {
+			_, serviceClusterIPRange, err = net.ParseCIDR(c.Spec.ServiceClusterIPRange)
+			if err != nil {
+				return fmt.Errorf("Cluster had an invalid ServiceClusterIPRange: %q", c.Spec.ServiceClusterIPRange)
+			}
 
-		if !isSubnet(nonMasqueradeCIDR, serviceClusterIPRange) {
-			return fmt.Errorf("ServiceClusterIPRange %q must be a subnet of NonMasqueradeCIDR %q", c.Spec.ServiceClusterIPRange, c.Spec.NonMasqueradeCIDR)
-		}
+			if nonMasqueradeCIDR != nil && !isSubnet(nonMasqueradeCIDR, serviceClusterIPRange) {
+				return fmt.Errorf("ServiceClusterIPRange %q must be a subnet of NonMasqueradeCIDR %q", c.Spec.ServiceClusterIPRange, c.Spec.NonMasqueradeCIDR)
+			}
 
-		if c.Spec.KubeAPIServer.ServiceClusterIPRange != c.Spec.ServiceClusterIPRange {
-			return fmt.Errorf("KubeAPIServer ServiceClusterIPRange did not match cluster ServiceClusterIPRange")
+			if c.Spec.KubeAPIServer != nil && mismatched(strict, c.Spec.KubeAPIServer.ServiceClusterIPRange, c.Spec.ServiceClusterIPRange) {
+				return fmt.Errorf("KubeAPIServer ServiceClusterIPRange did not match cluster ServiceClusterIPRange")
+			}
 		}
-
 	}
 
 	// Check ClusterCIDR
-	var clusterCIDR *net.IPNet
-	{
+	if c.Spec.KubeControllerManager != nil {
+		var clusterCIDR *net.IPNet
 		if c.Spec.KubeControllerManager.ClusterCIDR == "" {
-			return fmt.Errorf("Cluster did not have KubeControllerManager.ClusterCIDR set")
-		}
-		_, clusterCIDR, err = net.ParseCIDR(c.Spec.KubeControllerManager.ClusterCIDR)
-		if err != nil {
-			return fmt.Errorf("Cluster had an invalid KubeControllerManager.ClusterCIDR: %q", c.Spec.KubeControllerManager.ClusterCIDR)
-		}
+			if strict {
+				return fmt.Errorf("Cluster did not have KubeControllerManager.ClusterCIDR set")
+			}
+		} else {
+			_, clusterCIDR, err = net.ParseCIDR(c.Spec.KubeControllerManager.ClusterCIDR)
+			if err != nil {
+				return fmt.Errorf("Cluster had an invalid KubeControllerManager.ClusterCIDR: %q", c.Spec.KubeControllerManager.ClusterCIDR)
+			}
 
-		if !isSubnet(nonMasqueradeCIDR, clusterCIDR) {
-			return fmt.Errorf("KubeControllerManager.ClusterCIDR %q must be a subnet of NonMasqueradeCIDR %q", c.Spec.KubeControllerManager.ClusterCIDR, c.Spec.NonMasqueradeCIDR)
+			if nonMasqueradeCIDR != nil && !isSubnet(nonMasqueradeCIDR, clusterCIDR) {
+				return fmt.Errorf("KubeControllerManager.ClusterCIDR %q must be a subnet of NonMasqueradeCIDR %q", c.Spec.KubeControllerManager.ClusterCIDR, c.Spec.NonMasqueradeCIDR)
+			}
 		}
 	}
 
 	// Check KubeDNS.ServerIP
-	{
+	if c.Spec.KubeDNS != nil {
 		if c.Spec.KubeDNS.ServerIP == "" {
-			return fmt.Errorf("Cluster did not have KubeDNS.ServerIP set")
-		}
-
-		dnsServiceIP := net.ParseIP(c.Spec.KubeDNS.ServerIP)
-		if dnsServiceIP == nil {
-			return fmt.Errorf("Cluster had an invalid KubeDNS.ServerIP: %q", c.Spec.KubeDNS.ServerIP)
-		}
+			if strict {
+				return fmt.Errorf("Cluster did not have KubeDNS.ServerIP set")
+			}
+		} else {
+			dnsServiceIP := net.ParseIP(c.Spec.KubeDNS.ServerIP)
+			if dnsServiceIP == nil {
+				return fmt.Errorf("Cluster had an invalid KubeDNS.ServerIP: %q", c.Spec.KubeDNS.ServerIP)
+			}
 
-		if !serviceClusterIPRange.Contains(dnsServiceIP) {
-			return fmt.Errorf("ServiceClusterIPRange %q must contain the DNS Server IP %q", c.Spec.ServiceClusterIPRange, c.Spec.KubeDNS.ServerIP)
-		}
+			if serviceClusterIPRange != nil && !serviceClusterIPRange.Contains(dnsServiceIP) {
+				return fmt.Errorf("ServiceClusterIPRange %q must contain the DNS Server IP %q", c.Spec.ServiceClusterIPRange, c.Spec.KubeDNS.ServerIP)
+			}
 
-		if c.Spec.Kubelet.ClusterDNS != c.Spec.KubeDNS.ServerIP {
-			return fmt.Errorf("Kubelet ClusterDNS did not match cluster KubeDNS.ServerIP")
-		}
-		if c.Spec.MasterKubelet.ClusterDNS != c.Spec.KubeDNS.ServerIP {
-			return fmt.Errorf("MasterKubelet ClusterDNS did not match cluster KubeDNS.ServerIP")
+			if c.Spec.Kubelet != nil && mismatched(strict, c.Spec.Kubelet.ClusterDNS, c.Spec.KubeDNS.ServerIP) {
+				return fmt.Errorf("Kubelet ClusterDNS did not match cluster KubeDNS.ServerIP")
+			}
+			if c.Spec.MasterKubelet != nil && mismatched(strict, c.Spec.MasterKubelet.ClusterDNS, c.Spec.KubeDNS.ServerIP) {
+				return fmt.Errorf("MasterKubelet ClusterDNS did not match cluster KubeDNS.ServerIP")
+			}
 		}
 	}
 
 	// Check CloudProvider
 	{
 		if c.Spec.CloudProvider != "" {
-			if c.Spec.Kubelet.CloudProvider != c.Spec.CloudProvider {
+			if c.Spec.Kubelet != nil && mismatched(strict, c.Spec.Kubelet.CloudProvider, c.Spec.CloudProvider) {
 				return fmt.Errorf("Kubelet CloudProvider did not match cluster CloudProvider")
 			}
-			if c.Spec.MasterKubelet.CloudProvider != c.Spec.CloudProvider {
+			if c.Spec.MasterKubelet != nil && mismatched(strict, c.Spec.MasterKubelet.CloudProvider, c.Spec.CloudProvider) {
 				return fmt.Errorf("MasterKubelet CloudProvider did not match cluster CloudProvider")
 			}
-			if c.Spec.KubeAPIServer.CloudProvider != c.Spec.CloudProvider {
+			if c.Spec.KubeAPIServer != nil && mismatched(strict, c.Spec.KubeAPIServer.CloudProvider, c.Spec.CloudProvider) {
 				return fmt.Errorf("Errorf CloudProvider did not match cluster CloudProvider")
 			}
-			if c.Spec.KubeControllerManager.CloudProvider != c.Spec.CloudProvider {
+			if c.Spec.KubeControllerManager != nil && mismatched(strict, c.Spec.KubeControllerManager.CloudProvider, c.Spec.CloudProvider) {
 				return fmt.Errorf("KubeControllerManager CloudProvider did not match cluster CloudProvider")
 			}
 		}
 	}
 
 	// Check that the zone CIDRs are all consistent
+	clusterZones := make(map[string]*ClusterZoneSpec)
 	{
-
 		for _, z := range c.Spec.Zones {
+			if z.Name == "" {
+				return fmt.Errorf("Cluster had a Zone without a Name")
+			}
+			if clusterZones[z.Name] != nil {
+				return fmt.Errorf("Zones contained a duplicate value: %v", z.Name)
+			}
+			clusterZones[z.Name] = z
+
 			if z.CIDR == "" {
-				return fmt.Errorf("Zone %q did not have a CIDR set", z.Name)
+				if strict {
+					return fmt.Errorf("Zone %q did not have a CIDR set", z.Name)
+				}
+				continue
 			}
 
 			_, zoneCIDR, err := net.ParseCIDR(z.CIDR)
@@ -159,15 +186,69 @@ func (c *Cluster) Validate() error {
 				return fmt.Errorf("Zone %q had an invalid CIDR: %q", z.Name, z.CIDR)
 			}
 
-			if !isSubnet(networkCIDR, zoneCIDR) {
+			if networkCIDR != nil && !isSubnet(networkCIDR, zoneCIDR) {
 				return fmt.Errorf("Zone %q had a CIDR %q that was not a subnet of the NetworkCIDR %q", z.Name, z.CIDR, c.Spec.NetworkCIDR)
 			}
 		}
 	}
 
+	// Check etcd configuration
+	{
+		for i, etcd := range c.Spec.EtcdClusters {
+			if etcd.Name == "" {
+				return fmt.Errorf("EtcdClusters #%d did not specify a Name", i)
+			}
+
+			for i, m := range etcd.Members {
+				if m.Name == "" {
+					return fmt.Errorf("EtcdMember #%d of etcd-cluster %s did not specify a Name", i, etcd.Name)
+				}
+
+				z := m.Zone
+				if z == "" {
+					return fmt.Errorf("EtcdMember %s:%s did not specify a Zone", etcd.Name, m.Name)
+				}
+			}
+
+			etcdZones := make(map[string]*EtcdMemberSpec)
+			etcdNames := make(map[string]*EtcdMemberSpec)
+
+			for _, m := range etcd.Members {
+				if etcdNames[m.Name] != nil {
+					return fmt.Errorf("EtcdMembers found with same name %q in etcd-cluster %q", m.Name, etcd.Name)
+				}
+				etcdNames[m.Name] = m
+
+				if etcdZones[m.Zone] != nil {
+					// Maybe this should just be a warning
+					return fmt.Errorf("EtcdMembers are in the same zone %q in etcd-cluster %q", m.Zone, etcd.Name)
+				}
+
+				if clusterZones[m.Zone] == nil {
+					return fmt.Errorf("EtcdMembers for %q is configured in zone %q, but that is not configured at the k8s-cluster level", etcd.Name, m.Zone)
+				}
+				etcdZones[m.Zone] = m
+			}
+
+			if (len(etcdZones) % 2) == 0 {
+				// Not technically a requirement, but doesn't really make sense to allow
+				return fmt.Errorf("There should be an odd number of master-zones, for etcd's quorum.  Hint: Use --zone and --master-zone to declare node zones and master zones separately.")
+			}
+		}
+	}
+
 	return nil
 }
 
+// mismatched returns true if a component value does not match the cluster value.
+// If we are not strict, an unset component value is not a mismatch, because it will be filled in from the cluster value.
+func mismatched(strict bool, componentValue, clusterValue string) bool {
+	if !strict && componentValue == "" {
+		return false
+	}
+	return componentValue != clusterValue
+}
+
 // isSubnet checks if child is a subnet of parent
 func isSubnet(parent *net.IPNet, child *net.IPNet) bool {
 	parentOnes, parentBits := parent.Mask.Size()
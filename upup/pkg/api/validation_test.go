@@ -0,0 +1,49 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestValidateNotStrict(t *testing.T) {
+	grid := []struct {
+		spec  ClusterSpec
+		valid bool
+	}{
+		// Most fields are only set in the completed spec
+		{ClusterSpec{}, true},
+		{ClusterSpec{NetworkCIDR: "172.20.0.0/16", Zones: []*ClusterZoneSpec{{Name: "us-east-1a"}}}, true},
+		{ClusterSpec{NetworkCIDR: "172.20.0.0/33"}, false},
+		{ClusterSpec{NetworkCIDR: "172.20.0.0/16", Zones: []*ClusterZoneSpec{{Name: "us-east-1a", CIDR: "10.0.0.0/24"}}}, false},
+		{ClusterSpec{Zones: []*ClusterZoneSpec{{Name: "us-east-1a"}, {Name: "us-east-1a"}}}, false},
+		{ClusterSpec{NonMasqueradeCIDR: "100.64.0.0/10", Kubelet: &KubeletConfig{NonMasqueradeCIDR: "10.0.0.0/8"}}, false},
+		{ClusterSpec{NonMasqueradeCIDR: "100.64.0.0/10", Kubelet: &KubeletConfig{}}, true},
+		{ClusterSpec{
+			Zones: []*ClusterZoneSpec{{Name: "us-east-1a"}},
+			EtcdClusters: []*EtcdClusterSpec{
+				{Name: "main", Members: []*EtcdMemberSpec{{Name: "a", Zone: "us-east-1a"}}},
+			},
+		}, true},
+		{ClusterSpec{
+			Zones: []*ClusterZoneSpec{{Name: "us-east-1a"}},
+			EtcdClusters: []*EtcdClusterSpec{
+				{Name: "main", Members: []*EtcdMemberSpec{{Name: "b", Zone: "us-east-1b"}}},
+			},
+		}, false},
+	}
+	for i, g := range grid {
+		c := &Cluster{Spec: g.spec}
+		err := c.Validate(false)
+		if g.valid && err != nil {
+			t.Errorf("test case %d: unexpected error: %v", i, err)
+		}
+		if !g.valid && err == nil {
+			t.Errorf("test case %d: expected error", i)
+		}
+	}
+
+	// The completed spec must have every field set
+	c := &Cluster{}
+	if err := c.Validate(true); err == nil {
+		t.Errorf("expected error validating empty cluster with strict")
+	}
+}
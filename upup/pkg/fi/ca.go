@@ -87,6 +87,14 @@ type CAStore interface {
 
 	// AddCert adds an alternative certificate to the pool (primarily useful for CAs)
 	AddCert(id string, cert *Certificate) error
+
+	// RotateCA creates a new CA keypair, which becomes the primary CA.
+	// The previous CA certificates remain in the pool (and so are still trusted) until they are retired.
+	RotateCA() (*Certificate, error)
+	// RotateKeypair issues a new keypair for id, with the same subject & usage, signed by the primary CA
+	RotateKeypair(id string) (*Certificate, error)
+	// RetireKeypair removes all but the primary certificate & private key for id, returning the retired serials
+	RetireKeypair(id string) ([]string, error)
 }
 
 func (c *Certificate) AsString() (string, error) {
@@ -332,17 +340,31 @@ func (c *CertificatePool) AsString() (string, error) {
 	}
 
 	var data bytes.Buffer
+	_, err := c.WriteTo(&data)
+	if err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
+
+var _ io.WriterTo = &CertificatePool{}
+
+// WriteTo writes all the certificates in the pool (primary first) as a PEM bundle
+func (c *CertificatePool) WriteTo(w io.Writer) (int64, error) {
+	var total int64
 	if c.Primary != nil {
-		_, err := c.Primary.WriteTo(&data)
+		n, err := c.Primary.WriteTo(w)
+		total += n
 		if err != nil {
-			return "", fmt.Errorf("error writing SSL certificate: %v", err)
+			return total, fmt.Errorf("error writing SSL certificate: %v", err)
 		}
 	}
 	for _, cert := range c.Secondary {
-		_, err := cert.WriteTo(&data)
+		n, err := cert.WriteTo(w)
+		total += n
 		if err != nil {
-			return "", fmt.Errorf("error writing SSL certificate: %v", err)
+			return total, fmt.Errorf("error writing SSL certificate: %v", err)
 		}
 	}
-	return data.String(), nil
+	return total, nil
 }
@@ -0,0 +1,121 @@
+package fi
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// CloudProvider is implemented by each cloud we support, and registered with RegisterCloudProvider
+// from the init function of the cloud's package.
+// Commands look up the provider for the cluster's CloudProvider, rather than switching on the cloud themselves.
+type CloudProvider interface {
+	// BuildCloud constructs the Cloud for the cluster, checking the zones are valid
+	BuildCloud(clusterName string, zones []string, project string) (Cloud, error)
+
+	// ModelTag is the tag that selects the cloud-specific directories of the cloudup and nodeup models (e.g. _aws)
+	ModelTag() string
+
+	// TaskTypes maps the names used in the cloudup models to the task types
+	TaskTypes() map[string]interface{}
+
+	// TemplateFunctions returns any cloud-specific functions the models use
+	TemplateFunctions(cloud Cloud) template.FuncMap
+
+	// Region returns the region in which the cloud operates
+	Region(cloud Cloud) string
+
+	// DefaultMachineType is the machine type we use when an InstanceGroup does not specify one
+	DefaultMachineType() string
+
+	// DefaultImage is the image we use when an InstanceGroup does not specify one; it may be empty
+	DefaultImage() string
+
+	// NewAPITarget returns the target for applying changes directly to the cloud
+	NewAPITarget(cloud Cloud) Target
+
+	// TerraformProvider returns the name and configuration for the terraform provider block
+	TerraformProvider(cloud Cloud) (string, map[string]interface{})
+
+	// ListResources finds the cloud resources that belong to the cluster, so that they can be deleted
+	ListResources(cloud Cloud, clusterName string) (map[string]*ResourceTracker, error)
+
+	// ListInstanceGroups finds the cloud groups of instances (e.g. autoscaling groups) that belong to the cluster
+	ListInstanceGroups(cloud Cloud) ([]*CloudInstanceGroup, error)
+
+	// IsDependencyViolation returns true if a delete failed only because other resources still depend on the resource
+	IsDependencyViolation(err error) bool
+}
+
+var cloudProvidersMutex sync.Mutex
+var cloudProviders = make(map[CloudProviderID]CloudProvider)
+
+// RegisterCloudProvider registers a CloudProvider; it is called from the init function of the provider package
+func RegisterCloudProvider(id CloudProviderID, provider CloudProvider) {
+	cloudProvidersMutex.Lock()
+	defer cloudProvidersMutex.Unlock()
+
+	if cloudProviders[id] != nil {
+		panic(fmt.Sprintf("CloudProvider %q registered twice", id))
+	}
+	cloudProviders[id] = provider
+}
+
+// FindCloudProvider returns the registered CloudProvider with the specified id
+func FindCloudProvider(id CloudProviderID) (CloudProvider, error) {
+	cloudProvidersMutex.Lock()
+	defer cloudProvidersMutex.Unlock()
+
+	provider := cloudProviders[id]
+	if provider == nil {
+		var known []string
+		for k := range cloudProviders {
+			known = append(known, string(k))
+		}
+		sort.Strings(known)
+		return nil, fmt.Errorf("unknown CloudProvider %q (known providers: %v)", id, known)
+	}
+	return provider, nil
+}
+
+// ResourceTracker tracks a cloud resource during cluster deletion
+type ResourceTracker struct {
+	Name string
+	Type string
+	ID   string
+
+	// Blocks holds the keys of resources that cannot be deleted until this resource has been deleted
+	Blocks []string
+	// Blocked holds the keys of resources that must be deleted before this resource can be deleted
+	Blocked []string
+	Done    bool
+
+	Deleter func(cloud Cloud, tracker *ResourceTracker) error
+
+	// Obj holds the cloud object, when the deleter needs more than the ID
+	Obj interface{}
+}
+
+// CloudInstanceGroup is the cloud representation of an InstanceGroup (e.g. an AWS autoscaling group)
+type CloudInstanceGroup struct {
+	Name string
+	// Role is the role of the instances in the group: Master or Node
+	Role string
+
+	// Ready holds the instances that are running the current configuration
+	Ready []*CloudInstance
+	// NeedUpdate holds the instances that are running an out-of-date configuration
+	NeedUpdate []*CloudInstance
+
+	// Raw is the cloud-specific object, for example the *autoscaling.Group
+	Raw interface{}
+}
+
+// CloudInstance is a single instance in a CloudInstanceGroup
+type CloudInstance struct {
+	ID string
+
+	// Raw is the cloud-specific object, for example the *autoscaling.Instance
+	Raw interface{}
+}
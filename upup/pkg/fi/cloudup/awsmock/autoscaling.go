@@ -0,0 +1,316 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+	"sync"
+	"time"
+)
+
+// MockAutoscaling is an in-memory implementation of AutoScaling.
+// Groups do not launch instances; tests that need instances in a group can add them to Instances directly.
+type MockAutoscaling struct {
+	// Embedded so that we satisfy the interface; calls we have not implemented will panic
+	autoscalingiface.AutoScalingAPI
+
+	mutex sync.Mutex
+
+	Groups               map[string]*autoscaling.Group
+	LaunchConfigurations map[string]*autoscaling.LaunchConfiguration
+}
+
+var _ autoscalingiface.AutoScalingAPI = &MockAutoscaling{}
+
+func newMockAutoscaling() *MockAutoscaling {
+	return &MockAutoscaling{
+		Groups:               make(map[string]*autoscaling.Group),
+		LaunchConfigurations: make(map[string]*autoscaling.LaunchConfiguration),
+	}
+}
+
+func validationError(message string) error {
+	return awserr.New("ValidationError", message, nil)
+}
+
+func (m *MockAutoscaling) CreateLaunchConfiguration(request *autoscaling.CreateLaunchConfigurationInput) (*autoscaling.CreateLaunchConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LaunchConfigurationName)
+	if m.LaunchConfigurations[name] != nil {
+		return nil, awserr.New("AlreadyExists", fmt.Sprintf("Launch Configuration by this name already exists - A launch configuration already exists with the name %s", name), nil)
+	}
+
+	m.LaunchConfigurations[name] = &autoscaling.LaunchConfiguration{
+		LaunchConfigurationName:  aws.String(name),
+		ImageId:                  request.ImageId,
+		InstanceType:             request.InstanceType,
+		KeyName:                  request.KeyName,
+		SecurityGroups:           request.SecurityGroups,
+		AssociatePublicIpAddress: request.AssociatePublicIpAddress,
+		BlockDeviceMappings:      request.BlockDeviceMappings,
+		UserData:                 request.UserData,
+		IamInstanceProfile:       request.IamInstanceProfile,
+		CreatedTime:              aws.Time(time.Now()),
+	}
+	return &autoscaling.CreateLaunchConfigurationOutput{}, nil
+}
+
+func (m *MockAutoscaling) DescribeLaunchConfigurations(request *autoscaling.DescribeLaunchConfigurationsInput) (*autoscaling.DescribeLaunchConfigurationsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &autoscaling.DescribeLaunchConfigurationsOutput{}
+	for _, name := range sortedKeys(m.LaunchConfigurations) {
+		if !matchIDs(request.LaunchConfigurationNames, name) {
+			continue
+		}
+		response.LaunchConfigurations = append(response.LaunchConfigurations, m.LaunchConfigurations[name])
+	}
+	return response, nil
+}
+
+func (m *MockAutoscaling) DescribeLaunchConfigurationsPages(request *autoscaling.DescribeLaunchConfigurationsInput, fn func(*autoscaling.DescribeLaunchConfigurationsOutput, bool) bool) error {
+	response, err := m.DescribeLaunchConfigurations(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockAutoscaling) DeleteLaunchConfiguration(request *autoscaling.DeleteLaunchConfigurationInput) (*autoscaling.DeleteLaunchConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LaunchConfigurationName)
+	if m.LaunchConfigurations[name] == nil {
+		return nil, validationError(fmt.Sprintf("Launch configuration name not found - %s", name))
+	}
+	for _, g := range m.Groups {
+		if aws.StringValue(g.LaunchConfigurationName) == name {
+			return nil, awserr.New("ResourceInUse", fmt.Sprintf("Cannot delete launch configuration %s because it is attached to AutoScalingGroup %s", name, aws.StringValue(g.AutoScalingGroupName)), nil)
+		}
+	}
+	delete(m.LaunchConfigurations, name)
+	return &autoscaling.DeleteLaunchConfigurationOutput{}, nil
+}
+
+func (m *MockAutoscaling) CreateAutoScalingGroup(request *autoscaling.CreateAutoScalingGroupInput) (*autoscaling.CreateAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	if m.Groups[name] != nil {
+		return nil, awserr.New("AlreadyExists", fmt.Sprintf("AutoScalingGroup by this name already exists - A group with the name %s already exists", name), nil)
+	}
+	if m.LaunchConfigurations[aws.StringValue(request.LaunchConfigurationName)] == nil {
+		return nil, validationError(fmt.Sprintf("Launch configuration name not found - %s", aws.StringValue(request.LaunchConfigurationName)))
+	}
+
+	desiredCapacity := request.DesiredCapacity
+	if desiredCapacity == nil {
+		desiredCapacity = request.MinSize
+	}
+
+	g := &autoscaling.Group{
+		AutoScalingGroupName:    aws.String(name),
+		LaunchConfigurationName: request.LaunchConfigurationName,
+		MinSize:                 request.MinSize,
+		MaxSize:                 request.MaxSize,
+		DesiredCapacity:         desiredCapacity,
+		VPCZoneIdentifier:       request.VPCZoneIdentifier,
+		AvailabilityZones:       request.AvailabilityZones,
+		LoadBalancerNames:       request.LoadBalancerNames,
+		CreatedTime:             aws.Time(time.Now()),
+	}
+	for _, tag := range request.Tags {
+		g.Tags = append(g.Tags, &autoscaling.TagDescription{
+			Key:               tag.Key,
+			Value:             tag.Value,
+			ResourceId:        aws.String(name),
+			ResourceType:      aws.String("auto-scaling-group"),
+			PropagateAtLaunch: tag.PropagateAtLaunch,
+		})
+	}
+	m.Groups[name] = g
+
+	return &autoscaling.CreateAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) DescribeAutoScalingGroups(request *autoscaling.DescribeAutoScalingGroupsInput) (*autoscaling.DescribeAutoScalingGroupsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Like AWS, we silently ignore names that are not found
+	response := &autoscaling.DescribeAutoScalingGroupsOutput{}
+	for _, name := range sortedKeys(m.Groups) {
+		if !matchIDs(request.AutoScalingGroupNames, name) {
+			continue
+		}
+		c := *m.Groups[name]
+		response.AutoScalingGroups = append(response.AutoScalingGroups, &c)
+	}
+	return response, nil
+}
+
+func (m *MockAutoscaling) DescribeAutoScalingGroupsPages(request *autoscaling.DescribeAutoScalingGroupsInput, fn func(*autoscaling.DescribeAutoScalingGroupsOutput, bool) bool) error {
+	response, err := m.DescribeAutoScalingGroups(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockAutoscaling) UpdateAutoScalingGroup(request *autoscaling.UpdateAutoScalingGroupInput) (*autoscaling.UpdateAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.Groups[name]
+	if g == nil {
+		return nil, validationError(fmt.Sprintf("AutoScalingGroup name not found - %s", name))
+	}
+
+	if request.LaunchConfigurationName != nil {
+		if m.LaunchConfigurations[aws.StringValue(request.LaunchConfigurationName)] == nil {
+			return nil, validationError(fmt.Sprintf("Launch configuration name not found - %s", aws.StringValue(request.LaunchConfigurationName)))
+		}
+		g.LaunchConfigurationName = request.LaunchConfigurationName
+	}
+	if request.MinSize != nil {
+		g.MinSize = request.MinSize
+	}
+	if request.MaxSize != nil {
+		g.MaxSize = request.MaxSize
+	}
+	if request.DesiredCapacity != nil {
+		g.DesiredCapacity = request.DesiredCapacity
+	}
+	if request.VPCZoneIdentifier != nil {
+		g.VPCZoneIdentifier = request.VPCZoneIdentifier
+	}
+	return &autoscaling.UpdateAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) SetDesiredCapacity(request *autoscaling.SetDesiredCapacityInput) (*autoscaling.SetDesiredCapacityOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.Groups[name]
+	if g == nil {
+		return nil, validationError(fmt.Sprintf("AutoScalingGroup name not found - %s", name))
+	}
+
+	desired := aws.Int64Value(request.DesiredCapacity)
+	if desired < aws.Int64Value(g.MinSize) || desired > aws.Int64Value(g.MaxSize) {
+		return nil, validationError(fmt.Sprintf("New SetDesiredCapacity value %d is outside the bounds of the group (min %d, max %d)", desired, aws.Int64Value(g.MinSize), aws.Int64Value(g.MaxSize)))
+	}
+	g.DesiredCapacity = aws.Int64(desired)
+	return &autoscaling.SetDesiredCapacityOutput{}, nil
+}
+
+func (m *MockAutoscaling) TerminateInstanceInAutoScalingGroup(request *autoscaling.TerminateInstanceInAutoScalingGroupInput) (*autoscaling.TerminateInstanceInAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InstanceId)
+	for _, g := range m.Groups {
+		for i, instance := range g.Instances {
+			if aws.StringValue(instance.InstanceId) != id {
+				continue
+			}
+			g.Instances = append(g.Instances[:i], g.Instances[i+1:]...)
+			if aws.BoolValue(request.ShouldDecrementDesiredCapacity) {
+				g.DesiredCapacity = aws.Int64(aws.Int64Value(g.DesiredCapacity) - 1)
+			}
+			return &autoscaling.TerminateInstanceInAutoScalingGroupOutput{
+				Activity: &autoscaling.Activity{
+					AutoScalingGroupName: g.AutoScalingGroupName,
+					Description:          aws.String("Terminating EC2 instance: " + id),
+					StatusCode:           aws.String("InProgress"),
+				},
+			}, nil
+		}
+	}
+	return nil, validationError(fmt.Sprintf("Instance Id not found - %s", id))
+}
+
+func (m *MockAutoscaling) AttachLoadBalancers(request *autoscaling.AttachLoadBalancersInput) (*autoscaling.AttachLoadBalancersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.Groups[name]
+	if g == nil {
+		return nil, validationError(fmt.Sprintf("AutoScalingGroup name not found - %s", name))
+	}
+	for _, lb := range request.LoadBalancerNames {
+		if !containsString(g.LoadBalancerNames, aws.StringValue(lb)) {
+			g.LoadBalancerNames = append(g.LoadBalancerNames, lb)
+		}
+	}
+	return &autoscaling.AttachLoadBalancersOutput{}, nil
+}
+
+func (m *MockAutoscaling) DeleteAutoScalingGroup(request *autoscaling.DeleteAutoScalingGroupInput) (*autoscaling.DeleteAutoScalingGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.AutoScalingGroupName)
+	g := m.Groups[name]
+	if g == nil {
+		return nil, validationError(fmt.Sprintf("AutoScalingGroup name not found - %s", name))
+	}
+	if len(g.Instances) != 0 && !aws.BoolValue(request.ForceDelete) {
+		return nil, awserr.New("ResourceInUse", "You cannot delete an AutoScalingGroup while there are instances still in the group.", nil)
+	}
+	delete(m.Groups, name)
+	return &autoscaling.DeleteAutoScalingGroupOutput{}, nil
+}
+
+func (m *MockAutoscaling) DescribeTags(request *autoscaling.DescribeTagsInput) (*autoscaling.DescribeTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &autoscaling.DescribeTagsOutput{}
+	for _, name := range sortedKeys(m.Groups) {
+		for _, tag := range m.Groups[name].Tags {
+			attributes := map[string][]string{
+				"auto-scaling-group":  {name},
+				"key":                 {aws.StringValue(tag.Key)},
+				"value":               {aws.StringValue(tag.Value)},
+				"propagate-at-launch": {fmt.Sprintf("%v", aws.BoolValue(tag.PropagateAtLaunch))},
+			}
+
+			match := true
+			for _, filter := range request.Filters {
+				values := attributes[aws.StringValue(filter.Name)]
+				if values == nil {
+					return nil, validationError(fmt.Sprintf("filter %q is not supported by the mock", aws.StringValue(filter.Name)))
+				}
+				if !containsString(filter.Values, values[0]) {
+					match = false
+				}
+			}
+			if match {
+				response.Tags = append(response.Tags, tag)
+			}
+		}
+	}
+	return response, nil
+}
+
+func (m *MockAutoscaling) DescribeTagsPages(request *autoscaling.DescribeTagsInput, fn func(*autoscaling.DescribeTagsOutput, bool) bool) error {
+	response, err := m.DescribeTags(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
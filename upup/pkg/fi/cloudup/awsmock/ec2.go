@@ -0,0 +1,404 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MockAccountID is the owner of the resources created by the mock, and the account that "self" refers to
+const MockAccountID = "123456789012"
+
+// MockEC2 is an in-memory implementation of EC2.
+// Resources are created immediately in their final state (e.g. instances are running as soon as they are launched).
+// Unlike AWS, we do not create a default security group or a main route table for each VPC.
+type MockEC2 struct {
+	// Embedded so that we satisfy the interface; calls we have not implemented will panic
+	ec2iface.EC2API
+
+	mutex     sync.Mutex
+	ids       idGenerator
+	publicIPs int
+
+	region string
+
+	Zones  []*ec2.AvailabilityZone
+	Images []*ec2.Image
+
+	Vpcs             map[string]*ec2.Vpc
+	vpcAttributes    map[string]*ec2.DescribeVpcAttributeOutput
+	Subnets          map[string]*ec2.Subnet
+	SecurityGroups   map[string]*ec2.SecurityGroup
+	InternetGateways map[string]*ec2.InternetGateway
+	RouteTables      map[string]*ec2.RouteTable
+	DhcpOptions      map[string]*ec2.DhcpOptions
+	Volumes          map[string]*ec2.Volume
+	Addresses        map[string]*ec2.Address
+	KeyPairs         map[string]*ec2.KeyPairInfo
+	Instances        map[string]*ec2.Instance
+	userData         map[string]*string
+
+	// tags holds the tags for each resource, keyed by resource id
+	tags map[string]map[string]string
+}
+
+var _ ec2iface.EC2API = &MockEC2{}
+
+func newMockEC2(region string) *MockEC2 {
+	m := &MockEC2{
+		region: region,
+
+		Vpcs:             make(map[string]*ec2.Vpc),
+		vpcAttributes:    make(map[string]*ec2.DescribeVpcAttributeOutput),
+		Subnets:          make(map[string]*ec2.Subnet),
+		SecurityGroups:   make(map[string]*ec2.SecurityGroup),
+		InternetGateways: make(map[string]*ec2.InternetGateway),
+		RouteTables:      make(map[string]*ec2.RouteTable),
+		DhcpOptions:      make(map[string]*ec2.DhcpOptions),
+		Volumes:          make(map[string]*ec2.Volume),
+		Addresses:        make(map[string]*ec2.Address),
+		KeyPairs:         make(map[string]*ec2.KeyPairInfo),
+		Instances:        make(map[string]*ec2.Instance),
+		userData:         make(map[string]*string),
+
+		tags: make(map[string]map[string]string),
+	}
+
+	for _, suffix := range []string{"a", "b", "c"} {
+		m.Zones = append(m.Zones, &ec2.AvailabilityZone{
+			ZoneName:   aws.String(region + suffix),
+			RegionName: aws.String(region),
+			State:      aws.String("available"),
+		})
+	}
+
+	return m
+}
+
+// AddImage registers an image, so that it can be found by DescribeImages.
+// If OwnerId is not set, the image is owned by MockAccountID.
+func (m *MockEC2) AddImage(image *ec2.Image) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if image.ImageId == nil {
+		image.ImageId = aws.String(m.ids.next("ami"))
+	}
+	if image.OwnerId == nil {
+		image.OwnerId = aws.String(MockAccountID)
+	}
+	m.Images = append(m.Images, image)
+}
+
+// ResourceIDs returns the ids of all the (non-terminated) resources; it is used to check that deletion was complete
+func (m *MockEC2) ResourceIDs() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var ids []string
+	for id := range m.Vpcs {
+		ids = append(ids, id)
+	}
+	for id := range m.Subnets {
+		ids = append(ids, id)
+	}
+	for id := range m.SecurityGroups {
+		ids = append(ids, id)
+	}
+	for id := range m.InternetGateways {
+		ids = append(ids, id)
+	}
+	for id := range m.RouteTables {
+		ids = append(ids, id)
+	}
+	for id := range m.DhcpOptions {
+		ids = append(ids, id)
+	}
+	for id := range m.Volumes {
+		ids = append(ids, id)
+	}
+	for id := range m.Addresses {
+		ids = append(ids, id)
+	}
+	for id, instance := range m.Instances {
+		if aws.StringValue(instance.State.Name) == "terminated" {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (m *MockEC2) DescribeAvailabilityZones(request *ec2.DescribeAvailabilityZonesInput) (*ec2.DescribeAvailabilityZonesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return &ec2.DescribeAvailabilityZonesOutput{AvailabilityZones: m.Zones}, nil
+}
+
+func (m *MockEC2) DescribeImages(request *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeImagesOutput{}
+	for _, image := range m.Images {
+		if len(request.ImageIds) != 0 && !containsString(request.ImageIds, aws.StringValue(image.ImageId)) {
+			continue
+		}
+		if len(request.Owners) != 0 {
+			match := false
+			for _, owner := range request.Owners {
+				o := aws.StringValue(owner)
+				if o == "self" {
+					o = MockAccountID
+				}
+				if o == aws.StringValue(image.OwnerId) {
+					match = true
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+
+		attributes := map[string][]string{
+			"image-id": {aws.StringValue(image.ImageId)},
+			"name":     {aws.StringValue(image.Name)},
+			"owner-id": {aws.StringValue(image.OwnerId)},
+		}
+		match, err := matchFilters(request.Filters, nil, attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.Images = append(response.Images, image)
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) ImportKeyPair(request *ec2.ImportKeyPairInput) (*ec2.ImportKeyPairOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.KeyName)
+	if m.KeyPairs[name] != nil {
+		return nil, alreadyExists("InvalidKeyPair.Duplicate", name)
+	}
+
+	fingerprint, err := awsup.ComputeAWSKeyFingerprint(string(request.PublicKeyMaterial))
+	if err != nil {
+		return nil, awserr.New("InvalidKey.Format", err.Error(), nil)
+	}
+
+	m.KeyPairs[name] = &ec2.KeyPairInfo{
+		KeyName:        aws.String(name),
+		KeyFingerprint: aws.String(fingerprint),
+	}
+
+	return &ec2.ImportKeyPairOutput{
+		KeyName:        aws.String(name),
+		KeyFingerprint: aws.String(fingerprint),
+	}, nil
+}
+
+func (m *MockEC2) DescribeKeyPairs(request *ec2.DescribeKeyPairsInput) (*ec2.DescribeKeyPairsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeKeyPairsOutput{}
+	if len(request.KeyNames) != 0 {
+		for _, name := range request.KeyNames {
+			k := m.KeyPairs[aws.StringValue(name)]
+			if k == nil {
+				return nil, awserr.New("InvalidKeyPair.NotFound", fmt.Sprintf("The key pair '%s' does not exist", aws.StringValue(name)), nil)
+			}
+			response.KeyPairs = append(response.KeyPairs, k)
+		}
+		return response, nil
+	}
+
+	for _, name := range sortedKeys(m.KeyPairs) {
+		response.KeyPairs = append(response.KeyPairs, m.KeyPairs[name])
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteKeyPair(request *ec2.DeleteKeyPairInput) (*ec2.DeleteKeyPairOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Like AWS, deleting a key pair that does not exist is not an error
+	delete(m.KeyPairs, aws.StringValue(request.KeyName))
+	return &ec2.DeleteKeyPairOutput{}, nil
+}
+
+// resourceType returns the type of the resource with the specified id, as reported by DescribeTags
+func (m *MockEC2) resourceType(id string) string {
+	if m.Vpcs[id] != nil {
+		return "vpc"
+	}
+	if m.Subnets[id] != nil {
+		return "subnet"
+	}
+	if m.SecurityGroups[id] != nil {
+		return "security-group"
+	}
+	if m.InternetGateways[id] != nil {
+		return "internet-gateway"
+	}
+	if m.RouteTables[id] != nil {
+		return "route-table"
+	}
+	if m.DhcpOptions[id] != nil {
+		return "dhcp-options"
+	}
+	if m.Volumes[id] != nil {
+		return "volume"
+	}
+	if m.Instances[id] != nil {
+		return "instance"
+	}
+	return ""
+}
+
+func (m *MockEC2) CreateTags(request *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, resource := range request.Resources {
+		id := aws.StringValue(resource)
+		if m.resourceType(id) == "" {
+			// We deliberately don't return a *.NotFound code, as those are retried as eventual-consistency errors
+			return nil, awserr.New("InvalidID", fmt.Sprintf("The ID '%s' is not valid", id), nil)
+		}
+		tags := m.tags[id]
+		if tags == nil {
+			tags = make(map[string]string)
+			m.tags[id] = tags
+		}
+		for _, tag := range request.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (m *MockEC2) DeleteTags(request *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, resource := range request.Resources {
+		tags := m.tags[aws.StringValue(resource)]
+		for _, tag := range request.Tags {
+			k := aws.StringValue(tag.Key)
+			if tag.Value != nil && aws.StringValue(tag.Value) != tags[k] {
+				continue
+			}
+			delete(tags, k)
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+func (m *MockEC2) DescribeTags(request *ec2.DescribeTagsInput) (*ec2.DescribeTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &ec2.DescribeTagsOutput{}
+	for _, id := range sortedKeys(m.tags) {
+		resourceType := m.resourceType(id)
+		for _, k := range sortedKeys(m.tags[id]) {
+			v := m.tags[id][k]
+			attributes := map[string][]string{
+				"resource-id":   {id},
+				"resource-type": {resourceType},
+				"key":           {k},
+				"value":         {v},
+			}
+			match, err := matchFilters(request.Filters, nil, attributes)
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				continue
+			}
+			response.Tags = append(response.Tags, &ec2.TagDescription{
+				ResourceId:   aws.String(id),
+				ResourceType: aws.String(resourceType),
+				Key:          aws.String(k),
+				Value:        aws.String(v),
+			})
+		}
+	}
+	return response, nil
+}
+
+// ec2Tags returns the tags for the resource in the form that EC2 returns them
+func (m *MockEC2) ec2Tags(id string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for _, k := range sortedKeys(m.tags[id]) {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(m.tags[id][k])})
+	}
+	return tags
+}
+
+// matchFilters returns true if the resource matches all the filters.
+// Filters named tag:<key> are matched against tags; any other filter must be one of the attributes we know for the resource.
+func matchFilters(filters []*ec2.Filter, tags map[string]string, attributes map[string][]string) (bool, error) {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+
+		var actual []string
+		if strings.HasPrefix(name, "tag:") {
+			v, found := tags[strings.TrimPrefix(name, "tag:")]
+			if !found {
+				return false, nil
+			}
+			actual = []string{v}
+		} else {
+			values, found := attributes[name]
+			if !found {
+				return false, awserr.New("InvalidParameterValue", fmt.Sprintf("filter %q is not supported by the mock", name), nil)
+			}
+			actual = values
+		}
+
+		match := false
+		for _, want := range filter.Values {
+			for _, a := range actual {
+				if aws.StringValue(want) == a {
+					match = true
+				}
+			}
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// matchIDs returns true if the list of requested ids is empty, or if it contains id
+func matchIDs(ids []*string, id string) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	return containsString(ids, id)
+}
+
+func containsString(l []*string, s string) bool {
+	for _, v := range l {
+		if aws.StringValue(v) == s {
+			return true
+		}
+	}
+	return false
+}
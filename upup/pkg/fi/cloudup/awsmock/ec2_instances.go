@@ -0,0 +1,566 @@
+package awsmock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"net"
+	"strings"
+)
+
+func (m *MockEC2) RunInstances(request *ec2.RunInstancesInput) (*ec2.Reservation, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	count := aws.Int64Value(request.MinCount)
+	if count < 1 {
+		count = 1
+	}
+
+	reservation := &ec2.Reservation{
+		ReservationId: aws.String(m.ids.next("r")),
+		OwnerId:       aws.String(MockAccountID),
+	}
+
+	for i := int64(0); i < count; i++ {
+		id := m.ids.next("i")
+
+		instance := &ec2.Instance{
+			InstanceId:   aws.String(id),
+			ImageId:      request.ImageId,
+			InstanceType: request.InstanceType,
+			KeyName:      request.KeyName,
+			State: &ec2.InstanceState{
+				Code: aws.Int64(16),
+				Name: aws.String("running"),
+			},
+		}
+
+		subnetID := request.SubnetId
+		privateIP := request.PrivateIpAddress
+		associatePublicIP := false
+		groupIDs := request.SecurityGroupIds
+		for _, ni := range request.NetworkInterfaces {
+			if ni.SubnetId != nil {
+				subnetID = ni.SubnetId
+			}
+			if ni.PrivateIpAddress != nil {
+				privateIP = ni.PrivateIpAddress
+			}
+			if aws.BoolValue(ni.AssociatePublicIpAddress) {
+				associatePublicIP = true
+			}
+			groupIDs = append(groupIDs, ni.Groups...)
+		}
+
+		if subnetID != nil {
+			subnet := m.Subnets[aws.StringValue(subnetID)]
+			if subnet == nil {
+				return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(subnetID))
+			}
+			instance.SubnetId = subnet.SubnetId
+			instance.VpcId = subnet.VpcId
+			instance.Placement = &ec2.Placement{AvailabilityZone: subnet.AvailabilityZone}
+
+			if privateIP == nil {
+				ip, err := m.allocatePrivateIP(aws.StringValue(subnet.CidrBlock))
+				if err != nil {
+					return nil, err
+				}
+				privateIP = aws.String(ip)
+			}
+		}
+		if privateIP != nil {
+			instance.PrivateIpAddress = privateIP
+			instance.PrivateDnsName = aws.String(fmt.Sprintf("ip-%s.%s.compute.internal", strings.Replace(*privateIP, ".", "-", -1), m.region))
+		}
+
+		for _, groupID := range groupIDs {
+			sg := m.SecurityGroups[aws.StringValue(groupID)]
+			if sg == nil {
+				return nil, notFound("InvalidGroup.NotFound", aws.StringValue(groupID))
+			}
+			instance.SecurityGroups = append(instance.SecurityGroups, &ec2.GroupIdentifier{
+				GroupId:   sg.GroupId,
+				GroupName: sg.GroupName,
+			})
+		}
+
+		association := &ec2.InstanceNetworkInterfaceAssociation{}
+		if associatePublicIP {
+			association.PublicIp = aws.String(m.allocatePublicIP())
+			instance.PublicIpAddress = association.PublicIp
+		}
+		instance.NetworkInterfaces = []*ec2.InstanceNetworkInterface{
+			{
+				NetworkInterfaceId: aws.String(m.ids.next("eni")),
+				PrivateIpAddress:   privateIP,
+				SubnetId:           instance.SubnetId,
+				VpcId:              instance.VpcId,
+				Association:        association,
+			},
+		}
+
+		if request.IamInstanceProfile != nil {
+			arn := aws.StringValue(request.IamInstanceProfile.Arn)
+			if arn == "" {
+				arn = "arn:aws:iam::" + MockAccountID + ":instance-profile/" + aws.StringValue(request.IamInstanceProfile.Name)
+			}
+			instance.IamInstanceProfile = &ec2.IamInstanceProfile{Arn: aws.String(arn)}
+		}
+
+		for _, bdm := range request.BlockDeviceMappings {
+			if bdm.Ebs == nil || instance.Placement == nil {
+				// Ephemeral devices are not reported in the instance BlockDeviceMappings;
+				// we only create EBS volumes for instances launched into a subnet (so we know the zone)
+				continue
+			}
+			volumeID := m.ids.next("vol")
+			m.Volumes[volumeID] = &ec2.Volume{
+				VolumeId:         aws.String(volumeID),
+				Size:             bdm.Ebs.VolumeSize,
+				VolumeType:       bdm.Ebs.VolumeType,
+				AvailabilityZone: instance.Placement.AvailabilityZone,
+				State:            aws.String("in-use"),
+			}
+			m.attachVolume(instance, volumeID, aws.StringValue(bdm.DeviceName))
+		}
+
+		m.Instances[id] = instance
+		m.userData[id] = request.UserData
+
+		reservation.Instances = append(reservation.Instances, m.describeInstance(id))
+	}
+
+	return reservation, nil
+}
+
+// allocatePrivateIP returns the next free IP in the CIDR; like AWS we skip the first 4 addresses
+func (m *MockEC2) allocatePrivateIP(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", awserr.New("InvalidParameterValue", fmt.Sprintf("invalid CIDR %q", cidr), nil)
+	}
+
+	used := make(map[string]bool)
+	for _, instance := range m.Instances {
+		used[aws.StringValue(instance.PrivateIpAddress)] = true
+	}
+
+	base := binary.BigEndian.Uint32(ipNet.IP.To4())
+	for n := uint32(4); ; n++ {
+		candidate := make(net.IP, 4)
+		binary.BigEndian.PutUint32(candidate, base+n)
+		if !ipNet.Contains(candidate) {
+			break
+		}
+		if !used[candidate.String()] {
+			return candidate.String(), nil
+		}
+	}
+	return "", awserr.New("InsufficientFreeAddressesInSubnet", fmt.Sprintf("no free addresses in %q", cidr), nil)
+}
+
+// allocatePublicIP returns a public IP for an instance or elastic IP, from the TEST-NET-3 documentation range
+func (m *MockEC2) allocatePublicIP() string {
+	m.publicIPs++
+	return fmt.Sprintf("203.0.113.%d", m.publicIPs%256)
+}
+
+func (m *MockEC2) attachVolume(instance *ec2.Instance, volumeID string, device string) {
+	instance.BlockDeviceMappings = append(instance.BlockDeviceMappings, &ec2.InstanceBlockDeviceMapping{
+		DeviceName: aws.String(device),
+		Ebs: &ec2.EbsInstanceBlockDevice{
+			VolumeId: aws.String(volumeID),
+			Status:   aws.String("attached"),
+		},
+	})
+
+	volume := m.Volumes[volumeID]
+	volume.State = aws.String("in-use")
+	volume.Attachments = []*ec2.VolumeAttachment{
+		{
+			InstanceId: instance.InstanceId,
+			VolumeId:   aws.String(volumeID),
+			Device:     aws.String(device),
+			State:      aws.String("attached"),
+		},
+	}
+}
+
+func (m *MockEC2) describeInstance(id string) *ec2.Instance {
+	c := *m.Instances[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeInstances(request *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.InstanceIds {
+		if m.Instances[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	// We return each instance in its own reservation
+	response := &ec2.DescribeInstancesOutput{}
+	for _, id := range sortedKeys(m.Instances) {
+		instance := m.Instances[id]
+		if !matchIDs(request.InstanceIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"instance-id":         {id},
+			"instance-state-name": {aws.StringValue(instance.State.Name)},
+			"subnet-id":           {aws.StringValue(instance.SubnetId)},
+			"vpc-id":              {aws.StringValue(instance.VpcId)},
+			"private-ip-address":  {aws.StringValue(instance.PrivateIpAddress)},
+			"private-dns-name":    {aws.StringValue(instance.PrivateDnsName)},
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.Reservations = append(response.Reservations, &ec2.Reservation{
+				OwnerId:   aws.String(MockAccountID),
+				Instances: []*ec2.Instance{m.describeInstance(id)},
+			})
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeInstancesPages(request *ec2.DescribeInstancesInput, fn func(*ec2.DescribeInstancesOutput, bool) bool) error {
+	response, err := m.DescribeInstances(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockEC2) DescribeInstanceAttribute(request *ec2.DescribeInstanceAttributeInput) (*ec2.DescribeInstanceAttributeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InstanceId)
+	if m.Instances[id] == nil {
+		return nil, notFound("InvalidInstanceID.NotFound", id)
+	}
+
+	response := &ec2.DescribeInstanceAttributeOutput{InstanceId: aws.String(id)}
+	switch aws.StringValue(request.Attribute) {
+	case "userData":
+		if m.userData[id] != nil {
+			response.UserData = &ec2.AttributeValue{Value: m.userData[id]}
+		}
+	default:
+		return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("unsupported attribute %q", aws.StringValue(request.Attribute)), nil)
+	}
+	return response, nil
+}
+
+func (m *MockEC2) setInstanceState(ids []*string, code int64, name string) ([]*ec2.InstanceStateChange, error) {
+	for _, id := range ids {
+		if m.Instances[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	var changes []*ec2.InstanceStateChange
+	for _, id := range ids {
+		instance := m.Instances[aws.StringValue(id)]
+		change := &ec2.InstanceStateChange{
+			InstanceId:    id,
+			PreviousState: instance.State,
+			CurrentState:  &ec2.InstanceState{Code: aws.Int64(code), Name: aws.String(name)},
+		}
+		instance.State = change.CurrentState
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func (m *MockEC2) StopInstances(request *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	changes, err := m.setInstanceState(request.InstanceIds, 80, "stopped")
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.StopInstancesOutput{StoppingInstances: changes}, nil
+}
+
+// TerminateInstances marks the instances as terminated (they remain visible, as in AWS), and detaches their volumes
+func (m *MockEC2) TerminateInstances(request *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	changes, err := m.setInstanceState(request.InstanceIds, 48, "terminated")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range request.InstanceIds {
+		instance := m.Instances[aws.StringValue(id)]
+		for _, bdm := range instance.BlockDeviceMappings {
+			if bdm.Ebs == nil {
+				continue
+			}
+			if volume := m.Volumes[aws.StringValue(bdm.Ebs.VolumeId)]; volume != nil {
+				volume.Attachments = nil
+				volume.State = aws.String("available")
+			}
+		}
+		instance.BlockDeviceMappings = nil
+	}
+	return &ec2.TerminateInstancesOutput{TerminatingInstances: changes}, nil
+}
+
+func (m *MockEC2) CreateVolume(request *ec2.CreateVolumeInput) (*ec2.Volume, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.ids.next("vol")
+	volumeType := request.VolumeType
+	if volumeType == nil {
+		volumeType = aws.String("standard")
+	}
+	m.Volumes[id] = &ec2.Volume{
+		VolumeId:         aws.String(id),
+		AvailabilityZone: request.AvailabilityZone,
+		Size:             request.Size,
+		VolumeType:       volumeType,
+		Encrypted:        aws.Bool(aws.BoolValue(request.Encrypted)),
+		Iops:             request.Iops,
+		SnapshotId:       request.SnapshotId,
+		State:            aws.String("available"),
+	}
+
+	return m.describeVolume(id), nil
+}
+
+func (m *MockEC2) describeVolume(id string) *ec2.Volume {
+	c := *m.Volumes[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeVolumes(request *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.VolumeIds {
+		if m.Volumes[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidVolume.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeVolumesOutput{}
+	for _, id := range sortedKeys(m.Volumes) {
+		volume := m.Volumes[id]
+		if !matchIDs(request.VolumeIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"volume-id":         {id},
+			"availability-zone": {aws.StringValue(volume.AvailabilityZone)},
+			"status":            {aws.StringValue(volume.State)},
+		}
+		for _, a := range volume.Attachments {
+			attributes["attachment.instance-id"] = append(attributes["attachment.instance-id"], aws.StringValue(a.InstanceId))
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.Volumes = append(response.Volumes, m.describeVolume(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeVolumesPages(request *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool) error {
+	response, err := m.DescribeVolumes(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockEC2) AttachVolume(request *ec2.AttachVolumeInput) (*ec2.VolumeAttachment, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	volumeID := aws.StringValue(request.VolumeId)
+	volume := m.Volumes[volumeID]
+	if volume == nil {
+		return nil, notFound("InvalidVolume.NotFound", volumeID)
+	}
+	instanceID := aws.StringValue(request.InstanceId)
+	instance := m.Instances[instanceID]
+	if instance == nil {
+		return nil, notFound("InvalidInstanceID.NotFound", instanceID)
+	}
+	if len(volume.Attachments) != 0 {
+		return nil, awserr.New("VolumeInUse", fmt.Sprintf("%s is already attached to an instance", volumeID), nil)
+	}
+
+	m.attachVolume(instance, volumeID, aws.StringValue(request.Device))
+	return volume.Attachments[0], nil
+}
+
+func (m *MockEC2) DetachVolume(request *ec2.DetachVolumeInput) (*ec2.VolumeAttachment, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	volumeID := aws.StringValue(request.VolumeId)
+	volume := m.Volumes[volumeID]
+	if volume == nil {
+		return nil, notFound("InvalidVolume.NotFound", volumeID)
+	}
+	if len(volume.Attachments) == 0 {
+		return nil, awserr.New("IncorrectState", fmt.Sprintf("Volume '%s' is in the 'available' state", volumeID), nil)
+	}
+
+	attachment := volume.Attachments[0]
+	if instance := m.Instances[aws.StringValue(attachment.InstanceId)]; instance != nil {
+		var kept []*ec2.InstanceBlockDeviceMapping
+		for _, bdm := range instance.BlockDeviceMappings {
+			if bdm.Ebs != nil && aws.StringValue(bdm.Ebs.VolumeId) == volumeID {
+				continue
+			}
+			kept = append(kept, bdm)
+		}
+		instance.BlockDeviceMappings = kept
+	}
+
+	volume.Attachments = nil
+	volume.State = aws.String("available")
+	attachment.State = aws.String("detached")
+	return attachment, nil
+}
+
+func (m *MockEC2) DeleteVolume(request *ec2.DeleteVolumeInput) (*ec2.DeleteVolumeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.VolumeId)
+	volume := m.Volumes[id]
+	if volume == nil {
+		return nil, notFound("InvalidVolume.NotFound", id)
+	}
+	if len(volume.Attachments) != 0 {
+		return nil, awserr.New("VolumeInUse", fmt.Sprintf("Volume %s is currently attached", id), nil)
+	}
+	delete(m.Volumes, id)
+	delete(m.tags, id)
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+func (m *MockEC2) AllocateAddress(request *ec2.AllocateAddressInput) (*ec2.AllocateAddressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.ids.next("eipalloc")
+	address := &ec2.Address{
+		AllocationId: aws.String(id),
+		PublicIp:     aws.String(m.allocatePublicIP()),
+		Domain:       request.Domain,
+	}
+	m.Addresses[id] = address
+
+	return &ec2.AllocateAddressOutput{
+		AllocationId: address.AllocationId,
+		PublicIp:     address.PublicIp,
+		Domain:       address.Domain,
+	}, nil
+}
+
+func (m *MockEC2) DescribeAddresses(request *ec2.DescribeAddressesInput) (*ec2.DescribeAddressesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.AllocationIds {
+		if m.Addresses[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidAllocationID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeAddressesOutput{}
+	for _, id := range sortedKeys(m.Addresses) {
+		address := m.Addresses[id]
+		if !matchIDs(request.AllocationIds, id) {
+			continue
+		}
+		if len(request.PublicIps) != 0 && !containsString(request.PublicIps, aws.StringValue(address.PublicIp)) {
+			continue
+		}
+		attributes := map[string][]string{
+			"allocation-id": {id},
+			"public-ip":     {aws.StringValue(address.PublicIp)},
+			"domain":        {aws.StringValue(address.Domain)},
+			"instance-id":   {aws.StringValue(address.InstanceId)},
+		}
+		match, err := matchFilters(request.Filters, nil, attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			c := *address
+			response.Addresses = append(response.Addresses, &c)
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AssociateAddress(request *ec2.AssociateAddressInput) (*ec2.AssociateAddressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.AllocationId)
+	address := m.Addresses[id]
+	if address == nil {
+		return nil, notFound("InvalidAllocationID.NotFound", id)
+	}
+	instanceID := aws.StringValue(request.InstanceId)
+	instance := m.Instances[instanceID]
+	if instance == nil {
+		return nil, notFound("InvalidInstanceID.NotFound", instanceID)
+	}
+
+	associationID := m.ids.next("eipassoc")
+	address.InstanceId = aws.String(instanceID)
+	address.AssociationId = aws.String(associationID)
+	address.PrivateIpAddress = instance.PrivateIpAddress
+	instance.PublicIpAddress = address.PublicIp
+
+	return &ec2.AssociateAddressOutput{AssociationId: aws.String(associationID)}, nil
+}
+
+func (m *MockEC2) ReleaseAddress(request *ec2.ReleaseAddressInput) (*ec2.ReleaseAddressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.AllocationId)
+	if id == "" {
+		for k, address := range m.Addresses {
+			if aws.StringValue(address.PublicIp) == aws.StringValue(request.PublicIp) {
+				id = k
+			}
+		}
+	}
+	if m.Addresses[id] == nil {
+		return nil, notFound("InvalidAllocationID.NotFound", id)
+	}
+	delete(m.Addresses, id)
+	return &ec2.ReleaseAddressOutput{}, nil
+}
@@ -0,0 +1,697 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+func (m *MockEC2) CreateVpc(request *ec2.CreateVpcInput) (*ec2.CreateVpcOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.ids.next("vpc")
+	vpc := &ec2.Vpc{
+		VpcId:     aws.String(id),
+		CidrBlock: request.CidrBlock,
+		State:     aws.String("available"),
+		IsDefault: aws.Bool(false),
+	}
+	m.Vpcs[id] = vpc
+	m.vpcAttributes[id] = &ec2.DescribeVpcAttributeOutput{
+		VpcId:              aws.String(id),
+		EnableDnsSupport:   &ec2.AttributeBooleanValue{Value: aws.Bool(true)},
+		EnableDnsHostnames: &ec2.AttributeBooleanValue{Value: aws.Bool(false)},
+	}
+
+	return &ec2.CreateVpcOutput{Vpc: m.describeVpc(id)}, nil
+}
+
+func (m *MockEC2) describeVpc(id string) *ec2.Vpc {
+	c := *m.Vpcs[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeVpcs(request *ec2.DescribeVpcsInput) (*ec2.DescribeVpcsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.VpcIds {
+		if m.Vpcs[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidVpcID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeVpcsOutput{}
+	for _, id := range sortedKeys(m.Vpcs) {
+		vpc := m.Vpcs[id]
+		if !matchIDs(request.VpcIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"vpc-id":     {id},
+			"cidr":       {aws.StringValue(vpc.CidrBlock)},
+			"state":      {aws.StringValue(vpc.State)},
+			"is-default": {fmt.Sprintf("%v", aws.BoolValue(vpc.IsDefault))},
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.Vpcs = append(response.Vpcs, m.describeVpc(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DescribeVpcAttribute(request *ec2.DescribeVpcAttributeInput) (*ec2.DescribeVpcAttributeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.VpcId)
+	attributes := m.vpcAttributes[id]
+	if attributes == nil {
+		return nil, notFound("InvalidVpcID.NotFound", id)
+	}
+
+	response := &ec2.DescribeVpcAttributeOutput{VpcId: aws.String(id)}
+	switch aws.StringValue(request.Attribute) {
+	case ec2.VpcAttributeNameEnableDnsSupport:
+		response.EnableDnsSupport = attributes.EnableDnsSupport
+	case ec2.VpcAttributeNameEnableDnsHostnames:
+		response.EnableDnsHostnames = attributes.EnableDnsHostnames
+	default:
+		return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("unknown attribute %q", aws.StringValue(request.Attribute)), nil)
+	}
+	return response, nil
+}
+
+func (m *MockEC2) ModifyVpcAttribute(request *ec2.ModifyVpcAttributeInput) (*ec2.ModifyVpcAttributeOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.VpcId)
+	attributes := m.vpcAttributes[id]
+	if attributes == nil {
+		return nil, notFound("InvalidVpcID.NotFound", id)
+	}
+
+	if request.EnableDnsSupport != nil {
+		attributes.EnableDnsSupport = &ec2.AttributeBooleanValue{Value: request.EnableDnsSupport.Value}
+	}
+	if request.EnableDnsHostnames != nil {
+		attributes.EnableDnsHostnames = &ec2.AttributeBooleanValue{Value: request.EnableDnsHostnames.Value}
+	}
+	return &ec2.ModifyVpcAttributeOutput{}, nil
+}
+
+func (m *MockEC2) DeleteVpc(request *ec2.DeleteVpcInput) (*ec2.DeleteVpcOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.VpcId)
+	if m.Vpcs[id] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", id)
+	}
+	delete(m.Vpcs, id)
+	delete(m.vpcAttributes, id)
+	delete(m.tags, id)
+	return &ec2.DeleteVpcOutput{}, nil
+}
+
+func (m *MockEC2) CreateSubnet(request *ec2.CreateSubnetInput) (*ec2.CreateSubnetOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	if m.Vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	id := m.ids.next("subnet")
+	subnet := &ec2.Subnet{
+		SubnetId:         aws.String(id),
+		VpcId:            aws.String(vpcID),
+		CidrBlock:        request.CidrBlock,
+		AvailabilityZone: request.AvailabilityZone,
+		State:            aws.String("available"),
+	}
+	m.Subnets[id] = subnet
+
+	return &ec2.CreateSubnetOutput{Subnet: m.describeSubnet(id)}, nil
+}
+
+func (m *MockEC2) describeSubnet(id string) *ec2.Subnet {
+	c := *m.Subnets[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeSubnets(request *ec2.DescribeSubnetsInput) (*ec2.DescribeSubnetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.SubnetIds {
+		if m.Subnets[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidSubnetID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeSubnetsOutput{}
+	for _, id := range sortedKeys(m.Subnets) {
+		subnet := m.Subnets[id]
+		if !matchIDs(request.SubnetIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"subnet-id":         {id},
+			"vpc-id":            {aws.StringValue(subnet.VpcId)},
+			"availability-zone": {aws.StringValue(subnet.AvailabilityZone)},
+			"cidr":              {aws.StringValue(subnet.CidrBlock)},
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.Subnets = append(response.Subnets, m.describeSubnet(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) DeleteSubnet(request *ec2.DeleteSubnetInput) (*ec2.DeleteSubnetOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.SubnetId)
+	if m.Subnets[id] == nil {
+		return nil, notFound("InvalidSubnetID.NotFound", id)
+	}
+	delete(m.Subnets, id)
+	delete(m.tags, id)
+	return &ec2.DeleteSubnetOutput{}, nil
+}
+
+func (m *MockEC2) CreateSecurityGroup(request *ec2.CreateSecurityGroupInput) (*ec2.CreateSecurityGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	if m.Vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	for _, sg := range m.SecurityGroups {
+		if aws.StringValue(sg.VpcId) == vpcID && aws.StringValue(sg.GroupName) == aws.StringValue(request.GroupName) {
+			return nil, alreadyExists("InvalidGroup.Duplicate", aws.StringValue(request.GroupName))
+		}
+	}
+
+	id := m.ids.next("sg")
+	m.SecurityGroups[id] = &ec2.SecurityGroup{
+		GroupId:     aws.String(id),
+		GroupName:   request.GroupName,
+		Description: request.Description,
+		VpcId:       aws.String(vpcID),
+		OwnerId:     aws.String(MockAccountID),
+	}
+
+	return &ec2.CreateSecurityGroupOutput{GroupId: aws.String(id)}, nil
+}
+
+func (m *MockEC2) describeSecurityGroup(id string) *ec2.SecurityGroup {
+	c := *m.SecurityGroups[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeSecurityGroups(request *ec2.DescribeSecurityGroupsInput) (*ec2.DescribeSecurityGroupsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.GroupIds {
+		if m.SecurityGroups[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidGroup.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeSecurityGroupsOutput{}
+	for _, id := range sortedKeys(m.SecurityGroups) {
+		sg := m.SecurityGroups[id]
+		if !matchIDs(request.GroupIds, id) {
+			continue
+		}
+		if len(request.GroupNames) != 0 && !containsString(request.GroupNames, aws.StringValue(sg.GroupName)) {
+			continue
+		}
+		attributes := map[string][]string{
+			"group-id":   {id},
+			"group-name": {aws.StringValue(sg.GroupName)},
+			"vpc-id":     {aws.StringValue(sg.VpcId)},
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.SecurityGroups = append(response.SecurityGroups, m.describeSecurityGroup(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AuthorizeSecurityGroupIngress(request *ec2.AuthorizeSecurityGroupIngressInput) (*ec2.AuthorizeSecurityGroupIngressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.SecurityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+	sg.IpPermissions = append(sg.IpPermissions, request.IpPermissions...)
+	return &ec2.AuthorizeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *MockEC2) AuthorizeSecurityGroupEgress(request *ec2.AuthorizeSecurityGroupEgressInput) (*ec2.AuthorizeSecurityGroupEgressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.SecurityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+	sg.IpPermissionsEgress = append(sg.IpPermissionsEgress, request.IpPermissions...)
+	return &ec2.AuthorizeSecurityGroupEgressOutput{}, nil
+}
+
+func (m *MockEC2) RevokeSecurityGroupIngress(request *ec2.RevokeSecurityGroupIngressInput) (*ec2.RevokeSecurityGroupIngressOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	sg := m.SecurityGroups[id]
+	if sg == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+
+	var kept []*ec2.IpPermission
+	for _, p := range sg.IpPermissions {
+		revoked := false
+		for _, r := range request.IpPermissions {
+			if aws.StringValue(p.IpProtocol) == aws.StringValue(r.IpProtocol) && aws.Int64Value(p.FromPort) == aws.Int64Value(r.FromPort) && aws.Int64Value(p.ToPort) == aws.Int64Value(r.ToPort) {
+				revoked = true
+			}
+		}
+		if !revoked {
+			kept = append(kept, p)
+		}
+	}
+	sg.IpPermissions = kept
+	return &ec2.RevokeSecurityGroupIngressOutput{}, nil
+}
+
+func (m *MockEC2) DeleteSecurityGroup(request *ec2.DeleteSecurityGroupInput) (*ec2.DeleteSecurityGroupOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.GroupId)
+	if m.SecurityGroups[id] == nil {
+		return nil, notFound("InvalidGroup.NotFound", id)
+	}
+	delete(m.SecurityGroups, id)
+	delete(m.tags, id)
+	return &ec2.DeleteSecurityGroupOutput{}, nil
+}
+
+func (m *MockEC2) CreateInternetGateway(request *ec2.CreateInternetGatewayInput) (*ec2.CreateInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.ids.next("igw")
+	m.InternetGateways[id] = &ec2.InternetGateway{
+		InternetGatewayId: aws.String(id),
+	}
+
+	return &ec2.CreateInternetGatewayOutput{InternetGateway: m.describeInternetGateway(id)}, nil
+}
+
+func (m *MockEC2) describeInternetGateway(id string) *ec2.InternetGateway {
+	c := *m.InternetGateways[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeInternetGateways(request *ec2.DescribeInternetGatewaysInput) (*ec2.DescribeInternetGatewaysOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.InternetGatewayIds {
+		if m.InternetGateways[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeInternetGatewaysOutput{}
+	for _, id := range sortedKeys(m.InternetGateways) {
+		igw := m.InternetGateways[id]
+		if !matchIDs(request.InternetGatewayIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"internet-gateway-id": {id},
+		}
+		for _, attachment := range igw.Attachments {
+			attributes["attachment.vpc-id"] = append(attributes["attachment.vpc-id"], aws.StringValue(attachment.VpcId))
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.InternetGateways = append(response.InternetGateways, m.describeInternetGateway(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AttachInternetGateway(request *ec2.AttachInternetGatewayInput) (*ec2.AttachInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	igw := m.InternetGateways[id]
+	if igw == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+	vpcID := aws.StringValue(request.VpcId)
+	if m.Vpcs[vpcID] == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	if len(igw.Attachments) != 0 {
+		return nil, awserr.New("Resource.AlreadyAssociated", fmt.Sprintf("resource %s is already attached", id), nil)
+	}
+
+	igw.Attachments = []*ec2.InternetGatewayAttachment{
+		{
+			VpcId: aws.String(vpcID),
+			State: aws.String("available"),
+		},
+	}
+	return &ec2.AttachInternetGatewayOutput{}, nil
+}
+
+func (m *MockEC2) DetachInternetGateway(request *ec2.DetachInternetGatewayInput) (*ec2.DetachInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	igw := m.InternetGateways[id]
+	if igw == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+
+	var kept []*ec2.InternetGatewayAttachment
+	for _, attachment := range igw.Attachments {
+		if aws.StringValue(attachment.VpcId) != aws.StringValue(request.VpcId) {
+			kept = append(kept, attachment)
+		}
+	}
+	igw.Attachments = kept
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+func (m *MockEC2) DeleteInternetGateway(request *ec2.DeleteInternetGatewayInput) (*ec2.DeleteInternetGatewayOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.InternetGatewayId)
+	if m.InternetGateways[id] == nil {
+		return nil, notFound("InvalidInternetGatewayID.NotFound", id)
+	}
+	delete(m.InternetGateways, id)
+	delete(m.tags, id)
+	return &ec2.DeleteInternetGatewayOutput{}, nil
+}
+
+func (m *MockEC2) CreateRouteTable(request *ec2.CreateRouteTableInput) (*ec2.CreateRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.Vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+
+	id := m.ids.next("rtb")
+	m.RouteTables[id] = &ec2.RouteTable{
+		RouteTableId: aws.String(id),
+		VpcId:        aws.String(vpcID),
+		Routes: []*ec2.Route{
+			{
+				DestinationCidrBlock: vpc.CidrBlock,
+				GatewayId:            aws.String("local"),
+				State:                aws.String("active"),
+				Origin:               aws.String("CreateRouteTable"),
+			},
+		},
+	}
+
+	return &ec2.CreateRouteTableOutput{RouteTable: m.describeRouteTable(id)}, nil
+}
+
+func (m *MockEC2) describeRouteTable(id string) *ec2.RouteTable {
+	c := *m.RouteTables[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeRouteTables(request *ec2.DescribeRouteTablesInput) (*ec2.DescribeRouteTablesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.RouteTableIds {
+		if m.RouteTables[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidRouteTableID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeRouteTablesOutput{}
+	for _, id := range sortedKeys(m.RouteTables) {
+		rt := m.RouteTables[id]
+		if !matchIDs(request.RouteTableIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"route-table-id": {id},
+			"vpc-id":         {aws.StringValue(rt.VpcId)},
+		}
+		for _, a := range rt.Associations {
+			attributes["association.subnet-id"] = append(attributes["association.subnet-id"], aws.StringValue(a.SubnetId))
+			attributes["association.route-table-association-id"] = append(attributes["association.route-table-association-id"], aws.StringValue(a.RouteTableAssociationId))
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.RouteTables = append(response.RouteTables, m.describeRouteTable(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) buildRoute(destinationCidrBlock, gatewayID, instanceID *string) (*ec2.Route, error) {
+	route := &ec2.Route{
+		DestinationCidrBlock: destinationCidrBlock,
+		State:                aws.String("active"),
+		Origin:               aws.String("CreateRoute"),
+	}
+	if gatewayID != nil {
+		if m.InternetGateways[aws.StringValue(gatewayID)] == nil {
+			return nil, notFound("InvalidInternetGatewayID.NotFound", aws.StringValue(gatewayID))
+		}
+		route.GatewayId = gatewayID
+	}
+	if instanceID != nil {
+		if m.Instances[aws.StringValue(instanceID)] == nil {
+			return nil, notFound("InvalidInstanceID.NotFound", aws.StringValue(instanceID))
+		}
+		route.InstanceId = instanceID
+		route.InstanceOwnerId = aws.String(MockAccountID)
+	}
+	return route, nil
+}
+
+func (m *MockEC2) CreateRoute(request *ec2.CreateRouteInput) (*ec2.CreateRouteOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.RouteTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+	for _, r := range rt.Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == aws.StringValue(request.DestinationCidrBlock) {
+			return nil, alreadyExists("RouteAlreadyExists", aws.StringValue(request.DestinationCidrBlock))
+		}
+	}
+
+	route, err := m.buildRoute(request.DestinationCidrBlock, request.GatewayId, request.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	rt.Routes = append(rt.Routes, route)
+	return &ec2.CreateRouteOutput{Return: aws.Bool(true)}, nil
+}
+
+func (m *MockEC2) ReplaceRoute(request *ec2.ReplaceRouteInput) (*ec2.ReplaceRouteOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.RouteTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+
+	route, err := m.buildRoute(request.DestinationCidrBlock, request.GatewayId, request.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range rt.Routes {
+		if aws.StringValue(r.DestinationCidrBlock) == aws.StringValue(request.DestinationCidrBlock) {
+			rt.Routes[i] = route
+			return &ec2.ReplaceRouteOutput{}, nil
+		}
+	}
+	return nil, awserr.New("InvalidParameterValue", fmt.Sprintf("no route with destination %s", aws.StringValue(request.DestinationCidrBlock)), nil)
+}
+
+func (m *MockEC2) AssociateRouteTable(request *ec2.AssociateRouteTableInput) (*ec2.AssociateRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	rt := m.RouteTables[id]
+	if rt == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+	subnetID := aws.StringValue(request.SubnetId)
+	if m.Subnets[subnetID] == nil {
+		return nil, notFound("InvalidSubnetID.NotFound", subnetID)
+	}
+
+	associationID := m.ids.next("rtbassoc")
+	rt.Associations = append(rt.Associations, &ec2.RouteTableAssociation{
+		RouteTableAssociationId: aws.String(associationID),
+		RouteTableId:            aws.String(id),
+		SubnetId:                aws.String(subnetID),
+		Main:                    aws.Bool(false),
+	})
+	return &ec2.AssociateRouteTableOutput{AssociationId: aws.String(associationID)}, nil
+}
+
+func (m *MockEC2) DeleteRouteTable(request *ec2.DeleteRouteTableInput) (*ec2.DeleteRouteTableOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.RouteTableId)
+	if m.RouteTables[id] == nil {
+		return nil, notFound("InvalidRouteTableID.NotFound", id)
+	}
+	delete(m.RouteTables, id)
+	delete(m.tags, id)
+	return &ec2.DeleteRouteTableOutput{}, nil
+}
+
+func (m *MockEC2) CreateDhcpOptions(request *ec2.CreateDhcpOptionsInput) (*ec2.CreateDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := m.ids.next("dopt")
+	o := &ec2.DhcpOptions{
+		DhcpOptionsId: aws.String(id),
+	}
+	for _, c := range request.DhcpConfigurations {
+		configuration := &ec2.DhcpConfiguration{Key: c.Key}
+		for _, v := range c.Values {
+			configuration.Values = append(configuration.Values, &ec2.AttributeValue{Value: v})
+		}
+		o.DhcpConfigurations = append(o.DhcpConfigurations, configuration)
+	}
+	m.DhcpOptions[id] = o
+
+	return &ec2.CreateDhcpOptionsOutput{DhcpOptions: m.describeDhcpOptions(id)}, nil
+}
+
+func (m *MockEC2) describeDhcpOptions(id string) *ec2.DhcpOptions {
+	c := *m.DhcpOptions[id]
+	c.Tags = m.ec2Tags(id)
+	return &c
+}
+
+func (m *MockEC2) DescribeDhcpOptions(request *ec2.DescribeDhcpOptionsInput) (*ec2.DescribeDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, id := range request.DhcpOptionsIds {
+		if m.DhcpOptions[aws.StringValue(id)] == nil {
+			return nil, notFound("InvalidDhcpOptionID.NotFound", aws.StringValue(id))
+		}
+	}
+
+	response := &ec2.DescribeDhcpOptionsOutput{}
+	for _, id := range sortedKeys(m.DhcpOptions) {
+		if !matchIDs(request.DhcpOptionsIds, id) {
+			continue
+		}
+		attributes := map[string][]string{
+			"dhcp-options-id": {id},
+		}
+		match, err := matchFilters(request.Filters, m.tags[id], attributes)
+		if err != nil {
+			return nil, err
+		}
+		if match {
+			response.DhcpOptions = append(response.DhcpOptions, m.describeDhcpOptions(id))
+		}
+	}
+	return response, nil
+}
+
+func (m *MockEC2) AssociateDhcpOptions(request *ec2.AssociateDhcpOptionsInput) (*ec2.AssociateDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vpcID := aws.StringValue(request.VpcId)
+	vpc := m.Vpcs[vpcID]
+	if vpc == nil {
+		return nil, notFound("InvalidVpcID.NotFound", vpcID)
+	}
+	id := aws.StringValue(request.DhcpOptionsId)
+	if id != "default" && m.DhcpOptions[id] == nil {
+		return nil, notFound("InvalidDhcpOptionID.NotFound", id)
+	}
+	vpc.DhcpOptionsId = aws.String(id)
+	return &ec2.AssociateDhcpOptionsOutput{}, nil
+}
+
+func (m *MockEC2) DeleteDhcpOptions(request *ec2.DeleteDhcpOptionsInput) (*ec2.DeleteDhcpOptionsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := aws.StringValue(request.DhcpOptionsId)
+	if m.DhcpOptions[id] == nil {
+		return nil, notFound("InvalidDhcpOptionID.NotFound", id)
+	}
+	delete(m.DhcpOptions, id)
+	delete(m.tags, id)
+	return &ec2.DeleteDhcpOptionsOutput{}, nil
+}
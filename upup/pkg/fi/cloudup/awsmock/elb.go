@@ -0,0 +1,203 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
+	"sync"
+	"time"
+)
+
+// MockELB is an in-memory implementation of ELB
+type MockELB struct {
+	// Embedded so that we satisfy the interface; calls we have not implemented will panic
+	elbiface.ELBAPI
+
+	mutex  sync.Mutex
+	region string
+
+	LoadBalancers map[string]*elb.LoadBalancerDescription
+	tags          map[string]map[string]string
+}
+
+var _ elbiface.ELBAPI = &MockELB{}
+
+func newMockELB(region string) *MockELB {
+	return &MockELB{
+		region:        region,
+		LoadBalancers: make(map[string]*elb.LoadBalancerDescription),
+		tags:          make(map[string]map[string]string),
+	}
+}
+
+func loadBalancerNotFound(name string) error {
+	return awserr.New("LoadBalancerNotFound", fmt.Sprintf("There is no ACTIVE Load Balancer named '%s'", name), nil)
+}
+
+func (m *MockELB) CreateLoadBalancer(request *elb.CreateLoadBalancerInput) (*elb.CreateLoadBalancerOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	if m.LoadBalancers[name] != nil {
+		return nil, awserr.New("DuplicateLoadBalancerName", fmt.Sprintf("Load Balancer named '%s' already exists", name), nil)
+	}
+
+	scheme := aws.StringValue(request.Scheme)
+	if scheme == "" {
+		scheme = "internet-facing"
+	}
+
+	dnsName := fmt.Sprintf("%s-%d.%s.elb.amazonaws.com", name, len(m.LoadBalancers)+1, m.region)
+	if scheme == "internal" {
+		dnsName = "internal-" + dnsName
+	}
+
+	lb := &elb.LoadBalancerDescription{
+		LoadBalancerName:          aws.String(name),
+		DNSName:                   aws.String(dnsName),
+		CanonicalHostedZoneName:   aws.String(dnsName),
+		CanonicalHostedZoneNameID: aws.String("Z35SXDOTRQ7X7K"),
+		Scheme:                    aws.String(scheme),
+		Subnets:                   request.Subnets,
+		SecurityGroups:            request.SecurityGroups,
+		AvailabilityZones:         request.AvailabilityZones,
+		CreatedTime:               aws.Time(time.Now()),
+		HealthCheck: &elb.HealthCheck{
+			Target:             aws.String("TCP:80"),
+			Interval:           aws.Int64(30),
+			Timeout:            aws.Int64(5),
+			HealthyThreshold:   aws.Int64(10),
+			UnhealthyThreshold: aws.Int64(2),
+		},
+	}
+	for _, listener := range request.Listeners {
+		lb.ListenerDescriptions = append(lb.ListenerDescriptions, &elb.ListenerDescription{Listener: listener})
+	}
+	m.LoadBalancers[name] = lb
+
+	tags := make(map[string]string)
+	for _, tag := range request.Tags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	m.tags[name] = tags
+
+	return &elb.CreateLoadBalancerOutput{DNSName: aws.String(dnsName)}, nil
+}
+
+func (m *MockELB) DescribeLoadBalancers(request *elb.DescribeLoadBalancersInput) (*elb.DescribeLoadBalancersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, name := range request.LoadBalancerNames {
+		if m.LoadBalancers[aws.StringValue(name)] == nil {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+	}
+
+	response := &elb.DescribeLoadBalancersOutput{}
+	for _, name := range sortedKeys(m.LoadBalancers) {
+		if !matchIDs(request.LoadBalancerNames, name) {
+			continue
+		}
+		c := *m.LoadBalancers[name]
+		response.LoadBalancerDescriptions = append(response.LoadBalancerDescriptions, &c)
+	}
+	return response, nil
+}
+
+func (m *MockELB) DescribeLoadBalancersPages(request *elb.DescribeLoadBalancersInput, fn func(*elb.DescribeLoadBalancersOutput, bool) bool) error {
+	response, err := m.DescribeLoadBalancers(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockELB) CreateLoadBalancerListeners(request *elb.CreateLoadBalancerListenersInput) (*elb.CreateLoadBalancerListenersOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	lb := m.LoadBalancers[name]
+	if lb == nil {
+		return nil, loadBalancerNotFound(name)
+	}
+
+	for _, listener := range request.Listeners {
+		replaced := false
+		for _, existing := range lb.ListenerDescriptions {
+			if aws.Int64Value(existing.Listener.LoadBalancerPort) == aws.Int64Value(listener.LoadBalancerPort) {
+				existing.Listener = listener
+				replaced = true
+			}
+		}
+		if !replaced {
+			lb.ListenerDescriptions = append(lb.ListenerDescriptions, &elb.ListenerDescription{Listener: listener})
+		}
+	}
+	return &elb.CreateLoadBalancerListenersOutput{}, nil
+}
+
+func (m *MockELB) ConfigureHealthCheck(request *elb.ConfigureHealthCheckInput) (*elb.ConfigureHealthCheckOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.LoadBalancerName)
+	lb := m.LoadBalancers[name]
+	if lb == nil {
+		return nil, loadBalancerNotFound(name)
+	}
+	lb.HealthCheck = request.HealthCheck
+	return &elb.ConfigureHealthCheckOutput{HealthCheck: request.HealthCheck}, nil
+}
+
+func (m *MockELB) DeleteLoadBalancer(request *elb.DeleteLoadBalancerInput) (*elb.DeleteLoadBalancerOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Like AWS, deleting a load balancer that does not exist succeeds
+	name := aws.StringValue(request.LoadBalancerName)
+	delete(m.LoadBalancers, name)
+	delete(m.tags, name)
+	return &elb.DeleteLoadBalancerOutput{}, nil
+}
+
+func (m *MockELB) AddTags(request *elb.AddTagsInput) (*elb.AddTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, name := range request.LoadBalancerNames {
+		if m.LoadBalancers[aws.StringValue(name)] == nil {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+	}
+	for _, name := range request.LoadBalancerNames {
+		for _, tag := range request.Tags {
+			m.tags[aws.StringValue(name)][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &elb.AddTagsOutput{}, nil
+}
+
+func (m *MockELB) DescribeTags(request *elb.DescribeTagsInput) (*elb.DescribeTagsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &elb.DescribeTagsOutput{}
+	for _, name := range request.LoadBalancerNames {
+		tags, found := m.tags[aws.StringValue(name)]
+		if !found {
+			return nil, loadBalancerNotFound(aws.StringValue(name))
+		}
+		description := &elb.TagDescription{LoadBalancerName: name}
+		for _, k := range sortedKeys(tags) {
+			description.Tags = append(description.Tags, &elb.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+		}
+		response.TagDescriptions = append(response.TagDescriptions, description)
+	}
+	return response, nil
+}
@@ -0,0 +1,333 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockIAM is an in-memory implementation of IAM.
+// Like AWS, policy documents are returned URL-encoded.
+type MockIAM struct {
+	// Embedded so that we satisfy the interface; calls we have not implemented will panic
+	iamiface.IAMAPI
+
+	mutex sync.Mutex
+	ids   idGenerator
+
+	Roles            map[string]*iam.Role
+	InstanceProfiles map[string]*iam.InstanceProfile
+	// RolePolicies is keyed by role name, then by policy name; documents are stored as passed in
+	RolePolicies map[string]map[string]string
+}
+
+var _ iamiface.IAMAPI = &MockIAM{}
+
+func newMockIAM() *MockIAM {
+	return &MockIAM{
+		Roles:            make(map[string]*iam.Role),
+		InstanceProfiles: make(map[string]*iam.InstanceProfile),
+		RolePolicies:     make(map[string]map[string]string),
+	}
+}
+
+func noSuchEntity(kind string, name string) error {
+	return awserr.New("NoSuchEntity", fmt.Sprintf("The %s with name %s cannot be found.", kind, name), nil)
+}
+
+func entityAlreadyExists(kind string, name string) error {
+	return awserr.New("EntityAlreadyExists", fmt.Sprintf("%s with name %s already exists.", kind, name), nil)
+}
+
+// newIAMID generates an id in the style of IAM (e.g. AROA00000001)
+func (m *MockIAM) newIAMID(prefix string) string {
+	return strings.ToUpper(strings.Replace(m.ids.next(prefix), "-", "", -1))
+}
+
+func (m *MockIAM) CreateRole(request *iam.CreateRoleInput) (*iam.CreateRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	if m.Roles[name] != nil {
+		return nil, entityAlreadyExists("Role", name)
+	}
+
+	path := aws.StringValue(request.Path)
+	if path == "" {
+		path = "/"
+	}
+
+	role := &iam.Role{
+		RoleId:                   aws.String(m.newIAMID("AROA")),
+		RoleName:                 aws.String(name),
+		Path:                     aws.String(path),
+		Arn:                      aws.String("arn:aws:iam::" + MockAccountID + ":role" + path + name),
+		AssumeRolePolicyDocument: aws.String(url.QueryEscape(aws.StringValue(request.AssumeRolePolicyDocument))),
+		CreateDate:               aws.Time(time.Now()),
+	}
+	m.Roles[name] = role
+
+	c := *role
+	return &iam.CreateRoleOutput{Role: &c}, nil
+}
+
+func (m *MockIAM) GetRole(request *iam.GetRoleInput) (*iam.GetRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	role := m.Roles[name]
+	if role == nil {
+		return nil, noSuchEntity("role", name)
+	}
+	c := *role
+	return &iam.GetRoleOutput{Role: &c}, nil
+}
+
+func (m *MockIAM) UpdateAssumeRolePolicy(request *iam.UpdateAssumeRolePolicyInput) (*iam.UpdateAssumeRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	role := m.Roles[name]
+	if role == nil {
+		return nil, noSuchEntity("role", name)
+	}
+	role.AssumeRolePolicyDocument = aws.String(url.QueryEscape(aws.StringValue(request.PolicyDocument)))
+	return &iam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+func (m *MockIAM) DeleteRole(request *iam.DeleteRoleInput) (*iam.DeleteRoleOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.RoleName)
+	if m.Roles[name] == nil {
+		return nil, noSuchEntity("role", name)
+	}
+	if len(m.RolePolicies[name]) != 0 {
+		return nil, awserr.New("DeleteConflict", "Cannot delete entity, must delete policies first.", nil)
+	}
+	for _, ip := range m.InstanceProfiles {
+		for _, r := range ip.Roles {
+			if aws.StringValue(r.RoleName) == name {
+				return nil, awserr.New("DeleteConflict", "Cannot delete entity, must remove roles from instance profile first.", nil)
+			}
+		}
+	}
+	delete(m.Roles, name)
+	delete(m.RolePolicies, name)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func (m *MockIAM) ListRoles(request *iam.ListRolesInput) (*iam.ListRolesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &iam.ListRolesOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, name := range sortedKeys(m.Roles) {
+		c := *m.Roles[name]
+		response.Roles = append(response.Roles, &c)
+	}
+	return response, nil
+}
+
+func (m *MockIAM) ListRolesPages(request *iam.ListRolesInput, fn func(*iam.ListRolesOutput, bool) bool) error {
+	response, err := m.ListRoles(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockIAM) PutRolePolicy(request *iam.PutRolePolicyInput) (*iam.PutRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	if m.Roles[roleName] == nil {
+		return nil, noSuchEntity("role", roleName)
+	}
+	if m.RolePolicies[roleName] == nil {
+		m.RolePolicies[roleName] = make(map[string]string)
+	}
+	m.RolePolicies[roleName][aws.StringValue(request.PolicyName)] = aws.StringValue(request.PolicyDocument)
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (m *MockIAM) GetRolePolicy(request *iam.GetRolePolicyInput) (*iam.GetRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	policyName := aws.StringValue(request.PolicyName)
+	policy, found := m.RolePolicies[roleName][policyName]
+	if !found {
+		return nil, noSuchEntity("role policy", policyName)
+	}
+	return &iam.GetRolePolicyOutput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(policyName),
+		PolicyDocument: aws.String(url.QueryEscape(policy)),
+	}, nil
+}
+
+func (m *MockIAM) ListRolePolicies(request *iam.ListRolePoliciesInput) (*iam.ListRolePoliciesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	if m.Roles[roleName] == nil {
+		return nil, noSuchEntity("role", roleName)
+	}
+	response := &iam.ListRolePoliciesOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, name := range sortedKeys(m.RolePolicies[roleName]) {
+		response.PolicyNames = append(response.PolicyNames, aws.String(name))
+	}
+	return response, nil
+}
+
+func (m *MockIAM) DeleteRolePolicy(request *iam.DeleteRolePolicyInput) (*iam.DeleteRolePolicyOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	roleName := aws.StringValue(request.RoleName)
+	policyName := aws.StringValue(request.PolicyName)
+	if _, found := m.RolePolicies[roleName][policyName]; !found {
+		return nil, noSuchEntity("role policy", policyName)
+	}
+	delete(m.RolePolicies[roleName], policyName)
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (m *MockIAM) CreateInstanceProfile(request *iam.CreateInstanceProfileInput) (*iam.CreateInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	if m.InstanceProfiles[name] != nil {
+		return nil, entityAlreadyExists("Instance Profile", name)
+	}
+
+	path := aws.StringValue(request.Path)
+	if path == "" {
+		path = "/"
+	}
+
+	ip := &iam.InstanceProfile{
+		InstanceProfileId:   aws.String(m.newIAMID("AIPA")),
+		InstanceProfileName: aws.String(name),
+		Path:                aws.String(path),
+		Arn:                 aws.String("arn:aws:iam::" + MockAccountID + ":instance-profile" + path + name),
+		CreateDate:          aws.Time(time.Now()),
+	}
+	m.InstanceProfiles[name] = ip
+
+	c := *ip
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: &c}, nil
+}
+
+func (m *MockIAM) GetInstanceProfile(request *iam.GetInstanceProfileInput) (*iam.GetInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.InstanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	c := *ip
+	return &iam.GetInstanceProfileOutput{InstanceProfile: &c}, nil
+}
+
+func (m *MockIAM) ListInstanceProfiles(request *iam.ListInstanceProfilesInput) (*iam.ListInstanceProfilesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &iam.ListInstanceProfilesOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, name := range sortedKeys(m.InstanceProfiles) {
+		c := *m.InstanceProfiles[name]
+		response.InstanceProfiles = append(response.InstanceProfiles, &c)
+	}
+	return response, nil
+}
+
+func (m *MockIAM) ListInstanceProfilesPages(request *iam.ListInstanceProfilesInput, fn func(*iam.ListInstanceProfilesOutput, bool) bool) error {
+	response, err := m.ListInstanceProfiles(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockIAM) AddRoleToInstanceProfile(request *iam.AddRoleToInstanceProfileInput) (*iam.AddRoleToInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.InstanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	roleName := aws.StringValue(request.RoleName)
+	role := m.Roles[roleName]
+	if role == nil {
+		return nil, noSuchEntity("role", roleName)
+	}
+	if len(ip.Roles) != 0 {
+		return nil, awserr.New("LimitExceeded", "Cannot exceed quota for InstanceSessionsPerInstanceProfile: 1", nil)
+	}
+	c := *role
+	ip.Roles = append(ip.Roles, &c)
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (m *MockIAM) RemoveRoleFromInstanceProfile(request *iam.RemoveRoleFromInstanceProfileInput) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.InstanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	roleName := aws.StringValue(request.RoleName)
+	for i, r := range ip.Roles {
+		if aws.StringValue(r.RoleName) == roleName {
+			ip.Roles = append(ip.Roles[:i], ip.Roles[i+1:]...)
+			return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+		}
+	}
+	return nil, noSuchEntity("role", roleName)
+}
+
+func (m *MockIAM) DeleteInstanceProfile(request *iam.DeleteInstanceProfileInput) (*iam.DeleteInstanceProfileOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := aws.StringValue(request.InstanceProfileName)
+	ip := m.InstanceProfiles[name]
+	if ip == nil {
+		return nil, noSuchEntity("instance profile", name)
+	}
+	if len(ip.Roles) != 0 {
+		return nil, awserr.New("DeleteConflict", "Cannot delete entity, must remove roles from instance profile first.", nil)
+	}
+	delete(m.InstanceProfiles, name)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
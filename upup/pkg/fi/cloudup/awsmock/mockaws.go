@@ -0,0 +1,70 @@
+// Package awsmock implements in-memory versions of the AWS services used by kops,
+// so that the awstasks (and the commands built on them) can be exercised without an AWS account.
+//
+// Only the calls that kops makes are implemented; the AWS interfaces are embedded,
+// so calling anything else will panic (which is the signal to implement it here).
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"reflect"
+	"sort"
+)
+
+// MockAWS holds the mock implementation of each of the AWS services
+type MockAWS struct {
+	EC2         *MockEC2
+	IAM         *MockIAM
+	ELB         *MockELB
+	Autoscaling *MockAutoscaling
+	Route53     *MockRoute53
+
+	Region string
+}
+
+// NewMockAWS builds an empty mock AWS, with availability zones a, b & c in the specified region
+func NewMockAWS(region string) *MockAWS {
+	return &MockAWS{
+		EC2:         newMockEC2(region),
+		IAM:         newMockIAM(),
+		ELB:         newMockELB(region),
+		Autoscaling: newMockAutoscaling(),
+		Route53:     newMockRoute53(),
+		Region:      region,
+	}
+}
+
+// BuildCloud returns an AWSCloud that uses the mock services
+func (m *MockAWS) BuildCloud(tags map[string]string) *awsup.AWSCloud {
+	return awsup.NewAWSCloudWithClients(m.Region, tags, m.EC2, m.IAM, m.ELB, m.Autoscaling, m.Route53)
+}
+
+// idGenerator generates ids that look like AWS ids (e.g. vpc-00000001)
+type idGenerator struct {
+	last int
+}
+
+func (g *idGenerator) next(prefix string) string {
+	g.last++
+	return fmt.Sprintf("%s-%08x", prefix, g.last)
+}
+
+func notFound(code string, id string) error {
+	return awserr.New(code, fmt.Sprintf("The ID '%s' does not exist", id), nil)
+}
+
+func alreadyExists(code string, id string) error {
+	return awserr.New(code, fmt.Sprintf("The resource '%s' already exists", id), nil)
+}
+
+// sortedKeys returns the keys of a map[string]..., in sorted order, so that we return results in a stable order
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
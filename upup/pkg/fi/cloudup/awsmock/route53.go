@@ -0,0 +1,274 @@
+package awsmock
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MockRoute53 is an in-memory implementation of Route53
+type MockRoute53 struct {
+	// Embedded so that we satisfy the interface; calls we have not implemented will panic
+	route53iface.Route53API
+
+	mutex sync.Mutex
+	ids   idGenerator
+
+	// Zones is keyed by the zone id (without the /hostedzone/ prefix)
+	Zones map[string]*route53.HostedZone
+	// Records is keyed by the zone id, then by "<name>/<type>"
+	Records map[string]map[string]*route53.ResourceRecordSet
+}
+
+var _ route53iface.Route53API = &MockRoute53{}
+
+func newMockRoute53() *MockRoute53 {
+	return &MockRoute53{
+		Zones:   make(map[string]*route53.HostedZone),
+		Records: make(map[string]map[string]*route53.ResourceRecordSet),
+	}
+}
+
+// normalizeZoneID accepts both Z1234 and /hostedzone/Z1234, as AWS does
+func normalizeZoneID(id string) string {
+	return strings.TrimPrefix(id, "/hostedzone/")
+}
+
+// ensureDot returns the name as a fully-qualified name, ending in a dot
+func ensureDot(name string) string {
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+func (m *MockRoute53) newChangeInfo() *route53.ChangeInfo {
+	return &route53.ChangeInfo{
+		Id:          aws.String("/change/" + strings.ToUpper(m.ids.next("C"))),
+		Status:      aws.String("INSYNC"),
+		SubmittedAt: aws.Time(time.Now()),
+	}
+}
+
+func (m *MockRoute53) CreateHostedZone(request *route53.CreateHostedZoneInput) (*route53.CreateHostedZoneOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	name := ensureDot(aws.StringValue(request.Name))
+	for _, z := range m.Zones {
+		if aws.StringValue(z.CallerReference) == aws.StringValue(request.CallerReference) {
+			return nil, awserr.New("HostedZoneAlreadyExists", fmt.Sprintf("A hosted zone has already been created with the specified caller reference %q", aws.StringValue(request.CallerReference)), nil)
+		}
+	}
+
+	id := strings.ToUpper(strings.Replace(m.ids.next("Z"), "-", "", -1))
+	zone := &route53.HostedZone{
+		Id:              aws.String("/hostedzone/" + id),
+		Name:            aws.String(name),
+		CallerReference: request.CallerReference,
+		Config:          request.HostedZoneConfig,
+	}
+	m.Zones[id] = zone
+
+	nameServers := []*string{
+		aws.String("ns-1.awsdns-01.com."),
+		aws.String("ns-2.awsdns-02.net."),
+		aws.String("ns-3.awsdns-03.org."),
+		aws.String("ns-4.awsdns-04.co.uk."),
+	}
+	var nsRecords []*route53.ResourceRecord
+	for _, ns := range nameServers {
+		nsRecords = append(nsRecords, &route53.ResourceRecord{Value: ns})
+	}
+	m.Records[id] = map[string]*route53.ResourceRecordSet{
+		name + "/NS": {
+			Name:            aws.String(name),
+			Type:            aws.String("NS"),
+			TTL:             aws.Int64(172800),
+			ResourceRecords: nsRecords,
+		},
+		name + "/SOA": {
+			Name: aws.String(name),
+			Type: aws.String("SOA"),
+			TTL:  aws.Int64(900),
+			ResourceRecords: []*route53.ResourceRecord{
+				{Value: aws.String("ns-1.awsdns-01.com. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400")},
+			},
+		},
+	}
+
+	return &route53.CreateHostedZoneOutput{
+		HostedZone:    zone,
+		ChangeInfo:    m.newChangeInfo(),
+		DelegationSet: &route53.DelegationSet{NameServers: nameServers},
+		Location:      aws.String("https://route53.amazonaws.com/2013-04-01/hostedzone/" + id),
+	}, nil
+}
+
+func (m *MockRoute53) zoneWithCount(id string) *route53.HostedZone {
+	c := *m.Zones[id]
+	c.ResourceRecordSetCount = aws.Int64(int64(len(m.Records[id])))
+	return &c
+}
+
+func (m *MockRoute53) ListHostedZonesByName(request *route53.ListHostedZonesByNameInput) (*route53.ListHostedZonesByNameOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// We return every zone, ordered by name; callers are expected to check the names
+	response := &route53.ListHostedZonesByNameOutput{
+		DNSName:     request.DNSName,
+		IsTruncated: aws.Bool(false),
+	}
+	byName := make(map[string]string)
+	for id, z := range m.Zones {
+		byName[aws.StringValue(z.Name)+"/"+id] = id
+	}
+	for _, k := range sortedKeys(byName) {
+		response.HostedZones = append(response.HostedZones, m.zoneWithCount(byName[k]))
+	}
+	return response, nil
+}
+
+func (m *MockRoute53) ListHostedZones(request *route53.ListHostedZonesInput) (*route53.ListHostedZonesOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	response := &route53.ListHostedZonesOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, id := range sortedKeys(m.Zones) {
+		response.HostedZones = append(response.HostedZones, m.zoneWithCount(id))
+	}
+	return response, nil
+}
+
+func (m *MockRoute53) ListHostedZonesPages(request *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool) error {
+	response, err := m.ListHostedZones(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockRoute53) GetHostedZone(request *route53.GetHostedZoneInput) (*route53.GetHostedZoneOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := normalizeZoneID(aws.StringValue(request.Id))
+	if m.Zones[id] == nil {
+		return nil, notFound("NoSuchHostedZone", id)
+	}
+	delegationSet := &route53.DelegationSet{}
+	if ns := m.Records[id][aws.StringValue(m.Zones[id].Name)+"/NS"]; ns != nil {
+		for _, rr := range ns.ResourceRecords {
+			delegationSet.NameServers = append(delegationSet.NameServers, rr.Value)
+		}
+	}
+	return &route53.GetHostedZoneOutput{
+		HostedZone:    m.zoneWithCount(id),
+		DelegationSet: delegationSet,
+	}, nil
+}
+
+func (m *MockRoute53) DeleteHostedZone(request *route53.DeleteHostedZoneInput) (*route53.DeleteHostedZoneOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := normalizeZoneID(aws.StringValue(request.Id))
+	zone := m.Zones[id]
+	if zone == nil {
+		return nil, notFound("NoSuchHostedZone", id)
+	}
+	for k := range m.Records[id] {
+		if k != aws.StringValue(zone.Name)+"/NS" && k != aws.StringValue(zone.Name)+"/SOA" {
+			return nil, awserr.New("HostedZoneNotEmpty", "The specified hosted zone contains non-required resource record sets and so cannot be deleted.", nil)
+		}
+	}
+	delete(m.Zones, id)
+	delete(m.Records, id)
+	return &route53.DeleteHostedZoneOutput{ChangeInfo: m.newChangeInfo()}, nil
+}
+
+func (m *MockRoute53) ListResourceRecordSets(request *route53.ListResourceRecordSetsInput) (*route53.ListResourceRecordSetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := normalizeZoneID(aws.StringValue(request.HostedZoneId))
+	if m.Zones[id] == nil {
+		return nil, notFound("NoSuchHostedZone", id)
+	}
+
+	response := &route53.ListResourceRecordSetsOutput{
+		IsTruncated: aws.Bool(false),
+	}
+	for _, k := range sortedKeys(m.Records[id]) {
+		c := *m.Records[id][k]
+		response.ResourceRecordSets = append(response.ResourceRecordSets, &c)
+	}
+	return response, nil
+}
+
+func (m *MockRoute53) ListResourceRecordSetsPages(request *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	response, err := m.ListResourceRecordSets(request)
+	if err != nil {
+		return err
+	}
+	fn(response, true)
+	return nil
+}
+
+func (m *MockRoute53) ChangeResourceRecordSets(request *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	id := normalizeZoneID(aws.StringValue(request.HostedZoneId))
+	if m.Zones[id] == nil {
+		return nil, notFound("NoSuchHostedZone", id)
+	}
+	if request.ChangeBatch == nil || len(request.ChangeBatch.Changes) == 0 {
+		return nil, awserr.New("InvalidInput", "ChangeBatch must contain at least one change", nil)
+	}
+
+	// Changes are applied atomically, so we validate against a copy
+	records := make(map[string]*route53.ResourceRecordSet)
+	for k, v := range m.Records[id] {
+		records[k] = v
+	}
+
+	for _, change := range request.ChangeBatch.Changes {
+		rrs := change.ResourceRecordSet
+		if rrs == nil {
+			return nil, awserr.New("InvalidInput", "ResourceRecordSet is required", nil)
+		}
+		c := *rrs
+		c.Name = aws.String(ensureDot(aws.StringValue(rrs.Name)))
+		key := aws.StringValue(c.Name) + "/" + aws.StringValue(c.Type)
+
+		switch aws.StringValue(change.Action) {
+		case "CREATE":
+			if records[key] != nil {
+				return nil, awserr.New("InvalidChangeBatch", fmt.Sprintf("Tried to create resource record set %s but it already exists", key), nil)
+			}
+			records[key] = &c
+		case "UPSERT":
+			records[key] = &c
+		case "DELETE":
+			if records[key] == nil {
+				return nil, awserr.New("InvalidChangeBatch", fmt.Sprintf("Tried to delete resource record set %s but it was not found", key), nil)
+			}
+			delete(records, key)
+		default:
+			return nil, awserr.New("InvalidInput", fmt.Sprintf("unknown action %q", aws.StringValue(change.Action)), nil)
+		}
+	}
+
+	m.Records[id] = records
+	return &route53.ChangeResourceRecordSetsOutput{ChangeInfo: m.newChangeInfo()}, nil
+}
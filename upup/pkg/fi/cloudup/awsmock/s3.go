@@ -0,0 +1,172 @@
+package awsmock
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// MockS3 is an in-memory implementation of the S3 object calls that the vfs package makes.
+// vfs.S3Path uses the concrete s3 client rather than an interface, so the mock is served over HTTP.
+type MockS3 struct {
+	mutex  sync.Mutex
+	server *httptest.Server
+
+	// Objects is keyed by bucket, then by key
+	Objects map[string]map[string][]byte
+}
+
+// NewMockS3 starts a mock S3 server; it should be stopped with Close
+func NewMockS3() *MockS3 {
+	m := &MockS3{
+		Objects: make(map[string]map[string][]byte),
+	}
+	m.server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// Close stops the mock S3 server
+func (m *MockS3) Close() {
+	m.server.Close()
+}
+
+// Client returns an S3 client that talks to the mock
+func (m *MockS3) Client() *s3.S3 {
+	config := &aws.Config{
+		Region:           aws.String("us-east-1"),
+		Endpoint:         aws.String(m.server.URL),
+		S3ForcePathStyle: aws.Bool(true),
+		Credentials:      credentials.NewStaticCredentials("mock", "mock", ""),
+	}
+	return s3.New(session.New(), config)
+}
+
+// Keys returns the keys in the bucket, in sorted order
+func (m *MockS3) Keys(bucket string) []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return sortedKeys(m.Objects[bucket])
+}
+
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string
+	Message string
+}
+
+type s3Object struct {
+	Key  string
+	ETag string
+	Size int
+}
+
+type s3ListBucketResult struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	Prefix      string
+	IsTruncated bool
+	Contents    []s3Object
+}
+
+func etag(data []byte) string {
+	hash := md5.Sum(data)
+	return "\"" + hex.EncodeToString(hash[:]) + "\""
+}
+
+func (m *MockS3) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	tokens := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	bucket := tokens[0]
+	key := ""
+	if len(tokens) == 2 {
+		key = tokens[1]
+	}
+
+	if key == "" {
+		if r.Method != "GET" {
+			writeS3Error(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s of a bucket is not implemented", r.Method))
+			return
+		}
+		m.listObjects(w, bucket, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeS3Error(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		if m.Objects[bucket] == nil {
+			m.Objects[bucket] = make(map[string][]byte)
+		}
+		m.Objects[bucket][key] = data
+		w.Header().Set("ETag", etag(data))
+		w.WriteHeader(http.StatusOK)
+
+	case "GET":
+		data, found := m.Objects[bucket][key]
+		if !found {
+			writeS3Error(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+			return
+		}
+		w.Header().Set("ETag", etag(data))
+		w.WriteHeader(http.StatusOK)
+		w.Write(data)
+
+	case "DELETE":
+		delete(m.Objects[bucket], key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeS3Error(w, http.StatusNotImplemented, "NotImplemented", fmt.Sprintf("%s of an object is not implemented", r.Method))
+	}
+}
+
+func (m *MockS3) listObjects(w http.ResponseWriter, bucket string, prefix string, delimiter string) {
+	result := &s3ListBucketResult{
+		Name:   bucket,
+		Prefix: prefix,
+	}
+	for _, key := range sortedKeys(m.Objects[bucket]) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		// We don't return CommonPrefixes, because vfs does not use them
+		if delimiter != "" && strings.Contains(strings.TrimPrefix(key, prefix), delimiter) {
+			continue
+		}
+		data := m.Objects[bucket][key]
+		result.Contents = append(result.Contents, s3Object{Key: key, ETag: etag(data), Size: len(data)})
+	}
+
+	b, err := xml.Marshal(result)
+	if err != nil {
+		writeS3Error(w, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func writeS3Error(w http.ResponseWriter, status int, code string, message string) {
+	b, _ := xml.Marshal(&s3Error{Code: code, Message: message})
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write(b)
+}
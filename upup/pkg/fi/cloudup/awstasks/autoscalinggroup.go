@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"reflect"
 	"sort"
@@ -231,6 +232,15 @@ func (_ *AutoscalingGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, c
 	}
 
 	tags := e.buildTags(t.Cloud)
+
+	role := "node"
+	if _, found := tags["k8s.io/role/master"]; found {
+		role = "master"
+	}
+	if err := t.AddOutputVariableArray(role+"_autoscaling_group_ids", e.TerraformLink()); err != nil {
+		return err
+	}
+
 	// Make sure we output in a stable order
 	var tagKeys []string
 	for k := range tags {
@@ -252,3 +262,42 @@ func (_ *AutoscalingGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, c
 func (e *AutoscalingGroup) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_autoscaling_group", *e.Name, "id")
 }
+
+type cloudformationASGTag struct {
+	Key               *string `json:"Key"`
+	Value             *string `json:"Value"`
+	PropagateAtLaunch *bool   `json:"PropagateAtLaunch"`
+}
+
+type cloudformationAutoscalingGroup struct {
+	AutoScalingGroupName    *string                   `json:"AutoScalingGroupName,omitempty"`
+	LaunchConfigurationName *cloudformation.Literal   `json:"LaunchConfigurationName,omitempty"`
+	MaxSize                 *string                   `json:"MaxSize,omitempty"`
+	MinSize                 *string                   `json:"MinSize,omitempty"`
+	VPCZoneIdentifier       []*cloudformation.Literal `json:"VPCZoneIdentifier,omitempty"`
+	Tags                    []*cloudformationASGTag   `json:"Tags,omitempty"`
+	LoadBalancerNames       []*cloudformation.Literal `json:"LoadBalancerNames,omitempty"`
+}
+
+func (_ *AutoscalingGroup) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *AutoscalingGroup) error {
+	cf := &cloudformationAutoscalingGroup{
+		AutoScalingGroupName:    e.Name,
+		MinSize:                 int64AsString(e.MinSize),
+		MaxSize:                 int64AsString(e.MaxSize),
+		LaunchConfigurationName: e.LaunchConfiguration.CloudformationLink(),
+	}
+
+	for _, s := range e.Subnets {
+		cf.VPCZoneIdentifier = append(cf.VPCZoneIdentifier, s.CloudformationLink())
+	}
+
+	for _, tag := range buildCloudformationTags(e.buildTags(t.Cloud)) {
+		cf.Tags = append(cf.Tags, &cloudformationASGTag{
+			Key:               tag.Key,
+			Value:             tag.Value,
+			PropagateAtLaunch: fi.Bool(true),
+		})
+	}
+
+	return t.RenderResource("AWS::AutoScaling::AutoScalingGroup", *e.Name, cf)
+}
@@ -0,0 +1,177 @@
+package awstasks
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"text/template"
+)
+
+const DefaultMachineTypeAWS = "t2.medium"
+const DefaultImageAWS = "282335181503/k8s-1.3-debian-jessie-amd64-hvm-ebs-2016-06-18"
+
+// awsCloudProvider is the fi.CloudProvider for AWS
+type awsCloudProvider struct {
+}
+
+var _ fi.CloudProvider = &awsCloudProvider{}
+
+func init() {
+	fi.RegisterCloudProvider(fi.CloudProviderAWS, &awsCloudProvider{})
+}
+
+func (p *awsCloudProvider) BuildCloud(clusterName string, zones []string, project string) (fi.Cloud, error) {
+	region := ""
+	for _, zone := range zones {
+		if len(zone) <= 2 {
+			return nil, fmt.Errorf("Invalid AWS zone: %q", zone)
+		}
+
+		zoneRegion := zone[:len(zone)-1]
+		if region != "" && zoneRegion != region {
+			return nil, fmt.Errorf("Clusters cannot span multiple regions")
+		}
+
+		region = zoneRegion
+	}
+
+	err := awsup.ValidateRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	cloudTags := map[string]string{awsup.TagClusterName: clusterName}
+
+	awsCloud, err := awsup.NewAWSCloud(region, cloudTags)
+	if err != nil {
+		return nil, err
+	}
+
+	err = awsCloud.ValidateZones(zones)
+	if err != nil {
+		return nil, err
+	}
+	return awsCloud, nil
+}
+
+func (p *awsCloudProvider) ModelTag() string {
+	return "_aws"
+}
+
+func (p *awsCloudProvider) TaskTypes() map[string]interface{} {
+	return map[string]interface{}{
+		// EC2
+		"elasticIP":                   &ElasticIP{},
+		"instance":                    &Instance{},
+		"instanceElasticIPAttachment": &InstanceElasticIPAttachment{},
+		"instanceVolumeAttachment":    &InstanceVolumeAttachment{},
+		"ebsVolume":                   &EBSVolume{},
+		"sshKey":                      &SSHKey{},
+
+		// IAM
+		"iamInstanceProfile":     &IAMInstanceProfile{},
+		"iamInstanceProfileRole": &IAMInstanceProfileRole{},
+		"iamRole":                &IAMRole{},
+		"iamRolePolicy":          &IAMRolePolicy{},
+
+		// VPC / Networking
+		"dhcpOptions":                &DHCPOptions{},
+		"internetGateway":            &InternetGateway{},
+		"route":                      &Route{},
+		"routeTable":                 &RouteTable{},
+		"routeTableAssociation":      &RouteTableAssociation{},
+		"securityGroup":              &SecurityGroup{},
+		"securityGroupRule":          &SecurityGroupRule{},
+		"subnet":                     &Subnet{},
+		"vpc":                        &VPC{},
+		"vpcDHDCPOptionsAssociation": &VPCDHCPOptionsAssociation{},
+
+		// ELB
+		"loadBalancer":             &LoadBalancer{},
+		"loadBalancerAttachment":   &LoadBalancerAttachment{},
+		"loadBalancerHealthChecks": &LoadBalancerHealthChecks{},
+
+		// Autoscaling
+		"autoscalingGroup":    &AutoscalingGroup{},
+		"launchConfiguration": &LaunchConfiguration{},
+
+		// Route53
+		"dnsName": &DNSName{},
+		"dnsZone": &DNSZone{},
+	}
+}
+
+func (p *awsCloudProvider) TemplateFunctions(cloud fi.Cloud) template.FuncMap {
+	return template.FuncMap{
+		"MachineTypeInfo": awsup.GetMachineTypeInfo,
+	}
+}
+
+func (p *awsCloudProvider) Region(cloud fi.Cloud) string {
+	return cloud.(*awsup.AWSCloud).Region
+}
+
+func (p *awsCloudProvider) DefaultMachineType() string {
+	return DefaultMachineTypeAWS
+}
+
+func (p *awsCloudProvider) DefaultImage() string {
+	return DefaultImageAWS
+}
+
+func (p *awsCloudProvider) NewAPITarget(cloud fi.Cloud) fi.Target {
+	return awsup.NewAWSAPITarget(cloud.(*awsup.AWSCloud))
+}
+
+func (p *awsCloudProvider) TerraformProvider(cloud fi.Cloud) (string, map[string]interface{}) {
+	config := make(map[string]interface{})
+	config["region"] = cloud.(*awsup.AWSCloud).Region
+	return "aws", config
+}
+
+func (p *awsCloudProvider) ListResources(cloud fi.Cloud, clusterName string) (map[string]*fi.ResourceTracker, error) {
+	return listResourcesAWS(cloud.(*awsup.AWSCloud), clusterName)
+}
+
+func (p *awsCloudProvider) IsDependencyViolation(err error) bool {
+	return IsDependencyViolation(err)
+}
+
+func (p *awsCloudProvider) ListInstanceGroups(cloud fi.Cloud) ([]*fi.CloudInstanceGroup, error) {
+	awsCloud := cloud.(*awsup.AWSCloud)
+
+	asgs, err := FindAutoscalingGroups(awsCloud, awsCloud.Tags())
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*fi.CloudInstanceGroup
+	for _, asg := range asgs {
+		group := &fi.CloudInstanceGroup{
+			Name: aws.StringValue(asg.AutoScalingGroupName),
+			Role: string(api.InstanceGroupRoleNode),
+			Raw:  asg,
+		}
+		if _, found := awsup.FindASGTag(asg.Tags, TagRoleMaster); found {
+			group.Role = string(api.InstanceGroupRoleMaster)
+		}
+
+		launchConfigurationName := aws.StringValue(asg.LaunchConfigurationName)
+		for _, i := range asg.Instances {
+			instance := &fi.CloudInstance{
+				ID:  aws.StringValue(i.InstanceId),
+				Raw: i,
+			}
+			if launchConfigurationName == aws.StringValue(i.LaunchConfigurationName) {
+				group.Ready = append(group.Ready, instance)
+			} else {
+				group.NeedUpdate = append(group.NeedUpdate, instance)
+			}
+		}
+
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
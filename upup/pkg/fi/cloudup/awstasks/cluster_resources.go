@@ -0,0 +1,1473 @@
+package awstasks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/golang/glog"
+	"io"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"strings"
+)
+
+// This file finds and deletes the AWS resources of a cluster, for delete cluster and rolling-update.
+
+// TagRoleMaster is the tag we set on master autoscaling groups
+const TagRoleMaster = "k8s.io/role/master"
+
+type listFn func(fi.Cloud, string) ([]*fi.ResourceTracker, error)
+
+func gunzipBytes(d []byte) ([]byte, error) {
+	var out bytes.Buffer
+	in := bytes.NewReader(d)
+	r, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, fmt.Errorf("error building gunzip reader: %v", err)
+	}
+	defer r.Close()
+	_, err = io.Copy(&out, r)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing data: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+func BuildEC2Filters(cloud fi.Cloud) []*ec2.Filter {
+	awsCloud := cloud.(*awsup.AWSCloud)
+	tags := awsCloud.Tags()
+
+	var filters []*ec2.Filter
+	for k, v := range tags {
+		filter := awsup.NewEC2Filter("tag:"+k, v)
+		filters = append(filters, filter)
+	}
+	return filters
+}
+
+// listResourcesAWS finds all the AWS resources that belong to the cluster
+func listResourcesAWS(cloud *awsup.AWSCloud, clusterName string) (map[string]*fi.ResourceTracker, error) {
+	resources := make(map[string]*fi.ResourceTracker)
+
+	listFunctions := []listFn{
+		ListSubnets, ListRouteTables, ListSecurityGroups,
+		ListInstances, ListDhcpOptions, ListInternetGateways, ListVPCs, ListVolumes,
+		// ELBs
+		ListELBs,
+		// ASG
+		ListAutoScalingGroups,
+		ListAutoScalingLaunchConfigurations,
+		// IAM
+		ListIAMRoles, ListIAMInstanceProfiles,
+		ListKeypairs,
+		ListRoute53Records,
+	}
+	for _, fn := range listFunctions {
+		trackers, err := fn(cloud, clusterName)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range trackers {
+			resources[t.Type+":"+t.ID] = t
+		}
+	}
+
+	{
+		// Gateways weren't tagged in kube-up
+		// If we are deleting the VPC, we should delete the attached gateway
+		// (no real reason not to; easy to recreate; no real state etc)
+
+		gateways, err := DescribeInternetGatewaysIgnoreTags(cloud)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, igw := range gateways {
+			for _, attachment := range igw.Attachments {
+				vpcID := aws.StringValue(attachment.VpcId)
+				igwID := aws.StringValue(igw.InternetGatewayId)
+				if vpcID == "" || igwID == "" {
+					continue
+				}
+				if resources["vpc:"+vpcID] != nil && resources["internet-gateway:"+igwID] == nil {
+					resources["internet-gateway:"+igwID] = &fi.ResourceTracker{
+						Name:    FindName(igw.Tags),
+						ID:      igwID,
+						Type:    "internet-gateway",
+						Deleter: DeleteInternetGateway,
+					}
+				}
+			}
+		}
+	}
+
+	for k, t := range resources {
+		if t.Done {
+			delete(resources, k)
+		}
+	}
+	return resources, nil
+}
+
+func matchesAsgTags(tags map[string]string, actual []*autoscaling.TagDescription) bool {
+	for k, v := range tags {
+		found := false
+		for _, a := range actual {
+			if aws.StringValue(a.Key) == k {
+				if aws.StringValue(a.Value) == v {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesElbTags(tags map[string]string, actual []*elb.Tag) bool {
+	for k, v := range tags {
+		found := false
+		for _, a := range actual {
+			if aws.StringValue(a.Key) == k {
+				if aws.StringValue(a.Value) == v {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+//type DeletableResource interface {
+//	Delete(cloud fi.Cloud) error
+//}
+
+func DeleteInstance(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := t.ID
+	glog.V(2).Infof("Deleting EC2 instance %q", id)
+	request := &ec2.TerminateInstancesInput{
+		InstanceIds: []*string{&id},
+	}
+	_, err := c.EC2.TerminateInstances(request)
+	if err != nil {
+		return fmt.Errorf("error deleting instance %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListInstances(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Querying EC2 instances")
+	request := &ec2.DescribeInstancesInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	err := c.EC2.DescribeInstancesPages(request, func(p *ec2.DescribeInstancesOutput, lastPage bool) bool {
+		for _, reservation := range p.Reservations {
+			for _, instance := range reservation.Instances {
+				id := aws.StringValue(instance.InstanceId)
+
+				if instance.State != nil {
+					stateName := aws.StringValue(instance.State.Name)
+					switch stateName {
+					case "terminated":
+						continue
+
+					case "running":
+					case "stopped":
+						// We need to delete
+						glog.V(4).Infof("instance %q has state=%q", id, stateName)
+
+					default:
+						glog.Infof("unknown instance state for %q: %q", id, stateName)
+					}
+				}
+
+				tracker := &fi.ResourceTracker{
+					Name:    FindName(instance.Tags),
+					ID:      id,
+					Type:    "instance",
+					Deleter: DeleteInstance,
+				}
+
+				var blocks []string
+				blocks = append(blocks, "vpc:"+aws.StringValue(instance.VpcId))
+
+				for _, volume := range instance.BlockDeviceMappings {
+					if volume.Ebs == nil {
+						continue
+					}
+					blocks = append(blocks, "volume:"+aws.StringValue(volume.Ebs.VolumeId))
+				}
+				for _, sg := range instance.SecurityGroups {
+					blocks = append(blocks, "security-group:"+aws.StringValue(sg.GroupId))
+				}
+				blocks = append(blocks, "subnet:"+aws.StringValue(instance.SubnetId))
+				blocks = append(blocks, "vpc:"+aws.StringValue(instance.VpcId))
+
+				tracker.Blocks = blocks
+
+				trackers = append(trackers, tracker)
+
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing instances: %v", err)
+	}
+
+	return trackers, nil
+}
+
+func DeleteSecurityGroup(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := t.ID
+	// First clear all inter-dependent rules
+	// TODO: Move to a "pre-execute" phase?
+	{
+		request := &ec2.DescribeSecurityGroupsInput{
+			GroupIds: []*string{&id},
+		}
+		response, err := c.EC2.DescribeSecurityGroups(request)
+		if err != nil {
+			return fmt.Errorf("error describing SecurityGroup %q: %v", id, err)
+		}
+
+		if len(response.SecurityGroups) == 0 {
+			return nil
+		}
+		if len(response.SecurityGroups) != 1 {
+			return fmt.Errorf("found mutiple SecurityGroups with ID %q", id)
+		}
+		sg := response.SecurityGroups[0]
+
+		if len(sg.IpPermissions) != 0 {
+			revoke := &ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       &id,
+				IpPermissions: sg.IpPermissions,
+			}
+			_, err = c.EC2.RevokeSecurityGroupIngress(revoke)
+			if err != nil {
+				return fmt.Errorf("cannot revoke ingress for ID %q: %v", id, err)
+			}
+		}
+	}
+
+	{
+		glog.V(2).Infof("Deleting EC2 SecurityGroup %q", id)
+		request := &ec2.DeleteSecurityGroupInput{
+			GroupId: &id,
+		}
+		_, err := c.EC2.DeleteSecurityGroup(request)
+		if err != nil {
+			if IsDependencyViolation(err) {
+				return err
+			}
+			return fmt.Errorf("error deleting SecurityGroup %q: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func ListSecurityGroups(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 SecurityGroups")
+	request := &ec2.DescribeSecurityGroupsInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeSecurityGroups(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing SecurityGroups: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, sg := range response.SecurityGroups {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(sg.Tags),
+			ID:      aws.StringValue(sg.GroupId),
+			Type:    "security-group",
+			Deleter: DeleteSecurityGroup,
+		}
+
+		var blocks []string
+		blocks = append(blocks, "vpc:"+aws.StringValue(sg.VpcId))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DeleteVolume(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting EC2 Volume %q", id)
+	request := &ec2.DeleteVolumeInput{
+		VolumeId: &id,
+	}
+	_, err := c.EC2.DeleteVolume(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		if AWSErrorCode(err) == "InvalidVolume.NotFound" {
+			// Concurrently deleted
+			return nil
+		}
+		return fmt.Errorf("error deleting Volume %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListVolumes(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	volumes, err := DescribeVolumes(cloud)
+	if err != nil {
+		return nil, err
+	}
+	var trackers []*fi.ResourceTracker
+
+	elasticIPs := make(map[string]bool)
+	for _, volume := range volumes {
+		id := aws.StringValue(volume.VolumeId)
+
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(volume.Tags),
+			ID:      id,
+			Type:    "volume",
+			Deleter: DeleteVolume,
+		}
+
+		var blocks []string
+		//blocks = append(blocks, "vpc:" + aws.StringValue(rt.VpcId))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+
+		// Check for an elastic IP tag
+		for _, tag := range volume.Tags {
+			name := aws.StringValue(tag.Key)
+			ip := ""
+			if name == "kubernetes.io/master-ip" {
+				ip = aws.StringValue(tag.Value)
+			}
+			if ip != "" {
+				elasticIPs[ip] = true
+			}
+		}
+
+	}
+
+	if len(elasticIPs) != 0 {
+		glog.V(2).Infof("Querying EC2 Elastic IPs")
+		request := &ec2.DescribeAddressesInput{}
+		response, err := c.EC2.DescribeAddresses(request)
+		if err != nil {
+			return nil, fmt.Errorf("error describing addresses: %v", err)
+		}
+
+		for _, address := range response.Addresses {
+			ip := aws.StringValue(address.PublicIp)
+			if !elasticIPs[ip] {
+				continue
+			}
+
+			tracker := &fi.ResourceTracker{
+				Name:    ip,
+				ID:      aws.StringValue(address.AllocationId),
+				Type:    "elastic-ip",
+				Deleter: DeleteElasticIP,
+			}
+
+			trackers = append(trackers, tracker)
+
+		}
+	}
+
+	return trackers, nil
+}
+
+func DescribeVolumes(cloud fi.Cloud) ([]*ec2.Volume, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	var volumes []*ec2.Volume
+
+	glog.V(2).Infof("Listing EC2 Volumes")
+	request := &ec2.DescribeVolumesInput{
+		Filters: BuildEC2Filters(c),
+	}
+
+	err := c.EC2.DescribeVolumesPages(request, func(p *ec2.DescribeVolumesOutput, lastPage bool) bool {
+		for _, volume := range p.Volumes {
+			volumes = append(volumes, volume)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing volumes: %v", err)
+	}
+
+	return volumes, nil
+}
+
+// AWSErrorCode returns the aws error code, if it is an awserr.Error, otherwise ""
+func AWSErrorCode(err error) string {
+	if awsError, ok := err.(awserr.Error); ok {
+		return awsError.Code()
+	}
+	return ""
+}
+
+func IsDependencyViolation(err error) bool {
+	code := AWSErrorCode(err)
+	switch code {
+	case "":
+		return false
+	case "DependencyViolation", "VolumeInUse", "InvalidIPAddress.InUse", "DeleteConflict":
+		return true
+	default:
+		glog.Infof("unexpected aws error code: %q", code)
+		return false
+	}
+}
+
+func DeleteSubnet(cloud fi.Cloud, tracker *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := tracker.ID
+
+	glog.V(2).Infof("Deleting EC2 Subnet %q", id)
+	request := &ec2.DeleteSubnetInput{
+		SubnetId: &id,
+	}
+	_, err := c.EC2.DeleteSubnet(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting Subnet %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListSubnets(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	subnets, err := DescribeSubnets(cloud)
+	if err != nil {
+		return nil, fmt.Errorf("error listing subnets: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, subnet := range subnets {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(subnet.Tags),
+			ID:      aws.StringValue(subnet.SubnetId),
+			Type:    "subnet",
+			Deleter: DeleteSubnet,
+		}
+
+		var blocks []string
+		blocks = append(blocks, "vpc:"+aws.StringValue(subnet.VpcId))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DescribeSubnets(cloud fi.Cloud) ([]*ec2.Subnet, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 subnets")
+	request := &ec2.DescribeSubnetsInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeSubnets(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing subnets: %v", err)
+	}
+
+	return response.Subnets, nil
+}
+
+func DeleteRouteTable(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting EC2 RouteTable %q", id)
+	request := &ec2.DeleteRouteTableInput{
+		RouteTableId: &id,
+	}
+	_, err := c.EC2.DeleteRouteTable(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting RouteTable %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListRouteTables(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 RouteTables")
+	request := &ec2.DescribeRouteTablesInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeRouteTables(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing RouteTables: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, rt := range response.RouteTables {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(rt.Tags),
+			ID:      aws.StringValue(rt.RouteTableId),
+			Type:    "route-table",
+			Deleter: DeleteRouteTable,
+		}
+
+		var blocks []string
+		var blocked []string
+
+		blocks = append(blocks, "vpc:"+aws.StringValue(rt.VpcId))
+
+		for _, a := range rt.Associations {
+			blocked = append(blocked, "subnet:"+aws.StringValue(a.SubnetId))
+		}
+
+		tracker.Blocks = blocks
+		tracker.Blocked = blocked
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DeleteDhcpOptions(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting EC2 DhcpOptions %q", id)
+	request := &ec2.DeleteDhcpOptionsInput{
+		DhcpOptionsId: &id,
+	}
+	_, err := c.EC2.DeleteDhcpOptions(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting DhcpOptions %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListDhcpOptions(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	dhcpOptions, err := DescribeDhcpOptions(cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, o := range dhcpOptions {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(o.Tags),
+			ID:      aws.StringValue(o.DhcpOptionsId),
+			Type:    "dhcp-options",
+			Deleter: DeleteDhcpOptions,
+		}
+
+		var blocks []string
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DescribeDhcpOptions(cloud fi.Cloud) ([]*ec2.DhcpOptions, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 DhcpOptions")
+	request := &ec2.DescribeDhcpOptionsInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeDhcpOptions(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing DhcpOptions: %v", err)
+	}
+
+	return response.DhcpOptions, nil
+}
+
+func DeleteInternetGateway(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	var igw *ec2.InternetGateway
+	{
+		request := &ec2.DescribeInternetGatewaysInput{
+			InternetGatewayIds: []*string{&id},
+		}
+		response, err := c.EC2.DescribeInternetGateways(request)
+		if err != nil {
+			if AWSErrorCode(err) == "InvalidInternetGatewayID.NotFound" {
+				glog.Infof("Internet gateway %q not found; assuming already deleted", id)
+				return nil
+			}
+
+			return fmt.Errorf("error describing InternetGateway %q: %v", id, err)
+		}
+		if response == nil || len(response.InternetGateways) == 0 {
+			return nil
+		}
+		if len(response.InternetGateways) != 1 {
+			return fmt.Errorf("found multiple InternetGateways with id %q", id)
+		}
+		igw = response.InternetGateways[0]
+	}
+
+	for _, a := range igw.Attachments {
+		glog.V(2).Infof("Detaching EC2 InternetGateway %q", id)
+		request := &ec2.DetachInternetGatewayInput{
+			InternetGatewayId: &id,
+			VpcId:             a.VpcId,
+		}
+		_, err := c.EC2.DetachInternetGateway(request)
+		if err != nil {
+			if IsDependencyViolation(err) {
+				return err
+			}
+			return fmt.Errorf("error detaching InternetGateway %q: %v", id, err)
+		}
+	}
+
+	{
+		glog.V(2).Infof("Deleting EC2 InternetGateway %q", id)
+		request := &ec2.DeleteInternetGatewayInput{
+			InternetGatewayId: &id,
+		}
+		_, err := c.EC2.DeleteInternetGateway(request)
+		if err != nil {
+			if IsDependencyViolation(err) {
+				return err
+			}
+			if AWSErrorCode(err) == "InvalidInternetGatewayID.NotFound" {
+				glog.Infof("Internet gateway %q not found; assuming already deleted", id)
+				return nil
+			}
+			return fmt.Errorf("error deleting InternetGateway %q: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+func ListInternetGateways(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	gateways, err := DescribeInternetGateways(cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, o := range gateways {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(o.Tags),
+			ID:      aws.StringValue(o.InternetGatewayId),
+			Type:    "internet-gateway",
+			Deleter: DeleteInternetGateway,
+		}
+
+		var blocks []string
+		for _, a := range o.Attachments {
+			if aws.StringValue(a.VpcId) != "" {
+				blocks = append(blocks, "vpc:"+aws.StringValue(a.VpcId))
+			}
+		}
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DescribeInternetGateways(cloud fi.Cloud) ([]*ec2.InternetGateway, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 InternetGateways")
+	request := &ec2.DescribeInternetGatewaysInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeInternetGateways(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing InternetGateway: %v", err)
+	}
+
+	var gateways []*ec2.InternetGateway
+	for _, o := range response.InternetGateways {
+		gateways = append(gateways, o)
+	}
+
+	return gateways, nil
+}
+
+// DescribeInternetGatewaysIgnoreTags returns all ec2.InternetGateways, ignoring tags
+// (gateways were not always tagged in kube-up)
+func DescribeInternetGatewaysIgnoreTags(cloud fi.Cloud) ([]*ec2.InternetGateway, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing all Internet Gateways")
+
+	request := &ec2.DescribeInternetGatewaysInput{}
+	response, err := c.EC2.DescribeInternetGateways(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing (all) InternetGateways: %v", err)
+	}
+
+	var gateways []*ec2.InternetGateway
+
+	for _, igw := range response.InternetGateways {
+		gateways = append(gateways, igw)
+	}
+
+	return gateways, nil
+}
+
+func DeleteVPC(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting EC2 VPC %q", id)
+	request := &ec2.DeleteVpcInput{
+		VpcId: &id,
+	}
+	_, err := c.EC2.DeleteVpc(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting VPC %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListVPCs(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing EC2 VPC")
+	request := &ec2.DescribeVpcsInput{
+		Filters: BuildEC2Filters(cloud),
+	}
+	response, err := c.EC2.DescribeVpcs(request)
+	if err != nil {
+		return nil, fmt.Errorf("error listing VPCs: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, v := range response.Vpcs {
+		tracker := &fi.ResourceTracker{
+			Name:    FindName(v.Tags),
+			ID:      aws.StringValue(v.VpcId),
+			Type:    "vpc",
+			Deleter: DeleteVPC,
+		}
+
+		var blocks []string
+		blocks = append(blocks, "dhcp-options:"+aws.StringValue(v.DhcpOptionsId))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DeleteAutoScalingGroup(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting autoscaling group %q", id)
+	request := &autoscaling.DeleteAutoScalingGroupInput{
+		AutoScalingGroupName: &id,
+		ForceDelete:          aws.Bool(true),
+	}
+	_, err := c.Autoscaling.DeleteAutoScalingGroup(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting autoscaling group %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListAutoScalingGroups(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	tags := c.Tags()
+
+	asgs, err := FindAutoscalingGroups(c, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var trackers []*fi.ResourceTracker
+
+	for _, asg := range asgs {
+		tracker := &fi.ResourceTracker{
+			Name:    FindASGName(asg.Tags),
+			ID:      aws.StringValue(asg.AutoScalingGroupName),
+			Type:    "autoscaling-group",
+			Deleter: DeleteAutoScalingGroup,
+		}
+
+		var blocks []string
+		subnets := aws.StringValue(asg.VPCZoneIdentifier)
+		for _, subnet := range strings.Split(subnets, ",") {
+			if subnet == "" {
+				continue
+			}
+			blocks = append(blocks, "subnet:"+subnet)
+		}
+		blocks = append(blocks, "launchconfig:"+aws.StringValue(asg.LaunchConfigurationName))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func ListAutoScalingLaunchConfigurations(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	glog.V(2).Infof("Listing all Autoscaling LaunchConfigurations for cluster %q", clusterName)
+
+	var trackers []*fi.ResourceTracker
+
+	request := &autoscaling.DescribeLaunchConfigurationsInput{}
+	err := c.Autoscaling.DescribeLaunchConfigurationsPages(request, func(p *autoscaling.DescribeLaunchConfigurationsOutput, lastPage bool) bool {
+		for _, t := range p.LaunchConfigurations {
+			if t.UserData == nil {
+				continue
+			}
+
+			b, err := base64.StdEncoding.DecodeString(aws.StringValue(t.UserData))
+			if err != nil {
+				glog.Infof("Ignoring autoscaling LaunchConfiguration with invalid UserData: %v", *t.LaunchConfigurationName)
+				continue
+			}
+
+			userData, err := UserDataToString(b)
+			if err != nil {
+				glog.Infof("Ignoring autoscaling LaunchConfiguration with invalid UserData: %v", *t.LaunchConfigurationName)
+				continue
+			}
+
+			glog.V(8).Infof("UserData: %s", string(userData))
+
+			var matchStrings []string
+
+			// TODO: reintroduce
+			//clusterLocationLine := "ClusterLocation: s3://clusters.awsdata.com/upgraded.awsdata.com/cluster.spec\n"
+			//isNodeupConfig := strings.Contains(string(userData), clusterLocationLine)
+
+			// V1
+			matchStrings = append(matchStrings, "\nINSTANCE_PREFIX: "+clusterName+"\n")
+			matchStrings = append(matchStrings, "\nINSTANCE_PREFIX: '"+clusterName+"'\n")
+
+			match := false
+			for _, m := range matchStrings {
+				if strings.Contains(string(userData), m) {
+					match = true
+				}
+			}
+
+			// kops names launch configurations <name>.<cluster>-<timestamp>
+			name := aws.StringValue(t.LaunchConfigurationName)
+			if i := strings.LastIndex(name, "-"); i != -1 && strings.HasSuffix(name[:i], "."+clusterName) {
+				match = true
+			}
+
+			if match {
+				tracker := &fi.ResourceTracker{
+					Name:    aws.StringValue(t.LaunchConfigurationName),
+					ID:      aws.StringValue(t.LaunchConfigurationName),
+					Type:    "launchconfig",
+					Deleter: DeleteAutoscalingLaunchConfiguration,
+				}
+
+				var blocks []string
+				//blocks = append(blocks, "launchconfig:" + aws.StringValue(asg.LaunchConfigurationName))
+
+				tracker.Blocks = blocks
+
+				trackers = append(trackers, tracker)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing autoscaling LaunchConfigurations: %v", err)
+	}
+
+	return trackers, nil
+}
+
+func DeleteAutoscalingLaunchConfiguration(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+	glog.V(2).Infof("Deleting autoscaling LaunchConfiguration %q", id)
+	request := &autoscaling.DeleteLaunchConfigurationInput{
+		LaunchConfigurationName: &id,
+	}
+	_, err := c.Autoscaling.DeleteLaunchConfiguration(request)
+	if err != nil {
+		return fmt.Errorf("error deleting autoscaling LaunchConfiguration %q: %v", id, err)
+	}
+	return nil
+}
+
+func DeleteELB(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := r.ID
+
+	glog.V(2).Infof("Deleting ELB %q", id)
+	request := &elb.DeleteLoadBalancerInput{
+		LoadBalancerName: &id,
+	}
+	_, err := c.ELB.DeleteLoadBalancer(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting LoadBalancer %q: %v", id, err)
+	}
+	return nil
+}
+
+func ListELBs(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	elbs, elbTags, err := DescribeELBs(cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	var trackers []*fi.ResourceTracker
+	for _, elb := range elbs {
+		id := aws.StringValue(elb.LoadBalancerName)
+		tracker := &fi.ResourceTracker{
+			Name:    FindELBName(elbTags[id]),
+			ID:      id,
+			Type:    "load-balancer",
+			Deleter: DeleteELB,
+		}
+
+		var blocks []string
+		for _, sg := range elb.SecurityGroups {
+			blocks = append(blocks, "security-group:"+aws.StringValue(sg))
+		}
+		for _, s := range elb.Subnets {
+			blocks = append(blocks, "subnet:"+aws.StringValue(s))
+		}
+		blocks = append(blocks, "vpc:"+aws.StringValue(elb.VPCId))
+
+		tracker.Blocks = blocks
+
+		trackers = append(trackers, tracker)
+	}
+
+	return trackers, nil
+}
+
+func DescribeELBs(cloud fi.Cloud) ([]*elb.LoadBalancerDescription, map[string][]*elb.Tag, error) {
+	c := cloud.(*awsup.AWSCloud)
+	tags := c.Tags()
+
+	glog.V(2).Infof("Listing all ELBs")
+
+	request := &elb.DescribeLoadBalancersInput{}
+
+	var elbs []*elb.LoadBalancerDescription
+	elbTags := make(map[string][]*elb.Tag)
+
+	var innerError error
+	err := c.ELB.DescribeLoadBalancersPages(request, func(p *elb.DescribeLoadBalancersOutput, lastPage bool) bool {
+		if len(p.LoadBalancerDescriptions) == 0 {
+			return true
+		}
+
+		tagRequest := &elb.DescribeTagsInput{}
+
+		nameToELB := make(map[string]*elb.LoadBalancerDescription)
+		for _, elb := range p.LoadBalancerDescriptions {
+			name := aws.StringValue(elb.LoadBalancerName)
+			nameToELB[name] = elb
+
+			tagRequest.LoadBalancerNames = append(tagRequest.LoadBalancerNames, elb.LoadBalancerName)
+		}
+
+		tagResponse, err := c.ELB.DescribeTags(tagRequest)
+		if err != nil {
+			innerError = fmt.Errorf("error listing elb Tags: %v", err)
+			return false
+		}
+
+		for _, t := range tagResponse.TagDescriptions {
+			elbName := aws.StringValue(t.LoadBalancerName)
+
+			if !matchesElbTags(tags, t.Tags) {
+				continue
+			}
+
+			elbTags[elbName] = t.Tags
+
+			elb := nameToELB[elbName]
+			elbs = append(elbs, elb)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error describing LoadBalancers: %v", err)
+	}
+	if innerError != nil {
+		return nil, nil, fmt.Errorf("error describing LoadBalancers: %v", innerError)
+	}
+
+	return elbs, elbTags, nil
+}
+
+// iamNames returns the names that kops gives to the IAM roles & instance profiles of the cluster
+func iamNames(clusterName string) map[string]bool {
+	return map[string]bool{
+		"masters." + clusterName: true,
+		"master." + clusterName:  true,
+		"nodes." + clusterName:   true,
+	}
+}
+
+func DeleteIAMRole(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	name := r.ID
+
+	// The inline policies must be deleted before the role
+	policies, err := c.IAM.ListRolePolicies(&iam.ListRolePoliciesInput{RoleName: aws.String(name)})
+	if err != nil {
+		return fmt.Errorf("error listing policies of IAM role %q: %v", name, err)
+	}
+	for _, policyName := range policies.PolicyNames {
+		glog.V(2).Infof("Deleting IAM role policy %q %q", name, aws.StringValue(policyName))
+		request := &iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(name),
+			PolicyName: policyName,
+		}
+		_, err := c.IAM.DeleteRolePolicy(request)
+		if err != nil {
+			return fmt.Errorf("error deleting IAM role policy %q %q: %v", name, aws.StringValue(policyName), err)
+		}
+	}
+
+	glog.V(2).Infof("Deleting IAM role %q", name)
+	request := &iam.DeleteRoleInput{
+		RoleName: aws.String(name),
+	}
+	_, err = c.IAM.DeleteRole(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting IAM role %q: %v", name, err)
+	}
+	return nil
+}
+
+func ListIAMRoles(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+	names := iamNames(clusterName)
+
+	glog.V(2).Infof("Listing IAM roles")
+	var trackers []*fi.ResourceTracker
+	err := c.IAM.ListRolesPages(&iam.ListRolesInput{}, func(p *iam.ListRolesOutput, lastPage bool) bool {
+		for _, role := range p.Roles {
+			name := aws.StringValue(role.RoleName)
+			if !names[name] {
+				continue
+			}
+			trackers = append(trackers, &fi.ResourceTracker{
+				Name: name,
+				ID:   name,
+				Type: "iam-role",
+				// The role cannot be deleted while it is in the instance profile
+				Blocked: []string{"iam-instance-profile:" + name},
+				Deleter: DeleteIAMRole,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing IAM roles: %v", err)
+	}
+	return trackers, nil
+}
+
+func DeleteIAMInstanceProfile(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	name := r.ID
+
+	// The roles must be removed before the instance profile can be deleted
+	response, err := c.IAM.GetInstanceProfile(&iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+	if err != nil {
+		return fmt.Errorf("error getting IAM instance profile %q: %v", name, err)
+	}
+	for _, role := range response.InstanceProfile.Roles {
+		glog.V(2).Infof("Removing role %q from IAM instance profile %q", aws.StringValue(role.RoleName), name)
+		request := &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(name),
+			RoleName:            role.RoleName,
+		}
+		_, err := c.IAM.RemoveRoleFromInstanceProfile(request)
+		if err != nil {
+			return fmt.Errorf("error removing role %q from IAM instance profile %q: %v", aws.StringValue(role.RoleName), name, err)
+		}
+	}
+
+	glog.V(2).Infof("Deleting IAM instance profile %q", name)
+	request := &iam.DeleteInstanceProfileInput{
+		InstanceProfileName: aws.String(name),
+	}
+	_, err = c.IAM.DeleteInstanceProfile(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting IAM instance profile %q: %v", name, err)
+	}
+	return nil
+}
+
+func ListIAMInstanceProfiles(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+	names := iamNames(clusterName)
+
+	glog.V(2).Infof("Listing IAM instance profiles")
+	var trackers []*fi.ResourceTracker
+	err := c.IAM.ListInstanceProfilesPages(&iam.ListInstanceProfilesInput{}, func(p *iam.ListInstanceProfilesOutput, lastPage bool) bool {
+		for _, profile := range p.InstanceProfiles {
+			name := aws.StringValue(profile.InstanceProfileName)
+			if !names[name] {
+				continue
+			}
+			trackers = append(trackers, &fi.ResourceTracker{
+				Name:    name,
+				ID:      name,
+				Type:    "iam-instance-profile",
+				Deleter: DeleteIAMInstanceProfile,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing IAM instance profiles: %v", err)
+	}
+	return trackers, nil
+}
+
+func DeleteKeypair(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	name := r.ID
+
+	glog.V(2).Infof("Deleting EC2 keypair %q", name)
+	request := &ec2.DeleteKeyPairInput{
+		KeyName: aws.String(name),
+	}
+	_, err := c.EC2.DeleteKeyPair(request)
+	if err != nil {
+		return fmt.Errorf("error deleting keypair %q: %v", name, err)
+	}
+	return nil
+}
+
+func ListKeypairs(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	keypairName := "kubernetes." + clusterName
+
+	glog.V(2).Infof("Listing EC2 keypairs")
+	response, err := c.EC2.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing keypairs: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+	for _, keypair := range response.KeyPairs {
+		name := aws.StringValue(keypair.KeyName)
+		if name != keypairName && !strings.HasPrefix(name, keypairName+"-") {
+			continue
+		}
+		trackers = append(trackers, &fi.ResourceTracker{
+			Name:    name,
+			ID:      name,
+			Type:    "keypair",
+			Deleter: DeleteKeypair,
+		})
+	}
+	return trackers, nil
+}
+
+func DeleteRoute53Record(cloud fi.Cloud, r *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	// The ID is <zone>/<name>/<type>; the record must be passed back exactly as it was listed
+	zoneID := strings.SplitN(r.ID, "/", 2)[0]
+	rrs := r.Obj.(*route53.ResourceRecordSet)
+
+	glog.V(2).Infof("Deleting route53 record %q", r.ID)
+	request := &route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: aws.String(zoneID),
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String("DELETE"),
+					ResourceRecordSet: rrs,
+				},
+			},
+		},
+	}
+	_, err := c.Route53.ChangeResourceRecordSets(request)
+	if err != nil {
+		return fmt.Errorf("error deleting route53 record %q: %v", r.ID, err)
+	}
+	return nil
+}
+
+// ListRoute53Records finds the records that the masters publish for the cluster: the API names (api & api.internal)
+// and the other internal names (e.g. for etcd).  Records the user created in the cluster's domain are left alone.
+func ListRoute53Records(cloud fi.Cloud, clusterName string) ([]*fi.ResourceTracker, error) {
+	c := cloud.(*awsup.AWSCloud)
+
+	clusterSuffix := "." + strings.TrimSuffix(clusterName, ".") + "."
+
+	glog.V(2).Infof("Listing route53 hosted zones")
+	var zones []*route53.HostedZone
+	err := c.Route53.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(p *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, zone := range p.HostedZones {
+			zoneName := "." + aws.StringValue(zone.Name)
+			if strings.HasSuffix(clusterSuffix, zoneName) {
+				zones = append(zones, zone)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing route53 hosted zones: %v", err)
+	}
+
+	var trackers []*fi.ResourceTracker
+	for _, zone := range zones {
+		zoneID := strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/")
+
+		glog.V(2).Infof("Listing records in route53 hosted zone %q", zoneID)
+		request := &route53.ListResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+		}
+		err := c.Route53.ListResourceRecordSetsPages(request, func(p *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+			for _, rrs := range p.ResourceRecordSets {
+				switch aws.StringValue(rrs.Type) {
+				case "A", "AAAA", "CNAME":
+				default:
+					continue
+				}
+
+				name := aws.StringValue(rrs.Name)
+				if !strings.HasSuffix(name, clusterSuffix) {
+					continue
+				}
+				prefix := strings.TrimSuffix(name, clusterSuffix)
+				if prefix != "api" && prefix != "internal" && !strings.HasSuffix(prefix, ".internal") {
+					continue
+				}
+
+				trackers = append(trackers, &fi.ResourceTracker{
+					Name:    name,
+					ID:      zoneID + "/" + name + "/" + aws.StringValue(rrs.Type),
+					Type:    "route53-record",
+					Deleter: DeleteRoute53Record,
+					Obj:     rrs,
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing records in route53 hosted zone %q: %v", zoneID, err)
+		}
+	}
+	return trackers, nil
+}
+
+func DeleteElasticIP(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*awsup.AWSCloud)
+
+	id := t.ID
+
+	glog.V(2).Infof("Releasing IP %s", t.Name)
+	request := &ec2.ReleaseAddressInput{
+		AllocationId: &id,
+	}
+	_, err := c.EC2.ReleaseAddress(request)
+	if err != nil {
+		if IsDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting elastic ip %q: %v", t.Name, err)
+	}
+	return nil
+}
+
+func FindName(tags []*ec2.Tag) string {
+	if name, found := awsup.FindEC2Tag(tags, "Name"); found {
+		return name
+	}
+	return ""
+}
+
+func FindASGName(tags []*autoscaling.TagDescription) string {
+	if name, found := awsup.FindASGTag(tags, "Name"); found {
+		return name
+	}
+	return ""
+}
+
+func FindELBName(tags []*elb.Tag) string {
+	if name, found := awsup.FindELBTag(tags, "Name"); found {
+		return name
+	}
+	return ""
+}
+
+func UserDataToString(userData []byte) (string, error) {
+	var err error
+	if len(userData) > 2 && userData[0] == 31 && userData[1] == 139 {
+		// GZIP
+		glog.V(2).Infof("gzip data detected; will decompress")
+
+		userData, err = gunzipBytes(userData)
+		if err != nil {
+			return "", fmt.Errorf("error decompressing user data: %v", err)
+		}
+	}
+	return string(userData), nil
+}
+
+// FindAutoscalingGroups finds autoscaling groups matching the specified tags
+// This isn't entirely trivial because autoscaling doesn't let us filter with as much precision as we wouldlike
+func FindAutoscalingGroups(cloud *awsup.AWSCloud, tags map[string]string) ([]*autoscaling.Group, error) {
+	var asgs []*autoscaling.Group
+
+	glog.V(2).Infof("Listing all Autoscaling groups matching cluster tags")
+	var asgNames []*string
+	{
+		var asFilters []*autoscaling.Filter
+		for _, v := range tags {
+			// Not an exact match, but likely the best we can do
+			asFilters = append(asFilters, &autoscaling.Filter{
+				Name:   aws.String("value"),
+				Values: []*string{aws.String(v)},
+			})
+		}
+		request := &autoscaling.DescribeTagsInput{
+			Filters: asFilters,
+		}
+
+		err := cloud.Autoscaling.DescribeTagsPages(request, func(p *autoscaling.DescribeTagsOutput, lastPage bool) bool {
+			for _, t := range p.Tags {
+				switch *t.ResourceType {
+				case "auto-scaling-group":
+					asgNames = append(asgNames, t.ResourceId)
+				default:
+					glog.Warningf("Unknown resource type: %v", *t.ResourceType)
+
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing autoscaling cluster tags: %v", err)
+		}
+
+	}
+
+	if len(asgNames) != 0 {
+		request := &autoscaling.DescribeAutoScalingGroupsInput{
+			AutoScalingGroupNames: asgNames,
+		}
+		err := cloud.Autoscaling.DescribeAutoScalingGroupsPages(request, func(p *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			for _, asg := range p.AutoScalingGroups {
+				if !matchesAsgTags(tags, asg.Tags) {
+					// We used an inexact filter above
+					continue
+				}
+				asgs = append(asgs, asg)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error listing autoscaling groups: %v", err)
+		}
+
+	}
+
+	return asgs, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"strings"
 )
@@ -162,3 +163,28 @@ func (_ *DHCPOptions) RenderTerraform(t *terraform.TerraformTarget, a, e, change
 func (e *DHCPOptions) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_vpc_dhcp_options", *e.Name, "id")
 }
+
+type cloudformationDHCPOptions struct {
+	DomainName        *string              `json:"DomainName,omitempty"`
+	DomainNameServers []string             `json:"DomainNameServers,omitempty"`
+	Tags              []*cloudformationTag `json:"Tags,omitempty"`
+}
+
+func (_ *DHCPOptions) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *DHCPOptions) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	cf := &cloudformationDHCPOptions{
+		DomainName: e.DomainName,
+		Tags:       buildCloudformationTags(cloud.BuildTags(e.Name)),
+	}
+
+	if e.DomainNameServers != nil {
+		cf.DomainNameServers = strings.Split(*e.DomainNameServers, ",")
+	}
+
+	return t.RenderResource("AWS::EC2::DHCPOptions", *e.Name, cf)
+}
+
+func (e *DHCPOptions) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.Ref("AWS::EC2::DHCPOptions", *e.Name)
+}
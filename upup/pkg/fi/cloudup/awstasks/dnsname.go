@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"strings"
 )
@@ -80,6 +81,13 @@ func (e *DNSName) Find(c *fi.Context) (*DNSName, error) {
 	actual.Name = e.Name
 	actual.ResourceType = e.ResourceType
 
+	if found.AliasTarget != nil && e.TargetLoadBalancer != nil {
+		aliasDNSName := strings.TrimSuffix(aws.StringValue(found.AliasTarget.DNSName), ".")
+		if aliasDNSName == strings.TrimSuffix(fi.StringValue(e.TargetLoadBalancer.DNSName), ".") {
+			actual.TargetLoadBalancer = e.TargetLoadBalancer
+		}
+	}
+
 	return actual, nil
 }
 
@@ -171,3 +179,37 @@ func (_ *DNSName) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *D
 func (e *DNSName) TerraformLink() *terraform.Literal {
 	return terraform.LiteralSelfLink("aws_route53_record", *e.Name)
 }
+
+type cloudformationRoute53Record struct {
+	Name            *string  `json:"Name"`
+	Type            *string  `json:"Type"`
+	TTL             *string  `json:"TTL,omitempty"`
+	ResourceRecords []string `json:"ResourceRecords,omitempty"`
+
+	AliasTarget  *cloudformationAlias    `json:"AliasTarget,omitempty"`
+	HostedZoneId *cloudformation.Literal `json:"HostedZoneId"`
+}
+
+type cloudformationAlias struct {
+	DNSName              *cloudformation.Literal `json:"DNSName"`
+	HostedZoneId         *cloudformation.Literal `json:"HostedZoneId"`
+	EvaluateTargetHealth *bool                   `json:"EvaluateTargetHealth"`
+}
+
+func (_ *DNSName) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *DNSName) error {
+	cf := &cloudformationRoute53Record{
+		Name:         e.Name,
+		HostedZoneId: e.Zone.CloudformationLink(),
+		Type:         e.ResourceType,
+	}
+
+	if e.TargetLoadBalancer != nil {
+		cf.AliasTarget = &cloudformationAlias{
+			DNSName:              e.TargetLoadBalancer.CloudformationAttrDNSName(),
+			EvaluateTargetHealth: aws.Bool(false),
+			HostedZoneId:         e.TargetLoadBalancer.CloudformationAttrCanonicalHostedZoneNameID(),
+		}
+	}
+
+	return t.RenderResource("AWS::Route53::RecordSet", *e.Name, cf)
+}
@@ -0,0 +1,75 @@
+package awstasks
+
+import (
+	"bytes"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsmock"
+	"testing"
+)
+
+func TestDNSNameFindAlias(t *testing.T) {
+	mock := awsmock.NewMockAWS("us-east-1")
+	response, err := mock.Route53.CreateHostedZone(&route53.CreateHostedZoneInput{
+		Name:            aws.String("example.com"),
+		CallerReference: aws.String("test"),
+	})
+	if err != nil {
+		t.Fatalf("error creating zone: %v", err)
+	}
+	zone := &DNSZone{Name: fi.String("example.com"), ID: response.HostedZone.Id}
+
+	_, err = mock.Route53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: zone.ID,
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{{
+				Action: aws.String("CREATE"),
+				ResourceRecordSet: &route53.ResourceRecordSet{
+					Name: aws.String("api.example.com"),
+					Type: aws.String("A"),
+					AliasTarget: &route53.AliasTarget{
+						DNSName:              aws.String("api-example-com-1.us-east-1.elb.amazonaws.com."),
+						HostedZoneId:         aws.String("Z35SXDOTRQ7X7K"),
+						EvaluateTargetHealth: aws.Bool(false),
+					},
+				},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("error creating record: %v", err)
+	}
+
+	var report bytes.Buffer
+	context, err := fi.NewContext(fi.NewDryRunTarget(&report), mock.BuildCloud(nil), nil, nil, true)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	defer context.Close()
+
+	for _, test := range []struct {
+		loadBalancerDNSName string
+		match               bool
+	}{
+		{"api-example-com-1.us-east-1.elb.amazonaws.com", true},
+		{"api-example-com-2.us-east-1.elb.amazonaws.com", false},
+	} {
+		e := &DNSName{
+			Name:               fi.String("api.example.com"),
+			Zone:               zone,
+			ResourceType:       fi.String("A"),
+			TargetLoadBalancer: &LoadBalancer{Name: fi.String("api.example.com"), DNSName: fi.String(test.loadBalancerDNSName)},
+		}
+		actual, err := e.Find(context)
+		if err != nil {
+			t.Fatalf("error finding record: %v", err)
+		}
+		if actual == nil {
+			t.Fatalf("expected to find record")
+		}
+		if (actual.TargetLoadBalancer == e.TargetLoadBalancer) != test.match {
+			t.Errorf("load balancer %s: expected match=%v, got TargetLoadBalancer %v", test.loadBalancerDNSName, test.match, actual.TargetLoadBalancer)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"strings"
 )
@@ -150,3 +151,42 @@ func (e *DNSZone) TerraformLink() *terraform.Literal {
 
 	return terraform.LiteralSelfLink("aws_route53_zone", *e.Name)
 }
+
+type cloudformationRoute53Zone struct {
+	Name *string `json:"Name"`
+}
+
+func (_ *DNSZone) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *DNSZone) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	// As with terraform, we reuse an existing zone rather than creating a new one,
+	// because a new zone would require reconfiguring the NS records
+	glog.Infof("Check for existing route53 zone to re-use with name %q", *e.Name)
+	z, err := e.findExisting(cloud)
+	if err != nil {
+		return err
+	}
+
+	if z != nil {
+		glog.Infof("Existing zone %q found; will configure cloudformation to reuse", aws.StringValue(z.Name))
+
+		e.ID = z.Id
+		return nil
+	}
+
+	cf := &cloudformationRoute53Zone{
+		Name: e.Name,
+	}
+
+	return t.RenderResource("AWS::Route53::HostedZone", *e.Name, cf)
+}
+
+func (e *DNSZone) CloudformationLink() *cloudformation.Literal {
+	if e.ID != nil {
+		glog.V(4).Infof("reusing existing route53 zone with id %q", *e.ID)
+		// Cloudformation expects the bare ID, not /hostedzone/<id>
+		return cloudformation.LiteralString(strings.TrimPrefix(*e.ID, "/hostedzone/"))
+	}
+
+	return cloudformation.Ref("AWS::Route53::HostedZone", *e.Name)
+}
@@ -0,0 +1,127 @@
+package awstasks
+
+import (
+	"encoding/json"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsmock"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
+	"os"
+	"path"
+	"testing"
+)
+
+type cloudformationTemplate struct {
+	Resources map[string]struct {
+		Type       string
+		Properties map[string]interface{}
+	}
+}
+
+// renderCloudformation renders the zone and a record pointing at a load balancer, returning the template
+func renderCloudformation(t *testing.T, mock *awsmock.MockAWS, zone *DNSZone) *cloudformationTemplate {
+	outDir, err := ioutil.TempDir("", "cloudformation")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	target := cloudformation.NewCloudformationTarget(mock.BuildCloud(nil), mock.Region, outDir)
+
+	if err := zone.RenderCloudformation(target, nil, zone, zone); err != nil {
+		t.Fatalf("error rendering zone: %v", err)
+	}
+
+	record := &DNSName{
+		Name:               fi.String("api.example.com"),
+		Zone:               zone,
+		ResourceType:       fi.String("A"),
+		TargetLoadBalancer: &LoadBalancer{Name: fi.String("api-example-com")},
+	}
+	if err := record.RenderCloudformation(target, nil, record, record); err != nil {
+		t.Fatalf("error rendering record: %v", err)
+	}
+
+	if err := target.Finish(nil); err != nil {
+		t.Fatalf("error from Finish: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(outDir, "kubernetes.json"))
+	if err != nil {
+		t.Fatalf("error reading output: %v", err)
+	}
+	template := &cloudformationTemplate{}
+	if err := json.Unmarshal(b, template); err != nil {
+		t.Fatalf("error parsing output: %v", err)
+	}
+	return template
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("error serializing %v: %v", v, err)
+	}
+	return string(b)
+}
+
+func TestDNSZoneRenderCloudformationNewZone(t *testing.T) {
+	mock := awsmock.NewMockAWS("us-east-1")
+
+	template := renderCloudformation(t, mock, &DNSZone{Name: fi.String("example.com")})
+
+	zone, found := template.Resources["Route53HostedZoneexamplecom"]
+	if !found || zone.Type != "AWS::Route53::HostedZone" {
+		t.Fatalf("expected hosted zone to be created, got %v", template.Resources)
+	}
+	if zone.Properties["Name"] != "example.com" {
+		t.Errorf("unexpected zone properties: %v", zone.Properties)
+	}
+
+	record := template.Resources["Route53RecordSetapiexamplecom"]
+	if record.Type != "AWS::Route53::RecordSet" {
+		t.Fatalf("expected record set, got %v", template.Resources)
+	}
+	if actual := toJSON(t, record.Properties["HostedZoneId"]); actual != `{"Ref":"Route53HostedZoneexamplecom"}` {
+		t.Errorf("unexpected HostedZoneId: %s", actual)
+	}
+	alias := toJSON(t, record.Properties["AliasTarget"])
+	expected := `{"DNSName":{"Fn::GetAtt":["ElasticLoadBalancingLoadBalancerapiexamplecom","DNSName"]},` +
+		`"EvaluateTargetHealth":false,` +
+		`"HostedZoneId":{"Fn::GetAtt":["ElasticLoadBalancingLoadBalancerapiexamplecom","CanonicalHostedZoneNameID"]}}`
+	if alias != expected {
+		t.Errorf("unexpected AliasTarget: %s", alias)
+	}
+}
+
+func TestDNSZoneRenderCloudformationExistingZone(t *testing.T) {
+	mock := awsmock.NewMockAWS("us-east-1")
+	response, err := mock.Route53.CreateHostedZone(&route53.CreateHostedZoneInput{
+		Name:            aws.String("example.com"),
+		CallerReference: aws.String("test"),
+	})
+	if err != nil {
+		t.Fatalf("error creating zone: %v", err)
+	}
+	id := aws.StringValue(response.HostedZone.Id)
+
+	zone := &DNSZone{Name: fi.String("example.com")}
+	template := renderCloudformation(t, mock, zone)
+
+	if aws.StringValue(zone.ID) != id {
+		t.Errorf("expected existing zone %q to be reused, got %q", id, aws.StringValue(zone.ID))
+	}
+	for name, r := range template.Resources {
+		if r.Type == "AWS::Route53::HostedZone" {
+			t.Errorf("unexpected hosted zone %q in output", name)
+		}
+	}
+
+	record := template.Resources["Route53RecordSetapiexamplecom"]
+	// Cloudformation expects the bare zone id, not /hostedzone/<id>
+	if actual := toJSON(t, record.Properties["HostedZoneId"]); actual != toJSON(t, id[len("/hostedzone/"):]) {
+		t.Errorf("unexpected HostedZoneId: %s (zone %s)", actual, id)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -145,3 +146,21 @@ func (_ *EBSVolume) RenderTerraform(t *terraform.TerraformTarget, a, e, changes
 func (e *EBSVolume) TerraformLink() *terraform.Literal {
 	return terraform.LiteralSelfLink("aws_ebs_volume", *e.Name)
 }
+
+type cloudformationVolume struct {
+	AvailabilityZone *string              `json:"AvailabilityZone"`
+	Size             *int64               `json:"Size"`
+	VolumeType       *string              `json:"VolumeType"`
+	Tags             []*cloudformationTag `json:"Tags,omitempty"`
+}
+
+func (_ *EBSVolume) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *EBSVolume) error {
+	cf := &cloudformationVolume{
+		AvailabilityZone: e.AvailabilityZone,
+		Size:             e.SizeGB,
+		VolumeType:       e.VolumeType,
+		Tags:             buildCloudformationTags(e.Tags),
+	}
+
+	return t.RenderResource("AWS::EC2::Volume", *e.Name, cf)
+}
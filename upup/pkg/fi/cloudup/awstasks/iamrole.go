@@ -10,6 +10,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"k8s.io/kubernetes/pkg/util/diff"
 	"net/url"
@@ -183,3 +184,40 @@ func (_ *IAMRole) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *I
 func (e *IAMRole) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_iam_role", *e.Name, "name")
 }
+
+type cloudformationIAMRole struct {
+	RoleName                 *string     `json:"RoleName"`
+	AssumeRolePolicyDocument interface{} `json:"AssumeRolePolicyDocument"`
+}
+
+func (_ *IAMRole) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *IAMRole) error {
+	policy, err := cloudformationPolicyDocument(e.RolePolicyDocument)
+	if err != nil {
+		return fmt.Errorf("error rendering RolePolicyDocument: %v", err)
+	}
+
+	cf := &cloudformationIAMRole{
+		RoleName:                 e.Name,
+		AssumeRolePolicyDocument: policy,
+	}
+
+	return t.RenderResource("AWS::IAM::Role", *e.Name, cf)
+}
+
+func (e *IAMRole) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.Ref("AWS::IAM::Role", *e.Name)
+}
+
+// cloudformationPolicyDocument parses a policy document; cloudformation embeds policies as JSON objects, not strings
+func cloudformationPolicyDocument(r fi.Resource) (interface{}, error) {
+	data, err := fi.ResourceAsBytes(r)
+	if err != nil {
+		return nil, err
+	}
+	var policy interface{}
+	err = json.Unmarshal(data, &policy)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing policy document: %v", err)
+	}
+	return policy, nil
+}
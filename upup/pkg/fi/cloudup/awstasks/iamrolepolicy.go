@@ -9,6 +9,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"k8s.io/kubernetes/pkg/util/diff"
 	"net/url"
@@ -151,3 +152,24 @@ func (_ *IAMRolePolicy) RenderTerraform(t *terraform.TerraformTarget, a, e, chan
 func (e *IAMRolePolicy) TerraformLink() *terraform.Literal {
 	return terraform.LiteralSelfLink("aws_iam_role_policy", *e.Name)
 }
+
+type cloudformationIAMRolePolicy struct {
+	PolicyName     *string                   `json:"PolicyName"`
+	Roles          []*cloudformation.Literal `json:"Roles"`
+	PolicyDocument interface{}               `json:"PolicyDocument"`
+}
+
+func (_ *IAMRolePolicy) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *IAMRolePolicy) error {
+	policy, err := cloudformationPolicyDocument(e.PolicyDocument)
+	if err != nil {
+		return fmt.Errorf("error rendering PolicyDocument: %v", err)
+	}
+
+	cf := &cloudformationIAMRolePolicy{
+		PolicyName:     e.Name,
+		Roles:          []*cloudformation.Literal{e.Role.CloudformationLink()},
+		PolicyDocument: policy,
+	}
+
+	return t.RenderResource("AWS::IAM::Policy", *e.Name, cf)
+}
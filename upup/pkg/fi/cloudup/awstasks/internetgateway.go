@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -167,3 +168,51 @@ func (e *InternetGateway) TerraformLink() *terraform.Literal {
 
 	return terraform.LiteralProperty("aws_internet_gateway", *e.Name, "id")
 }
+
+type cloudformationInternetGateway struct {
+	Tags []*cloudformationTag `json:"Tags,omitempty"`
+}
+
+type cloudformationVPCGatewayAttachment struct {
+	VpcId             *cloudformation.Literal `json:"VpcId"`
+	InternetGatewayId *cloudformation.Literal `json:"InternetGatewayId"`
+}
+
+func (_ *InternetGateway) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *InternetGateway) error {
+	shared := fi.BoolValue(e.Shared)
+	if shared {
+		// Not cloudformation owned / managed
+		return nil
+	}
+
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	cf := &cloudformationInternetGateway{
+		Tags: buildCloudformationTags(cloud.BuildTags(e.Name)),
+	}
+	err := t.RenderResource("AWS::EC2::InternetGateway", *e.Name, cf)
+	if err != nil {
+		return err
+	}
+
+	// In cloudformation, the gateway is attached to the VPC by a separate resource
+	attachment := &cloudformationVPCGatewayAttachment{
+		VpcId:             e.VPC.CloudformationLink(),
+		InternetGatewayId: e.CloudformationLink(),
+	}
+	return t.RenderResource("AWS::EC2::VPCGatewayAttachment", *e.Name, attachment)
+}
+
+func (e *InternetGateway) CloudformationLink() *cloudformation.Literal {
+	shared := fi.BoolValue(e.Shared)
+	if shared {
+		if e.ID == nil {
+			glog.Fatalf("ID must be set, if InternetGateway is shared: %s", e)
+		}
+
+		glog.V(4).Infof("reusing existing InternetGateway with id %q", *e.ID)
+		return cloudformation.LiteralString(*e.ID)
+	}
+
+	return cloudformation.Ref("AWS::EC2::InternetGateway", *e.Name)
+}
@@ -9,7 +9,9 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
+	"sort"
 	"strings"
 )
 
@@ -298,3 +300,75 @@ func (_ *LaunchConfiguration) RenderTerraform(t *terraform.TerraformTarget, a, e
 func (e *LaunchConfiguration) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_launch_configuration", *e.Name, "id")
 }
+
+type cloudformationLaunchConfiguration struct {
+	ImageId                  *string                      `json:"ImageId,omitempty"`
+	InstanceType             *string                      `json:"InstanceType,omitempty"`
+	KeyName                  *cloudformation.Literal      `json:"KeyName,omitempty"`
+	IamInstanceProfile       *cloudformation.Literal      `json:"IamInstanceProfile,omitempty"`
+	SecurityGroups           []*cloudformation.Literal    `json:"SecurityGroups,omitempty"`
+	AssociatePublicIpAddress *bool                        `json:"AssociatePublicIpAddress,omitempty"`
+	UserData                 *cloudformation.Literal      `json:"UserData,omitempty"`
+	BlockDeviceMappings      []*cloudformationBlockDevice `json:"BlockDeviceMappings,omitempty"`
+}
+
+type cloudformationBlockDevice struct {
+	DeviceName  *string `json:"DeviceName"`
+	VirtualName *string `json:"VirtualName"`
+}
+
+func (_ *LaunchConfiguration) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *LaunchConfiguration) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	if e.ImageID == nil {
+		return fi.RequiredField("ImageID")
+	}
+	image, err := cloud.ResolveImage(*e.ImageID)
+	if err != nil {
+		return err
+	}
+
+	cf := &cloudformationLaunchConfiguration{
+		ImageId:                  image.ImageId,
+		InstanceType:             e.InstanceType,
+		AssociatePublicIpAddress: e.AssociatePublicIP,
+	}
+
+	if e.SSHKey != nil {
+		cf.KeyName = e.SSHKey.CloudformationLink()
+	}
+
+	for _, sg := range e.SecurityGroups {
+		cf.SecurityGroups = append(cf.SecurityGroups, sg.CloudformationLink())
+	}
+
+	// Make sure we output in a stable order
+	var deviceNames []string
+	for deviceName := range e.BlockDeviceMappings {
+		deviceNames = append(deviceNames, deviceName)
+	}
+	sort.Strings(deviceNames)
+	for _, deviceName := range deviceNames {
+		cf.BlockDeviceMappings = append(cf.BlockDeviceMappings, &cloudformationBlockDevice{
+			DeviceName:  fi.String(deviceName),
+			VirtualName: e.BlockDeviceMappings[deviceName].VirtualName,
+		})
+	}
+
+	if e.UserData != nil {
+		userData, err := e.UserData.AsString()
+		if err != nil {
+			return fmt.Errorf("error rendering UserData: %v", err)
+		}
+		cf.UserData = cloudformation.Base64(userData)
+	}
+	if e.IAMInstanceProfile != nil {
+		cf.IamInstanceProfile = e.IAMInstanceProfile.CloudformationLink()
+	}
+
+	return t.RenderResource("AWS::AutoScaling::LaunchConfiguration", *e.Name, cf)
+}
+
+func (e *LaunchConfiguration) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.Ref("AWS::AutoScaling::LaunchConfiguration", *e.Name)
+}
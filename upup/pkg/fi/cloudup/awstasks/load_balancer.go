@@ -2,6 +2,7 @@ package awstasks
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
@@ -9,6 +10,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"strconv"
 )
 
@@ -253,3 +255,79 @@ func (_ *LoadBalancer) RenderAWS(t *awsup.AWSAPITarget, a, e, changes *LoadBalan
 
 	return t.AddELBTags(*e.ID, t.Cloud.BuildTags(e.Name))
 }
+
+type cloudformationLoadBalancer struct {
+	LoadBalancerName *string                    `json:"LoadBalancerName"`
+	Listeners        []*cloudformationListener  `json:"Listeners"`
+	SecurityGroups   []*cloudformation.Literal  `json:"SecurityGroups"`
+	Subnets          []*cloudformation.Literal  `json:"Subnets"`
+	HealthCheck      *cloudformationHealthCheck `json:"HealthCheck,omitempty"`
+	Tags             []*cloudformationTag       `json:"Tags,omitempty"`
+}
+
+type cloudformationListener struct {
+	InstancePort     string `json:"InstancePort"`
+	InstanceProtocol string `json:"InstanceProtocol"`
+	LoadBalancerPort string `json:"LoadBalancerPort"`
+	Protocol         string `json:"Protocol"`
+}
+
+type cloudformationHealthCheck struct {
+	Target             *string `json:"Target"`
+	HealthyThreshold   *string `json:"HealthyThreshold"`
+	UnhealthyThreshold *string `json:"UnhealthyThreshold"`
+	Interval           *string `json:"Interval"`
+	Timeout            *string `json:"Timeout"`
+}
+
+func (_ *LoadBalancer) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *LoadBalancer) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	elbName := e.ID
+	if elbName == nil {
+		elbName = e.Name
+	}
+
+	cf := &cloudformationLoadBalancer{
+		LoadBalancerName: elbName,
+		Tags:             buildCloudformationTags(cloud.BuildTags(e.Name)),
+	}
+
+	for _, subnet := range e.Subnets {
+		cf.Subnets = append(cf.Subnets, subnet.CloudformationLink())
+	}
+
+	for _, sg := range e.SecurityGroups {
+		cf.SecurityGroups = append(cf.SecurityGroups, sg.CloudformationLink())
+	}
+
+	// Make sure we output in a stable order
+	var ports []string
+	for loadBalancerPort := range e.Listeners {
+		ports = append(ports, loadBalancerPort)
+	}
+	sort.Strings(ports)
+	for _, loadBalancerPort := range ports {
+		listener := e.Listeners[loadBalancerPort]
+		cf.Listeners = append(cf.Listeners, &cloudformationListener{
+			InstancePort:     strconv.Itoa(listener.InstancePort),
+			InstanceProtocol: "TCP",
+			LoadBalancerPort: loadBalancerPort,
+			Protocol:         "TCP",
+		})
+	}
+
+	return t.RenderResource("AWS::ElasticLoadBalancing::LoadBalancer", *e.Name, cf)
+}
+
+func (e *LoadBalancer) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.Ref("AWS::ElasticLoadBalancing::LoadBalancer", *e.Name)
+}
+
+func (e *LoadBalancer) CloudformationAttrDNSName() *cloudformation.Literal {
+	return cloudformation.GetAtt("AWS::ElasticLoadBalancing::LoadBalancer", *e.Name, "DNSName")
+}
+
+func (e *LoadBalancer) CloudformationAttrCanonicalHostedZoneNameID() *cloudformation.Literal {
+	return cloudformation.GetAtt("AWS::ElasticLoadBalancing::LoadBalancer", *e.Name, "CanonicalHostedZoneNameID")
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 )
 
 type LoadBalancerAttachment struct {
@@ -76,3 +77,12 @@ func (_ *LoadBalancerAttachment) RenderAWS(t *awsup.AWSAPITarget, a, e, changes
 
 	return nil
 }
+
+func (_ *LoadBalancerAttachment) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *LoadBalancerAttachment) error {
+	// In cloudformation, the load balancers are a property of the autoscaling group
+	return t.UpdateResource("AWS::AutoScaling::AutoScalingGroup", *e.AutoscalingGroup.Name, func(r interface{}) error {
+		asg := r.(*cloudformationAutoscalingGroup)
+		asg.LoadBalancerNames = append(asg.LoadBalancerNames, e.LoadBalancer.CloudformationLink())
+		return nil
+	})
+}
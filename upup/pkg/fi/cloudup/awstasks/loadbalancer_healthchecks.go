@@ -2,11 +2,13 @@ package awstasks
 
 import (
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go/service/elb"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 )
 
 type LoadBalancerHealthChecks struct {
@@ -88,3 +90,26 @@ func (_ *LoadBalancerHealthChecks) RenderAWS(t *awsup.AWSAPITarget, a, e, change
 
 	return nil
 }
+
+func (_ *LoadBalancerHealthChecks) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *LoadBalancerHealthChecks) error {
+	// In cloudformation, the health check is part of the load balancer
+	return t.UpdateResource("AWS::ElasticLoadBalancing::LoadBalancer", *e.LoadBalancer.Name, func(r interface{}) error {
+		lb := r.(*cloudformationLoadBalancer)
+		lb.HealthCheck = &cloudformationHealthCheck{
+			Target:             e.Target,
+			HealthyThreshold:   int64AsString(e.HealthyThreshold),
+			UnhealthyThreshold: int64AsString(e.UnhealthyThreshold),
+			Interval:           int64AsString(e.Interval),
+			Timeout:            int64AsString(e.Timeout),
+		}
+		return nil
+	})
+}
+
+// int64AsString formats an optional number; cloudformation declares many numeric properties as strings
+func int64AsString(v *int64) *string {
+	if v == nil {
+		return nil
+	}
+	return fi.String(strconv.FormatInt(*v, 10))
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -196,3 +197,35 @@ func (_ *Route) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Rou
 
 	return t.RenderResource("aws_route", *e.Name, tf)
 }
+
+type cloudformationRoute struct {
+	RouteTableId         *cloudformation.Literal `json:"RouteTableId"`
+	DestinationCidrBlock *string                 `json:"DestinationCidrBlock,omitempty"`
+	GatewayId            *cloudformation.Literal `json:"GatewayId,omitempty"`
+}
+
+func (_ *Route) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *Route) error {
+	if e.Instance != nil {
+		return fmt.Errorf("routes to instances are not supported with cloudformation")
+	}
+
+	cf := &cloudformationRoute{
+		DestinationCidrBlock: e.CIDR,
+		RouteTableId:         e.RouteTable.CloudformationLink(),
+	}
+
+	if e.InternetGateway != nil {
+		cf.GatewayId = e.InternetGateway.CloudformationLink()
+	}
+
+	err := t.RenderResource("AWS::EC2::Route", *e.Name, cf)
+	if err != nil {
+		return err
+	}
+
+	// A route to a gateway can't be created until the gateway is attached to the VPC, which isn't implied by the Ref
+	if e.InternetGateway != nil && !fi.BoolValue(e.InternetGateway.Shared) {
+		return t.AddDependency("AWS::EC2::Route", *e.Name, "AWS::EC2::VPCGatewayAttachment", *e.InternetGateway.Name)
+	}
+	return nil
+}
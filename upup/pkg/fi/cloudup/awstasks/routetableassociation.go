@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -129,3 +130,17 @@ func (_ *RouteTableAssociation) RenderTerraform(t *terraform.TerraformTarget, a,
 func (e *RouteTableAssociation) TerraformLink() *terraform.Literal {
 	return terraform.LiteralSelfLink("aws_route_table_association", *e.Name)
 }
+
+type cloudformationRouteTableAssociation struct {
+	SubnetId     *cloudformation.Literal `json:"SubnetId"`
+	RouteTableId *cloudformation.Literal `json:"RouteTableId"`
+}
+
+func (_ *RouteTableAssociation) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *RouteTableAssociation) error {
+	cf := &cloudformationRouteTableAssociation{
+		SubnetId:     e.Subnet.CloudformationLink(),
+		RouteTableId: e.RouteTable.CloudformationLink(),
+	}
+
+	return t.RenderResource("AWS::EC2::SubnetRouteTableAssociation", *e.Name, cf)
+}
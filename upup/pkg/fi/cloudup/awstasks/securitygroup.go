@@ -2,11 +2,13 @@ package awstasks
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -120,6 +122,12 @@ type terraformSecurityGroup struct {
 func (_ *SecurityGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *SecurityGroup) error {
 	cloud := t.Cloud.(*awsup.AWSCloud)
 
+	// Our security groups are named <role>.<clustername>, e.g. masters.mycluster.example.com
+	role := strings.SplitN(*e.Name, ".", 2)[0]
+	if err := t.AddOutputVariableArray(role+"_security_group_ids", e.TerraformLink()); err != nil {
+		return err
+	}
+
 	tf := &terraformSecurityGroup{
 		Name:        e.Name,
 		VPCID:       e.VPC.TerraformLink(),
@@ -133,3 +141,31 @@ func (_ *SecurityGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, chan
 func (e *SecurityGroup) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_security_group", *e.Name, "id")
 }
+
+type cloudformationSecurityGroup struct {
+	GroupDescription *string                 `json:"GroupDescription"`
+	VpcId            *cloudformation.Literal `json:"VpcId"`
+	Tags             []*cloudformationTag    `json:"Tags,omitempty"`
+}
+
+func (_ *SecurityGroup) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *SecurityGroup) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	description := e.Description
+	if description == nil {
+		// GroupDescription is required by cloudformation
+		description = e.Name
+	}
+
+	cf := &cloudformationSecurityGroup{
+		GroupDescription: description,
+		VpcId:            e.VPC.CloudformationLink(),
+		Tags:             buildCloudformationTags(cloud.BuildTags(e.Name)),
+	}
+
+	return t.RenderResource("AWS::EC2::SecurityGroup", *e.Name, cf)
+}
+
+func (e *SecurityGroup) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.GetAtt("AWS::EC2::SecurityGroup", *e.Name, "GroupId")
+}
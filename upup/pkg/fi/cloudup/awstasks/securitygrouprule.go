@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 )
 
@@ -254,3 +255,62 @@ func (_ *SecurityGroupRule) RenderTerraform(t *terraform.TerraformTarget, a, e,
 	}
 	return t.RenderResource("aws_security_group_rule", *e.Name, tf)
 }
+
+type cloudformationSecurityGroupIngress struct {
+	GroupId *cloudformation.Literal `json:"GroupId"`
+
+	SourceSecurityGroupId      *cloudformation.Literal `json:"SourceSecurityGroupId,omitempty"`
+	DestinationSecurityGroupId *cloudformation.Literal `json:"DestinationSecurityGroupId,omitempty"`
+
+	FromPort *int64 `json:"FromPort"`
+	ToPort   *int64 `json:"ToPort"`
+
+	IpProtocol *string `json:"IpProtocol"`
+	CidrIp     *string `json:"CidrIp,omitempty"`
+}
+
+func (_ *SecurityGroupRule) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *SecurityGroupRule) error {
+	egress := fi.BoolValue(e.Egress)
+
+	if egress && e.Protocol == nil && e.SourceGroup == nil && fi.StringValue(e.CIDR) == "0.0.0.0/0" {
+		// Cloudformation security groups allow all egress unless egress rules are specified on the group,
+		// and creating the same rule again fails as a duplicate
+		glog.V(2).Infof("Skipping egress rule %q, which is the default in cloudformation", *e.Name)
+		return nil
+	}
+
+	cf := &cloudformationSecurityGroupIngress{
+		GroupId:    e.SecurityGroup.CloudformationLink(),
+		FromPort:   e.FromPort,
+		ToPort:     e.ToPort,
+		IpProtocol: e.Protocol,
+		CidrIp:     e.CIDR,
+	}
+
+	if e.Protocol == nil {
+		// The ports are ignored for all protocols, but egress rules require them
+		cf.IpProtocol = fi.String("-1")
+		cf.FromPort = fi.Int64(0)
+		cf.ToPort = fi.Int64(0)
+	}
+
+	if cf.FromPort == nil {
+		cf.FromPort = fi.Int64(0)
+	}
+	if cf.ToPort == nil {
+		cf.ToPort = fi.Int64(65535)
+	}
+
+	if e.SourceGroup != nil {
+		if egress {
+			cf.DestinationSecurityGroupId = e.SourceGroup.CloudformationLink()
+		} else {
+			cf.SourceSecurityGroupId = e.SourceGroup.CloudformationLink()
+		}
+	}
+
+	if egress {
+		return t.RenderResource("AWS::EC2::SecurityGroupEgress", *e.Name, cf)
+	}
+	return t.RenderResource("AWS::EC2::SecurityGroupIngress", *e.Name, cf)
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"k8s.io/kops/upup/pkg/fi/utils"
 )
@@ -143,6 +144,10 @@ type terraformSubnet struct {
 func (_ *Subnet) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Subnet) error {
 	cloud := t.Cloud.(*awsup.AWSCloud)
 
+	if err := t.AddOutputVariableArray("subnet_ids", e.TerraformLink()); err != nil {
+		return err
+	}
+
 	tf := &terraformSubnet{
 		VPCID:            e.VPC.TerraformLink(),
 		CIDR:             e.CIDR,
@@ -156,3 +161,27 @@ func (_ *Subnet) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Su
 func (e *Subnet) TerraformLink() *terraform.Literal {
 	return terraform.LiteralProperty("aws_subnet", *e.Name, "id")
 }
+
+type cloudformationSubnet struct {
+	VpcId            *cloudformation.Literal `json:"VpcId"`
+	CidrBlock        *string                 `json:"CidrBlock"`
+	AvailabilityZone *string                 `json:"AvailabilityZone"`
+	Tags             []*cloudformationTag    `json:"Tags,omitempty"`
+}
+
+func (_ *Subnet) RenderCloudformation(t *cloudformation.CloudformationTarget, a, e, changes *Subnet) error {
+	cloud := t.Cloud.(*awsup.AWSCloud)
+
+	cf := &cloudformationSubnet{
+		VpcId:            e.VPC.CloudformationLink(),
+		CidrBlock:        e.CIDR,
+		AvailabilityZone: e.AvailabilityZone,
+		Tags:             buildCloudformationTags(cloud.BuildTags(e.Name)),
+	}
+
+	return t.RenderResource("AWS::EC2::Subnet", *e.Name, cf)
+}
+
+func (e *Subnet) CloudformationLink() *cloudformation.Literal {
+	return cloudformation.Ref("AWS::EC2::Subnet", *e.Name)
+}
@@ -3,6 +3,7 @@ package awstasks
 import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"sort"
 )
 
 func mapEC2TagsToMap(tags []*ec2.Tag) map[string]string {
@@ -24,3 +25,26 @@ func findNameTag(tags []*ec2.Tag) *string {
 	}
 	return nil
 }
+
+type cloudformationTag struct {
+	Key   *string `json:"Key"`
+	Value *string `json:"Value"`
+}
+
+// buildCloudformationTags converts tags to the list form used in cloudformation, sorted so the output is stable
+func buildCloudformationTags(tags map[string]string) []*cloudformationTag {
+	var keys []string
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var cfTags []*cloudformationTag
+	for _, k := range keys {
+		cfTags = append(cfTags, &cloudformationTag{
+			Key:   aws.String(k),
+			Value: aws.String(tags[k]),
+		})
+	}
+	return cfTags
+}
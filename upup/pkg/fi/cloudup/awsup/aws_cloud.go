@@ -6,10 +6,15 @@ import (
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/elb"
+	"github.com/aws/aws-sdk-go/service/elb/elbiface"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/fi"
 	"strings"
@@ -21,12 +26,14 @@ const MaxCreateTagsAttempts = 60
 
 const TagClusterName = "KubernetesCluster"
 
+// AWSCloud holds the clients for the AWS services we use.
+// The clients are interfaces, so that an in-memory implementation can be substituted in tests (see awsmock)
 type AWSCloud struct {
-	EC2         *ec2.EC2
-	IAM         *iam.IAM
-	ELB         *elb.ELB
-	Autoscaling *autoscaling.AutoScaling
-	Route53     *route53.Route53
+	EC2         ec2iface.EC2API
+	IAM         iamiface.IAMAPI
+	ELB         elbiface.ELBAPI
+	Autoscaling autoscalingiface.AutoScalingAPI
+	Route53     route53iface.Route53API
 
 	Region string
 
@@ -53,6 +60,19 @@ func NewAWSCloud(region string, tags map[string]string) (*AWSCloud, error) {
 	return c, nil
 }
 
+// NewAWSCloudWithClients builds an AWSCloud using the supplied service clients, rather than connecting to AWS
+func NewAWSCloudWithClients(region string, tags map[string]string, ec2Client ec2iface.EC2API, iamClient iamiface.IAMAPI, elbClient elbiface.ELBAPI, autoscalingClient autoscalingiface.AutoScalingAPI, route53Client route53iface.Route53API) *AWSCloud {
+	return &AWSCloud{
+		EC2:         ec2Client,
+		IAM:         iamClient,
+		ELB:         elbClient,
+		Autoscaling: autoscalingClient,
+		Route53:     route53Client,
+		Region:      region,
+		tags:        tags,
+	}
+}
+
 func NewEC2Filter(name string, values ...string) *ec2.Filter {
 	awsValues := []*string{}
 	for _, value := range values {
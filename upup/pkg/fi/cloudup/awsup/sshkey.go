@@ -0,0 +1,82 @@
+package awsup
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/dsa"
+	"crypto/md5"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"reflect"
+	"strings"
+)
+
+// ComputeAWSKeyFingerprint computes the fingerprint that AWS reports for an imported SSH public key
+func ComputeAWSKeyFingerprint(publicKeyString string) (string, error) {
+	tokens := strings.Split(publicKeyString, " ")
+	if len(tokens) < 2 {
+		return "", fmt.Errorf("error parsing SSH public key: %s", publicKeyString)
+	}
+
+	sshPublicKeyBytes, err := base64.StdEncoding.DecodeString(tokens[1])
+	if len(tokens) < 2 {
+		return "", fmt.Errorf("error decoding SSH public key: %s", publicKeyString)
+	}
+
+	sshPublicKey, err := ssh.ParsePublicKey(sshPublicKeyBytes)
+	if err != nil {
+		return "", fmt.Errorf("error parsing SSH public key: %v", err)
+	}
+
+	der, err := toDER(sshPublicKey)
+	if err != nil {
+		return "", fmt.Errorf("error computing fingerprint for SSH public key: %v", err)
+	}
+	h := md5.Sum(der)
+	sshKeyFingerprint := fmt.Sprintf("%x", h)
+
+	var colonSeparated bytes.Buffer
+	for i := 0; i < len(sshKeyFingerprint); i++ {
+		if (i%2) == 0 && i != 0 {
+			colonSeparated.WriteByte(':')
+		}
+		colonSeparated.WriteByte(sshKeyFingerprint[i])
+	}
+
+	return colonSeparated.String(), nil
+}
+
+// toDER gets the DER encoding of the SSH public key
+// Annoyingly, the ssh code wraps the actual crypto keys, so we have to use reflection tricks
+func toDER(pubkey ssh.PublicKey) ([]byte, error) {
+	pubkeyValue := reflect.ValueOf(pubkey)
+	typeName := utils.BuildTypeName(pubkeyValue.Type())
+
+	var cryptoKey crypto.PublicKey
+	switch typeName {
+	case "*rsaPublicKey":
+		var rsaPublicKey *rsa.PublicKey
+		targetType := reflect.ValueOf(rsaPublicKey).Type()
+		rsaPublicKey = pubkeyValue.Convert(targetType).Interface().(*rsa.PublicKey)
+		cryptoKey = rsaPublicKey
+
+	case "*dsaPublicKey":
+		var dsaPublicKey *dsa.PublicKey
+		targetType := reflect.ValueOf(dsaPublicKey).Type()
+		dsaPublicKey = pubkeyValue.Convert(targetType).Interface().(*dsa.PublicKey)
+		cryptoKey = dsaPublicKey
+
+	default:
+		return nil, fmt.Errorf("Unknown type for SSH PublicKey; cannot compute fingerprint: %q", typeName)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(cryptoKey)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling SSH public key: %v", err)
+	}
+	return der, nil
+}
@@ -0,0 +1,7 @@
+package cloudup
+
+import (
+	// The cloud providers register themselves with fi.RegisterCloudProvider
+	_ "k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
+	_ "k8s.io/kops/upup/pkg/fi/cloudup/gcetasks"
+)
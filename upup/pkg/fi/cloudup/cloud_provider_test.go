@@ -0,0 +1,20 @@
+package cloudup
+
+import (
+	"k8s.io/kops/upup/pkg/fi"
+	"testing"
+)
+
+// The providers must be registered by importing cloudup alone
+func TestCloudProvidersRegistered(t *testing.T) {
+	for _, id := range []fi.CloudProviderID{fi.CloudProviderAWS, fi.CloudProviderGCE} {
+		provider, err := fi.FindCloudProvider(id)
+		if err != nil {
+			t.Errorf("CloudProvider %q not registered: %v", id, err)
+			continue
+		}
+		if provider.ModelTag() != "_"+string(id) {
+			t.Errorf("unexpected ModelTag for %q: %q", id, provider.ModelTag())
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package cloudformation
+
+import "encoding/json"
+
+// Literal is a value in the template, which may be an intrinsic function such as Ref or Fn::GetAtt
+type Literal struct {
+	data interface{}
+}
+
+var _ json.Marshaler = &Literal{}
+
+func (l *Literal) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&l.data)
+}
+
+// Ref is the Ref function, which evaluates to the "primary" value of a resource (for most resources, the ID)
+func Ref(resourceType, resourceName string) *Literal {
+	return &Literal{
+		data: map[string]interface{}{
+			"Ref": LogicalID(resourceType, resourceName),
+		},
+	}
+}
+
+// GetAtt is the Fn::GetAtt function, which evaluates to an attribute of a resource
+func GetAtt(resourceType, resourceName, attribute string) *Literal {
+	return &Literal{
+		data: map[string]interface{}{
+			"Fn::GetAtt": []string{LogicalID(resourceType, resourceName), attribute},
+		},
+	}
+}
+
+// Base64 is the Fn::Base64 function, which is needed for user data
+func Base64(s string) *Literal {
+	return &Literal{
+		data: map[string]interface{}{
+			"Fn::Base64": s,
+		},
+	}
+}
+
+func LiteralString(s string) *Literal {
+	return &Literal{data: s}
+}
@@ -0,0 +1,132 @@
+package cloudformation
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+type CloudformationTarget struct {
+	Cloud  fi.Cloud
+	Region string
+
+	// mutex protects resources; tasks are rendered concurrently
+	mutex     sync.Mutex
+	resources map[string]*cloudformationResource
+
+	outDir string
+}
+
+func NewCloudformationTarget(cloud fi.Cloud, region string, outDir string) *CloudformationTarget {
+	return &CloudformationTarget{
+		Cloud:     cloud,
+		Region:    region,
+		outDir:    outDir,
+		resources: make(map[string]*cloudformationResource),
+	}
+}
+
+var _ fi.Target = &CloudformationTarget{}
+
+type cloudformationResource struct {
+	Type       string      `json:"Type"`
+	Properties interface{} `json:"Properties"`
+	DependsOn  []string    `json:"DependsOn,omitempty"`
+}
+
+// LogicalID is the name of a resource in the template; logical IDs can only contain letters and digits,
+// so we remove anything else, and we include the type to avoid collisions between resources with the same name
+func LogicalID(resourceType, resourceName string) string {
+	s := strings.TrimPrefix(resourceType, "AWS::") + resourceName
+	var b []rune
+	for _, c := range s {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') {
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+func (t *CloudformationTarget) RenderResource(resourceType string, resourceName string, e interface{}) error {
+	id := LogicalID(resourceType, resourceName)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.resources[id] != nil {
+		return fmt.Errorf("duplicate resource found: %s (%s %s)", id, resourceType, resourceName)
+	}
+	t.resources[id] = &cloudformationResource{
+		Type:       resourceType,
+		Properties: e,
+	}
+	return nil
+}
+
+// UpdateResource modifies the properties of a resource that has already been rendered.
+// CloudFormation doesn't have separate resources for some of our tasks (e.g. attaching an autoscaling group
+// to a load balancer), so those tasks update the resource they are attached to instead.
+func (t *CloudformationTarget) UpdateResource(resourceType string, resourceName string, update func(e interface{}) error) error {
+	id := LogicalID(resourceType, resourceName)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r := t.resources[id]
+	if r == nil {
+		return fmt.Errorf("resource not found: %s (%s %s)", id, resourceType, resourceName)
+	}
+	return update(r.Properties)
+}
+
+// AddDependency records that a resource must be created after another resource, where this is not implied by a Ref
+func (t *CloudformationTarget) AddDependency(resourceType string, resourceName string, dependsOnType string, dependsOnName string) error {
+	id := LogicalID(resourceType, resourceName)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r := t.resources[id]
+	if r == nil {
+		return fmt.Errorf("resource not found: %s (%s %s)", id, resourceType, resourceName)
+	}
+	r.DependsOn = append(r.DependsOn, LogicalID(dependsOnType, dependsOnName))
+	return nil
+}
+
+func (t *CloudformationTarget) Finish(taskMap map[string]fi.Task) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	data := make(map[string]interface{})
+	data["AWSTemplateFormatVersion"] = "2010-09-09"
+	data["Resources"] = t.resources
+
+	// Maps are marshalled in sorted order, so the output is the same every time
+	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling cloudformation data to json: %v", err)
+	}
+
+	p := path.Join(t.outDir, "kubernetes.json")
+
+	err = os.MkdirAll(path.Dir(p), os.FileMode(0755))
+	if err != nil {
+		return fmt.Errorf("error creating output directory %q: %v", path.Dir(p), err)
+	}
+
+	err = ioutil.WriteFile(p, jsonBytes, os.FileMode(0644))
+	if err != nil {
+		return fmt.Errorf("error writing cloudformation data to output file %q: %v", p, err)
+	}
+
+	glog.Infof("Cloudformation output is in %s", p)
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package cloudformation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestLogicalID(t *testing.T) {
+	grid := []struct {
+		resourceType string
+		resourceName string
+		expected     string
+	}{
+		{"AWS::EC2::VPC", "kubernetes.example.com", "EC2VPCkubernetesexamplecom"},
+		{"AWS::EC2::Subnet", "us-east-1a.kubernetes.example.com", "EC2Subnetuseast1akubernetesexamplecom"},
+	}
+	for _, g := range grid {
+		actual := LogicalID(g.resourceType, g.resourceName)
+		if actual != g.expected {
+			t.Errorf("unexpected logical ID for %s %s: %q (expected %q)", g.resourceType, g.resourceName, actual, g.expected)
+		}
+	}
+}
+
+type testSecurityGroup struct {
+	VpcId *Literal `json:"VpcId"`
+}
+
+type testLoadBalancer struct {
+	SecurityGroups []*Literal `json:"SecurityGroups"`
+	HealthCheck    *string    `json:"HealthCheck,omitempty"`
+}
+
+func TestFinish(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "cloudformation")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	target := NewCloudformationTarget(nil, "us-east-1", outDir)
+
+	if err := target.RenderResource("AWS::EC2::SecurityGroup", "api", &testSecurityGroup{VpcId: Ref("AWS::EC2::VPC", "vpc")}); err != nil {
+		t.Fatalf("error rendering resource: %v", err)
+	}
+	if err := target.RenderResource("AWS::EC2::SecurityGroup", "api", &testSecurityGroup{}); err == nil {
+		t.Errorf("expected error rendering duplicate resource")
+	}
+
+	lb := &testLoadBalancer{
+		SecurityGroups: []*Literal{GetAtt("AWS::EC2::SecurityGroup", "api", "GroupId")},
+	}
+	if err := target.RenderResource("AWS::ElasticLoadBalancing::LoadBalancer", "api", lb); err != nil {
+		t.Fatalf("error rendering resource: %v", err)
+	}
+	err = target.UpdateResource("AWS::ElasticLoadBalancing::LoadBalancer", "api", func(r interface{}) error {
+		s := "TCP:443"
+		r.(*testLoadBalancer).HealthCheck = &s
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error updating resource: %v", err)
+	}
+
+	if err := target.Finish(nil); err != nil {
+		t.Fatalf("error from Finish: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path.Join(outDir, "kubernetes.json"))
+	if err != nil {
+		t.Fatalf("error reading output: %v", err)
+	}
+
+	var template struct {
+		Resources map[string]struct {
+			Type       string
+			Properties map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(b, &template); err != nil {
+		t.Fatalf("error parsing output: %v", err)
+	}
+
+	sg := template.Resources["EC2SecurityGroupapi"]
+	if sg.Type != "AWS::EC2::SecurityGroup" {
+		t.Fatalf("security group not found in output:\n%s", b)
+	}
+	ref, _ := json.Marshal(sg.Properties["VpcId"])
+	if string(ref) != `{"Ref":"EC2VPCvpc"}` {
+		t.Errorf("unexpected Ref: %s", ref)
+	}
+
+	elb := template.Resources["ElasticLoadBalancingLoadBalancerapi"]
+	getAtt, _ := json.Marshal(elb.Properties["SecurityGroups"])
+	if string(getAtt) != `[{"Fn::GetAtt":["EC2SecurityGroupapi","GroupId"]}]` {
+		t.Errorf("unexpected GetAtt: %s", getAtt)
+	}
+	if elb.Properties["HealthCheck"] != "TCP:443" {
+		t.Errorf("update was not applied:\n%s", b)
+	}
+}
@@ -8,27 +8,24 @@ import (
 	"io/ioutil"
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
-	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
-	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
-	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
-	"k8s.io/kops/upup/pkg/fi/cloudup/gcetasks"
+	"k8s.io/kops/upup/pkg/fi/cloudup/cloudformation"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
 	"k8s.io/kops/upup/pkg/fi/fitasks"
 	"k8s.io/kops/upup/pkg/fi/loader"
-	"k8s.io/kops/upup/pkg/fi/utils"
 	"k8s.io/kops/upup/pkg/fi/vfs"
 	"net"
 	"os"
 	"path"
 	"strings"
+	"time"
 )
 
-const DefaultNodeTypeAWS = "t2.medium"
-const DefaultNodeTypeGCE = "n1-standard-2"
-
 // Path for completed cluster spec in the state store
 const PathClusterCompleted = "cluster.spec"
 
+// Path for the execution trace of the last update in the state store
+const PathExecutionTrace = "trace.json"
+
 type CreateClusterCmd struct {
 	// Cluster is the api object representing the whole cluster
 	Cluster *api.Cluster
@@ -67,9 +64,30 @@ type CreateClusterCmd struct {
 	SSHPublicKey string
 	// OutDir is a local directory in which we place output, can cache files etc
 	OutDir string
+	// TerraformModule is set if the terraform output will be used as a module, and so should not configure the provider
+	TerraformModule bool
 
 	// Assets is a list of sources for files (primarily when not using everything containerized)
 	Assets []string
+
+	// Cloud is the cloud we will apply changes to; if not set it is built from the cluster spec.
+	// Tests set this to run against an in-memory cloud.
+	Cloud fi.Cloud
+
+	// PlanFormat is the format of the dry-run report: text (the default), json or yaml
+	PlanFormat string
+	// SavePlan is a file to which we save the plan, when doing a dry-run
+	SavePlan string
+	// ApplyPlan is a previously saved plan; if set, we only apply changes if they still match the plan
+	ApplyPlan string
+
+	// RunTasksOptions controls concurrency, timeouts and retries when running the tasks
+	RunTasksOptions fi.RunTasksOptions
+	// TraceFile is a local file to which we write the execution trace, in addition to the state store
+	TraceFile string
+
+	// Plan is set by Run when doing a dry-run, to the changes that would be made
+	Plan *fi.Plan
 }
 
 func (c *CreateClusterCmd) LoadConfig(configFile string) error {
@@ -77,10 +95,12 @@ func (c *CreateClusterCmd) LoadConfig(configFile string) error {
 	if err != nil {
 		return fmt.Errorf("error loading configuration file %q: %v", configFile, err)
 	}
-	err = utils.YamlUnmarshal(conf, c.Cluster)
+	cluster, instanceGroups, err := api.ParseManifest(conf)
 	if err != nil {
 		return fmt.Errorf("error parsing configuration file %q: %v", configFile, err)
 	}
+	c.Cluster = cluster
+	c.InstanceGroups = instanceGroups
 	return nil
 }
 
@@ -96,6 +116,14 @@ func (c *CreateClusterCmd) Run() error {
 	//	c.NodeUpConfig = &nodeup.NodeConfig{}
 	//}
 
+	if c.SavePlan != "" && c.Target != "dryrun" {
+		return fmt.Errorf("a plan can only be saved when doing a dry-run")
+	}
+
+	if c.PlanFormat != "" && c.Target != "dryrun" {
+		return fmt.Errorf("a plan format can only be specified when doing a dry-run")
+	}
+
 	clusterName := c.Cluster.Name
 	if clusterName == "" {
 		return fmt.Errorf("ClusterName is required (e.g. --name=mycluster.myzone.com)")
@@ -151,66 +179,16 @@ func (c *CreateClusterCmd) Run() error {
 		return err
 	}
 
-	// Check that instance groups are defined in valid zones
-	{
-		clusterZones := make(map[string]*api.ClusterZoneSpec)
-		for _, z := range c.Cluster.Spec.Zones {
-			if clusterZones[z.Name] != nil {
-				return fmt.Errorf("Zones contained a duplicate value: %v", z.Name)
-			}
-			clusterZones[z.Name] = z
-		}
-
-		for _, group := range c.InstanceGroups {
-			for _, z := range group.Spec.Zones {
-				if clusterZones[z] == nil {
-					return fmt.Errorf("InstanceGroup %q is configured in %q, but this is not configured as a Zone in the cluster", group.Name, z)
-				}
-			}
-		}
-
-		// Check etcd configuration
-		{
-			for i, etcd := range c.Cluster.Spec.EtcdClusters {
-				if etcd.Name == "" {
-					return fmt.Errorf("EtcdClusters #%d did not specify a Name", i)
-				}
-
-				for i, m := range etcd.Members {
-					if m.Name == "" {
-						return fmt.Errorf("EtcdMember #%d of etcd-cluster %s did not specify a Name", i, etcd.Name)
-					}
-
-					z := m.Zone
-					if z == "" {
-						return fmt.Errorf("EtcdMember %s:%s did not specify a Zone", etcd.Name, m.Name)
-					}
-				}
-
-				etcdZones := make(map[string]*api.EtcdMemberSpec)
-				etcdNames := make(map[string]*api.EtcdMemberSpec)
-
-				for _, m := range etcd.Members {
-					if etcdNames[m.Name] != nil {
-						return fmt.Errorf("EtcdMembers found with same name %q in etcd-cluster %q", m.Name, etcd.Name)
-					}
-
-					if etcdZones[m.Zone] != nil {
-						// Maybe this should just be a warning
-						return fmt.Errorf("EtcdMembers are in the same zone %q in etcd-cluster %q", m.Zone, etcd.Name)
-					}
-
-					if clusterZones[m.Zone] == nil {
-						return fmt.Errorf("EtcdMembers for %q is configured in zone %q, but that is not configured at the k8s-cluster level", etcd.Name, m.Zone)
-					}
-					etcdZones[m.Zone] = m
-				}
+	err = c.Cluster.Validate(false)
+	if err != nil {
+		return err
+	}
 
-				if (len(etcdZones) % 2) == 0 {
-					// Not technically a requirement, but doesn't really make sense to allow
-					return fmt.Errorf("There should be an odd number of master-zones, for etcd's quorum.  Hint: Use --zone and --master-zone to declare node zones and master zones separately.")
-				}
-			}
+	// Check that instance groups are defined in valid zones
+	for _, group := range c.InstanceGroups {
+		err = group.CrossValidate(c.Cluster)
+		if err != nil {
+			return err
 		}
 	}
 
@@ -272,6 +250,40 @@ func (c *CreateClusterCmd) Run() error {
 		// We do support this...
 	}
 
+	{
+		etcdBackupStore, err := EtcdBackupStore(c.StateStore, c.Cluster)
+		if err != nil {
+			return err
+		}
+		if c.Cluster.Spec.EtcdBackup == nil {
+			c.Cluster.Spec.EtcdBackup = &api.EtcdBackupSpec{}
+		}
+		if c.Cluster.Spec.EtcdBackup.Interval != "" {
+			if _, err := time.ParseDuration(c.Cluster.Spec.EtcdBackup.Interval); err != nil {
+				return fmt.Errorf("invalid etcdBackup.interval %q: %v", c.Cluster.Spec.EtcdBackup.Interval, err)
+			}
+		}
+		if vfs.IsClusterReadable(etcdBackupStore) {
+			c.Cluster.Spec.EtcdBackup.Store = etcdBackupStore.Path()
+			if s3Path, ok := etcdBackupStore.(*vfs.S3Path); ok {
+				if c.Cluster.Spec.MasterPermissions == nil {
+					c.Cluster.Spec.MasterPermissions = &api.CloudPermissions{}
+				}
+				c.Cluster.Spec.MasterPermissions.AddS3Bucket(s3Path.Bucket())
+			}
+		} else {
+			glog.Warningf("etcd backup store %s is not cluster readable; snapshots of etcd will not be taken", etcdBackupStore)
+			c.Cluster.Spec.EtcdBackup.Store = ""
+		}
+	}
+
+	if c.Cluster.Spec.DNS != nil {
+		err := c.defaultDNS()
+		if err != nil {
+			return err
+		}
+	}
+
 	if c.Cluster.Spec.KubernetesVersion == "" {
 		stableURL := "https://storage.googleapis.com/kubernetes-release/release/stable.txt"
 		b, err := vfs.Context.ReadFile(stableURL)
@@ -342,7 +354,8 @@ func (c *CreateClusterCmd) Run() error {
 		tags["_not_master_lb"] = struct{}{}
 	}
 
-	if c.Cluster.Spec.MasterPublicName != "" {
+	// We only manage the API DNS name in route53; with other providers the masters publish it themselves
+	if c.Cluster.Spec.MasterPublicName != "" && (c.Cluster.Spec.DNS == nil || c.Cluster.Spec.DNS.Provider == "route53") {
 		tags["_master_dns"] = struct{}{}
 	}
 
@@ -351,94 +364,38 @@ func (c *CreateClusterCmd) Run() error {
 		"secret":  &fitasks.Secret{},
 	})
 
-	cloud, err := BuildCloud(c.Cluster)
+	provider, err := fi.FindCloudProvider(fi.CloudProviderID(c.Cluster.Spec.CloudProvider))
 	if err != nil {
 		return err
 	}
 
-	region := ""
-	project := ""
-
-	switch c.Cluster.Spec.CloudProvider {
-	case "gce":
-		{
-			gceCloud := cloud.(*gce.GCECloud)
-			region = gceCloud.Region
-			project = gceCloud.Project
-
-			glog.Fatalf("GCE is (probably) not working currently - please ping @justinsb for cleanup")
-			tags["_gce"] = struct{}{}
-			c.NodeUpTags = append(c.NodeUpTags, "_gce")
-
-			l.AddTypes(map[string]interface{}{
-				"persistentDisk":       &gcetasks.PersistentDisk{},
-				"instance":             &gcetasks.Instance{},
-				"instanceTemplate":     &gcetasks.InstanceTemplate{},
-				"network":              &gcetasks.Network{},
-				"managedInstanceGroup": &gcetasks.ManagedInstanceGroup{},
-				"firewallRule":         &gcetasks.FirewallRule{},
-				"ipAddress":            &gcetasks.IPAddress{},
-			})
-		}
-
-	case "aws":
-		{
-			awsCloud := cloud.(*awsup.AWSCloud)
-			region = awsCloud.Region
-
-			tags["_aws"] = struct{}{}
-			c.NodeUpTags = append(c.NodeUpTags, "_aws")
-
-			l.AddTypes(map[string]interface{}{
-				// EC2
-				"elasticIP":                   &awstasks.ElasticIP{},
-				"instance":                    &awstasks.Instance{},
-				"instanceElasticIPAttachment": &awstasks.InstanceElasticIPAttachment{},
-				"instanceVolumeAttachment":    &awstasks.InstanceVolumeAttachment{},
-				"ebsVolume":                   &awstasks.EBSVolume{},
-				"sshKey":                      &awstasks.SSHKey{},
-
-				// IAM
-				"iamInstanceProfile":     &awstasks.IAMInstanceProfile{},
-				"iamInstanceProfileRole": &awstasks.IAMInstanceProfileRole{},
-				"iamRole":                &awstasks.IAMRole{},
-				"iamRolePolicy":          &awstasks.IAMRolePolicy{},
-
-				// VPC / Networking
-				"dhcpOptions":           &awstasks.DHCPOptions{},
-				"internetGateway":       &awstasks.InternetGateway{},
-				"route":                 &awstasks.Route{},
-				"routeTable":            &awstasks.RouteTable{},
-				"routeTableAssociation": &awstasks.RouteTableAssociation{},
-				"securityGroup":         &awstasks.SecurityGroup{},
-				"securityGroupRule":     &awstasks.SecurityGroupRule{},
-				"subnet":                &awstasks.Subnet{},
-				"vpc":                   &awstasks.VPC{},
-				"vpcDHDCPOptionsAssociation": &awstasks.VPCDHCPOptionsAssociation{},
-
-				// ELB
-				"loadBalancer":             &awstasks.LoadBalancer{},
-				"loadBalancerAttachment":   &awstasks.LoadBalancerAttachment{},
-				"loadBalancerHealthChecks": &awstasks.LoadBalancerHealthChecks{},
-
-				// Autoscaling
-				"autoscalingGroup":    &awstasks.AutoscalingGroup{},
-				"launchConfiguration": &awstasks.LaunchConfiguration{},
-
-				// Route53
-				"dnsName": &awstasks.DNSName{},
-				"dnsZone": &awstasks.DNSZone{},
-			})
-
-			if c.SSHPublicKey == "" {
-				return fmt.Errorf("SSH public key must be specified when running with AWS")
-			}
-
-			l.TemplateFunctions["MachineTypeInfo"] = awsup.GetMachineTypeInfo
+	cloud := c.Cloud
+	if cloud == nil {
+		cloud, err = BuildCloud(c.Cluster)
+		if err != nil {
+			return err
 		}
+	}
 
-	default:
-		return fmt.Errorf("unknown CloudProvider %q", c.Cluster.Spec.CloudProvider)
+	if cloud.ProviderID() == fi.CloudProviderGCE {
+		glog.Fatalf("GCE is (probably) not working currently - please ping @justinsb for cleanup")
+	}
+
+	region := provider.Region(cloud)
+
+	modelTag := provider.ModelTag()
+	tags[modelTag] = struct{}{}
+	c.NodeUpTags = append(c.NodeUpTags, modelTag)
+
+	taskTypes := provider.TaskTypes()
+	l.AddTypes(taskTypes)
+
+	if _, found := taskTypes["sshKey"]; found && c.SSHPublicKey == "" {
+		return fmt.Errorf("SSH public key must be specified when running with %s", c.Cluster.Spec.CloudProvider)
+	}
+
+	for k, fn := range provider.TemplateFunctions(cloud) {
+		l.TemplateFunctions[k] = fn
 	}
 
 	tf := &TemplateFunctions{
@@ -535,7 +492,7 @@ func (c *CreateClusterCmd) Run() error {
 	l.cluster.Spec = *completed
 	tf.cluster = l.cluster
 
-	err = l.cluster.Validate()
+	err = l.cluster.Validate(true)
 	if err != nil {
 		return fmt.Errorf("Completed cluster failed validation: %v", err)
 	}
@@ -554,33 +511,61 @@ func (c *CreateClusterCmd) Run() error {
 
 	switch c.Target {
 	case "direct":
-		switch c.Cluster.Spec.CloudProvider {
-		case "gce":
-			target = gce.NewGCEAPITarget(cloud.(*gce.GCECloud))
-		case "aws":
-			target = awsup.NewAWSAPITarget(cloud.(*awsup.AWSCloud))
-		default:
-			return fmt.Errorf("direct configuration not supported with CloudProvider:%q", c.Cluster.Spec.CloudProvider)
-		}
+		target = provider.NewAPITarget(cloud)
 
 	case "terraform":
 		checkExisting = false
 		outDir := path.Join(c.OutDir, "terraform")
-		target = terraform.NewTerraformTarget(cloud, region, project, outDir)
+		tfTarget := terraform.NewTerraformTarget(cloud, region, c.Cluster.Spec.Project, outDir)
+		tfTarget.ProviderName, tfTarget.ProviderConfig = provider.TerraformProvider(cloud)
+		tfTarget.Module = c.TerraformModule
+		if err := tfTarget.AddOutputVariable("cluster_name", terraform.LiteralFromStringValue(c.Cluster.Name)); err != nil {
+			return err
+		}
+		if c.Cluster.Spec.MasterPublicName != "" {
+			if err := tfTarget.AddOutputVariable("master_dns_name", terraform.LiteralFromStringValue(c.Cluster.Spec.MasterPublicName)); err != nil {
+				return err
+			}
+		}
+		target = tfTarget
+
+	case "cloudformation":
+		if c.Cluster.Spec.CloudProvider != "aws" {
+			return fmt.Errorf("cloudformation output is only supported on AWS")
+		}
+		checkExisting = false
+		outDir := path.Join(c.OutDir, "cloudformation")
+		target = cloudformation.NewCloudformationTarget(cloud, region, outDir)
 
 	case "dryrun":
-		target = fi.NewDryRunTarget(os.Stdout)
+		dryRunTarget := fi.NewDryRunTarget(os.Stdout)
+		dryRunTarget.Format = c.PlanFormat
+		target = dryRunTarget
 	default:
 		return fmt.Errorf("unsupported target type %q", c.Target)
 	}
 
+	if c.ApplyPlan != "" {
+		err = c.verifyPlan(taskMap, cloud, keyStore, secretStore, checkExisting)
+		if err != nil {
+			return err
+		}
+	}
+
 	context, err := fi.NewContext(target, cloud, keyStore, secretStore, checkExisting)
 	if err != nil {
 		return fmt.Errorf("error building context: %v", err)
 	}
 	defer context.Close()
+	context.RunTasksOptions = c.RunTasksOptions
 
 	err = context.RunTasks(taskMap)
+	if context.Trace != nil {
+		// We write the trace even (especially) if we failed
+		if traceErr := c.writeTrace(context.Trace); traceErr != nil {
+			glog.Warningf("unable to write execution trace: %v", traceErr)
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("error running tasks: %v", err)
 	}
@@ -590,6 +575,82 @@ func (c *CreateClusterCmd) Run() error {
 		return fmt.Errorf("error closing target: %v", err)
 	}
 
+	if dryRunTarget, ok := target.(*fi.DryRunTarget); ok {
+		plan, err := dryRunTarget.BuildPlan(taskMap)
+		if err != nil {
+			return fmt.Errorf("error building plan: %v", err)
+		}
+		c.Plan = plan
+
+		if c.SavePlan != "" {
+			err = plan.WriteFile(c.SavePlan)
+			if err != nil {
+				return err
+			}
+			glog.Infof("Saved plan to %q", c.SavePlan)
+		}
+	}
+
+	return nil
+}
+
+// writeTrace saves the execution trace to the state store (unless this is a dry-run), and to TraceFile if set
+func (c *CreateClusterCmd) writeTrace(trace *fi.ExecutionTrace) error {
+	data, err := trace.ToJSON()
+	if err != nil {
+		return err
+	}
+
+	if c.Target != "dryrun" {
+		p := c.StateStore.VFSPath().Join(PathExecutionTrace)
+		err = p.WriteFile(data)
+		if err != nil {
+			return fmt.Errorf("error writing execution trace to %s: %v", p, err)
+		}
+	}
+
+	if c.TraceFile != "" {
+		err = ioutil.WriteFile(c.TraceFile, data, 0644)
+		if err != nil {
+			return fmt.Errorf("error writing execution trace to %q: %v", c.TraceFile, err)
+		}
+	}
+	return nil
+}
+
+// verifyPlan computes the changes we would make now, and checks that they match the saved plan.
+// This means we only apply changes that have been reviewed, even if the live state has changed since then.
+func (c *CreateClusterCmd) verifyPlan(taskMap map[string]fi.Task, cloud fi.Cloud, keyStore fi.CAStore, secretStore fi.SecretStore, checkExisting bool) error {
+	saved, err := fi.ReadPlanFile(c.ApplyPlan)
+	if err != nil {
+		return err
+	}
+
+	target := fi.NewDryRunTarget(ioutil.Discard)
+	context, err := fi.NewContext(target, cloud, keyStore, secretStore, checkExisting)
+	if err != nil {
+		return fmt.Errorf("error building context: %v", err)
+	}
+	defer context.Close()
+	context.RunTasksOptions = c.RunTasksOptions
+
+	err = context.RunTasks(taskMap)
+	if err != nil {
+		return fmt.Errorf("error computing plan: %v", err)
+	}
+
+	live, err := target.BuildPlan(taskMap)
+	if err != nil {
+		return fmt.Errorf("error building plan: %v", err)
+	}
+
+	diffs := saved.Diff(live)
+	if len(diffs) != 0 {
+		for _, d := range diffs {
+			glog.Warningf("plan mismatch: %s", d)
+		}
+		return fmt.Errorf("the changes to be applied no longer match the plan in %q; please review a new plan", c.ApplyPlan)
+	}
 	return nil
 }
 
@@ -660,28 +721,24 @@ func (c *CreateClusterCmd) populateMasters() ([]*api.InstanceGroup, error) {
 // defaultMachineType returns the default MachineType, based on the cloudprovider
 func (c *CreateClusterCmd) defaultMachineType() string {
 	cluster := c.Cluster
-	switch cluster.Spec.CloudProvider {
-	case "aws":
-		return DefaultNodeTypeAWS
-	case "gce":
-		return DefaultNodeTypeGCE
-	default:
+	provider, err := fi.FindCloudProvider(fi.CloudProviderID(cluster.Spec.CloudProvider))
+	if err != nil {
 		glog.V(2).Infof("Cannot set default MachineType for CloudProvider=%q", cluster.Spec.CloudProvider)
 		return ""
 	}
+	return provider.DefaultMachineType()
 }
 
 // defaultImage returns the default Image, based on the cloudprovider
 func (c *CreateClusterCmd) defaultImage() string {
 	// TODO: Use spec
 	cluster := c.Cluster
-	switch cluster.Spec.CloudProvider {
-	case "aws":
-		return "282335181503/k8s-1.3-debian-jessie-amd64-hvm-ebs-2016-06-18"
-	default:
+	provider, err := fi.FindCloudProvider(fi.CloudProviderID(cluster.Spec.CloudProvider))
+	if err != nil {
 		glog.V(2).Infof("Cannot set default Image for CloudProvider=%q", cluster.Spec.CloudProvider)
 		return ""
 	}
+	return provider.DefaultImage()
 }
 
 func (c *CreateClusterCmd) assignSubnets() error {
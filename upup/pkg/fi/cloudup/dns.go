@@ -0,0 +1,70 @@
+package cloudup
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+)
+
+// Path for the records shared by the hosts DNS provider in the state store, unless the cluster specifies dns.hostsStore
+const PathDNSHosts = "dns/hosts"
+
+// defaultDNS validates the DNS provider, and fills in the defaults for it
+func (c *CreateClusterCmd) defaultDNS() error {
+	dns := c.Cluster.Spec.DNS
+
+	if dns.Provider == "" {
+		switch c.Cluster.Spec.CloudProvider {
+		case "gce":
+			dns.Provider = "google-clouddns"
+		default:
+			dns.Provider = "route53"
+		}
+	}
+
+	switch dns.Provider {
+	case "route53", "google-clouddns":
+		// No options
+
+	case "rfc2136":
+		if dns.Server == "" {
+			return fmt.Errorf("dns.server must be specified with the rfc2136 DNS provider")
+		}
+		if dns.TSIGKeyName != "" && dns.TSIGSecretFile == "" {
+			return fmt.Errorf("dns.tsigSecretFile must be specified with dns.tsigKeyName")
+		}
+
+	case "hosts":
+		var store vfs.Path
+		if dns.HostsStore != "" {
+			p, err := vfs.Context.BuildVfsPath(dns.HostsStore)
+			if err != nil {
+				return fmt.Errorf("error parsing dns.hostsStore %q: %v", dns.HostsStore, err)
+			}
+			store = p
+		} else {
+			store = c.StateStore.VFSPath().Join(PathDNSHosts)
+		}
+		if !vfs.IsClusterReadable(store) {
+			return fmt.Errorf("dns hosts store %s must be readable from the cluster", store)
+		}
+		dns.HostsStore = store.Path()
+
+		// Every machine publishes its own records
+		if s3Path, ok := store.(*vfs.S3Path); ok {
+			if c.Cluster.Spec.MasterPermissions == nil {
+				c.Cluster.Spec.MasterPermissions = &api.CloudPermissions{}
+			}
+			c.Cluster.Spec.MasterPermissions.AddS3Bucket(s3Path.Bucket())
+			if c.Cluster.Spec.NodePermissions == nil {
+				c.Cluster.Spec.NodePermissions = &api.CloudPermissions{}
+			}
+			c.Cluster.Spec.NodePermissions.AddS3Bucket(s3Path.Bucket())
+		}
+
+	default:
+		return fmt.Errorf("unknown dns.provider %q (must be route53, google-clouddns, rfc2136 or hosts)", dns.Provider)
+	}
+
+	return nil
+}
@@ -0,0 +1,23 @@
+package cloudup
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+)
+
+// Path for etcd snapshots in the state store, unless the cluster specifies etcdBackup.store
+const PathEtcdBackups = "backups/etcd"
+
+// EtcdBackupStore returns the VFS path where the snapshots of etcd are stored for the cluster
+func EtcdBackupStore(stateStore fi.StateStore, cluster *api.Cluster) (vfs.Path, error) {
+	if cluster.Spec.EtcdBackup != nil && cluster.Spec.EtcdBackup.Store != "" {
+		p, err := vfs.Context.BuildVfsPath(cluster.Spec.EtcdBackup.Store)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing etcdBackup.store %q: %v", cluster.Spec.EtcdBackup.Store, err)
+		}
+		return p, nil
+	}
+	return stateStore.VFSPath().Join(PathEtcdBackups), nil
+}
@@ -1,7 +1,11 @@
 package gce
 
 import (
+	"bytes"
+	"fmt"
 	"google.golang.org/api/googleapi"
+	"strconv"
+	"strings"
 )
 
 func IsNotFound(err error) bool {
@@ -28,3 +32,75 @@ func IsNotReady(err error) bool {
 	}
 	return false
 }
+
+// IsInUse returns true if the error reports that the resource is still used by another resource
+func IsInUse(err error) bool {
+	apiErr, ok := err.(*googleapi.Error)
+	if !ok {
+		return false
+	}
+	for _, e := range apiErr.Errors {
+		if e.Reason == "resourceInUseByAnotherResource" {
+			return true
+		}
+	}
+	return false
+}
+
+// LastComponent returns the last component of a URL, i.e. anything after the last slash
+// If there is no slash, returns the whole string
+func LastComponent(s string) string {
+	lastSlash := strings.LastIndex(s, "/")
+	if lastSlash != -1 {
+		s = s[lastSlash+1:]
+	}
+	return s
+}
+
+// MaxLabelValueLength is the maximum length of a GCE label value
+const MaxLabelValueLength = 63
+
+// SafeObjectName converts a name (e.g. a cluster name) so that it can be used in the name of a GCE resource,
+// which only allows lowercase letters, digits and -.  Unlike EncodeLabelValue, this cannot be reversed.
+func SafeObjectName(s string) string {
+	s = strings.Replace(s, ".", "-", -1)
+	return strings.ToLower(s)
+}
+
+// EncodeLabelValue encodes a value (e.g. a cluster name or an etcd cluster spec) so that it is a valid GCE label value:
+// labels only allow lowercase letters, digits, _ and -.  Lowercase letters, digits and - are kept as they are;
+// every other byte is escaped as _ followed by two hex digits, so that DecodeLabelValue can reverse the encoding.
+func EncodeLabelValue(s string) string {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// DecodeLabelValue reverses EncodeLabelValue
+func DecodeLabelValue(s string) (string, error) {
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '_' {
+			b.WriteByte(c)
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", fmt.Errorf("invalid escape in label value %q", s)
+		}
+		v, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in label value %q", s)
+		}
+		b.WriteByte(byte(v))
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,48 @@
+package gce
+
+import (
+	"testing"
+)
+
+func TestEncodeLabelValueRoundTrip(t *testing.T) {
+	grid := []struct {
+		value   string
+		encoded string
+	}{
+		{value: "", encoded: ""},
+		{value: "simple", encoded: "simple"},
+		{value: "kubernetes.example.com", encoded: "kubernetes_2eexample_2ecom"},
+		{value: "my_cluster.Example.com", encoded: "my_5fcluster_2e_45xample_2ecom"},
+		{value: "my-cluster", encoded: "my-cluster"},
+		{value: "a/a,b,c", encoded: "a_2fa_2cb_2cc"},
+		{value: "us-central1-a/us-central1-a,us-central1-b", encoded: "us-central1-a_2fus-central1-a_2cus-central1-b"},
+	}
+	for _, g := range grid {
+		encoded := EncodeLabelValue(g.value)
+		if encoded != g.encoded {
+			t.Errorf("EncodeLabelValue(%q): expected %q, got %q", g.value, g.encoded, encoded)
+		}
+		decoded, err := DecodeLabelValue(encoded)
+		if err != nil {
+			t.Errorf("DecodeLabelValue(%q): unexpected error: %v", encoded, err)
+			continue
+		}
+		if decoded != g.value {
+			t.Errorf("DecodeLabelValue(%q): expected %q, got %q", encoded, g.value, decoded)
+		}
+	}
+}
+
+func TestDecodeLabelValueInvalid(t *testing.T) {
+	for _, s := range []string{"_", "_2", "abc_", "abc_zz", "a_2g"} {
+		if _, err := DecodeLabelValue(s); err == nil {
+			t.Errorf("DecodeLabelValue(%q): expected error", s)
+		}
+	}
+}
+
+func TestSafeObjectName(t *testing.T) {
+	if actual := SafeObjectName("Kubernetes.Example.com"); actual != "kubernetes-example-com" {
+		t.Errorf("unexpected SafeObjectName: %q", actual)
+	}
+}
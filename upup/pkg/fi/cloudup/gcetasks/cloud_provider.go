@@ -0,0 +1,104 @@
+package gcetasks
+
+import (
+	"fmt"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"strings"
+	"text/template"
+)
+
+const DefaultMachineTypeGCE = "n1-standard-2"
+
+// gceCloudProvider is the fi.CloudProvider for GCE
+type gceCloudProvider struct {
+}
+
+var _ fi.CloudProvider = &gceCloudProvider{}
+
+func init() {
+	fi.RegisterCloudProvider(fi.CloudProviderGCE, &gceCloudProvider{})
+}
+
+func (p *gceCloudProvider) BuildCloud(clusterName string, zones []string, project string) (fi.Cloud, error) {
+	region := ""
+	for _, zone := range zones {
+		tokens := strings.Split(zone, "-")
+		if len(tokens) <= 2 {
+			return nil, fmt.Errorf("Invalid GCE Zone: %v", zone)
+		}
+		zoneRegion := tokens[0] + "-" + tokens[1]
+		if region != "" && zoneRegion != region {
+			return nil, fmt.Errorf("Clusters cannot span multiple regions")
+		}
+
+		region = zoneRegion
+	}
+
+	if project == "" {
+		return nil, fmt.Errorf("project is required for GCE")
+	}
+	gceCloud, err := gce.NewGCECloud(region, project)
+	if err != nil {
+		return nil, err
+	}
+
+	return gceCloud, nil
+}
+
+func (p *gceCloudProvider) ModelTag() string {
+	return "_gce"
+}
+
+func (p *gceCloudProvider) TaskTypes() map[string]interface{} {
+	return map[string]interface{}{
+		"persistentDisk":       &PersistentDisk{},
+		"instance":             &Instance{},
+		"instanceTemplate":     &InstanceTemplate{},
+		"network":              &Network{},
+		"managedInstanceGroup": &ManagedInstanceGroup{},
+		"firewallRule":         &FirewallRule{},
+		"ipAddress":            &IPAddress{},
+	}
+}
+
+func (p *gceCloudProvider) TemplateFunctions(cloud fi.Cloud) template.FuncMap {
+	return template.FuncMap{}
+}
+
+func (p *gceCloudProvider) Region(cloud fi.Cloud) string {
+	return cloud.(*gce.GCECloud).Region
+}
+
+func (p *gceCloudProvider) DefaultMachineType() string {
+	return DefaultMachineTypeGCE
+}
+
+func (p *gceCloudProvider) DefaultImage() string {
+	return ""
+}
+
+func (p *gceCloudProvider) NewAPITarget(cloud fi.Cloud) fi.Target {
+	return gce.NewGCEAPITarget(cloud.(*gce.GCECloud))
+}
+
+func (p *gceCloudProvider) TerraformProvider(cloud fi.Cloud) (string, map[string]interface{}) {
+	gceCloud := cloud.(*gce.GCECloud)
+
+	config := make(map[string]interface{})
+	config["project"] = gceCloud.Project
+	config["region"] = gceCloud.Region
+	return "google", config
+}
+
+func (p *gceCloudProvider) ListResources(cloud fi.Cloud, clusterName string) (map[string]*fi.ResourceTracker, error) {
+	return listResourcesGCE(cloud.(*gce.GCECloud), clusterName)
+}
+
+func (p *gceCloudProvider) ListInstanceGroups(cloud fi.Cloud) ([]*fi.CloudInstanceGroup, error) {
+	return nil, fmt.Errorf("listing instance groups is not yet supported on GCE")
+}
+
+func (p *gceCloudProvider) IsDependencyViolation(err error) bool {
+	return isGCEDependencyViolation(err)
+}
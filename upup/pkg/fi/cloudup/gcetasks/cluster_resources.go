@@ -0,0 +1,537 @@
+package gcetasks
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"strings"
+	"time"
+)
+
+// The metadata key on instances and instance templates that holds the name of the cluster
+const gceMetadataKeyClusterName = "cluster-name"
+
+// The label on persistent disks that holds the (encoded) name of the cluster
+const gceLabelClusterName = "k8s-io-cluster-name"
+
+// The network that GCE creates in every project; we never delete it
+const gceDefaultNetwork = "default"
+
+// gceResources collects the GCE resources of a cluster; the GCE APIs don't support tags on most resources,
+// so we find resources through their relationships with the instances & templates we can identify
+type gceResources struct {
+	cloud       *gce.GCECloud
+	clusterName string
+	// safeName is the cluster name as encoded into GCE labels
+	safeName string
+
+	zones     []string
+	resources map[string]*fi.ResourceTracker
+}
+
+// listResourcesGCE finds all the GCE resources that belong to the cluster
+func listResourcesGCE(cloud *gce.GCECloud, clusterName string) (map[string]*fi.ResourceTracker, error) {
+	r := &gceResources{
+		cloud:       cloud,
+		clusterName: clusterName,
+		safeName:    gce.EncodeLabelValue(clusterName),
+		resources:   make(map[string]*fi.ResourceTracker),
+	}
+
+	zones, err := r.listZones()
+	if err != nil {
+		return nil, err
+	}
+	r.zones = zones
+
+	// Order matters: later listers look for references to the resources found by earlier listers
+	listFunctions := []func() error{
+		r.listInstanceTemplates,
+		r.listInstanceGroupManagers,
+		r.listInstances,
+		r.listDisks,
+		r.listAddresses,
+		r.listNetworks,
+		r.listSubnetworks,
+		r.listFirewallRules,
+	}
+	for _, fn := range listFunctions {
+		if err := fn(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r.resources, nil
+}
+
+func (r *gceResources) add(t *fi.ResourceTracker) {
+	r.resources[t.Type+":"+t.ID] = t
+}
+
+func (r *gceResources) has(resourceType string, id string) bool {
+	return r.resources[resourceType+":"+id] != nil
+}
+
+// matchesName returns true if the resource is named after the cluster, as in kubernetes-master-<clusterName>.
+// We don't match on the encoded name, because e.g. a.example.com and b-a.example.com encode to overlapping names.
+func (r *gceResources) matchesName(name string) bool {
+	return name == r.clusterName || strings.HasSuffix(name, "-"+r.clusterName)
+}
+
+func (r *gceResources) matchesMetadata(metadata *compute.Metadata) bool {
+	if metadata == nil {
+		return false
+	}
+	for _, item := range metadata.Items {
+		if item.Key == gceMetadataKeyClusterName && item.Value != nil {
+			return strings.TrimSpace(*item.Value) == r.clusterName
+		}
+	}
+	return false
+}
+
+// listZones returns the zones in the region of the cluster
+func (r *gceResources) listZones() ([]string, error) {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE zones")
+	var zones []string
+	pageToken := ""
+	for {
+		response, err := c.Compute.Zones.List(c.Project).PageToken(pageToken).Do()
+		if err != nil {
+			return nil, fmt.Errorf("error listing zones: %v", err)
+		}
+		for _, zone := range response.Items {
+			if gce.LastComponent(zone.Region) == c.Region {
+				zones = append(zones, zone.Name)
+			}
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return zones, nil
+}
+
+func (r *gceResources) listInstanceTemplates() error {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE instance templates")
+	pageToken := ""
+	for {
+		response, err := c.Compute.InstanceTemplates.List(c.Project).PageToken(pageToken).Do()
+		if err != nil {
+			return fmt.Errorf("error listing instance templates: %v", err)
+		}
+		for _, t := range response.Items {
+			if t.Properties == nil || !r.matchesMetadata(t.Properties.Metadata) {
+				continue
+			}
+
+			tracker := &fi.ResourceTracker{
+				Name:    t.Name,
+				ID:      t.Name,
+				Type:    "instance-template",
+				Deleter: deleteGCEInstanceTemplate,
+			}
+			for _, ni := range t.Properties.NetworkInterfaces {
+				tracker.Blocks = append(tracker.Blocks, "network:"+gce.LastComponent(ni.Network))
+			}
+			r.add(tracker)
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listInstanceGroupManagers() error {
+	c := r.cloud
+
+	for _, zone := range r.zones {
+		glog.V(2).Infof("Listing GCE instance group managers in %s", zone)
+		pageToken := ""
+		for {
+			response, err := c.Compute.InstanceGroupManagers.List(c.Project, zone).PageToken(pageToken).Do()
+			if err != nil {
+				return fmt.Errorf("error listing instance group managers: %v", err)
+			}
+			for _, mig := range response.Items {
+				template := gce.LastComponent(mig.InstanceTemplate)
+				if !r.has("instance-template", template) {
+					continue
+				}
+
+				tracker := &fi.ResourceTracker{
+					Name:    mig.Name,
+					ID:      zone + "/" + mig.Name,
+					Type:    "instance-group-manager",
+					Deleter: deleteGCEInstanceGroupManager,
+				}
+				tracker.Blocks = append(tracker.Blocks, "instance-template:"+template)
+				r.add(tracker)
+			}
+			pageToken = response.NextPageToken
+			if pageToken == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listInstances() error {
+	c := r.cloud
+
+	for _, zone := range r.zones {
+		glog.V(2).Infof("Listing GCE instances in %s", zone)
+		pageToken := ""
+		for {
+			response, err := c.Compute.Instances.List(c.Project, zone).PageToken(pageToken).Do()
+			if err != nil {
+				return fmt.Errorf("error listing instances: %v", err)
+			}
+			for _, i := range response.Items {
+				if !r.matchesMetadata(i.Metadata) {
+					continue
+				}
+
+				tracker := &fi.ResourceTracker{
+					Name:    i.Name,
+					ID:      zone + "/" + i.Name,
+					Type:    "instance",
+					Deleter: deleteGCEInstance,
+				}
+
+				// Instances in a managed instance group are deleted along with the group;
+				// deleting them first would just cause the group to recreate them
+				if i.Metadata != nil {
+					for _, item := range i.Metadata.Items {
+						if item.Key == "created-by" && item.Value != nil {
+							mig := "instance-group-manager:" + zone + "/" + gce.LastComponent(*item.Value)
+							if r.resources[mig] != nil {
+								tracker.Blocked = append(tracker.Blocked, mig)
+							}
+						}
+					}
+				}
+
+				for _, ni := range i.NetworkInterfaces {
+					tracker.Blocks = append(tracker.Blocks, "network:"+gce.LastComponent(ni.Network))
+				}
+				r.add(tracker)
+			}
+			pageToken = response.NextPageToken
+			if pageToken == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listDisks() error {
+	c := r.cloud
+
+	for _, zone := range r.zones {
+		glog.V(2).Infof("Listing GCE disks in %s", zone)
+		pageToken := ""
+		for {
+			response, err := c.Compute.Disks.List(c.Project, zone).PageToken(pageToken).Do()
+			if err != nil {
+				return fmt.Errorf("error listing disks: %v", err)
+			}
+			for _, d := range response.Items {
+				if d.Labels[gceLabelClusterName] != r.safeName && !r.matchesName(d.Name) {
+					continue
+				}
+
+				tracker := &fi.ResourceTracker{
+					Name:    d.Name,
+					ID:      zone + "/" + d.Name,
+					Type:    "disk",
+					Deleter: deleteGCEDisk,
+				}
+				for _, user := range d.Users {
+					instance := zone + "/" + gce.LastComponent(user)
+					if r.has("instance", instance) {
+						tracker.Blocked = append(tracker.Blocked, "instance:"+instance)
+					}
+				}
+				r.add(tracker)
+			}
+			pageToken = response.NextPageToken
+			if pageToken == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listAddresses() error {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE addresses")
+	pageToken := ""
+	for {
+		response, err := c.Compute.Addresses.List(c.Project, c.Region).PageToken(pageToken).Do()
+		if err != nil {
+			return fmt.Errorf("error listing addresses: %v", err)
+		}
+		for _, a := range response.Items {
+			// Addresses used by the cluster instances belong to the cluster too
+			var blocked []string
+			for _, user := range a.Users {
+				u, err := gce.ParseGoogleCloudURL(user)
+				if err != nil {
+					glog.Warningf("ignoring unexpected user %q of address %q: %v", user, a.Name, err)
+					continue
+				}
+				if u.Type == "instances" && r.has("instance", u.Zone+"/"+u.Name) {
+					blocked = append(blocked, "instance:"+u.Zone+"/"+u.Name)
+				}
+			}
+			if len(blocked) == 0 && !r.matchesName(a.Name) {
+				continue
+			}
+
+			r.add(&fi.ResourceTracker{
+				Name:    a.Name,
+				ID:      a.Name,
+				Type:    "address",
+				Deleter: deleteGCEAddress,
+				Blocked: blocked,
+			})
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listNetworks() error {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE networks")
+	response, err := c.Compute.Networks.List(c.Project).Do()
+	if err != nil {
+		return fmt.Errorf("error listing networks: %v", err)
+	}
+	for _, n := range response.Items {
+		if n.Name == gceDefaultNetwork || !r.matchesName(n.Name) {
+			continue
+		}
+
+		r.add(&fi.ResourceTracker{
+			Name:    n.Name,
+			ID:      n.Name,
+			Type:    "network",
+			Deleter: deleteGCENetwork,
+		})
+	}
+	return nil
+}
+
+func (r *gceResources) listSubnetworks() error {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE subnetworks")
+	pageToken := ""
+	for {
+		response, err := c.Compute.Subnetworks.List(c.Project, c.Region).PageToken(pageToken).Do()
+		if err != nil {
+			return fmt.Errorf("error listing subnetworks: %v", err)
+		}
+		for _, s := range response.Items {
+			network := gce.LastComponent(s.Network)
+			if !r.has("network", network) {
+				continue
+			}
+
+			r.add(&fi.ResourceTracker{
+				Name:    s.Name,
+				ID:      s.Name,
+				Type:    "subnet",
+				Deleter: deleteGCESubnetwork,
+				Blocks:  []string{"network:" + network},
+			})
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return nil
+}
+
+func (r *gceResources) listFirewallRules() error {
+	c := r.cloud
+
+	glog.V(2).Infof("Listing GCE firewall rules")
+	pageToken := ""
+	for {
+		response, err := c.Compute.Firewalls.List(c.Project).PageToken(pageToken).Do()
+		if err != nil {
+			return fmt.Errorf("error listing firewall rules: %v", err)
+		}
+		for _, f := range response.Items {
+			network := gce.LastComponent(f.Network)
+			if !r.has("network", network) && !r.matchesName(f.Name) {
+				continue
+			}
+
+			r.add(&fi.ResourceTracker{
+				Name:    f.Name,
+				ID:      f.Name,
+				Type:    "firewall",
+				Deleter: deleteGCEFirewallRule,
+				Blocks:  []string{"network:" + network},
+			})
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return nil
+}
+
+// splitZonalID splits the ID of a zonal resource, which we build as <zone>/<name>
+func splitZonalID(id string) (string, string, error) {
+	tokens := strings.SplitN(id, "/", 2)
+	if len(tokens) != 2 {
+		return "", "", fmt.Errorf("unexpected zonal resource id %q", id)
+	}
+	return tokens[0], tokens[1], nil
+}
+
+func deleteGCEInstanceTemplate(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	glog.V(2).Infof("Deleting GCE instance template %q", t.ID)
+	op, err := c.Compute.InstanceTemplates.Delete(c.Project, t.ID).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCEInstanceGroupManager(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	zone, name, err := splitZonalID(t.ID)
+	if err != nil {
+		return err
+	}
+
+	glog.V(2).Infof("Deleting GCE instance group manager %q", t.ID)
+	op, err := c.Compute.InstanceGroupManagers.Delete(c.Project, zone, name).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCEInstance(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	zone, name, err := splitZonalID(t.ID)
+	if err != nil {
+		return err
+	}
+
+	glog.V(2).Infof("Deleting GCE instance %q", t.ID)
+	op, err := c.Compute.Instances.Delete(c.Project, zone, name).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCEDisk(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	zone, name, err := splitZonalID(t.ID)
+	if err != nil {
+		return err
+	}
+
+	glog.V(2).Infof("Deleting GCE disk %q", t.ID)
+	op, err := c.Compute.Disks.Delete(c.Project, zone, name).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCEAddress(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	glog.V(2).Infof("Deleting GCE address %q", t.ID)
+	op, err := c.Compute.Addresses.Delete(c.Project, c.Region, t.ID).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCENetwork(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	glog.V(2).Infof("Deleting GCE network %q", t.ID)
+	op, err := c.Compute.Networks.Delete(c.Project, t.ID).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCESubnetwork(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	glog.V(2).Infof("Deleting GCE subnetwork %q", t.ID)
+	op, err := c.Compute.Subnetworks.Delete(c.Project, c.Region, t.ID).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+func deleteGCEFirewallRule(cloud fi.Cloud, t *fi.ResourceTracker) error {
+	c := cloud.(*gce.GCECloud)
+
+	glog.V(2).Infof("Deleting GCE firewall rule %q", t.ID)
+	op, err := c.Compute.Firewalls.Delete(c.Project, t.ID).Do()
+	return waitGCEDelete(c, t, op, err)
+}
+
+// isGCEDependencyViolation returns true if the error reports that a GCE resource can't be deleted yet,
+// because it is still used by another resource (or is still being created)
+func isGCEDependencyViolation(err error) bool {
+	return gce.IsInUse(err) || gce.IsNotReady(err)
+}
+
+// waitGCEDelete checks the result of a delete call, and waits for the delete operation to complete.
+// A resource that is already gone is treated as deleted.
+func waitGCEDelete(c *gce.GCECloud, t *fi.ResourceTracker, op *compute.Operation, err error) error {
+	if err != nil {
+		if gce.IsNotFound(err) {
+			return nil
+		}
+		if isGCEDependencyViolation(err) {
+			return err
+		}
+		return fmt.Errorf("error deleting %s %q: %v", t.Type, t.ID, err)
+	}
+
+	for op.Status != "DONE" {
+		// TODO: Exponential backoff or similar
+		time.Sleep(1 * time.Second)
+
+		name := op.Name
+		if op.Zone != "" {
+			op, err = c.Compute.ZoneOperations.Get(c.Project, gce.LastComponent(op.Zone), name).Do()
+		} else if op.Region != "" {
+			op, err = c.Compute.RegionOperations.Get(c.Project, gce.LastComponent(op.Region), name).Do()
+		} else {
+			op, err = c.Compute.GlobalOperations.Get(c.Project, name).Do()
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching status of operation %q: %v", name, err)
+		}
+		glog.V(4).Infof("operation %q status=%v", name, op.Status)
+	}
+
+	if op.Error != nil && len(op.Error.Errors) != 0 {
+		return fmt.Errorf("error deleting %s %q: %v", t.Type, t.ID, op.Error.Errors[0].Message)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+package gcetasks
+
+import (
+	"encoding/json"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testGCEProject = "test-project"
+
+const testGCEBaseURL = "https://www.googleapis.com/compute/v1/projects/" + testGCEProject
+
+// fakeGCECompute serves the compute API calls made when deleting a cluster, from canned list responses
+type fakeGCECompute struct {
+	mutex sync.Mutex
+
+	// lists maps the path of a list call (relative to the project) to the items returned
+	lists map[string]interface{}
+	// errors maps the path of a delete call to the reason of the error it returns
+	errors map[string]string
+	// deleted records the paths of the delete calls
+	deleted []string
+}
+
+func (f *fakeGCECompute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/"+testGCEProject+"/")
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		if strings.Contains(p, "/operations/") {
+			json.NewEncoder(w).Encode(&compute.Operation{Name: p, Status: "DONE"})
+			return
+		}
+		items, found := f.lists[p]
+		if !found {
+			items = []interface{}{}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+
+	case "DELETE":
+		f.deleted = append(f.deleted, p)
+		if reason := f.errors[p]; reason != "" {
+			code := http.StatusBadRequest
+			if reason == "notFound" {
+				code = http.StatusNotFound
+			}
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    code,
+					"message": reason,
+					"errors":  []interface{}{map[string]interface{}{"reason": reason}},
+				},
+			})
+			return
+		}
+		// A pending operation, so that we exercise waiting for it
+		json.NewEncoder(w).Encode(&compute.Operation{Name: "op-1", Status: "PENDING", Zone: testGCEBaseURL + "/zones/us-central1-a"})
+
+	default:
+		http.Error(w, "unexpected method", http.StatusMethodNotAllowed)
+	}
+}
+
+func newFakeGCECloud(t *testing.T, f *fakeGCECompute) (*gce.GCECloud, func()) {
+	server := httptest.NewServer(f)
+	svc, err := compute.New(http.DefaultClient)
+	if err != nil {
+		server.Close()
+		t.Fatalf("error building compute service: %v", err)
+	}
+	svc.BasePath = server.URL + "/"
+	return &gce.GCECloud{Compute: svc, Project: testGCEProject, Region: "us-central1"}, server.Close
+}
+
+func clusterMetadata(clusterName string, extra ...*compute.MetadataItems) *compute.Metadata {
+	value := clusterName
+	items := []*compute.MetadataItems{{Key: gceMetadataKeyClusterName, Value: &value}}
+	return &compute.Metadata{Items: append(items, extra...)}
+}
+
+func buildFakeGCECompute() *fakeGCECompute {
+	network := testGCEBaseURL + "/global/networks/net-test.example.com"
+	migURL := testGCEBaseURL + "/zones/us-central1-a/instanceGroupManagers/nodes-group"
+	createdBy := migURL
+
+	return &fakeGCECompute{
+		lists: map[string]interface{}{
+			"zones": []*compute.Zone{
+				{Name: "us-central1-a", Region: testGCEBaseURL + "/regions/us-central1"},
+				{Name: "europe-west1-b", Region: testGCEBaseURL + "/regions/europe-west1"},
+			},
+			"global/instanceTemplates": []*compute.InstanceTemplate{
+				{
+					Name: "nodes-template",
+					Properties: &compute.InstanceProperties{
+						Metadata:          clusterMetadata("test.example.com"),
+						NetworkInterfaces: []*compute.NetworkInterface{{Network: network}},
+					},
+				},
+				{
+					Name:       "other-template",
+					Properties: &compute.InstanceProperties{Metadata: clusterMetadata("other.example.com")},
+				},
+			},
+			"zones/us-central1-a/instanceGroupManagers": []*compute.InstanceGroupManager{
+				{Name: "nodes-group", InstanceTemplate: testGCEBaseURL + "/global/instanceTemplates/nodes-template"},
+				{Name: "other-group", InstanceTemplate: testGCEBaseURL + "/global/instanceTemplates/other-template"},
+			},
+			"zones/us-central1-a/instances": []*compute.Instance{
+				{
+					Name:              "master",
+					Metadata:          clusterMetadata("test.example.com"),
+					NetworkInterfaces: []*compute.NetworkInterface{{Network: network}},
+				},
+				{
+					Name:              "nodes-abcd",
+					Metadata:          clusterMetadata("test.example.com", &compute.MetadataItems{Key: "created-by", Value: &createdBy}),
+					NetworkInterfaces: []*compute.NetworkInterface{{Network: network}},
+				},
+				// An instance of a similarly named cluster
+				{Name: "other", Metadata: clusterMetadata("a-test.example.com")},
+			},
+			"zones/us-central1-a/disks": []*compute.Disk{
+				{
+					Name:   "etcd-main-a-test-example-com",
+					Labels: map[string]string{gceLabelClusterName: gce.EncodeLabelValue("test.example.com")},
+					Users:  []string{testGCEBaseURL + "/zones/us-central1-a/instances/master"},
+				},
+				{
+					Name:   "etcd-main-a-a-test-example-com",
+					Labels: map[string]string{gceLabelClusterName: gce.EncodeLabelValue("a-test.example.com")},
+				},
+			},
+			"regions/us-central1/addresses": []*compute.Address{
+				{Name: "master-ip", Users: []string{testGCEBaseURL + "/zones/us-central1-a/instances/master"}},
+				{Name: "kubernetes-master-test.example.com"},
+				{Name: "unrelated-ip"},
+			},
+			"global/networks": []*compute.Network{
+				{Name: "default"},
+				{Name: "net-test.example.com"},
+			},
+			"regions/us-central1/subnetworks": []*compute.Subnetwork{
+				{Name: "subnet-a", Network: network},
+				{Name: "default", Network: testGCEBaseURL + "/global/networks/default"},
+			},
+			"global/firewalls": []*compute.Firewall{
+				{Name: "net-test.example.com-default-ssh", Network: network},
+				{Name: "kubernetes-master-https-test.example.com", Network: testGCEBaseURL + "/global/networks/default"},
+				{Name: "default-allow-ssh", Network: testGCEBaseURL + "/global/networks/default"},
+			},
+		},
+	}
+}
+
+func TestListResourcesGCE(t *testing.T) {
+	f := buildFakeGCECompute()
+	cloud, cleanup := newFakeGCECloud(t, f)
+	defer cleanup()
+
+	resources, err := listResourcesGCE(cloud, "test.example.com")
+	if err != nil {
+		t.Fatalf("error listing resources: %v", err)
+	}
+
+	var keys []string
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	expected := []string{
+		"address:kubernetes-master-test.example.com",
+		"address:master-ip",
+		"disk:us-central1-a/etcd-main-a-test-example-com",
+		"firewall:kubernetes-master-https-test.example.com",
+		"firewall:net-test.example.com-default-ssh",
+		"instance-group-manager:us-central1-a/nodes-group",
+		"instance-template:nodes-template",
+		"instance:us-central1-a/master",
+		"instance:us-central1-a/nodes-abcd",
+		"network:net-test.example.com",
+		"subnet:subnet-a",
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("unexpected resources:\n%v\nexpected:\n%v", keys, expected)
+	}
+
+	grid := []struct {
+		key     string
+		blocks  []string
+		blocked []string
+	}{
+		{"instance-template:nodes-template", []string{"network:net-test.example.com"}, nil},
+		{"instance-group-manager:us-central1-a/nodes-group", []string{"instance-template:nodes-template"}, nil},
+		// Instances in a managed group are deleted with the group
+		{"instance:us-central1-a/nodes-abcd", []string{"network:net-test.example.com"}, []string{"instance-group-manager:us-central1-a/nodes-group"}},
+		{"instance:us-central1-a/master", []string{"network:net-test.example.com"}, nil},
+		{"disk:us-central1-a/etcd-main-a-test-example-com", nil, []string{"instance:us-central1-a/master"}},
+		{"address:master-ip", nil, []string{"instance:us-central1-a/master"}},
+		{"subnet:subnet-a", []string{"network:net-test.example.com"}, nil},
+		{"firewall:net-test.example.com-default-ssh", []string{"network:net-test.example.com"}, nil},
+	}
+	for _, g := range grid {
+		r := resources[g.key]
+		if !reflect.DeepEqual(r.Blocks, g.blocks) || !reflect.DeepEqual(r.Blocked, g.blocked) {
+			t.Errorf("unexpected dependencies for %s: blocks=%v blocked=%v", g.key, r.Blocks, r.Blocked)
+		}
+	}
+}
+
+func TestDeleteGCEResources(t *testing.T) {
+	f := buildFakeGCECompute()
+	cloud, cleanup := newFakeGCECloud(t, f)
+	defer cleanup()
+
+	f.errors = map[string]string{
+		"zones/us-central1-a/disks/in-use":  "resourceInUseByAnotherResource",
+		"zones/us-central1-a/disks/gone":    "notFound",
+		"zones/us-central1-a/disks/invalid": "invalid",
+	}
+
+	if err := deleteGCEDisk(cloud, &fi.ResourceTracker{Type: "disk", ID: "us-central1-a/etcd"}); err != nil {
+		t.Errorf("unexpected error deleting disk: %v", err)
+	}
+
+	// A resource that is already gone has been deleted
+	if err := deleteGCEDisk(cloud, &fi.ResourceTracker{Type: "disk", ID: "us-central1-a/gone"}); err != nil {
+		t.Errorf("unexpected error deleting missing disk: %v", err)
+	}
+
+	err := deleteGCEDisk(cloud, &fi.ResourceTracker{Type: "disk", ID: "us-central1-a/in-use"})
+	if err == nil || !isGCEDependencyViolation(err) {
+		t.Errorf("expected dependency violation, got %v", err)
+	}
+
+	err = deleteGCEDisk(cloud, &fi.ResourceTracker{Type: "disk", ID: "us-central1-a/invalid"})
+	if err == nil || isGCEDependencyViolation(err) {
+		t.Errorf("expected other error, got %v", err)
+	}
+
+	if err := deleteGCEAddress(cloud, &fi.ResourceTracker{Type: "address", ID: "master-ip"}); err != nil {
+		t.Errorf("unexpected error deleting address: %v", err)
+	}
+
+	expected := []string{
+		"zones/us-central1-a/disks/etcd",
+		"zones/us-central1-a/disks/gone",
+		"zones/us-central1-a/disks/in-use",
+		"zones/us-central1-a/disks/invalid",
+		"regions/us-central1/addresses/master-ip",
+	}
+	if !reflect.DeepEqual(f.deleted, expected) {
+		t.Errorf("unexpected delete calls: %v", f.deleted)
+	}
+}
+
+func TestIsGCEDependencyViolation(t *testing.T) {
+	grid := []struct {
+		err      error
+		expected bool
+	}{
+		{&googleapi.Error{Code: 400, Errors: []googleapi.ErrorItem{{Reason: "resourceInUseByAnotherResource"}}}, true},
+		{&googleapi.Error{Code: 400, Errors: []googleapi.ErrorItem{{Reason: "resourceNotReady"}}}, true},
+		{&googleapi.Error{Code: 404, Errors: []googleapi.ErrorItem{{Reason: "notFound"}}}, false},
+		{&googleapi.Error{Code: 400}, false},
+	}
+	for _, g := range grid {
+		if actual := isGCEDependencyViolation(g.err); actual != g.expected {
+			t.Errorf("isGCEDependencyViolation(%v): expected %v", g.err, g.expected)
+		}
+	}
+}
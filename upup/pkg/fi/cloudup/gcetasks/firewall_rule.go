@@ -39,7 +39,7 @@ func (e *FirewallRule) Find(c *fi.Context) (*FirewallRule, error) {
 
 	actual := &FirewallRule{}
 	actual.Name = &r.Name
-	actual.Network = &Network{Name: fi.String(lastComponent(r.Network))}
+	actual.Network = &Network{Name: fi.String(gce.LastComponent(r.Network))}
 	actual.TargetTags = r.TargetTags
 	actual.SourceRanges = r.SourceRanges
 	actual.SourceTags = r.SourceTags
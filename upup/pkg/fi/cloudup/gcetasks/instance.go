@@ -59,8 +59,8 @@ func (e *Instance) Find(c *fi.Context) (*Instance, error) {
 	for _, tag := range r.Tags.Items {
 		actual.Tags = append(actual.Tags, tag)
 	}
-	actual.Zone = fi.String(lastComponent(r.Zone))
-	actual.MachineType = fi.String(lastComponent(r.MachineType))
+	actual.Zone = fi.String(gce.LastComponent(r.Zone))
+	actual.MachineType = fi.String(gce.LastComponent(r.MachineType))
 	actual.CanIPForward = &r.CanIpForward
 
 	if r.Scheduling != nil {
@@ -68,7 +68,7 @@ func (e *Instance) Find(c *fi.Context) (*Instance, error) {
 	}
 	if len(r.NetworkInterfaces) != 0 {
 		ni := r.NetworkInterfaces[0]
-		actual.Network = &Network{Name: fi.String(lastComponent(ni.Network))}
+		actual.Network = &Network{Name: fi.String(gce.LastComponent(ni.Network))}
 		if len(ni.AccessConfigs) != 0 {
 			ac := ni.AccessConfigs[0]
 			if ac.NatIP != "" {
@@ -96,7 +96,7 @@ func (e *Instance) Find(c *fi.Context) (*Instance, error) {
 			source := disk.Source
 
 			// TODO: Parse source URL instead of assuming same project/zone?
-			name := lastComponent(source)
+			name := gce.LastComponent(source)
 			d, err := cloud.Compute.Disks.Get(cloud.Project, *e.Zone, name).Do()
 			if err != nil {
 				if gce.IsNotFound(err) {
@@ -366,7 +366,7 @@ func (_ *Instance) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Instance) error
 }
 
 func waitCompletion(c *compute.Service, project string, op *compute.Operation) error {
-	zone := lastComponent(op.Zone)
+	zone := gce.LastComponent(op.Zone)
 	var status *compute.Operation
 	for {
 		var err error
@@ -454,7 +454,7 @@ func (_ *Instance) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *
 	tf := &terraformInstanceTemplate{
 		Name:         i.Name,
 		CanIPForward: i.CanIpForward,
-		MachineType:  lastComponent(i.MachineType),
+		MachineType:  gce.LastComponent(i.MachineType),
 		Zone:         i.Zone,
 		Tags:         i.Tags.Items,
 	}
@@ -473,7 +473,7 @@ func (_ *Instance) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *
 			DeviceName: d.DeviceName,
 
 			// TODO: Does this need to be a TF link?
-			Disk: lastComponent(d.Source),
+			Disk: gce.LastComponent(d.Source),
 		}
 		if d.InitializeParams != nil {
 			tfd.Disk = d.InitializeParams.DiskName
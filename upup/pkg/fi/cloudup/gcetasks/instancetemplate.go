@@ -56,7 +56,7 @@ func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	for _, tag := range p.Tags.Items {
 		actual.Tags = append(actual.Tags, tag)
 	}
-	actual.MachineType = fi.String(lastComponent(p.MachineType))
+	actual.MachineType = fi.String(gce.LastComponent(p.MachineType))
 	actual.CanIPForward = &p.CanIpForward
 
 	bootDiskImage, err := ShortenImageURL(cloud.Project, p.Disks[0].InitializeParams.SourceImage)
@@ -72,7 +72,7 @@ func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	}
 	if len(p.NetworkInterfaces) != 0 {
 		ni := p.NetworkInterfaces[0]
-		actual.Network = &Network{Name: fi.String(lastComponent(ni.Network))}
+		actual.Network = &Network{Name: fi.String(gce.LastComponent(ni.Network))}
 	}
 
 	for _, serviceAccount := range p.ServiceAccounts {
@@ -86,7 +86,7 @@ func (e *InstanceTemplate) Find(c *fi.Context) (*InstanceTemplate, error) {
 	//		source := disk.Source
 	//
 	//		// TODO: Parse source URL instead of assuming same project/zone?
-	//		name := lastComponent(source)
+	//		name := gce.LastComponent(source)
 	//		d, err := cloud.Compute.Disks.Get(cloud.Project, *e.Zone, name).Do()
 	//		if err != nil {
 	//			if gce.IsNotFound(err) {
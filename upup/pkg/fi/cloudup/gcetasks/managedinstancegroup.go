@@ -40,10 +40,10 @@ func (e *ManagedInstanceGroup) Find(c *fi.Context) (*ManagedInstanceGroup, error
 
 	actual := &ManagedInstanceGroup{}
 	actual.Name = &r.Name
-	actual.Zone = fi.String(lastComponent(r.Zone))
+	actual.Zone = fi.String(gce.LastComponent(r.Zone))
 	actual.BaseInstanceName = &r.BaseInstanceName
 	actual.TargetSize = &r.TargetSize
-	actual.InstanceTemplate = &InstanceTemplate{Name: fi.String(lastComponent(r.InstanceTemplate))}
+	actual.InstanceTemplate = &InstanceTemplate{Name: fi.String(gce.LastComponent(r.InstanceTemplate))}
 
 	return actual, nil
 }
@@ -101,6 +101,10 @@ type terraformInstanceGroupManager struct {
 }
 
 func (_ *ManagedInstanceGroup) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *ManagedInstanceGroup) error {
+	if err := t.AddOutputVariableArray("instance_group_manager_names", terraform.LiteralProperty("google_compute_instance_group_manager", *e.Name, "name")); err != nil {
+		return err
+	}
+
 	tf := &terraformInstanceGroupManager{
 		Name:             e.Name,
 		Zone:             e.Zone,
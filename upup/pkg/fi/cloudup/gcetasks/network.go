@@ -96,6 +96,10 @@ type terraformNetwork struct {
 }
 
 func (_ *Network) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *Network) error {
+	if err := t.AddOutputVariable("network_name", e.TerraformName()); err != nil {
+		return err
+	}
+
 	tf := &terraformNetwork{
 		Name: e.Name,
 		CIDR: e.CIDR,
@@ -7,7 +7,7 @@ import (
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
 	"k8s.io/kops/upup/pkg/fi/cloudup/terraform"
-	"strings"
+	"reflect"
 )
 
 //go:generate fitask -type=PersistentDisk
@@ -16,6 +16,7 @@ type PersistentDisk struct {
 	VolumeType *string
 	SizeGB     *int64
 	Zone       *string
+	Labels     map[string]string
 }
 
 var _ fi.CompareWithID = &PersistentDisk{}
@@ -24,16 +25,6 @@ func (e *PersistentDisk) CompareWithID() *string {
 	return e.Name
 }
 
-// Returns the last component of a URL, i.e. anything after the last slash
-// If there is no slash, returns the whole string
-func lastComponent(s string) string {
-	lastSlash := strings.LastIndex(s, "/")
-	if lastSlash != -1 {
-		s = s[lastSlash+1:]
-	}
-	return s
-}
-
 func (e *PersistentDisk) Find(c *fi.Context) (*PersistentDisk, error) {
 	cloud := c.Cloud.(*gce.GCECloud)
 
@@ -47,9 +38,10 @@ func (e *PersistentDisk) Find(c *fi.Context) (*PersistentDisk, error) {
 
 	actual := &PersistentDisk{}
 	actual.Name = &r.Name
-	actual.VolumeType = fi.String(lastComponent(r.Type))
-	actual.Zone = fi.String(lastComponent(r.Zone))
+	actual.VolumeType = fi.String(gce.LastComponent(r.Type))
+	actual.Zone = fi.String(gce.LastComponent(r.Zone))
 	actual.SizeGB = &r.SizeGb
+	actual.Labels = r.Labels
 
 	return actual, nil
 }
@@ -97,6 +89,7 @@ func (_ *PersistentDisk) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Persisten
 		Name:   *e.Name,
 		SizeGb: *e.SizeGB,
 		Type:   typeURL,
+		Labels: e.Labels,
 	}
 
 	if a == nil {
@@ -105,17 +98,38 @@ func (_ *PersistentDisk) RenderGCE(t *gce.GCEAPITarget, a, e, changes *Persisten
 			return fmt.Errorf("error creating PersistentDisk: %v", err)
 		}
 	} else {
-		return fmt.Errorf("Cannot apply changes to PersistentDisk: %v", changes)
+		if changes.Labels != nil {
+			// SetLabels needs the current fingerprint, to guard against concurrent changes
+			r, err := t.Cloud.Compute.Disks.Get(t.Cloud.Project, *e.Zone, *e.Name).Do()
+			if err != nil {
+				return fmt.Errorf("error reading PersistentDisk: %v", err)
+			}
+			request := &compute.ZoneSetLabelsRequest{
+				LabelFingerprint: r.LabelFingerprint,
+				Labels:           e.Labels,
+			}
+			_, err = t.Cloud.Compute.Disks.SetLabels(t.Cloud.Project, *e.Zone, *e.Name, request).Do()
+			if err != nil {
+				return fmt.Errorf("error setting labels on PersistentDisk: %v", err)
+			}
+			changes.Labels = nil
+		}
+
+		empty := &PersistentDisk{}
+		if !reflect.DeepEqual(empty, changes) {
+			return fmt.Errorf("Cannot apply changes to PersistentDisk: %v", changes)
+		}
 	}
 
 	return nil
 }
 
 type terraformDisk struct {
-	Name       *string `json:"name"`
-	VolumeType *string `json:"type"`
-	SizeGB     *int64  `json:"size"`
-	Zone       *string `json:"zone"`
+	Name       *string           `json:"name"`
+	VolumeType *string           `json:"type"`
+	SizeGB     *int64            `json:"size"`
+	Zone       *string           `json:"zone"`
+	Labels     map[string]string `json:"labels,omitempty"`
 }
 
 func (_ *PersistentDisk) RenderTerraform(t *terraform.TerraformTarget, a, e, changes *PersistentDisk) error {
@@ -124,6 +138,7 @@ func (_ *PersistentDisk) RenderTerraform(t *terraform.TerraformTarget, a, e, cha
 		VolumeType: e.VolumeType,
 		SizeGB:     e.SizeGB,
 		Zone:       e.Zone,
+		Labels:     e.Labels,
 	}
 	return t.RenderResource("google_compute_disk", *e.Name, tf)
 }
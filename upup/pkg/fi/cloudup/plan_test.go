@@ -0,0 +1,86 @@
+package cloudup
+
+import (
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// planTestTask is a task that is always missing, so it is always planned for creation
+type planTestTask struct {
+	Name *string
+}
+
+func (e *planTestTask) Find(c *fi.Context) (*planTestTask, error) {
+	return nil, nil
+}
+
+func (e *planTestTask) Run(c *fi.Context) error {
+	return fi.DefaultDeltaRunMethod(e, c)
+}
+
+func (_ *planTestTask) CheckChanges(a, e, changes *planTestTask) error {
+	return nil
+}
+
+func TestVerifyPlan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := "a"
+	taskMap := map[string]fi.Task{
+		"task/a": &planTestTask{Name: &name},
+	}
+
+	matching := &fi.Plan{
+		Changes: []*fi.PlanChange{
+			{
+				Key:    "task/a",
+				Type:   "cloudup.planTestTask",
+				Action: fi.PlanActionCreate,
+				Fields: []*fi.PlanFieldChange{{Name: "Name", After: "a"}},
+			},
+		},
+	}
+	stale := &fi.Plan{
+		Changes: []*fi.PlanChange{
+			{Key: "task/a", Type: "cloudup.planTestTask", Action: fi.PlanActionNoOp},
+		},
+	}
+
+	c := &CreateClusterCmd{}
+
+	c.ApplyPlan = path.Join(dir, "matching.yaml")
+	if err := matching.WriteFile(c.ApplyPlan); err != nil {
+		t.Fatalf("error writing plan: %v", err)
+	}
+	if err := c.verifyPlan(taskMap, nil, nil, nil, true); err != nil {
+		t.Fatalf("expected matching plan to verify, got %v", err)
+	}
+
+	c.ApplyPlan = path.Join(dir, "stale.json")
+	if err := stale.WriteFile(c.ApplyPlan); err != nil {
+		t.Fatalf("error writing plan: %v", err)
+	}
+	err = c.verifyPlan(taskMap, nil, nil, nil, true)
+	if err == nil || !strings.Contains(err.Error(), "no longer match") {
+		t.Fatalf("expected stale plan to be rejected, got %v", err)
+	}
+}
+
+func TestPlanFormatRequiresDryRun(t *testing.T) {
+	c := &CreateClusterCmd{
+		Target:     "direct",
+		PlanFormat: fi.PlanFormatJSON,
+	}
+	err := c.Run()
+	if err == nil || !strings.Contains(err.Error(), "plan format") {
+		t.Fatalf("expected plan format to be rejected, got %v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi/cloudup/gce"
 	"math/big"
 	"net"
 	"sort"
@@ -49,6 +50,9 @@ func (tf *TemplateFunctions) WellKnownServiceIP(id int) (net.IP, error) {
 
 func (tf *TemplateFunctions) AddTo(dest template.FuncMap) {
 	dest["EtcdClusterMemberTags"] = tf.EtcdClusterMemberTags
+	dest["EtcdClusterMemberLabels"] = tf.EtcdClusterMemberLabels
+	// GCE resource names can't contain dots (so can't include the cluster name directly)
+	dest["GCESafeName"] = gce.SafeObjectName
 	dest["SharedVPC"] = tf.SharedVPC
 	dest["WellKnownServiceIP"] = tf.WellKnownServiceIP
 }
@@ -73,6 +77,36 @@ func (tf *TemplateFunctions) EtcdClusterMemberTags(etcd *api.EtcdClusterSpec, m
 	return tags
 }
 
+// EtcdClusterMemberLabels is the GCE equivalent of EtcdClusterMemberTags: the labels for the disk of an etcd member.
+// GCE label keys and values are restricted, so we encode them; GCE has no KubernetesCluster tag, so we add a label.
+func (tf *TemplateFunctions) EtcdClusterMemberLabels(etcd *api.EtcdClusterSpec, m *api.EtcdMemberSpec) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	var allMembers []string
+
+	for _, m := range etcd.Members {
+		allMembers = append(allMembers, m.Name)
+	}
+
+	sort.Strings(allMembers)
+
+	labels["k8s-io-cluster-name"] = gce.EncodeLabelValue(tf.cluster.Name)
+
+	// This is the configuration of the etcd cluster
+	labels["k8s-io-etcd-"+etcd.Name] = gce.EncodeLabelValue(m.Name + "/" + strings.Join(allMembers, ","))
+
+	// This says "only mount on a master"
+	labels["k8s-io-role-master"] = "1"
+
+	for k, v := range labels {
+		if len(v) > gce.MaxLabelValueLength {
+			return nil, fmt.Errorf("GCE label %s=%s is too long (the limit is %d characters); use shorter etcd member names", k, v, gce.MaxLabelValueLength)
+		}
+	}
+
+	return labels, nil
+}
+
 // SharedVPC is a simple helper function which makes the templates for a shared VPC clearer
 func (tf *TemplateFunctions) SharedVPC() bool {
 	return tf.cluster.Spec.NetworkID != ""
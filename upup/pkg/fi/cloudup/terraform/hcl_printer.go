@@ -83,8 +83,10 @@ func hclPrint(node ast.Node) ([]byte, error) {
 
 	// Remove extra whitespace...
 	s = strings.Replace(s, "\n\n", "\n", -1)
-	// ...but leave whitespace between resources
-	s = strings.Replace(s, "}\nresource", "}\n\nresource", -1)
+	// ...but leave whitespace between resources, variables and outputs
+	for _, block := range []string{"resource", "provider", "variable", "output"} {
+		s = strings.Replace(s, "}\n"+block, "}\n\n"+block, -1)
+	}
 
 	// Workaround HCL insanity #6359: quotes are _not_ escaped in quotes (huh?)
 	// This hits the file function
@@ -9,7 +9,9 @@ import (
 	"k8s.io/kops/upup/pkg/fi"
 	"os"
 	"path"
+	"sort"
 	"strings"
+	"sync"
 )
 
 type TerraformTarget struct {
@@ -17,7 +19,19 @@ type TerraformTarget struct {
 	Region  string
 	Project string
 
+	// ProviderName is the name of the terraform provider (e.g. aws); we don't output a provider block if it is not set
+	ProviderName string
+	// ProviderConfig is the configuration for the terraform provider block.
+	// Each setting is exposed as a variable (e.g. region), so that it can be overridden.
+	ProviderConfig map[string]interface{}
+
+	// Module is set if the output will be used as a terraform module; we then leave the provider block to the caller
+	Module bool
+
+	// mutex protects the fields below; tasks are rendered concurrently
+	mutex     sync.Mutex
 	resources []*terraformResource
+	outputs   map[string]*terraformOutputVariable
 
 	files  map[string][]byte
 	outDir string
@@ -30,6 +44,7 @@ func NewTerraformTarget(cloud fi.Cloud, region, project string, outDir string) *
 		Project: project,
 		outDir:  outDir,
 		files:   make(map[string][]byte),
+		outputs: make(map[string]*terraformOutputVariable),
 	}
 }
 
@@ -41,6 +56,13 @@ type terraformResource struct {
 	Item         interface{}
 }
 
+// terraformOutputVariable is an output, which is either a single value or a list of values
+type terraformOutputVariable struct {
+	Key        string
+	Value      *Literal
+	ValueArray []*Literal
+}
+
 // A TF name can't have dots in it (if we want to refer to it from a literal),
 // so we replace them
 func tfSanitize(name string) string {
@@ -58,12 +80,58 @@ func (t *TerraformTarget) AddFile(resourceType string, resourceName string, key
 	}
 
 	p := path.Join("data", id)
+	t.mutex.Lock()
 	t.files[p] = d
+	t.mutex.Unlock()
 
-	l := LiteralExpression(fmt.Sprintf("${file(%q)}", p))
+	// We use path.module so that the paths are still correct when the output is used as a module
+	l := LiteralExpression(fmt.Sprintf("${file(%q)}", "${path.module}/"+p))
 	return l, nil
 }
 
+// AddOutputVariable adds an output with a single value, for example the ID of the VPC
+func (t *TerraformTarget) AddOutputVariable(key string, literal *Literal) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	existing := t.outputs[key]
+	if existing != nil {
+		if existing.Value == nil || existing.Value.value != literal.value {
+			return fmt.Errorf("duplicate output variable %q", key)
+		}
+		return nil
+	}
+	t.outputs[key] = &terraformOutputVariable{
+		Key:   key,
+		Value: literal,
+	}
+	return nil
+}
+
+// AddOutputVariableArray adds a value to an output that is a list, for example the IDs of the subnets
+func (t *TerraformTarget) AddOutputVariableArray(key string, literal *Literal) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	existing := t.outputs[key]
+	if existing == nil {
+		existing = &terraformOutputVariable{
+			Key: key,
+		}
+		t.outputs[key] = existing
+	}
+	if existing.Value != nil {
+		return fmt.Errorf("output variable %q is not a list", key)
+	}
+	for _, v := range existing.ValueArray {
+		if v.value == literal.value {
+			return nil
+		}
+	}
+	existing.ValueArray = append(existing.ValueArray, literal)
+	return nil
+}
+
 func (t *TerraformTarget) RenderResource(resourceType string, resourceName string, e interface{}) error {
 	res := &terraformResource{
 		ResourceType: resourceType,
@@ -71,12 +139,20 @@ func (t *TerraformTarget) RenderResource(resourceType string, resourceName strin
 		Item:         e,
 	}
 
+	t.mutex.Lock()
 	t.resources = append(t.resources, res)
+	t.mutex.Unlock()
 
 	return nil
 }
 
 func (t *TerraformTarget) Finish(taskMap map[string]fi.Task) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	// Tasks run concurrently, so we sort everything to get the same output every time
+	sort.Sort(byTypeAndName(t.resources))
+
 	resourcesByType := make(map[string]map[string]interface{})
 
 	for _, res := range t.resources {
@@ -95,52 +171,77 @@ func (t *TerraformTarget) Finish(taskMap map[string]fi.Task) error {
 		resources[tfName] = res.Item
 	}
 
-	providersByName := make(map[string]map[string]interface{})
-	if t.Cloud.ProviderID() == fi.CloudProviderGCE {
-		providerGoogle := make(map[string]interface{})
-		providerGoogle["project"] = t.Project
-		providerGoogle["region"] = t.Region
-		providersByName["google"] = providerGoogle
-	} else if t.Cloud.ProviderID() == fi.CloudProviderAWS {
-		providerAWS := make(map[string]interface{})
-		providerAWS["region"] = t.Region
-		providersByName["aws"] = providerAWS
+	variables := make(map[string]interface{})
+	providerConfig := make(map[string]interface{})
+	for k, v := range t.ProviderConfig {
+		variables[k] = map[string]interface{}{
+			"default": v,
+		}
+		providerConfig[k] = "${var." + k + "}"
+	}
+
+	outputs := make(map[string]interface{})
+	for key, v := range t.outputs {
+		if v.Value != nil {
+			outputs[key] = map[string]interface{}{
+				"value": v.Value,
+			}
+		} else {
+			values := v.ValueArray
+			sort.Sort(byLiteralValue(values))
+			outputs[key] = map[string]interface{}{
+				"value": values,
+			}
+		}
 	}
 
 	data := make(map[string]interface{})
 	data["resource"] = resourcesByType
-	if len(providersByName) != 0 {
-		data["provider"] = providersByName
+	if t.ProviderName != "" && !t.Module {
+		data["provider"] = map[string]interface{}{
+			t.ProviderName: providerConfig,
+		}
 	}
 
-	jsonBytes, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return fmt.Errorf("error marshalling terraform data to json: %v", err)
+	// We split the output into several files, so that the variables and outputs are easy to find
+	files := []struct {
+		name string
+		key  string
+		data interface{}
+	}{
+		{"kubernetes.tf", "", data},
+		{"variables.tf", "variable", variables},
+		{"outputs.tf", "output", outputs},
 	}
 
-	useJson := false
-
-	if useJson {
-		t.files["kubernetes.tf"] = jsonBytes
-	} else {
-		f, err := hcl_parser.Parse(jsonBytes)
-		if err != nil {
-			return fmt.Errorf("error parsing terraform json: %v", err)
+	for _, f := range files {
+		d := f.data
+		if f.key != "" {
+			m := f.data.(map[string]interface{})
+			if len(m) == 0 {
+				continue
+			}
+			d = map[string]interface{}{f.key: m}
 		}
 
-		b, err := hclPrint(f)
+		b, err := renderTerraform(d)
 		if err != nil {
-			return fmt.Errorf("error writing terraform data to output: %v", err)
+			return err
 		}
+		t.files[f.name] = b
+	}
 
-		t.files["kubernetes.tf"] = b
-
+	var relativePaths []string
+	for relativePath := range t.files {
+		relativePaths = append(relativePaths, relativePath)
 	}
+	sort.Strings(relativePaths)
 
-	for relativePath, contents := range t.files {
+	for _, relativePath := range relativePaths {
+		contents := t.files[relativePath]
 		p := path.Join(t.outDir, relativePath)
 
-		err = os.MkdirAll(path.Dir(p), os.FileMode(0755))
+		err := os.MkdirAll(path.Dir(p), os.FileMode(0755))
 		if err != nil {
 			return fmt.Errorf("error creating output directory %q: %v", path.Dir(p), err)
 		}
@@ -155,3 +256,45 @@ func (t *TerraformTarget) Finish(taskMap map[string]fi.Task) error {
 
 	return nil
 }
+
+// renderTerraform converts the data to HCL; we build JSON (where maps are written in sorted order), and convert it
+func renderTerraform(data interface{}) ([]byte, error) {
+	jsonBytes, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling terraform data to json: %v", err)
+	}
+
+	useJson := false
+
+	if useJson {
+		return jsonBytes, nil
+	}
+
+	f, err := hcl_parser.Parse(jsonBytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing terraform json: %v", err)
+	}
+
+	b, err := hclPrint(f)
+	if err != nil {
+		return nil, fmt.Errorf("error writing terraform data to output: %v", err)
+	}
+	return b, nil
+}
+
+type byTypeAndName []*terraformResource
+
+func (a byTypeAndName) Len() int      { return len(a) }
+func (a byTypeAndName) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byTypeAndName) Less(i, j int) bool {
+	if a[i].ResourceType != a[j].ResourceType {
+		return a[i].ResourceType < a[j].ResourceType
+	}
+	return a[i].ResourceName < a[j].ResourceName
+}
+
+type byLiteralValue []*Literal
+
+func (a byLiteralValue) Len() int           { return len(a) }
+func (a byLiteralValue) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byLiteralValue) Less(i, j int) bool { return a[i].value < a[j].value }
@@ -0,0 +1,83 @@
+package terraform
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+type testResource struct {
+	Name *Literal `json:"name"`
+}
+
+func TestFinishOutputsAndVariables(t *testing.T) {
+	outDir, err := ioutil.TempDir("", "terraform")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(outDir)
+
+	target := NewTerraformTarget(nil, "us-east-1", "", outDir)
+	target.ProviderName = "aws"
+	target.ProviderConfig = map[string]interface{}{"region": "us-east-1"}
+
+	// Add in a different order from the output, to check that the output is sorted
+	for _, name := range []string{"b", "a"} {
+		if err := target.RenderResource("aws_subnet", name, &testResource{Name: LiteralFromStringValue(name)}); err != nil {
+			t.Fatalf("error rendering resource: %v", err)
+		}
+		if err := target.AddOutputVariableArray("subnet_ids", LiteralProperty("aws_subnet", name, "id")); err != nil {
+			t.Fatalf("error adding output: %v", err)
+		}
+	}
+	if err := target.AddOutputVariable("vpc_id", LiteralProperty("aws_vpc", "c", "id")); err != nil {
+		t.Fatalf("error adding output: %v", err)
+	}
+	if err := target.AddOutputVariable("vpc_id", LiteralProperty("aws_vpc", "d", "id")); err == nil {
+		t.Errorf("expected error adding a different value for an existing output")
+	}
+
+	if err := target.Finish(nil); err != nil {
+		t.Fatalf("error from Finish: %v", err)
+	}
+
+	read := func(name string) string {
+		b, err := ioutil.ReadFile(path.Join(outDir, name))
+		if err != nil {
+			t.Fatalf("error reading %s: %v", name, err)
+		}
+		return string(b)
+	}
+
+	resources := read("kubernetes.tf")
+	if strings.Index(resources, `"a"`) > strings.Index(resources, `"b"`) {
+		t.Errorf("resources were not sorted:\n%s", resources)
+	}
+	if !strings.Contains(resources, "${var.region}") {
+		t.Errorf("provider does not use the region variable:\n%s", resources)
+	}
+
+	variables := read("variables.tf")
+	if !strings.Contains(variables, `variable "region"`) || !strings.Contains(variables, `"us-east-1"`) {
+		t.Errorf("unexpected variables:\n%s", variables)
+	}
+
+	outputs := read("outputs.tf")
+	if !strings.Contains(outputs, "${aws_vpc.c.id}") {
+		t.Errorf("vpc_id output not found:\n%s", outputs)
+	}
+	if strings.Index(outputs, "${aws_subnet.a.id}") > strings.Index(outputs, "${aws_subnet.b.id}") {
+		t.Errorf("subnet_ids output was not sorted:\n%s", outputs)
+	}
+
+	// In a module, the provider is configured by the caller
+	target.Module = true
+	if err := target.Finish(nil); err != nil {
+		t.Fatalf("error from Finish: %v", err)
+	}
+	if strings.Contains(read("kubernetes.tf"), "provider") {
+		t.Errorf("module output should not contain a provider block")
+	}
+}
@@ -0,0 +1,31 @@
+package cloudup
+
+import (
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+)
+
+// InstanceGroupsNeedingRollingUpdate returns the InstanceGroups whose existing instances will not pick up the plan:
+// those where the launch configuration changes but the autoscaling group already exists.
+// The instances in these groups must be replaced (e.g. with rolling-update cluster).
+func InstanceGroupsNeedingRollingUpdate(plan *fi.Plan, cluster *api.Cluster, instanceGroups []*api.InstanceGroup) []*api.InstanceGroup {
+	var needUpdate []*api.InstanceGroup
+	for _, g := range instanceGroups {
+		name := g.Name + "." + cluster.Name
+		if g.IsMaster() {
+			name = g.Name + ".masters." + cluster.Name
+		}
+
+		lc := plan.Change("launchConfiguration/" + name)
+		if lc == nil || lc.Action == fi.PlanActionNoOp {
+			continue
+		}
+		asg := plan.Change("autoscalingGroup/" + name)
+		if asg == nil || asg.Action == fi.PlanActionCreate {
+			// A new group will be launched with the new configuration
+			continue
+		}
+		needUpdate = append(needUpdate, g)
+	}
+	return needUpdate
+}
@@ -0,0 +1,43 @@
+package cloudup
+
+import (
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"testing"
+)
+
+func TestInstanceGroupsNeedingRollingUpdate(t *testing.T) {
+	cluster := &api.Cluster{}
+	cluster.Name = "kubernetes.example.com"
+
+	master := &api.InstanceGroup{Spec: api.InstanceGroupSpec{Role: api.InstanceGroupRoleMaster}}
+	master.Name = "master-us-east-1a"
+	nodes := &api.InstanceGroup{Spec: api.InstanceGroupSpec{Role: api.InstanceGroupRoleNode}}
+	nodes.Name = "nodes"
+	unchanged := &api.InstanceGroup{Spec: api.InstanceGroupSpec{Role: api.InstanceGroupRoleNode}}
+	unchanged.Name = "unchanged"
+	added := &api.InstanceGroup{Spec: api.InstanceGroupSpec{Role: api.InstanceGroupRoleNode}}
+	added.Name = "added"
+
+	plan := &fi.Plan{
+		Changes: []*fi.PlanChange{
+			{Key: "launchConfiguration/master-us-east-1a.masters.kubernetes.example.com", Action: fi.PlanActionUpdate},
+			{Key: "autoscalingGroup/master-us-east-1a.masters.kubernetes.example.com", Action: fi.PlanActionNoOp},
+			{Key: "launchConfiguration/nodes.kubernetes.example.com", Action: fi.PlanActionCreate},
+			{Key: "autoscalingGroup/nodes.kubernetes.example.com", Action: fi.PlanActionUpdate},
+			{Key: "launchConfiguration/unchanged.kubernetes.example.com", Action: fi.PlanActionNoOp},
+			{Key: "autoscalingGroup/unchanged.kubernetes.example.com", Action: fi.PlanActionNoOp},
+			{Key: "launchConfiguration/added.kubernetes.example.com", Action: fi.PlanActionCreate},
+			{Key: "autoscalingGroup/added.kubernetes.example.com", Action: fi.PlanActionCreate},
+		},
+	}
+
+	needUpdate := InstanceGroupsNeedingRollingUpdate(plan, cluster, []*api.InstanceGroup{master, nodes, unchanged, added})
+	if len(needUpdate) != 2 || needUpdate[0] != master || needUpdate[1] != nodes {
+		var names []string
+		for _, g := range needUpdate {
+			names = append(names, g.Name)
+		}
+		t.Errorf("unexpected instance groups needing update: %v", names)
+	}
+}
@@ -3,6 +3,7 @@ package fi
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
 	"io/ioutil"
 	"os"
 	"reflect"
@@ -18,6 +19,15 @@ type Context struct {
 	SecretStore SecretStore
 
 	CheckExisting bool
+
+	// RunTasksOptions controls concurrency, timeouts and retries when running tasks
+	RunTasksOptions RunTasksOptions
+
+	// Trace is the timeline of the last call to RunTasks (recorded even if RunTasks fails)
+	Trace *ExecutionTrace
+
+	// ctx is cancelled if the task running with this Context times out
+	ctx context.Context
 }
 
 func NewContext(target Target, cloud Cloud, castore CAStore, secretStore SecretStore, checkExisting bool) (*Context, error) {
@@ -41,8 +51,27 @@ func NewContext(target Target, cloud Cloud, castore CAStore, secretStore SecretS
 func (c *Context) RunTasks(taskMap map[string]Task) error {
 	e := &executor{
 		context: c,
+		options: c.RunTasksOptions,
+	}
+	err := e.RunTasks(taskMap)
+	c.Trace = e.trace
+	return err
+}
+
+// Ctx returns a context.Context that is cancelled if the current task times out.
+// Long-running tasks (e.g. those that poll) should stop when it is done; the executor has already abandoned them.
+func (c *Context) Ctx() context.Context {
+	if c.ctx == nil {
+		return context.Background()
 	}
-	return e.RunTasks(taskMap)
+	return c.ctx
+}
+
+// withCtx returns a copy of the Context, for running a single task with the specified context.Context
+func (c *Context) withCtx(ctx context.Context) *Context {
+	taskContext := *c
+	taskContext.ctx = ctx
+	return &taskContext
 }
 
 func (c *Context) Close() {
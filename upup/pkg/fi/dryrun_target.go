@@ -8,6 +8,7 @@ import (
 	"io"
 	"k8s.io/kops/upup/pkg/fi/utils"
 	"reflect"
+	"sort"
 )
 
 // DryRunTarget is a special Target that does not execute anything, but instead tracks all changes.
@@ -18,6 +19,9 @@ type DryRunTarget struct {
 
 	// The destination to which the final report will be printed on Finish()
 	out io.Writer
+
+	// Format controls the format of the final report: text (the default), json or yaml
+	Format string
 }
 
 type render struct {
@@ -72,76 +76,26 @@ func (t *DryRunTarget) PrintReport(taskMap map[string]Task, out io.Writer) error
 		}
 
 		fmt.Fprintf(b, "Will modify resources:\n")
-		// We can't use our reflection helpers here - we want corresponding values from a,e,c
 		for _, r := range t.changes {
 			if r.aIsNil {
 				continue
 			}
-			var changeList []string
 
-			valC := reflect.ValueOf(r.changes)
-			valA := reflect.ValueOf(r.a)
-			valE := reflect.ValueOf(r.e)
-			if valC.Kind() == reflect.Ptr && !valC.IsNil() {
-				valC = valC.Elem()
-			}
-			if valA.Kind() == reflect.Ptr && !valA.IsNil() {
-				valA = valA.Elem()
-			}
-			if valE.Kind() == reflect.Ptr && !valE.IsNil() {
-				valE = valE.Elem()
+			fieldChanges, err := r.fieldChanges()
+			if err != nil {
+				return err
 			}
-			if valC.Kind() == reflect.Struct {
-				for i := 0; i < valC.NumField(); i++ {
-					fieldValC := valC.Field(i)
-
-					changed := true
-					switch fieldValC.Kind() {
-					case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
-						changed = !fieldValC.IsNil()
-
-					case reflect.String:
-						changed = fieldValC.Interface().(string) != ""
-					}
-					if !changed {
-						continue
-					}
-
-					if fieldValC.Kind() == reflect.String && fieldValC.Interface().(string) == "" {
-						// No change
-						continue
-					}
-
-					fieldValE := valE.Field(i)
-
-					description := ""
-					ignored := false
-					if fieldValE.CanInterface() {
-						fieldValA := valA.Field(i)
-
-						switch fieldValE.Interface().(type) {
-						//case SimpleUnit:
-						//	ignored = true
-						default:
-							description = fmt.Sprintf(" %v -> %v", ValueAsString(fieldValA), ValueAsString(fieldValE))
-						}
-					}
-					if ignored {
-						continue
-					}
-					changeList = append(changeList, valC.Type().Field(i).Name+description)
-				}
-			} else {
-				return fmt.Errorf("unhandled change type: %v", valC.Type())
-			}
-
-			if len(changeList) == 0 {
+			if len(fieldChanges) == 0 {
 				continue
 			}
 
 			fmt.Fprintf(b, "  %T\t%s\n", r.changes, IdForTask(taskMap, r.e))
-			for _, f := range changeList {
-				fmt.Fprintf(b, "    %s\n", f)
+			for _, f := range fieldChanges {
+				if f.Before == "" && f.After == "" {
+					fmt.Fprintf(b, "    %s\n", f.Name)
+				} else {
+					fmt.Fprintf(b, "    %s %s -> %s\n", f.Name, f.Before, f.After)
+				}
 			}
 			fmt.Fprintf(b, "\n")
 		}
@@ -151,6 +105,59 @@ func (t *DryRunTarget) PrintReport(taskMap map[string]Task, out io.Writer) error
 	return err
 }
 
+// fieldChanges returns the fields that are set in the changes object, with the actual and expected values.
+// If the field cannot be read (it is unexported), Before and After are left empty.
+func (r *render) fieldChanges() ([]*PlanFieldChange, error) {
+	var fieldChanges []*PlanFieldChange
+
+	// We can't use our reflection helpers here - we want corresponding values from a,e,c
+	valC := reflect.ValueOf(r.changes)
+	valA := reflect.ValueOf(r.a)
+	valE := reflect.ValueOf(r.e)
+	if valC.Kind() == reflect.Ptr && !valC.IsNil() {
+		valC = valC.Elem()
+	}
+	if valA.Kind() == reflect.Ptr && !valA.IsNil() {
+		valA = valA.Elem()
+	}
+	if valE.Kind() == reflect.Ptr && !valE.IsNil() {
+		valE = valE.Elem()
+	}
+	if valC.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unhandled change type: %v", valC.Type())
+	}
+
+	for i := 0; i < valC.NumField(); i++ {
+		fieldValC := valC.Field(i)
+
+		changed := true
+		switch fieldValC.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+			changed = !fieldValC.IsNil()
+
+		case reflect.String:
+			changed = fieldValC.Interface().(string) != ""
+		}
+		if !changed {
+			continue
+		}
+
+		f := &PlanFieldChange{
+			Name: valC.Type().Field(i).Name,
+		}
+
+		fieldValE := valE.Field(i)
+		if fieldValE.CanInterface() {
+			if !r.aIsNil {
+				f.Before = ValueAsString(valA.Field(i))
+			}
+			f.After = ValueAsString(fieldValE)
+		}
+		fieldChanges = append(fieldChanges, f)
+	}
+	return fieldChanges, nil
+}
+
 // asString returns a human-readable string representation of the passed value
 func ValueAsString(value reflect.Value) string {
 	b := &bytes.Buffer{}
@@ -188,15 +195,24 @@ func ValueAsString(value reflect.Value) string {
 			return utils.SkipReflection
 
 		case reflect.Map:
-			keys := v.MapKeys()
+			// We sort the keys so that the output is stable (and plans can be compared)
+			keys := make(map[string]reflect.Value)
+			var keyStrings []string
+			for _, key := range v.MapKeys() {
+				k := ValueAsString(key)
+				keys[k] = key
+				keyStrings = append(keyStrings, k)
+			}
+			sort.Strings(keyStrings)
+
 			fmt.Fprintf(b, "{")
-			for i, key := range keys {
-				mv := v.MapIndex(key)
+			for i, k := range keyStrings {
+				mv := v.MapIndex(keys[k])
 
 				if i != 0 {
 					fmt.Fprintf(b, ", ")
 				}
-				fmt.Fprintf(b, "%s: %s", ValueAsString(key), ValueAsString(mv))
+				fmt.Fprintf(b, "%s: %s", k, ValueAsString(mv))
 			}
 			fmt.Fprintf(b, "}")
 			return utils.SkipReflection
@@ -235,5 +251,23 @@ func ValueAsString(value reflect.Value) string {
 
 // Finish is called at the end of a run, and prints a list of changes to the configured Writer
 func (t *DryRunTarget) Finish(taskMap map[string]Task) error {
-	return t.PrintReport(taskMap, t.out)
+	switch t.Format {
+	case "", PlanFormatText:
+		return t.PrintReport(taskMap, t.out)
+
+	case PlanFormatJSON, PlanFormatYAML:
+		plan, err := t.BuildPlan(taskMap)
+		if err != nil {
+			return err
+		}
+		data, err := plan.Marshal(t.Format)
+		if err != nil {
+			return err
+		}
+		_, err = t.out.Write(data)
+		return err
+
+	default:
+		return fmt.Errorf("unknown plan format %q", t.Format)
+	}
 }
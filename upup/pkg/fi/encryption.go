@@ -0,0 +1,301 @@
+package fi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	crypto_rand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// We use envelope encryption for secrets and private keys in the state store:
+// each file is encrypted with a random data key (AES-256-GCM), and the data key is itself
+// encrypted ("wrapped") by a KeyProvider.  Only the wrapped data key is stored, alongside the ciphertext.
+// This means that a KeyProvider backed by a KMS only needs to be called to wrap / unwrap small keys.
+
+// encryptedHeader marks a file as encrypted; files without it are treated as plaintext,
+// so that state stores created before encryption was enabled remain readable.
+const encryptedHeader = "kops-encrypted:v1\n"
+
+const dataKeySize = 32
+
+// KeyProvider wraps and unwraps the data keys used to encrypt files in the state store
+type KeyProvider interface {
+	// ProviderID identifies the type of provider; it is recorded in the encrypted file
+	ProviderID() string
+	// KeyID is a fingerprint of the key; it is recorded in the encrypted file, so we can tell which key was used
+	KeyID() string
+	// WrapKey encrypts a data key
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key that was encrypted by WrapKey
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// encryptedEnvelope is the (JSON) format of an encrypted file, following encryptedHeader
+type encryptedEnvelope struct {
+	Provider   string `json:"provider"`
+	KeyID      string `json:"keyID,omitempty"`
+	WrappedKey []byte `json:"wrappedKey"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// IsEncrypted returns true if the data was produced by EncryptData
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedHeader))
+}
+
+// IsEncryptedWithKey returns true if the data was produced by EncryptData using the specified key
+func IsEncryptedWithKey(keyProvider KeyProvider, data []byte) bool {
+	if keyProvider == nil || !IsEncrypted(data) {
+		return false
+	}
+	envelope := &encryptedEnvelope{}
+	if err := json.Unmarshal(data[len(encryptedHeader):], envelope); err != nil {
+		return false
+	}
+	return envelope.Provider == keyProvider.ProviderID() && envelope.KeyID == keyProvider.KeyID()
+}
+
+// EncryptData encrypts the data using a new data key, wrapped by the KeyProvider.
+// If keyProvider is nil, the data is returned unchanged.
+func EncryptData(keyProvider KeyProvider, plaintext []byte) ([]byte, error) {
+	if keyProvider == nil {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(crypto_rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("error generating data key: %v", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(crypto_rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %v", err)
+	}
+
+	wrapped, err := keyProvider.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("error wrapping data key: %v", err)
+	}
+
+	envelope := &encryptedEnvelope{
+		Provider:   keyProvider.ProviderID(),
+		KeyID:      keyProvider.KeyID(),
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing encrypted data: %v", err)
+	}
+	return append([]byte(encryptedHeader), data...), nil
+}
+
+// DecryptData decrypts data produced by EncryptData.  Data that is not encrypted is returned unchanged.
+func DecryptData(keyProvider KeyProvider, data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+
+	envelope := &encryptedEnvelope{}
+	err := json.Unmarshal(data[len(encryptedHeader):], envelope)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing encrypted data: %v", err)
+	}
+
+	if keyProvider == nil {
+		return nil, fmt.Errorf("data is encrypted (with a %q key), but no encryption key was configured", envelope.Provider)
+	}
+	if envelope.Provider != keyProvider.ProviderID() {
+		return nil, fmt.Errorf("data is encrypted with a %q key, but the configured key is %q", envelope.Provider, keyProvider.ProviderID())
+	}
+	// Files written before we recorded the key id don't have one; we find out if the key is wrong when we unwrap
+	if envelope.KeyID != "" && envelope.KeyID != keyProvider.KeyID() {
+		return nil, fmt.Errorf("data is encrypted with key %s, but the configured key is %s", envelope.KeyID, keyProvider.KeyID())
+	}
+
+	dataKey, err := keyProvider.UnwrapKey(envelope.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("error unwrapping data key (is the encryption key correct?): %v", err)
+	}
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data has invalid nonce")
+	}
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting data: %v", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error building cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("error building cipher: %v", err)
+	}
+	return gcm, nil
+}
+
+const (
+	localKeySaltSize = 16
+	// localKeyIDSalt is the (fixed) salt used to compute the fingerprint of a local key
+	localKeyIDSalt = "kops-key-id"
+	localKeyIDSize = 8
+	// scrypt parameters, as recommended for interactive logins
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// LocalKeyProvider wraps data keys with a key derived (using scrypt) from a local secret:
+// either a passphrase or the contents of a key file.
+// The salt is stored with each wrapped key; derived keys are cached, because scrypt is deliberately slow.
+type LocalKeyProvider struct {
+	secret []byte
+	keyID  string
+
+	mutex       sync.Mutex
+	salt        []byte
+	derivedKeys map[string][]byte
+}
+
+var _ KeyProvider = &LocalKeyProvider{}
+
+// NewLocalKeyProvider builds a LocalKeyProvider from a passphrase (or other secret)
+func NewLocalKeyProvider(secret []byte) (*LocalKeyProvider, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("encryption key must not be empty")
+	}
+	// The fingerprint is derived with scrypt, so that it is no easier to guess the secret from the fingerprint than from the ciphertext
+	fingerprint, err := scrypt.Key(secret, []byte(localKeyIDSalt), scryptN, scryptR, scryptP, localKeyIDSize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key fingerprint: %v", err)
+	}
+	return &LocalKeyProvider{
+		secret:      secret,
+		keyID:       hex.EncodeToString(fingerprint),
+		derivedKeys: make(map[string][]byte),
+	}, nil
+}
+
+// NewLocalKeyProviderFromFile builds a LocalKeyProvider using the contents of a key file
+func NewLocalKeyProviderFromFile(keyFile string) (*LocalKeyProvider, error) {
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading encryption key file %q: %v", keyFile, err)
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return nil, fmt.Errorf("encryption key file %q is empty", keyFile)
+	}
+	return NewLocalKeyProvider([]byte(secret))
+}
+
+func (k *LocalKeyProvider) ProviderID() string {
+	return "local"
+}
+
+func (k *LocalKeyProvider) KeyID() string {
+	return k.keyID
+}
+
+func (k *LocalKeyProvider) deriveKey(salt []byte) ([]byte, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key := k.derivedKeys[string(salt)]
+	if key != nil {
+		return key, nil
+	}
+	key, err := scrypt.Key(k.secret, salt, scryptN, scryptR, scryptP, dataKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key: %v", err)
+	}
+	k.derivedKeys[string(salt)] = key
+	return key, nil
+}
+
+// currentSalt returns the salt we use for wrapping; we reuse one salt per process, to avoid repeating the scrypt cost
+func (k *LocalKeyProvider) currentSalt() ([]byte, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.salt == nil {
+		salt := make([]byte, localKeySaltSize)
+		if _, err := io.ReadFull(crypto_rand.Reader, salt); err != nil {
+			return nil, fmt.Errorf("error generating salt: %v", err)
+		}
+		k.salt = salt
+	}
+	return k.salt, nil
+}
+
+// WrapKey returns salt || nonce || AES-GCM(derivedKey, dataKey)
+func (k *LocalKeyProvider) WrapKey(dataKey []byte) ([]byte, error) {
+	salt, err := k.currentSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := k.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(crypto_rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %v", err)
+	}
+
+	wrapped := append([]byte{}, salt...)
+	wrapped = append(wrapped, nonce...)
+	return gcm.Seal(wrapped, nonce, dataKey, nil), nil
+}
+
+func (k *LocalKeyProvider) UnwrapKey(wrapped []byte) ([]byte, error) {
+	if len(wrapped) < localKeySaltSize {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	salt := wrapped[:localKeySaltSize]
+	key, err := k.deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	rest := wrapped[localKeySaltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	dataKey, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("wrong encryption key")
+	}
+	return dataKey, nil
+}
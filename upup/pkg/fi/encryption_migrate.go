@@ -0,0 +1,84 @@
+package fi
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+)
+
+// EncryptedPaths returns the subtrees of the state store that hold sensitive data, and are encrypted when a
+// KeyProvider is configured: secrets/ and pki/private/ (certificates are public, and are not encrypted)
+func EncryptedPaths(stateStore StateStore) []vfs.Path {
+	return []vfs.Path{
+		stateStore.Secrets().VFSPath(),
+		stateStore.CA().VFSPath().Join("private"),
+	}
+}
+
+// ReencryptStateStore rewrites every file holding secrets or private keys,
+// decrypting with from (plaintext files are read as-is) and then encrypting with to.
+// If to is nil, the files are written back as plaintext.
+// Files that are already encrypted with to are left alone, so an interrupted run can simply be repeated.
+// It returns the number of files that were rewritten.
+func ReencryptStateStore(stateStore StateStore, from KeyProvider, to KeyProvider) (int, error) {
+	count := 0
+	for _, base := range EncryptedPaths(stateStore) {
+		files, err := base.ReadTree()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return count, fmt.Errorf("error listing %s: %v", base, err)
+		}
+
+		for _, p := range files {
+			if IsLocalDirectory(p) {
+				continue
+			}
+
+			data, err := p.ReadFile()
+			if err != nil {
+				return count, fmt.Errorf("error reading %s: %v", p, err)
+			}
+
+			if IsEncryptedWithKey(to, data) {
+				// Already under the target key (e.g. we are resuming an interrupted run)
+				continue
+			}
+
+			plaintext, err := DecryptData(from, data)
+			if err != nil {
+				return count, fmt.Errorf("error decrypting %s: %v", p, err)
+			}
+
+			if !IsEncrypted(data) && to == nil {
+				// Already plaintext; nothing to do
+				continue
+			}
+
+			encrypted, err := EncryptData(to, plaintext)
+			if err != nil {
+				return count, fmt.Errorf("error encrypting %s: %v", p, err)
+			}
+
+			glog.V(2).Infof("Rewriting %s", p)
+			err = p.WriteFile(encrypted)
+			if err != nil {
+				return count, fmt.Errorf("error writing %s: %v", p, err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+// IsLocalDirectory returns true if p is a directory on the local filesystem (FSPath.ReadTree includes directories)
+func IsLocalDirectory(p vfs.Path) bool {
+	fsPath, ok := p.(*vfs.FSPath)
+	if !ok {
+		return false
+	}
+	stat, err := os.Stat(fsPath.Path())
+	return err == nil && stat.IsDir()
+}
@@ -0,0 +1,125 @@
+package fi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, err := NewLocalKeyProvider([]byte("correct horse battery staple"))
+	if err != nil {
+		t.Fatalf("error building key provider: %v", err)
+	}
+
+	plaintext := []byte("secret data")
+	encrypted, err := EncryptData(key, plaintext)
+	if err != nil {
+		t.Fatalf("error encrypting: %v", err)
+	}
+	if !IsEncrypted(encrypted) || bytes.Contains(encrypted, plaintext) {
+		t.Fatalf("data was not encrypted: %q", encrypted)
+	}
+
+	decrypted, err := DecryptData(key, encrypted)
+	if err != nil {
+		t.Fatalf("error decrypting: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("unexpected decrypted data: %q", decrypted)
+	}
+
+	// Plaintext passes through unchanged, so existing state stores remain readable
+	decrypted, err = DecryptData(key, plaintext)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("expected plaintext to pass through, got %q (err=%v)", decrypted, err)
+	}
+
+	wrongKey, err := NewLocalKeyProvider([]byte("wrong"))
+	if err != nil {
+		t.Fatalf("error building key provider: %v", err)
+	}
+	if _, err := DecryptData(wrongKey, encrypted); err == nil || !strings.Contains(err.Error(), "encrypted with key "+key.KeyID()) {
+		t.Fatalf("expected key mismatch error decrypting with the wrong key, got %v", err)
+	}
+	if !IsEncryptedWithKey(key, encrypted) || IsEncryptedWithKey(wrongKey, encrypted) || IsEncryptedWithKey(key, plaintext) {
+		t.Fatalf("IsEncryptedWithKey did not match the key used to encrypt")
+	}
+	if _, err := DecryptData(nil, encrypted); err == nil {
+		t.Fatalf("expected error decrypting without a key")
+	}
+}
+
+func TestReencryptStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statestore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := vfs.NewFSPath(dir)
+
+	// Create an unencrypted store, with a CA and a secret
+	stateStore, err := NewVFSStateStore(base, "cluster", false, nil)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+	secret, _, err := stateStore.Secrets().GetOrCreateSecret("admin")
+	if err != nil {
+		t.Fatalf("error creating secret: %v", err)
+	}
+
+	key, err := NewLocalKeyProvider([]byte("passphrase"))
+	if err != nil {
+		t.Fatalf("error building key provider: %v", err)
+	}
+	count, err := ReencryptStateStore(stateStore, nil, key)
+	if err != nil {
+		t.Fatalf("error encrypting state store: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected CA key and secret to be encrypted, but %d files were rewritten", count)
+	}
+
+	// Files that are already encrypted with the key are skipped, even if we don't pass the old key
+	count, err = ReencryptStateStore(stateStore, nil, key)
+	if err != nil {
+		t.Fatalf("error repeating encryption of state store: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no files to be rewritten, but %d were", count)
+	}
+
+	data, err := ioutil.ReadFile(path.Join(dir, "cluster", "secrets", "admin"))
+	if err != nil {
+		t.Fatalf("error reading secret file: %v", err)
+	}
+	if !IsEncrypted(data) {
+		t.Fatalf("secret was not encrypted on disk")
+	}
+
+	// Without the key, we can't load the CA private key
+	if _, err := NewVFSStateStore(base, "cluster", false, nil); err == nil {
+		t.Fatalf("expected error loading encrypted state store without key")
+	}
+
+	stateStore, err = NewVFSStateStore(base, "cluster", false, key)
+	if err != nil {
+		t.Fatalf("error loading encrypted state store: %v", err)
+	}
+	loaded, err := stateStore.Secrets().Secret("admin")
+	if err != nil {
+		t.Fatalf("error reading encrypted secret: %v", err)
+	}
+	if !bytes.Equal(loaded.Data, secret.Data) {
+		t.Fatalf("secret did not round-trip")
+	}
+	caKey, err := stateStore.CA().FindPrivateKey(CertificateId_CA)
+	if err != nil || caKey == nil {
+		t.Fatalf("error reading encrypted CA key: %v", err)
+	}
+}
@@ -4,10 +4,32 @@ import (
 	"fmt"
 )
 
+// RequiredField returns a (terminal) error indicating that a field was not set
 func RequiredField(key string) error {
-	return fmt.Errorf("Field is required: %s", key)
+	return NewTerminalError(fmt.Errorf("Field is required: %s", key))
 }
 
+// CannotChangeField returns a (terminal) error indicating that a field cannot be changed
 func CannotChangeField(key string) error {
-	return fmt.Errorf("Field cannot be changed: %s", key)
+	return NewTerminalError(fmt.Errorf("Field cannot be changed: %s", key))
+}
+
+// TerminalError is an error that will not be fixed by retrying, so the executor should not retry the task
+type TerminalError struct {
+	err error
+}
+
+func (e *TerminalError) Error() string {
+	return e.err.Error()
+}
+
+// NewTerminalError marks an error as terminal; tasks return this when retrying is pointless (e.g. invalid configuration)
+func NewTerminalError(err error) error {
+	return &TerminalError{err: err}
+}
+
+// IsTerminalError returns true if the error was returned from NewTerminalError
+func IsTerminalError(err error) bool {
+	_, ok := err.(*TerminalError)
+	return ok
 }
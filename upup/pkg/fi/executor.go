@@ -3,35 +3,121 @@ package fi
 import (
 	"fmt"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"math/rand"
 	"strings"
-	"sync"
 	"time"
 )
 
-const MaxAttemptsWithNoProgress = 3
+// RunTasksOptions controls how the executor runs tasks
+type RunTasksOptions struct {
+	// MaxConcurrency is the maximum number of tasks that will run at the same time
+	MaxConcurrency int
+	// TaskTimeout is the time after which we abandon an attempt of a task; zero means no timeout.
+	// We cannot interrupt the attempt, and we don't know whether it will succeed, so an abandoned task is never
+	// retried: it fails the run.
+	TaskTimeout time.Duration
+	// MaxTaskAttempts is the number of times we will try a task before giving up
+	MaxTaskAttempts int
+	// InitialBackoff is the delay before the first retry of a failed task; it doubles on each subsequent failure
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries
+	MaxBackoff time.Duration
+}
+
+// InitDefaults fills in any options that have not been set
+func (o *RunTasksOptions) InitDefaults() {
+	if o.MaxConcurrency <= 0 {
+		o.MaxConcurrency = 10
+	}
+	if o.MaxTaskAttempts <= 0 {
+		o.MaxTaskAttempts = 8
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = 2 * time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+}
+
+// RetryPolicy overrides the executor options for a single task
+type RetryPolicy struct {
+	// MaxAttempts is the number of times the task will be tried; 1 means the task is never retried
+	MaxAttempts int
+	// Timeout is the maximum time for a single attempt of the task
+	Timeout time.Duration
+}
+
+// HasRetryPolicy is implemented by tasks that need a different retry policy from the executor default
+type HasRetryPolicy interface {
+	RetryPolicy() *RetryPolicy
+}
 
 type executor struct {
 	context *Context
+
+	options RunTasksOptions
+
+	// trace records the timeline of the run
+	trace *ExecutionTrace
 }
 
 type taskState struct {
 	done         bool
+	running      bool
 	key          string
 	task         Task
 	dependencies []*taskState
+
+	// attempts is the number of times we have run the task
+	attempts int
+	// maxAttempts is the retry budget for the task
+	maxAttempts int
+	// timeout is the timeout for each attempt; zero means no timeout
+	timeout time.Duration
+	// notBefore is the time at which the task can next run (after backing off)
+	notBefore time.Time
+	// lastError is the error from the last attempt
+	lastError error
+
+	trace *TaskTrace
+}
+
+type taskResult struct {
+	ts  *taskState
+	err error
 }
 
-// RunTasks executes all the tasks, considering their dependencies
-// It will perform some re-execution on error, retrying as long as progess is still being made
+// RunTasks executes all the tasks, considering their dependencies.
+// Up to MaxConcurrency tasks run at once; a task that fails is retried with exponential backoff,
+// until it succeeds, it exhausts its retry budget or it returns a TerminalError.
 func (e *executor) RunTasks(taskMap map[string]Task) error {
+	e.options.InitDefaults()
+
 	dependencies := FindTaskDependencies(taskMap)
 
+	e.trace = newExecutionTrace(taskMap, dependencies)
+
 	taskStates := make(map[string]*taskState)
 
 	for k, task := range taskMap {
 		ts := &taskState{
-			key:  k,
-			task: task,
+			key:         k,
+			task:        task,
+			maxAttempts: e.options.MaxTaskAttempts,
+			timeout:     e.options.TaskTimeout,
+			trace:       e.trace.Task(k),
+		}
+		if hrp, ok := task.(HasRetryPolicy); ok {
+			if policy := hrp.RetryPolicy(); policy != nil {
+				if policy.MaxAttempts > 0 {
+					ts.maxAttempts = policy.MaxAttempts
+				}
+				if policy.Timeout > 0 {
+					ts.timeout = policy.Timeout
+				}
+			}
 		}
 		taskStates[k] = ts
 	}
@@ -40,75 +126,111 @@ func (e *executor) RunTasks(taskMap map[string]Task) error {
 		for _, dep := range dependencies[k] {
 			d := taskStates[dep]
 			if d == nil {
-				glog.Fatalf("did not find task state for dependency: %q", k)
+				return fmt.Errorf("did not find task state for dependency %q of %q", dep, k)
 			}
 			ts.dependencies = append(ts.dependencies, d)
 		}
 	}
 
-	noProgressCount := 0
+	results := make(chan taskResult)
+	running := 0
+	doneCount := 0
+	var failure error
+
 	for {
-		var canRun []*taskState
-		doneCount := 0
-		for _, ts := range taskStates {
-			if ts.done {
-				doneCount++
-				continue
-			}
-			ready := true
-			for _, dep := range ts.dependencies {
-				if !dep.done {
-					ready = false
+		// Start any tasks that are ready, unless we have already failed
+		now := time.Now()
+		var nextWakeup time.Time
+		if failure == nil {
+			for _, ts := range taskStates {
+				if running >= e.options.MaxConcurrency {
 					break
 				}
-			}
-			if ready {
-				canRun = append(canRun, ts)
+				if ts.done || ts.running || !ts.ready() {
+					continue
+				}
+				if ts.notBefore.After(now) {
+					if nextWakeup.IsZero() || ts.notBefore.Before(nextWakeup) {
+						nextWakeup = ts.notBefore
+					}
+					continue
+				}
+
+				ts.running = true
+				ts.attempts++
+				ts.trace.Attempts = append(ts.trace.Attempts, &TaskAttempt{Start: time.Now()})
+				running++
+				go func(ts *taskState) {
+					results <- taskResult{ts: ts, err: e.runTask(ts)}
+				}(ts)
 			}
 		}
 
-		glog.Infof("Tasks: %d done / %d total; %d can run", doneCount, len(taskStates), len(canRun))
-		if len(canRun) == 0 {
-			break
+		if running == 0 {
+			if failure != nil {
+				e.finishTrace(failure)
+				return failure
+			}
+			if nextWakeup.IsZero() {
+				// Nothing is running, and nothing is waiting to retry
+				break
+			}
 		}
 
-		progress := false
+		glog.V(2).Infof("Tasks: %d done / %d total; %d running", doneCount, len(taskStates), running)
 
-		var tasks []*taskState
-		for _, ts := range canRun {
-			tasks = append(tasks, ts)
+		var wakeup <-chan time.Time
+		if !nextWakeup.IsZero() {
+			wakeup = time.After(nextWakeup.Sub(now))
 		}
 
-		errors := e.forkJoin(tasks)
-		for i, err := range errors {
-			ts := tasks[i]
-			if err != nil {
-				glog.Warningf("error running task %q: %v", ts.key, err)
-				errors = append(errors, err)
-			} else {
+		select {
+		case <-wakeup:
+			continue
+
+		case result := <-results:
+			ts := result.ts
+			ts.running = false
+			running--
+
+			attempt := ts.trace.Attempts[len(ts.trace.Attempts)-1]
+			attempt.End = time.Now()
+			if result.err != nil {
+				attempt.Error = result.err.Error()
+			}
+
+			if result.err == nil {
 				ts.done = true
-				progress = true
+				ts.trace.Done = true
+				ts.lastError = nil
+				doneCount++
+				continue
 			}
-		}
 
-		if !progress {
-			if len(errors) != 0 {
-				noProgressCount++
-				if noProgressCount == MaxAttemptsWithNoProgress {
-					return fmt.Errorf("did not make any progress executing task.  Example error: %v", errors[0])
-				} else {
-					glog.Infof("No progress made, sleeping before retrying failed tasks")
-					time.Sleep(10 * time.Second)
+			ts.lastError = result.err
+			if IsTerminalError(result.err) {
+				glog.Warningf("error running task %q (not retryable): %v", ts.key, result.err)
+				if failure == nil {
+					failure = fmt.Errorf("error running task %q: %v", ts.key, result.err)
 				}
-			} else {
-				// Logic error!
-				panic("did not make progress executing tasks; but no errors reported")
+				continue
+			}
+			if ts.attempts >= ts.maxAttempts {
+				glog.Warningf("error running task %q (attempt %d of %d, giving up): %v", ts.key, ts.attempts, ts.maxAttempts, result.err)
+				if failure == nil {
+					failure = fmt.Errorf("error running task %q after %d attempts: %v", ts.key, ts.attempts, result.err)
+				}
+				continue
 			}
-		} else {
-			noProgressCount = 0
+
+			backoff := e.backoff(ts.attempts)
+			glog.Warningf("error running task %q (attempt %d of %d, will retry after %v): %v", ts.key, ts.attempts, ts.maxAttempts, backoff, result.err)
+			ts.notBefore = time.Now().Add(backoff)
 		}
 	}
 
+	glog.Infof("Tasks: %d done / %d total", doneCount, len(taskStates))
+
 	// Raise error if not all tasks done - this means they depended on each other
 	var notDone []string
 	for _, ts := range taskStates {
@@ -117,32 +239,95 @@ func (e *executor) RunTasks(taskMap map[string]Task) error {
 		}
 	}
 	if len(notDone) != 0 {
-		return fmt.Errorf("Unable to execute tasks (circular dependency): %s", strings.Join(notDone, ", "))
+		err := fmt.Errorf("Unable to execute tasks (circular dependency): %s", strings.Join(notDone, ", "))
+		e.finishTrace(err)
+		return err
 	}
 
+	e.finishTrace(nil)
 	return nil
 }
 
-type runnable func() error
+// finishTrace records the end of the run in the trace
+func (e *executor) finishTrace(err error) {
+	e.trace.End = time.Now()
+	if err != nil {
+		e.trace.Error = err.Error()
+	}
+	e.trace.computeBlockedBy()
+}
+
+// ready returns true if all the dependencies of the task are done
+func (ts *taskState) ready() bool {
+	for _, dep := range ts.dependencies {
+		if !dep.done {
+			return false
+		}
+	}
+	return true
+}
 
-func (e *executor) forkJoin(tasks []*taskState) []error {
-	if len(tasks) == 0 {
-		return nil
+// backoff returns the delay before the next attempt, after the specified number of failed attempts.
+// We add up to 25% jitter, so that tasks that were throttled together don't all retry together.
+func (e *executor) backoff(attempts int) time.Duration {
+	d := e.options.InitialBackoff
+	for i := 1; i < attempts && d < e.options.MaxBackoff; i++ {
+		d *= 2
 	}
+	if d > e.options.MaxBackoff {
+		d = e.options.MaxBackoff
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/4+1))
+}
 
-	var wg sync.WaitGroup
-	results := make([]error, len(tasks))
-	for i := 0; i < len(tasks); i++ {
-		wg.Add(1)
-		go func(ts *taskState, index int) {
-			results[index] = fmt.Errorf("function panic")
-			defer wg.Done()
-			glog.V(2).Infof("Executing task %q: %v\n", ts.key, ts.task)
-			results[index] = ts.task.Run(e.context)
-		}(tasks[i], i)
+// runTask runs a single attempt of the task, enforcing the timeout.
+// The task is given a Context whose Ctx() is cancelled on timeout, but we cannot interrupt a task that ignores it.
+// So on timeout we stop waiting and abandon the attempt, returning a TerminalError: retrying could repeat calls
+// that the abandoned attempt is still making.  If the attempt has already returned, we report its result instead.
+func (e *executor) runTask(ts *taskState) error {
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if ts.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, ts.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
 	}
+	defer cancel()
 
-	wg.Wait()
+	taskContext := e.context.withCtx(ctx)
 
-	return results
+	// Buffered, so that an abandoned attempt does not block when it eventually returns
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- NewTerminalError(fmt.Errorf("panic running task: %v", r))
+			}
+		}()
+		glog.V(2).Infof("Executing task %q: %v\n", ts.key, ts.task)
+		done <- ts.task.Run(taskContext)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// The attempt may have returned at the same moment; if so we have its result
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+
+		glog.Warningf("task %q did not complete within %v; abandoning it", ts.key, ts.timeout)
+		go func() {
+			err := <-done
+			if err != nil {
+				glog.Warningf("abandoned task %q failed: %v", ts.key, err)
+			} else {
+				glog.Warningf("abandoned task %q completed", ts.key)
+			}
+		}()
+		return NewTerminalError(fmt.Errorf("task abandoned after %v (it may still complete, so it is not retried)", ts.timeout))
+	}
 }
@@ -0,0 +1,202 @@
+package fi
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testTask is a task that fails a configurable number of times before succeeding
+type testTask struct {
+	deps     []Task
+	failures int
+	err      error
+	run      func()
+
+	mutex    sync.Mutex
+	attempts int
+}
+
+func (t *testTask) GetDependencies(tasks map[string]Task) []Task {
+	return t.deps
+}
+
+func (t *testTask) Run(c *Context) error {
+	t.mutex.Lock()
+	t.attempts++
+	attempts := t.attempts
+	t.mutex.Unlock()
+
+	if t.run != nil {
+		t.run()
+	}
+	if attempts <= t.failures {
+		if t.err != nil {
+			return t.err
+		}
+		return fmt.Errorf("failure %d", attempts)
+	}
+	return nil
+}
+
+func runTestTasks(options RunTasksOptions, tasks map[string]Task) error {
+	options.InitialBackoff = time.Millisecond
+	options.MaxBackoff = 5 * time.Millisecond
+	c := &Context{RunTasksOptions: options}
+	return c.RunTasks(tasks)
+}
+
+func TestExecutorRetriesUntilSuccess(t *testing.T) {
+	a := &testTask{failures: 2}
+	b := &testTask{deps: []Task{a}}
+	err := runTestTasks(RunTasksOptions{MaxTaskAttempts: 3}, map[string]Task{"a": a, "b": b})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a.attempts != 3 || b.attempts != 1 {
+		t.Fatalf("unexpected attempts: a=%d b=%d", a.attempts, b.attempts)
+	}
+}
+
+func TestExecutorRetryBudget(t *testing.T) {
+	a := &testTask{failures: 5}
+	b := &testTask{deps: []Task{a}}
+	err := runTestTasks(RunTasksOptions{MaxTaskAttempts: 3}, map[string]Task{"a": a, "b": b})
+	if err == nil || !strings.Contains(err.Error(), "after 3 attempts") {
+		t.Fatalf("expected retry budget to be exhausted, got %v", err)
+	}
+	if b.attempts != 0 {
+		t.Fatalf("dependent task should not have run")
+	}
+}
+
+func TestExecutorTerminalError(t *testing.T) {
+	a := &testTask{failures: 1, err: CannotChangeField("Name")}
+	err := runTestTasks(RunTasksOptions{MaxTaskAttempts: 3}, map[string]Task{"a": a})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if a.attempts != 1 {
+		t.Fatalf("terminal error should not be retried, but task ran %d times", a.attempts)
+	}
+}
+
+func TestExecutorMaxConcurrency(t *testing.T) {
+	var mutex sync.Mutex
+	running := 0
+	maxRunning := 0
+	run := func() {
+		mutex.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+	}
+
+	tasks := make(map[string]Task)
+	for i := 0; i < 10; i++ {
+		tasks[fmt.Sprintf("task-%d", i)] = &testTask{run: run}
+	}
+	err := runTestTasks(RunTasksOptions{MaxConcurrency: 3}, tasks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if maxRunning > 3 {
+		t.Fatalf("expected at most 3 concurrent tasks, got %d", maxRunning)
+	}
+}
+
+func TestExecutorTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	a := &testTask{run: func() { <-release }}
+	start := time.Now()
+	err := runTestTasks(RunTasksOptions{MaxTaskAttempts: 3, TaskTimeout: 10 * time.Millisecond}, map[string]Task{"a": a})
+	if err == nil || !strings.Contains(err.Error(), "abandoned") {
+		t.Fatalf("expected task to be abandoned, got %v", err)
+	}
+	// We don't wait for the abandoned attempt
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("executor waited %v for an abandoned task", elapsed)
+	}
+	// An abandoned task is never retried, because the first attempt may still be running
+	a.mutex.Lock()
+	attempts := a.attempts
+	a.mutex.Unlock()
+	if attempts != 1 {
+		t.Fatalf("expected a single attempt, got %d", attempts)
+	}
+}
+
+func TestExecutorTimeoutStopsRun(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	a := &testTask{run: func() { <-release }}
+	b := &testTask{deps: []Task{a}}
+	err := runTestTasks(RunTasksOptions{TaskTimeout: 10 * time.Millisecond}, map[string]Task{"a": a, "b": b})
+	if err == nil || !strings.Contains(err.Error(), "abandoned") {
+		t.Fatalf("expected task to be abandoned, got %v", err)
+	}
+	if b.attempts != 0 {
+		t.Fatalf("dependent of an abandoned task should not run")
+	}
+}
+
+func TestExecutorTimeoutReportsSuccess(t *testing.T) {
+	// A task that returns within the timeout is not affected by it
+	a := &testTask{run: func() { time.Sleep(5 * time.Millisecond) }}
+	err := runTestTasks(RunTasksOptions{TaskTimeout: time.Second}, map[string]Task{"a": a})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestExecutorCircularDependency(t *testing.T) {
+	a := &testTask{}
+	b := &testTask{deps: []Task{a}}
+	a.deps = []Task{b}
+	err := runTestTasks(RunTasksOptions{}, map[string]Task{"a": a, "b": b})
+	if err == nil || !strings.Contains(err.Error(), "circular dependency") {
+		t.Fatalf("expected circular dependency error, got %v", err)
+	}
+}
+
+func TestExecutorTrace(t *testing.T) {
+	a := &testTask{}
+	b := &testTask{deps: []Task{a}, failures: 1}
+	c := &testTask{}
+	context := &Context{RunTasksOptions: RunTasksOptions{InitialBackoff: time.Millisecond}}
+	err := context.RunTasks(map[string]Task{"a": a, "b": b, "c": c})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	trace := context.Trace
+	if trace == nil {
+		t.Fatalf("trace was not recorded")
+	}
+	bTrace := trace.Task("b")
+	if len(bTrace.Attempts) != 2 || bTrace.FailedAttempts() != 1 || !bTrace.Done {
+		t.Fatalf("unexpected trace for b: %v", DebugAsJsonString(bTrace))
+	}
+	if bTrace.BlockedBy != "a" {
+		t.Fatalf("expected b to be blocked by a, was %q", bTrace.BlockedBy)
+	}
+
+	var path []string
+	for _, task := range trace.CriticalPath() {
+		path = append(path, task.Key)
+	}
+	if strings.Join(path, ",") != "a,b" {
+		t.Fatalf("unexpected critical path: %v", path)
+	}
+}
@@ -27,14 +27,52 @@ const (
 	localPackageDir = "/var/cache/nodeup/packages/"
 )
 
+// packageManager is the tool we use to install packages: apt-get (with dpkg), or yum / dnf (with rpm)
+type packageManager string
+
+const (
+	packageManagerApt = packageManager("apt-get")
+	packageManagerYum = packageManager("yum")
+	packageManagerDnf = packageManager("dnf")
+)
+
+// findPackageManager detects the package manager of the distro we are running on.
+// dnf is preferred over yum, as yum is only a compatibility wrapper on distros that have dnf.
+func findPackageManager() (packageManager, error) {
+	for _, pm := range []packageManager{packageManagerApt, packageManagerDnf, packageManagerYum} {
+		if _, err := exec.LookPath(string(pm)); err == nil {
+			return pm, nil
+		}
+	}
+	return "", fmt.Errorf("cannot find a supported package manager (apt-get, dnf or yum)")
+}
+
+// isRedhatFamily returns true if the package manager installs rpm packages
+func (pm packageManager) isRedhatFamily() bool {
+	return pm == packageManagerYum || pm == packageManagerDnf
+}
+
 var _ fi.HasDependencies = &Package{}
 
+// packageDependencies lists the packages that must be installed before a package, where the package manager
+// would not otherwise install them first (we install each package from a file, one at a time)
+var packageDependencies = map[string][]string{
+	"docker-engine": {"docker-engine-selinux"},
+}
+
 func (p *Package) GetDependencies(tasks map[string]fi.Task) []fi.Task {
 	var deps []fi.Task
 	for _, v := range tasks {
 		if _, ok := v.(*UpdatePackages); ok {
 			deps = append(deps, v)
 		}
+		if other, ok := v.(*Package); ok {
+			for _, name := range packageDependencies[p.Name] {
+				if other.Name == name {
+					deps = append(deps, v)
+				}
+			}
+		}
 	}
 	return deps
 }
@@ -55,6 +93,17 @@ func NewPackage(name string, contents string, meta string) (fi.Task, error) {
 }
 
 func (e *Package) Find(c *fi.Context) (*Package, error) {
+	pm, err := findPackageManager()
+	if err != nil {
+		return nil, err
+	}
+	if pm.isRedhatFamily() {
+		return e.findRpm()
+	}
+	return e.findDpkg()
+}
+
+func (e *Package) findDpkg() (*Package, error) {
 	args := []string{"dpkg-query", "-f", "${db:Status-Abbrev}${Version}\\n", "-W", e.Name}
 	human := strings.Join(args, " ")
 
@@ -107,6 +156,40 @@ func (e *Package) Find(c *fi.Context) (*Package, error) {
 	}, nil
 }
 
+func (e *Package) findRpm() (*Package, error) {
+	args := []string{"rpm", "-q", "--queryformat", "%{VERSION}-%{RELEASE}\\n", e.Name}
+	human := strings.Join(args, " ")
+
+	glog.V(2).Infof("Listing installed packages: %s", human)
+	cmd := exec.Command(args[0], args[1:]...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(output), "is not installed") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error listing installed packages: %v: %s", err, string(output))
+	}
+
+	// If several versions are installed (e.g. kernel), we report the last one
+	installedVersion := ""
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		installedVersion = line
+	}
+
+	if installedVersion == "" {
+		return nil, nil
+	}
+
+	return &Package{
+		Name:    e.Name,
+		Version: fi.String(installedVersion),
+	}, nil
+}
+
 func (e *Package) Run(c *fi.Context) error {
 	return fi.DefaultDeltaRunMethod(e, c)
 }
@@ -116,13 +199,22 @@ func (s *Package) CheckChanges(a, e, changes *Package) error {
 }
 
 func (_ *Package) RenderLocal(t *local.LocalTarget, a, e, changes *Package) error {
-	if changes.Version != nil {
+	if a == nil || changes.Version != nil {
 		glog.Infof("Installing package %q", e.Name)
 
+		pm, err := findPackageManager()
+		if err != nil {
+			return err
+		}
+
 		if e.Source != nil {
-			// Install a deb
+			// Install a deb or an rpm
 			local := path.Join(localPackageDir, e.Name)
-			err := os.MkdirAll(localPackageDir, 0755)
+			if pm.isRedhatFamily() {
+				// yum & dnf only install local files that have the rpm extension
+				local += ".rpm"
+			}
+			err = os.MkdirAll(localPackageDir, 0755)
 			if err != nil {
 				return fmt.Errorf("error creating directories %q: %v", path.Dir(local), err)
 			}
@@ -140,7 +232,12 @@ func (_ *Package) RenderLocal(t *local.LocalTarget, a, e, changes *Package) erro
 				return err
 			}
 
-			args := []string{"dpkg", "-i", local}
+			var args []string
+			if pm.isRedhatFamily() {
+				args = []string{string(pm), "install", "-y", local}
+			} else {
+				args = []string{"dpkg", "-i", local}
+			}
 			glog.Infof("running command %s", args)
 			cmd := exec.Command(args[0], args[1:]...)
 			output, err := cmd.CombinedOutput()
@@ -148,7 +245,16 @@ func (_ *Package) RenderLocal(t *local.LocalTarget, a, e, changes *Package) erro
 				return fmt.Errorf("error installing package %q: %v: %s", e.Name, err, string(output))
 			}
 		} else {
-			args := []string{"apt-get", "install", "--yes", e.Name}
+			var args []string
+			if pm.isRedhatFamily() {
+				packageSpec := e.Name
+				if e.Version != nil {
+					packageSpec += "-" + *e.Version
+				}
+				args = []string{string(pm), "install", "-y", packageSpec}
+			} else {
+				args = []string{"apt-get", "install", "--yes", e.Name}
+			}
 			glog.Infof("running command %s", args)
 			cmd := exec.Command(args[0], args[1:]...)
 			output, err := cmd.CombinedOutput()
@@ -0,0 +1,38 @@
+package nodetasks
+
+import (
+	"k8s.io/kops/upup/pkg/fi"
+	"testing"
+)
+
+func TestPackageDependencies(t *testing.T) {
+	update := &UpdatePackages{}
+	engine := &Package{Name: "docker-engine"}
+	selinux := &Package{Name: "docker-engine-selinux"}
+	other := &Package{Name: "bridge-utils"}
+	tasks := map[string]fi.Task{
+		"update-packages":       update,
+		"docker-engine":         engine,
+		"docker-engine-selinux": selinux,
+		"bridge-utils":          other,
+	}
+
+	deps := engine.GetDependencies(tasks)
+	if len(deps) != 2 || !containsTask(deps, update) || !containsTask(deps, selinux) {
+		t.Errorf("expected docker-engine to depend on update-packages and docker-engine-selinux, got %v", deps)
+	}
+
+	deps = selinux.GetDependencies(tasks)
+	if len(deps) != 1 || !containsTask(deps, update) {
+		t.Errorf("expected docker-engine-selinux to depend only on update-packages, got %v", deps)
+	}
+}
+
+func containsTask(tasks []fi.Task, task fi.Task) bool {
+	for _, t := range tasks {
+		if t == task {
+			return true
+		}
+	}
+	return false
+}
@@ -40,7 +40,18 @@ func (_ *UpdatePackages) RenderLocal(t *local.LocalTarget, a, e, changes *Update
 		glog.Infof("SKIP_PACKAGE_UPDATE was set; skipping package update")
 		return nil
 	}
-	args := []string{"apt-get", "update"}
+	pm, err := findPackageManager()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	if pm.isRedhatFamily() {
+		// makecache refreshes the metadata; we don't want to upgrade everything as "yum update" would
+		args = []string{string(pm), "makecache"}
+	} else {
+		args = []string{"apt-get", "update"}
+	}
 	glog.Infof("running command %s", args)
 	cmd := exec.Command(args[0], args[1:]...)
 	output, err := cmd.CombinedOutput()
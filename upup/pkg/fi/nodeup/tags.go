@@ -23,6 +23,21 @@ func FindOSTags(rootfs string) ([]string, error) {
 	} else if !os.IsNotExist(err) {
 		glog.Infof("error reading /etc/debian_version: %v", err)
 	}
+
+	redhatReleaseBytes, err := ioutil.ReadFile(path.Join(rootfs, "etc/redhat-release"))
+	if err == nil {
+		redhatRelease := strings.TrimSpace(string(redhatReleaseBytes))
+		if strings.HasPrefix(redhatRelease, "CentOS Linux release 7.") {
+			return []string{"_centos7", "_redhat_family", "_systemd"}, nil
+		} else if strings.HasPrefix(redhatRelease, "Red Hat Enterprise Linux Server release 7.") {
+			return []string{"_rhel7", "_redhat_family", "_systemd"}, nil
+		} else {
+			return nil, fmt.Errorf("unhandled redhat release %q", redhatRelease)
+		}
+	} else if !os.IsNotExist(err) {
+		glog.Infof("error reading /etc/redhat-release: %v", err)
+	}
+
 	return nil, fmt.Errorf("cannot identify distro")
 }
 
@@ -0,0 +1,45 @@
+package nodeup
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestFindOSTags(t *testing.T) {
+	grid := []struct {
+		file     string
+		contents string
+		expected []string
+	}{
+		{"etc/debian_version", "8.5\n", []string{"_jessie", "_debian_family", "_systemd"}},
+		{"etc/redhat-release", "CentOS Linux release 7.2.1511 (Core)\n", []string{"_centos7", "_redhat_family", "_systemd"}},
+		{"etc/redhat-release", "Red Hat Enterprise Linux Server release 7.2 (Maipo)\n", []string{"_rhel7", "_redhat_family", "_systemd"}},
+	}
+	for _, g := range grid {
+		rootfs, err := ioutil.TempDir("", "rootfs")
+		if err != nil {
+			t.Fatalf("error creating temp dir: %v", err)
+		}
+		defer os.RemoveAll(rootfs)
+
+		p := path.Join(rootfs, g.file)
+		if err := os.MkdirAll(path.Dir(p), 0755); err != nil {
+			t.Fatalf("error creating directory: %v", err)
+		}
+		if err := ioutil.WriteFile(p, []byte(g.contents), 0644); err != nil {
+			t.Fatalf("error writing file: %v", err)
+		}
+
+		actual, err := FindOSTags(rootfs)
+		if err != nil {
+			t.Errorf("unexpected error for %s %q: %v", g.file, g.contents, err)
+			continue
+		}
+		if !reflect.DeepEqual(actual, g.expected) {
+			t.Errorf("unexpected tags for %s %q: %v (expected %v)", g.file, g.contents, actual, g.expected)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/vfs"
+	"strconv"
+	"strings"
 	"text/template"
 )
 
@@ -32,6 +34,16 @@ func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags
 		tags:         tags,
 	}
 
+	var keyProvider fi.KeyProvider
+	if cluster.Spec.SecretsEncryption != nil && cluster.Spec.SecretsEncryption.KeyFile != "" {
+		glog.Infof("Reading secrets encryption key from %q", cluster.Spec.SecretsEncryption.KeyFile)
+		k, err := fi.NewLocalKeyProviderFromFile(cluster.Spec.SecretsEncryption.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keyProvider = k
+	}
+
 	if cluster.Spec.SecretStore != "" {
 		glog.Infof("Building SecretStore at %q", cluster.Spec.SecretStore)
 		p, err := vfs.Context.BuildVfsPath(cluster.Spec.SecretStore)
@@ -39,7 +51,7 @@ func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags
 			return nil, fmt.Errorf("error building secret store path: %v", err)
 		}
 
-		secretStore, err := fi.NewVFSSecretStore(p)
+		secretStore, err := fi.NewVFSSecretStore(p, keyProvider)
 		if err != nil {
 			return nil, fmt.Errorf("error building secret store: %v", err)
 		}
@@ -56,7 +68,7 @@ func newTemplateFunctions(nodeupConfig *NodeUpConfig, cluster *api.Cluster, tags
 			return nil, fmt.Errorf("error building key store path: %v", err)
 		}
 
-		keyStore, err := fi.NewVFSCAStore(p, false)
+		keyStore, err := fi.NewVFSCAStore(p, false, keyProvider)
 		if err != nil {
 			return nil, fmt.Errorf("error building key store: %v", err)
 		}
@@ -107,6 +119,51 @@ func (t *templateFunctions) populate(dest template.FuncMap) {
 		}
 	}
 	dest["ClusterName"] = func() string { return t.cluster.Name }
+	dest["ProtokubeFlags"] = t.ProtokubeFlags
+}
+
+// ProtokubeFlags returns the flags for protokube (other than those set directly in the template)
+func (t *templateFunctions) ProtokubeFlags() string {
+	var flags []string
+	if dns := t.cluster.Spec.DNS; dns != nil && dns.Provider != "" {
+		flags = append(flags, "--dns-provider="+dns.Provider)
+		switch dns.Provider {
+		case "rfc2136":
+			flags = append(flags, "--dns-server="+dns.Server)
+			// Only masters publish records, and the TSIG secret is only installed on masters
+			if dns.TSIGKeyName != "" && t.IsMaster() {
+				flags = append(flags, "--dns-tsig-key-name="+dns.TSIGKeyName)
+				flags = append(flags, "--dns-tsig-secret-file="+dns.TSIGSecretFile)
+				if dns.TSIGAlgorithm != "" {
+					flags = append(flags, "--dns-tsig-algorithm="+dns.TSIGAlgorithm)
+				}
+			}
+		case "hosts":
+			if dns.HostsStore != "" {
+				flags = append(flags, "--dns-hosts-store="+dns.HostsStore)
+			}
+		}
+		if dns.Provider != "route53" && t.IsMaster() && t.cluster.Spec.MasterInternalName != "" {
+			// cloudup only manages the API name with route53
+			flags = append(flags, "--master-internal-name="+t.cluster.Spec.MasterInternalName)
+		}
+	}
+	if t.IsMaster() {
+		if b := t.cluster.Spec.EtcdBackup; b != nil && b.Store != "" {
+			flags = append(flags, "--etcd-backup-store="+b.Store)
+			if b.Interval != "" {
+				flags = append(flags, "--etcd-backup-interval="+b.Interval)
+			}
+			if b.Retention != 0 {
+				flags = append(flags, "--etcd-backup-retention="+strconv.Itoa(b.Retention))
+			}
+			// Snapshots hold the secrets, so we encrypt them with the same key as the state store
+			if e := t.cluster.Spec.SecretsEncryption; e != nil && e.KeyFile != "" {
+				flags = append(flags, "--encryption-key-file="+e.KeyFile)
+			}
+		}
+	}
+	return strings.Join(flags, " ")
 }
 
 // IsMaster returns true if we are tagged as a master
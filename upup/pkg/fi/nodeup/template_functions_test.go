@@ -0,0 +1,37 @@
+package nodeup
+
+import (
+	"k8s.io/kops/upup/pkg/api"
+	"strings"
+	"testing"
+)
+
+func TestProtokubeFlagsDNS(t *testing.T) {
+	cluster := &api.Cluster{}
+	cluster.Spec.MasterInternalName = "api.internal.example.com"
+	cluster.Spec.DNS = &api.DNSSpec{
+		Provider:       "rfc2136",
+		Server:         "10.0.0.2:53",
+		TSIGKeyName:    "kops-update",
+		TSIGSecretFile: "/etc/kubernetes/dns-tsig-secret",
+		TSIGAlgorithm:  "hmac-sha256",
+	}
+
+	master := &templateFunctions{cluster: cluster, tags: map[string]struct{}{TagMaster: {}}}
+	flags := master.ProtokubeFlags()
+	for _, expected := range []string{"--dns-provider=rfc2136", "--dns-server=10.0.0.2:53", "--dns-tsig-key-name=kops-update", "--dns-tsig-secret-file=/etc/kubernetes/dns-tsig-secret", "--dns-tsig-algorithm=hmac-sha256", "--master-internal-name=api.internal.example.com"} {
+		if !strings.Contains(flags, expected) {
+			t.Errorf("expected master flags to contain %q, got %q", expected, flags)
+		}
+	}
+
+	// The TSIG secret is only installed on the masters
+	node := &templateFunctions{cluster: cluster, tags: map[string]struct{}{}}
+	flags = node.ProtokubeFlags()
+	if !strings.Contains(flags, "--dns-provider=rfc2136") {
+		t.Errorf("expected node flags to contain the DNS provider, got %q", flags)
+	}
+	if strings.Contains(flags, "--dns-tsig-") || strings.Contains(flags, "--master-internal-name") {
+		t.Errorf("unexpected master-only flags for node: %q", flags)
+	}
+}
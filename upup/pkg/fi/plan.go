@@ -0,0 +1,218 @@
+package fi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/utils"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	PlanFormatText = "text"
+	PlanFormatJSON = "json"
+	PlanFormatYAML = "yaml"
+)
+
+type PlanAction string
+
+const (
+	PlanActionCreate PlanAction = "create"
+	PlanActionUpdate PlanAction = "update"
+	PlanActionNoOp   PlanAction = "no-op"
+)
+
+// Plan is a machine-readable version of the changes that a DryRunTarget collected.
+// A plan can be saved, and then later compared against a freshly computed plan,
+// so that we only apply changes if the live state has not changed since the plan was reviewed.
+type Plan struct {
+	Changes []*PlanChange `json:"changes"`
+}
+
+// PlanChange is the planned action for a single task
+type PlanChange struct {
+	Key    string             `json:"key"`
+	Type   string             `json:"type"`
+	Action PlanAction         `json:"action"`
+	Fields []*PlanFieldChange `json:"fields,omitempty"`
+}
+
+// PlanFieldChange is a change to a single field of a task.
+// Before is empty when the task is being created.
+type PlanFieldChange struct {
+	Name   string `json:"name"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// BuildPlan builds a Plan from the changes collected so far; tasks with no changes are included as no-ops
+func (t *DryRunTarget) BuildPlan(taskMap map[string]Task) (*Plan, error) {
+	byKey := make(map[string]*PlanChange)
+
+	for _, r := range t.changes {
+		key := IdForTask(taskMap, r.e)
+
+		fieldChanges, err := r.fieldChanges()
+		if err != nil {
+			return nil, err
+		}
+
+		c := &PlanChange{
+			Key:    key,
+			Type:   taskTypeName(r.e),
+			Fields: fieldChanges,
+		}
+		if r.aIsNil {
+			c.Action = PlanActionCreate
+		} else if len(fieldChanges) != 0 {
+			c.Action = PlanActionUpdate
+		} else {
+			c.Action = PlanActionNoOp
+		}
+		byKey[key] = c
+	}
+
+	for key, task := range taskMap {
+		if byKey[key] == nil {
+			byKey[key] = &PlanChange{
+				Key:    key,
+				Type:   taskTypeName(task),
+				Action: PlanActionNoOp,
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	plan := &Plan{}
+	for _, k := range keys {
+		plan.Changes = append(plan.Changes, byKey[k])
+	}
+	return plan, nil
+}
+
+// taskTypeName returns the type name for a task, e.g. awstasks.VPC
+func taskTypeName(t Task) string {
+	return strings.TrimPrefix(reflect.TypeOf(t).String(), "*")
+}
+
+// HasChanges returns true if any task would be created or updated
+func (p *Plan) HasChanges() bool {
+	for _, c := range p.Changes {
+		if c.Action != PlanActionNoOp {
+			return true
+		}
+	}
+	return false
+}
+
+// Change returns the planned change for the task with the given key, or nil if there is no such task
+func (p *Plan) Change(key string) *PlanChange {
+	for _, c := range p.Changes {
+		if c.Key == key {
+			return c
+		}
+	}
+	return nil
+}
+
+// Marshal serializes the plan in the specified format (json or yaml)
+func (p *Plan) Marshal(format string) ([]byte, error) {
+	switch format {
+	case PlanFormatJSON:
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("error serializing plan: %v", err)
+		}
+		return append(data, '\n'), nil
+
+	case PlanFormatYAML:
+		data, err := utils.YamlMarshal(p)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing plan: %v", err)
+		}
+		return data, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported plan format %q", format)
+	}
+}
+
+// WriteFile saves the plan to a file; the format is JSON if the file ends in .json, otherwise YAML
+func (p *Plan) WriteFile(path string) error {
+	format := PlanFormatYAML
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		format = PlanFormatJSON
+	}
+
+	data, err := p.Marshal(format)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(path, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing plan to %q: %v", path, err)
+	}
+	return nil
+}
+
+// ReadPlanFile reads a plan previously saved with WriteFile (in either JSON or YAML)
+func ReadPlanFile(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan %q: %v", path, err)
+	}
+
+	plan := &Plan{}
+	err = utils.YamlUnmarshal(data, plan)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing plan %q: %v", path, err)
+	}
+	return plan, nil
+}
+
+// Diff returns a description of each difference between this plan and the other plan.
+// An empty result means that the plans are the same.
+func (p *Plan) Diff(other *Plan) []string {
+	var diffs []string
+
+	ours := make(map[string]*PlanChange)
+	for _, c := range p.Changes {
+		ours[c.Key] = c
+	}
+	theirs := make(map[string]*PlanChange)
+	for _, c := range other.Changes {
+		theirs[c.Key] = c
+	}
+
+	for _, c := range p.Changes {
+		o := theirs[c.Key]
+		if o == nil {
+			diffs = append(diffs, fmt.Sprintf("%s: only in first plan", c.Key))
+			continue
+		}
+		if c.Action != o.Action {
+			diffs = append(diffs, fmt.Sprintf("%s: action %s -> %s", c.Key, c.Action, o.Action))
+			continue
+		}
+		if !reflect.DeepEqual(c.Fields, o.Fields) {
+			diffs = append(diffs, fmt.Sprintf("%s: field changes differ", c.Key))
+		}
+	}
+
+	for _, c := range other.Changes {
+		if ours[c.Key] == nil {
+			diffs = append(diffs, fmt.Sprintf("%s: only in second plan", c.Key))
+		}
+	}
+
+	return diffs
+}
@@ -0,0 +1,264 @@
+package fi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// planTestTask is a task whose actual state is looked up in planTestActual
+type planTestTask struct {
+	Name *string
+	Size *int
+}
+
+var planTestActual map[string]*planTestTask
+
+func (e *planTestTask) Find(c *Context) (*planTestTask, error) {
+	a := planTestActual[*e.Name]
+	if a == nil {
+		return nil, nil
+	}
+	actual := *a
+	return &actual, nil
+}
+
+func (e *planTestTask) Run(c *Context) error {
+	return DefaultDeltaRunMethod(e, c)
+}
+
+func (_ *planTestTask) CheckChanges(a, e, changes *planTestTask) error {
+	return nil
+}
+
+func newPlanTestTask(name string, size int) *planTestTask {
+	return &planTestTask{Name: &name, Size: &size}
+}
+
+// buildTestPlan runs the tasks against a DryRunTarget and returns the resulting plan
+func buildTestPlan(t *testing.T, actual map[string]*planTestTask, tasks map[string]Task) *Plan {
+	planTestActual = actual
+	defer func() { planTestActual = nil }()
+
+	target := NewDryRunTarget(ioutil.Discard)
+	c, err := NewContext(target, nil, nil, nil, true)
+	if err != nil {
+		t.Fatalf("error building context: %v", err)
+	}
+	defer c.Close()
+
+	err = c.RunTasks(tasks)
+	if err != nil {
+		t.Fatalf("error running tasks: %v", err)
+	}
+
+	plan, err := target.BuildPlan(tasks)
+	if err != nil {
+		t.Fatalf("error building plan: %v", err)
+	}
+	return plan
+}
+
+func TestBuildPlan(t *testing.T) {
+	actual := map[string]*planTestTask{
+		"b": newPlanTestTask("b", 1),
+		"c": newPlanTestTask("c", 3),
+	}
+	tasks := map[string]Task{
+		"task/a": newPlanTestTask("a", 1),
+		"task/b": newPlanTestTask("b", 2),
+		"task/c": newPlanTestTask("c", 3),
+	}
+
+	plan := buildTestPlan(t, actual, tasks)
+
+	expected := &Plan{
+		Changes: []*PlanChange{
+			{
+				Key:    "task/a",
+				Type:   "fi.planTestTask",
+				Action: PlanActionCreate,
+				Fields: []*PlanFieldChange{
+					{Name: "Name", After: "a"},
+					{Name: "Size", After: "1"},
+				},
+			},
+			{
+				Key:    "task/b",
+				Type:   "fi.planTestTask",
+				Action: PlanActionUpdate,
+				Fields: []*PlanFieldChange{
+					{Name: "Size", Before: "1", After: "2"},
+				},
+			},
+			{
+				Key:    "task/c",
+				Type:   "fi.planTestTask",
+				Action: PlanActionNoOp,
+			},
+		},
+	}
+	if !reflect.DeepEqual(plan, expected) {
+		t.Fatalf("unexpected plan: %s", DebugAsJsonString(plan))
+	}
+
+	if !plan.HasChanges() {
+		t.Fatalf("expected plan to have changes")
+	}
+	if c := plan.Change("task/b"); c == nil || c.Action != PlanActionUpdate {
+		t.Fatalf("unexpected change for task/b: %v", c)
+	}
+	if c := plan.Change("task/missing"); c != nil {
+		t.Fatalf("unexpected change for missing task: %v", c)
+	}
+}
+
+func TestBuildPlanNoChanges(t *testing.T) {
+	actual := map[string]*planTestTask{
+		"a": newPlanTestTask("a", 1),
+	}
+	tasks := map[string]Task{
+		"task/a": newPlanTestTask("a", 1),
+	}
+
+	plan := buildTestPlan(t, actual, tasks)
+	if plan.HasChanges() {
+		t.Fatalf("expected no changes, got %s", DebugAsJsonString(plan))
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Action != PlanActionNoOp {
+		t.Fatalf("expected a single no-op, got %s", DebugAsJsonString(plan))
+	}
+}
+
+func TestPlanMarshal(t *testing.T) {
+	plan := &Plan{
+		Changes: []*PlanChange{
+			{
+				Key:    "task/a",
+				Type:   "fi.planTestTask",
+				Action: PlanActionUpdate,
+				Fields: []*PlanFieldChange{{Name: "Size", Before: "1", After: "2"}},
+			},
+		},
+	}
+
+	data, err := plan.Marshal(PlanFormatJSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded := &Plan{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("error parsing json plan: %v", err)
+	}
+	if !reflect.DeepEqual(plan, decoded) {
+		t.Fatalf("json plan did not round-trip: %s", string(data))
+	}
+
+	data, err = plan.Marshal(PlanFormatYAML)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(data), "action: update") {
+		t.Fatalf("unexpected yaml plan: %s", string(data))
+	}
+
+	_, err = plan.Marshal(PlanFormatText)
+	if err == nil || !strings.Contains(err.Error(), "unsupported plan format") {
+		t.Fatalf("expected unsupported format error, got %v", err)
+	}
+}
+
+func TestPlanWriteAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plan")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	plan := &Plan{
+		Changes: []*PlanChange{
+			{Key: "task/a", Type: "fi.planTestTask", Action: PlanActionCreate, Fields: []*PlanFieldChange{{Name: "Size", After: "1"}}},
+			{Key: "task/b", Type: "fi.planTestTask", Action: PlanActionNoOp},
+		},
+	}
+
+	for _, name := range []string{"plan.json", "plan.yaml"} {
+		p := path.Join(dir, name)
+		if err := plan.WriteFile(p); err != nil {
+			t.Fatalf("error writing %s: %v", name, err)
+		}
+		read, err := ReadPlanFile(p)
+		if err != nil {
+			t.Fatalf("error reading %s: %v", name, err)
+		}
+		if diffs := plan.Diff(read); len(diffs) != 0 {
+			t.Fatalf("plan %s did not round-trip: %v", name, diffs)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path.Join(dir, "plan.json"))
+	if err != nil {
+		t.Fatalf("error reading plan.json: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte("{")) {
+		t.Fatalf("expected plan.json to be written as JSON, got %s", string(data))
+	}
+}
+
+func TestPlanDiff(t *testing.T) {
+	a := &Plan{
+		Changes: []*PlanChange{
+			{Key: "task/a", Action: PlanActionCreate},
+			{Key: "task/b", Action: PlanActionUpdate, Fields: []*PlanFieldChange{{Name: "Size", Before: "1", After: "2"}}},
+			{Key: "task/c", Action: PlanActionNoOp},
+		},
+	}
+	b := &Plan{
+		Changes: []*PlanChange{
+			{Key: "task/a", Action: PlanActionNoOp},
+			{Key: "task/b", Action: PlanActionUpdate, Fields: []*PlanFieldChange{{Name: "Size", Before: "3", After: "2"}}},
+			{Key: "task/d", Action: PlanActionCreate},
+		},
+	}
+
+	if diffs := a.Diff(a); len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %v", diffs)
+	}
+
+	expected := []string{
+		"task/a: action create -> no-op",
+		"task/b: field changes differ",
+		"task/c: only in first plan",
+		"task/d: only in second plan",
+	}
+	if diffs := a.Diff(b); !reflect.DeepEqual(diffs, expected) {
+		t.Fatalf("unexpected differences: %v", diffs)
+	}
+}
+
+func TestDryRunTargetFinishFormat(t *testing.T) {
+	tasks := map[string]Task{
+		"task/a": newPlanTestTask("a", 1),
+	}
+
+	out := &bytes.Buffer{}
+	target := NewDryRunTarget(out)
+	target.Format = PlanFormatJSON
+	if err := target.Finish(tasks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plan := &Plan{}
+	if err := json.Unmarshal(out.Bytes(), plan); err != nil {
+		t.Fatalf("expected json output, got %q: %v", out.String(), err)
+	}
+
+	target.Format = "xml"
+	if err := target.Finish(tasks); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
@@ -0,0 +1,276 @@
+package fi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/golang/glog"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+)
+
+// Path for the advisory lock in the state store
+const PathStateLock = "lock.json"
+
+// DefaultStateLockTTL is how long a lock is valid for without being renewed; after this we assume the holder has crashed.
+// The holder renews the lock every third of the TTL, so long operations (e.g. rolling-update) keep the lock.
+const DefaultStateLockTTL = 10 * time.Minute
+
+// stateLockSettleTime is how long we wait after writing the lock before checking that we still hold it.
+// CreateFile is not atomic on every VFS (on S3 it is a read then a write), so another client that also found
+// no lock may overwrite ours; the last writer wins, and the others see its lock when they read back.
+var stateLockSettleTime = 2 * time.Second
+
+// StateLock is an advisory lock on the state store of a cluster, which prevents concurrent mutating operations.
+// It is only advisory: it relies on CreateFile, which is not fully atomic on every VFS.
+type StateLock struct {
+	// ID is a random id, so that we only ever release our own lock
+	ID string `json:"id"`
+	// Owner describes who holds the lock (user@host)
+	Owner string `json:"owner"`
+	// Operation is the operation being performed (e.g. "create cluster")
+	Operation string `json:"operation"`
+	// Created is when the lock was acquired
+	Created time.Time `json:"created"`
+	// Expires is when the lock stops being valid, unless it is renewed
+	Expires time.Time `json:"expires"`
+
+	ttl time.Duration
+
+	// mutex guards stopRenewal & renewalDone
+	mutex       sync.Mutex
+	stopRenewal chan struct{}
+	renewalDone chan struct{}
+}
+
+func (l *StateLock) String() string {
+	return fmt.Sprintf("%s (operation %q, acquired %s, expires %s)", l.Owner, l.Operation, l.Created.Format(time.RFC3339), l.Expires.Format(time.RFC3339))
+}
+
+// IsExpired returns true if the lock has passed its TTL
+func (l *StateLock) IsExpired() bool {
+	return time.Now().After(l.Expires)
+}
+
+// ReadStateLock returns the current lock on the state store, or nil if it is not locked
+func ReadStateLock(stateStore StateStore) (*StateLock, error) {
+	p := stateStore.VFSPath().Join(PathStateLock)
+	data, err := p.ReadFile()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading lock %s: %v", p, err)
+	}
+
+	lock := &StateLock{}
+	err = json.Unmarshal(data, lock)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing lock %s: %v", p, err)
+	}
+	return lock, nil
+}
+
+// AcquireStateLock takes the lock on the state store; it fails if another (unexpired) lock is held.
+// Expired locks are broken automatically.  The lock is renewed in the background until it is released.
+func AcquireStateLock(stateStore StateStore, operation string, ttl time.Duration) (*StateLock, error) {
+	if ttl <= 0 {
+		ttl = DefaultStateLockTTL
+	}
+
+	id, err := randomLockID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lock := &StateLock{
+		ID:        id,
+		Owner:     lockOwner(),
+		Operation: operation,
+		Created:   now,
+		Expires:   now.Add(ttl),
+		ttl:       ttl,
+	}
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing lock: %v", err)
+	}
+
+	p := stateStore.VFSPath().Join(PathStateLock)
+
+	// We try at most twice: the second time is after breaking an expired lock
+	for attempt := 0; attempt < 2; attempt++ {
+		err = p.CreateFile(data)
+		if err == nil {
+			// Wait for any concurrent writer, then read back to check we won the race
+			time.Sleep(stateLockSettleTime)
+			actual, err := ReadStateLock(stateStore)
+			if err != nil {
+				return nil, err
+			}
+			if actual == nil || actual.ID != lock.ID {
+				return nil, fmt.Errorf("lost race to acquire lock on cluster state; please retry")
+			}
+			glog.V(2).Infof("acquired state lock %s", lock)
+			lock.startRenewal(stateStore)
+			return lock, nil
+		}
+
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("error creating lock %s: %v", p, err)
+		}
+
+		existing, err := ReadStateLock(stateStore)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			// Released between our create and our read
+			continue
+		}
+		if !existing.IsExpired() {
+			return nil, fmt.Errorf("cluster state is locked by %s; if you are sure no other operation is running, retry with --force-unlock", existing)
+		}
+
+		glog.Warningf("breaking expired lock held by %s", existing)
+		err = p.Remove()
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error removing expired lock %s: %v", p, err)
+		}
+	}
+
+	return nil, fmt.Errorf("unable to acquire lock on cluster state; please retry")
+}
+
+// startRenewal starts a goroutine that extends the expiry of the lock every third of its TTL, until Release
+func (l *StateLock) startRenewal(stateStore StateStore) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.stopRenewal = make(chan struct{})
+	l.renewalDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := l.renew(stateStore)
+				if err != nil {
+					glog.Errorf("error renewing lock on cluster state: %v", err)
+					if err == errStateLockLost {
+						return
+					}
+				}
+			}
+		}
+	}(l.stopRenewal, l.renewalDone)
+}
+
+var errStateLockLost = fmt.Errorf("lock on cluster state is no longer held by this operation")
+
+// renew extends the expiry of the lock, if it is still ours
+func (l *StateLock) renew(stateStore StateStore) error {
+	actual, err := ReadStateLock(stateStore)
+	if err != nil {
+		return err
+	}
+	if actual == nil || actual.ID != l.ID {
+		return errStateLockLost
+	}
+
+	actual.Expires = time.Now().Add(l.ttl)
+	data, err := json.Marshal(actual)
+	if err != nil {
+		return fmt.Errorf("error serializing lock: %v", err)
+	}
+
+	p := stateStore.VFSPath().Join(PathStateLock)
+	err = p.WriteFile(data)
+	if err != nil {
+		return fmt.Errorf("error writing lock %s: %v", p, err)
+	}
+	glog.V(4).Infof("renewed state lock until %s", actual.Expires.Format(time.RFC3339))
+	return nil
+}
+
+// Release stops renewing the lock, and removes it if it is still ours
+func (l *StateLock) Release(stateStore StateStore) error {
+	l.mutex.Lock()
+	if l.stopRenewal != nil {
+		close(l.stopRenewal)
+		<-l.renewalDone
+		l.stopRenewal = nil
+		l.renewalDone = nil
+	}
+	l.mutex.Unlock()
+
+	actual, err := ReadStateLock(stateStore)
+	if err != nil {
+		return err
+	}
+	if actual == nil {
+		// Already removed (e.g. the cluster state was deleted)
+		return nil
+	}
+	if actual.ID != l.ID {
+		return fmt.Errorf("not releasing lock on cluster state, as it is now held by %s", actual)
+	}
+
+	p := stateStore.VFSPath().Join(PathStateLock)
+	err = p.Remove()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing lock %s: %v", p, err)
+	}
+	return nil
+}
+
+// ForceUnlock removes any lock on the state store, regardless of who holds it
+func ForceUnlock(stateStore StateStore) error {
+	existing, err := ReadStateLock(stateStore)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+
+	glog.Warningf("forcibly removing lock held by %s", existing)
+	p := stateStore.VFSPath().Join(PathStateLock)
+	err = p.Remove()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing lock %s: %v", p, err)
+	}
+	return nil
+}
+
+func randomLockID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", fmt.Errorf("error generating lock id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// lockOwner returns a description of the current user, for display to other users
+func lockOwner() string {
+	username := "unknown"
+	if u, err := user.Current(); err == nil {
+		username = u.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return username + "@" + hostname
+}
@@ -0,0 +1,224 @@
+package fi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestStateStore(t *testing.T) (StateStore, func()) {
+	dir, err := ioutil.TempDir("", "statelock")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	stateStore, err := NewVFSStateStore(vfs.NewFSPath(dir), "cluster", false, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("error building state store: %v", err)
+	}
+	// The local filesystem has an atomic CreateFile, so there is no need to wait for other writers
+	stateLockSettleTime = 0
+	return stateStore, func() { os.RemoveAll(dir) }
+}
+
+func writeTestStateLock(t *testing.T, stateStore StateStore, lock *StateLock) {
+	data, err := json.Marshal(lock)
+	if err != nil {
+		t.Fatalf("error serializing lock: %v", err)
+	}
+	if err := stateStore.VFSPath().Join(PathStateLock).WriteFile(data); err != nil {
+		t.Fatalf("error writing lock: %v", err)
+	}
+}
+
+func TestAcquireAndReleaseStateLock(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	lock, err := AcquireStateLock(stateStore, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring lock: %v", err)
+	}
+
+	actual, err := ReadStateLock(stateStore)
+	if err != nil {
+		t.Fatalf("unexpected error reading lock: %v", err)
+	}
+	if actual == nil || actual.ID != lock.ID || actual.Operation != "test" {
+		t.Fatalf("unexpected lock in state store: %v", actual)
+	}
+
+	if err := lock.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock: %v", err)
+	}
+	actual, err = ReadStateLock(stateStore)
+	if err != nil {
+		t.Fatalf("unexpected error reading lock: %v", err)
+	}
+	if actual != nil {
+		t.Fatalf("expected lock to be removed, found %v", actual)
+	}
+
+	// Releasing twice is harmless
+	if err := lock.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock again: %v", err)
+	}
+}
+
+func TestStateLockContention(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	lock, err := AcquireStateLock(stateStore, "first", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring lock: %v", err)
+	}
+
+	_, err = AcquireStateLock(stateStore, "second", time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "is locked by") || !strings.Contains(err.Error(), "first") {
+		t.Fatalf("expected lock contention error, got %v", err)
+	}
+
+	if err := lock.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock: %v", err)
+	}
+	second, err := AcquireStateLock(stateStore, "second", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring released lock: %v", err)
+	}
+	if err := second.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock: %v", err)
+	}
+}
+
+func TestStateLockBreaksExpiredLock(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	expired := &StateLock{
+		ID:        "expired",
+		Owner:     "someone@elsewhere",
+		Operation: "crashed",
+		Created:   time.Now().Add(-2 * time.Hour),
+		Expires:   time.Now().Add(-time.Hour),
+	}
+	writeTestStateLock(t, stateStore, expired)
+
+	lock, err := AcquireStateLock(stateStore, "test", time.Minute)
+	if err != nil {
+		t.Fatalf("expected expired lock to be broken, got %v", err)
+	}
+
+	// The previous holder must not be able to release our lock
+	err = expired.Release(stateStore)
+	if err == nil || !strings.Contains(err.Error(), "now held by") {
+		t.Fatalf("expected release of a stale lock to fail, got %v", err)
+	}
+	actual, err := ReadStateLock(stateStore)
+	if err != nil {
+		t.Fatalf("unexpected error reading lock: %v", err)
+	}
+	if actual == nil || actual.ID != lock.ID {
+		t.Fatalf("expected our lock to be held, found %v", actual)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	// ForceUnlock of an unlocked store is a no-op
+	if err := ForceUnlock(stateStore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := AcquireStateLock(stateStore, "first", time.Minute); err != nil {
+		t.Fatalf("unexpected error acquiring lock: %v", err)
+	}
+	if err := ForceUnlock(stateStore); err != nil {
+		t.Fatalf("unexpected error forcing unlock: %v", err)
+	}
+
+	lock, err := AcquireStateLock(stateStore, "second", time.Minute)
+	if err != nil {
+		t.Fatalf("expected lock to be available after ForceUnlock, got %v", err)
+	}
+	if err := lock.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock: %v", err)
+	}
+}
+
+func TestStateLockLostRace(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	// Simulate a client that also found no lock, and whose write lands just after ours
+	stateLockSettleTime = 500 * time.Millisecond
+	defer func() { stateLockSettleTime = 0 }()
+	other, err := json.Marshal(&StateLock{
+		ID:        "other",
+		Owner:     "someone@elsewhere",
+		Operation: "racing",
+		Created:   time.Now(),
+		Expires:   time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("error serializing lock: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := stateStore.VFSPath().Join(PathStateLock).WriteFile(other); err != nil {
+			t.Errorf("error writing lock: %v", err)
+		}
+	}()
+
+	_, err = AcquireStateLock(stateStore, "test", time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "lost race") {
+		t.Fatalf("expected to lose the race for the lock, got %v", err)
+	}
+}
+
+func TestStateLockRenewal(t *testing.T) {
+	stateStore, cleanup := newTestStateStore(t)
+	defer cleanup()
+
+	ttl := 300 * time.Millisecond
+	lock, err := AcquireStateLock(stateStore, "test", ttl)
+	if err != nil {
+		t.Fatalf("unexpected error acquiring lock: %v", err)
+	}
+
+	// Hold the lock for several TTLs; it must not expire while held
+	time.Sleep(3 * ttl)
+	actual, err := ReadStateLock(stateStore)
+	if err != nil {
+		t.Fatalf("unexpected error reading lock: %v", err)
+	}
+	if actual == nil || actual.ID != lock.ID {
+		t.Fatalf("expected our lock to be held, found %v", actual)
+	}
+	if actual.IsExpired() || !actual.Expires.After(lock.Expires) {
+		t.Fatalf("expected lock to have been renewed, found %v", actual)
+	}
+	if _, err := AcquireStateLock(stateStore, "second", ttl); err == nil {
+		t.Fatalf("expected renewed lock to block other operations")
+	}
+
+	if err := lock.Release(stateStore); err != nil {
+		t.Fatalf("unexpected error releasing lock: %v", err)
+	}
+
+	// Once released, the lock is no longer renewed
+	time.Sleep(ttl)
+	actual, err = ReadStateLock(stateStore)
+	if err != nil {
+		t.Fatalf("unexpected error reading lock: %v", err)
+	}
+	if actual != nil {
+		t.Fatalf("expected lock to be removed, found %v", actual)
+	}
+}
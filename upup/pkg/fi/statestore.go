@@ -31,17 +31,19 @@ type VFSStateStore struct {
 
 var _ StateStore = &VFSStateStore{}
 
-func NewVFSStateStore(base vfs.Path, clusterName string, dryrun bool) (*VFSStateStore, error) {
+// NewVFSStateStore builds a StateStore for the named cluster.
+// If keyProvider is non-nil, secrets and private keys are encrypted with it.
+func NewVFSStateStore(base vfs.Path, clusterName string, dryrun bool, keyProvider KeyProvider) (*VFSStateStore, error) {
 	location := base.Join(clusterName)
 	s := &VFSStateStore{
 		location: location,
 	}
 	var err error
-	s.ca, err = NewVFSCAStore(location.Join("pki"), dryrun)
+	s.ca, err = NewVFSCAStore(location.Join("pki"), dryrun, keyProvider)
 	if err != nil {
 		return nil, fmt.Errorf("error building CA store: %v", err)
 	}
-	s.secrets, err = NewVFSSecretStore(location.Join("secrets"))
+	s.secrets, err = NewVFSSecretStore(location.Join("secrets"), keyProvider)
 	if err != nil {
 		return nil, fmt.Errorf("error building secret store: %v", err)
 	}
@@ -0,0 +1,189 @@
+package fi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ExecutionTrace records the timeline of a run of the executor, so that we can find slow or flapping tasks
+type ExecutionTrace struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Error is the error returned from the run, if it failed
+	Error string `json:"error,omitempty"`
+
+	Tasks []*TaskTrace `json:"tasks"`
+}
+
+// TaskTrace is the timeline of a single task
+type TaskTrace struct {
+	Key          string   `json:"key"`
+	Type         string   `json:"type"`
+	Dependencies []string `json:"dependencies,omitempty"`
+
+	// Done is true if the task completed successfully
+	Done bool `json:"done"`
+	// Ready is the time at which all dependencies were done
+	Ready time.Time `json:"ready"`
+	// BlockedBy is the dependency that we waited on longest (the last to finish), or the dependency that never finished
+	BlockedBy string `json:"blockedBy,omitempty"`
+
+	Attempts []*TaskAttempt `json:"attempts,omitempty"`
+}
+
+// TaskAttempt is a single attempt at running a task
+type TaskAttempt struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Error string    `json:"error,omitempty"`
+}
+
+// StartTime is the start of the first attempt, or the zero time if the task never ran
+func (t *TaskTrace) StartTime() time.Time {
+	if len(t.Attempts) == 0 {
+		return time.Time{}
+	}
+	return t.Attempts[0].Start
+}
+
+// EndTime is the end of the last attempt, or the zero time if the task never ran
+func (t *TaskTrace) EndTime() time.Time {
+	if len(t.Attempts) == 0 {
+		return time.Time{}
+	}
+	return t.Attempts[len(t.Attempts)-1].End
+}
+
+// Duration is the time from the start of the first attempt to the end of the last attempt (including backoff)
+func (t *TaskTrace) Duration() time.Duration {
+	if len(t.Attempts) == 0 {
+		return 0
+	}
+	return t.EndTime().Sub(t.StartTime())
+}
+
+// FailedAttempts returns the number of attempts that returned an error
+func (t *TaskTrace) FailedAttempts() int {
+	n := 0
+	for _, a := range t.Attempts {
+		if a.Error != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// Task returns the trace for the task with the specified key, or nil if not found
+func (t *ExecutionTrace) Task(key string) *TaskTrace {
+	for _, task := range t.Tasks {
+		if task.Key == key {
+			return task
+		}
+	}
+	return nil
+}
+
+// computeBlockedBy fills in Ready & BlockedBy, once the run has finished
+func (t *ExecutionTrace) computeBlockedBy() {
+	byKey := make(map[string]*TaskTrace)
+	for _, task := range t.Tasks {
+		byKey[task.Key] = task
+	}
+
+	for _, task := range t.Tasks {
+		task.Ready = t.Start
+		task.BlockedBy = ""
+		for _, depKey := range task.Dependencies {
+			dep := byKey[depKey]
+			if dep == nil {
+				continue
+			}
+			if !dep.Done {
+				// Blocked by a dependency that never completed
+				task.Ready = time.Time{}
+				task.BlockedBy = dep.Key
+				break
+			}
+			if dep.EndTime().After(task.Ready) {
+				task.Ready = dep.EndTime()
+				task.BlockedBy = dep.Key
+			}
+		}
+	}
+}
+
+// CriticalPath returns the chain of tasks that determined how long the run took (or the chain that led to the failure).
+// It starts from the task that finished last (or the task that failed) and follows BlockedBy back to the start.
+func (t *ExecutionTrace) CriticalPath() []*TaskTrace {
+	var last *TaskTrace
+	for _, task := range t.Tasks {
+		if len(task.Attempts) == 0 {
+			continue
+		}
+		if last == nil {
+			last = task
+			continue
+		}
+		// Prefer failed tasks: they are what stopped the run
+		if !task.Done && last.Done {
+			last = task
+			continue
+		}
+		if task.Done == last.Done && task.EndTime().After(last.EndTime()) {
+			last = task
+		}
+	}
+
+	var path []*TaskTrace
+	seen := make(map[string]bool)
+	for task := last; task != nil && !seen[task.Key]; task = t.Task(task.BlockedBy) {
+		seen[task.Key] = true
+		path = append([]*TaskTrace{task}, path...)
+	}
+	return path
+}
+
+// ToJSON serializes the trace as (indented) JSON
+func (t *ExecutionTrace) ToJSON() ([]byte, error) {
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error serializing execution trace: %v", err)
+	}
+	return data, nil
+}
+
+// ParseExecutionTrace parses a trace previously written with ToJSON
+func ParseExecutionTrace(data []byte) (*ExecutionTrace, error) {
+	trace := &ExecutionTrace{}
+	err := json.Unmarshal(data, trace)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing execution trace: %v", err)
+	}
+	return trace, nil
+}
+
+// newExecutionTrace builds an empty trace for the tasks, sorted by key
+func newExecutionTrace(taskMap map[string]Task, dependencies map[string][]string) *ExecutionTrace {
+	trace := &ExecutionTrace{
+		Start: time.Now(),
+	}
+
+	var keys []string
+	for k := range taskMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		deps := append([]string(nil), dependencies[k]...)
+		sort.Strings(deps)
+		trace.Tasks = append(trace.Tasks, &TaskTrace{
+			Key:          k,
+			Type:         taskTypeName(taskMap[k]),
+			Dependencies: deps,
+		})
+	}
+	return trace
+}
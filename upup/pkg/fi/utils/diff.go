@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines we show around each change
+const diffContext = 3
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+}
+
+// FormatDiff returns a line-by-line diff between a and b, in the style of diff -u (without the headers).
+// It returns an empty string if there are no differences.
+func FormatDiff(a, b string) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	// Mark the lines we want to show: changes, plus context
+	show := make([]bool, len(ops))
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		for j := i - diffContext; j <= i+diffContext; j++ {
+			if j >= 0 && j < len(ops) {
+				show[j] = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	aLine, bLine := 1, 1
+	for i := 0; i < len(ops); {
+		if !show[i] {
+			if ops[i].kind != '+' {
+				aLine++
+			}
+			if ops[i].kind != '-' {
+				bLine++
+			}
+			i++
+			continue
+		}
+
+		// Emit a hunk
+		end := i
+		aCount, bCount := 0, 0
+		for end < len(ops) && show[end] {
+			if ops[end].kind != '+' {
+				aCount++
+			}
+			if ops[end].kind != '-' {
+				bCount++
+			}
+			end++
+		}
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@\n", hunkStart(aLine, aCount), aCount, hunkStart(bLine, bCount), bCount)
+		for ; i < end; i++ {
+			fmt.Fprintf(&buf, "%c%s\n", ops[i].kind, ops[i].line)
+		}
+		aLine += aCount
+		bLine += bCount
+	}
+	return buf.String()
+}
+
+// hunkStart returns the start line for a hunk header; like diff -u, an empty range refers to the line before it
+func hunkStart(line, count int) int {
+	if count == 0 {
+		return line - 1
+	}
+	return line
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes the diff using the longest common subsequence.
+// This is quadratic, but our inputs (configuration files) are small.
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if a[i] == b[j] {
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		} else {
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
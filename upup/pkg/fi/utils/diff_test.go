@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestFormatDiff(t *testing.T) {
+	grid := []struct {
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			a:        "a\nb\nc\n",
+			b:        "a\nb\nc\n",
+			expected: "",
+		},
+		{
+			a:        "a\nb\nc\n",
+			b:        "a\nB\nc\n",
+			expected: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			a:        "",
+			b:        "a\n",
+			expected: "@@ -0,0 +1,1 @@\n+a\n",
+		},
+		{
+			a:        "a\n",
+			b:        "",
+			expected: "@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			a:        "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:        "1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			expected: "@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+	}
+	for _, g := range grid {
+		actual := FormatDiff(g.a, g.b)
+		if actual != g.expected {
+			t.Errorf("unexpected diff of %q and %q: %q (expected %q)", g.a, g.b, actual, g.expected)
+		}
+	}
+}
@@ -6,15 +6,22 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/golang/glog"
+	"golang.org/x/net/context"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/storage/v1"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 )
 
 // VFSContext is a 'context' for VFS, that is normally a singleton
 // but allows us to configure S3 credentials, for example
 type VFSContext struct {
+	mutex sync.Mutex
+	// gcsClient is the (lazily-built) client for Google Cloud Storage
+	gcsClient *storage.Service
 }
 
 var Context VFSContext
@@ -69,6 +76,10 @@ func (c *VFSContext) BuildVfsPath(p string) (Path, error) {
 		return c.buildS3Path(p)
 	}
 
+	if strings.HasPrefix(p, "gs://") {
+		return c.buildGCSPath(p)
+	}
+
 	return nil, fmt.Errorf("unknown / unhandled path type: %q", p)
 }
 
@@ -139,3 +150,49 @@ func (c *VFSContext) buildS3Path(p string) (*S3Path, error) {
 	s3path := NewS3Path(s3Client, bucket, u.Path)
 	return s3path, nil
 }
+
+func (c *VFSContext) buildGCSPath(p string) (*GSPath, error) {
+	u, err := url.Parse(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid google cloud storage path: %q", err)
+	}
+
+	if u.Scheme != "gs" {
+		return nil, fmt.Errorf("invalid google cloud storage path: %q", p)
+	}
+
+	bucket := strings.TrimSuffix(u.Host, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("bucket name is required in google cloud storage path: %q", p)
+	}
+
+	client, err := c.getGCSClient()
+	if err != nil {
+		return nil, err
+	}
+
+	gcsPath := NewGSPath(client, bucket, u.Path)
+	return gcsPath, nil
+}
+
+func (c *VFSContext) getGCSClient() (*storage.Service, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.gcsClient != nil {
+		return c.gcsClient, nil
+	}
+
+	// Uses the application default credentials (or the instance service account, when running on GCE)
+	httpClient, err := google.DefaultClient(context.Background(), storage.DevstorageReadWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("error building google API client: %v", err)
+	}
+	client, err := storage.New(httpClient)
+	if err != nil {
+		return nil, fmt.Errorf("error building google cloud storage client: %v", err)
+	}
+
+	c.gcsClient = client
+	return client, nil
+}
@@ -8,7 +8,6 @@ import (
 	"k8s.io/kops/upup/pkg/fi/hashing"
 	"os"
 	"path"
-	"sync"
 )
 
 type FSPath struct {
@@ -72,27 +71,46 @@ func (p *FSPath) WriteFile(data []byte) error {
 	return err
 }
 
-// To prevent concurrent creates on the same file while maintaining atomicity of writes,
-// we take a process-wide lock during the operation.
-// Not a great approach, but fine for a single process (with low concurrency)
-// TODO: should we take a file lock or equivalent here?  Can we use RENAME_NOREPLACE ?
-var createFileLock sync.Mutex
-
+// CreateFile writes the file atomically, and only if it does not exist.
+// We write to a temp file, and then hard-link it into place; link fails if the target exists,
+// so this is safe even against other processes (which matters for locking).
 func (p *FSPath) CreateFile(data []byte) error {
-	createFileLock.Lock()
-	defer createFileLock.Unlock()
+	dir := path.Dir(p.location)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return fmt.Errorf("error creating directories %q: %v", dir, err)
+	}
 
-	// Check if exists
-	_, err := os.Stat(p.location)
-	if err == nil {
-		return os.ErrExist
+	f, err := ioutil.TempFile(dir, "tmp")
+	if err != nil {
+		return fmt.Errorf("error creating temp file in %q: %v", dir, err)
 	}
+	tempfile := f.Name()
+	defer func() {
+		if removeErr := os.Remove(tempfile); removeErr != nil {
+			glog.Warningf("unable to remove temp file %q: %v", tempfile, removeErr)
+		}
+	}()
 
-	if !os.IsNotExist(err) {
-		return err
+	n, err := f.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing temp file %q: %v", tempfile, err)
 	}
 
-	return p.WriteFile(data)
+	err = os.Link(tempfile, p.location)
+	if err != nil {
+		if os.IsExist(err) {
+			return os.ErrExist
+		}
+		return fmt.Errorf("error creating file %q: %v", p.location, err)
+	}
+	return nil
 }
 
 func (p *FSPath) ReadFile() ([]byte, error) {
@@ -0,0 +1,210 @@
+package vfs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang/glog"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/storage/v1"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/hashing"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// GSPath is a vfs path for Google Cloud Storage
+type GSPath struct {
+	client *storage.Service
+	bucket string
+	key    string
+	md5    string
+}
+
+var _ Path = &GSPath{}
+var _ HasHash = &GSPath{}
+
+func NewGSPath(client *storage.Service, bucket string, key string) *GSPath {
+	bucket = strings.TrimSuffix(bucket, "/")
+	key = strings.TrimPrefix(key, "/")
+
+	return &GSPath{
+		client: client,
+		bucket: bucket,
+		key:    key,
+	}
+}
+
+func (p *GSPath) Path() string {
+	return "gs://" + p.bucket + "/" + p.key
+}
+
+func (p *GSPath) Bucket() string {
+	return p.bucket
+}
+
+func (p *GSPath) String() string {
+	return p.Path()
+}
+
+func (p *GSPath) Remove() error {
+	err := p.client.Objects.Delete(p.bucket, p.key).Do()
+	if err != nil {
+		if isGCSNotFound(err) {
+			return os.ErrNotExist
+		}
+		return fmt.Errorf("error deleting %s: %v", p, err)
+	}
+
+	return nil
+}
+
+func (p *GSPath) Join(relativePath ...string) Path {
+	args := []string{p.key}
+	args = append(args, relativePath...)
+	joined := path.Join(args...)
+	return &GSPath{
+		client: p.client,
+		bucket: p.bucket,
+		key:    joined,
+	}
+}
+
+func (p *GSPath) WriteFile(data []byte) error {
+	glog.V(4).Infof("Writing file %q", p)
+
+	obj := &storage.Object{Name: p.key}
+	_, err := p.client.Objects.Insert(p.bucket, obj).Media(bytes.NewReader(data)).Do()
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	return nil
+}
+
+// CreateFile writes the file, but only if it does not already exist.
+// Unlike S3, GCS supports preconditions, so this is atomic:
+// ifGenerationMatch=0 means the write only succeeds if there is no live version of the object.
+func (p *GSPath) CreateFile(data []byte) error {
+	glog.V(4).Infof("Creating file %q", p)
+
+	obj := &storage.Object{Name: p.key}
+	_, err := p.client.Objects.Insert(p.bucket, obj).IfGenerationMatch(0).Media(bytes.NewReader(data)).Do()
+	if err != nil {
+		if gcsErrorCode(err) == http.StatusPreconditionFailed {
+			return os.ErrExist
+		}
+		return fmt.Errorf("error writing %s: %v", p, err)
+	}
+
+	return nil
+}
+
+func (p *GSPath) ReadFile() ([]byte, error) {
+	glog.V(4).Infof("Reading file %q", p)
+
+	response, err := p.client.Objects.Get(p.bucket, p.key).Download()
+	if err != nil {
+		if isGCSNotFound(err) {
+			return nil, os.ErrNotExist
+		}
+		return nil, fmt.Errorf("error fetching %s: %v", p, err)
+	}
+	defer response.Body.Close()
+
+	d, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", p, err)
+	}
+	return d, nil
+}
+
+func (p *GSPath) ReadDir() ([]Path, error) {
+	prefix := p.key
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	paths, err := p.listObjects(prefix, "/")
+	if err != nil {
+		return nil, err
+	}
+	glog.V(8).Infof("Listed files in %v: %v", p, paths)
+	return paths, nil
+}
+
+func (p *GSPath) ReadTree() ([]Path, error) {
+	// No delimiter for recursive search
+	return p.listObjects(p.key, "")
+}
+
+func (p *GSPath) listObjects(prefix string, delimiter string) ([]Path, error) {
+	var paths []Path
+	pageToken := ""
+	for {
+		call := p.client.Objects.List(p.bucket).Prefix(prefix)
+		if delimiter != "" {
+			call = call.Delimiter(delimiter)
+		}
+		if pageToken != "" {
+			call = call.PageToken(pageToken)
+		}
+		response, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s: %v", p, err)
+		}
+		for _, o := range response.Items {
+			child := &GSPath{
+				client: p.client,
+				bucket: p.bucket,
+				key:    o.Name,
+				md5:    o.Md5Hash,
+			}
+			paths = append(paths, child)
+		}
+		pageToken = response.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return paths, nil
+}
+
+func (p *GSPath) Base() string {
+	return path.Base(p.key)
+}
+
+func (p *GSPath) PreferredHash() (*hashing.Hash, error) {
+	return p.Hash(hashing.HashAlgorithmMD5)
+}
+
+func (p *GSPath) Hash(a hashing.HashAlgorithm) (*hashing.Hash, error) {
+	if a != hashing.HashAlgorithmMD5 {
+		return nil, nil
+	}
+
+	if p.md5 == "" {
+		return nil, nil
+	}
+
+	md5Bytes, err := base64.StdEncoding.DecodeString(p.md5)
+	if err != nil {
+		return nil, fmt.Errorf("md5Hash was not a valid MD5 sum: %q", p.md5)
+	}
+
+	return &hashing.Hash{Algorithm: hashing.HashAlgorithmMD5, HashValue: md5Bytes}, nil
+}
+
+// gcsErrorCode returns the http status code, if it is a googleapi.Error, otherwise 0
+func gcsErrorCode(err error) int {
+	if apiErr, ok := err.(*googleapi.Error); ok {
+		return apiErr.Code
+	}
+	return 0
+}
+
+func isGCSNotFound(err error) bool {
+	return gcsErrorCode(err) == http.StatusNotFound
+}
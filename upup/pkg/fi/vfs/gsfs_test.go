@@ -0,0 +1,214 @@
+package vfs
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"google.golang.org/api/storage/v1"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCS is a minimal in-memory implementation of the GCS JSON API, sufficient for GSPath
+type fakeGCS struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) writeError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{"code": code, "message": message},
+	})
+}
+
+func (f *fakeGCS) objectResource(name string, data []byte) map[string]interface{} {
+	sum := md5.Sum(data)
+	return map[string]interface{}{
+		"kind":    "storage#object",
+		"name":    name,
+		"md5Hash": base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	// The object name can contain slashes, so everything after /o/ is the name
+	p := r.URL.Path
+	object := ""
+	if i := strings.Index(p, "/o/"); i != -1 {
+		object = p[i+3:]
+	}
+
+	switch {
+	case r.Method == "POST" && strings.HasSuffix(p, "/o"):
+		name, data, err := readUpload(r)
+		if err != nil {
+			f.writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if r.URL.Query().Get("ifGenerationMatch") == "0" {
+			if _, found := f.objects[name]; found {
+				f.writeError(w, http.StatusPreconditionFailed, "precondition failed")
+				return
+			}
+		}
+		f.objects[name] = data
+		json.NewEncoder(w).Encode(f.objectResource(name, data))
+
+	case r.Method == "GET" && strings.HasSuffix(p, "/o"):
+		prefix := r.URL.Query().Get("prefix")
+		delimiter := r.URL.Query().Get("delimiter")
+		var names []string
+		for name := range f.objects {
+			if !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if delimiter != "" && strings.Contains(name[len(prefix):], delimiter) {
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var items []interface{}
+		for _, name := range names {
+			items = append(items, f.objectResource(name, f.objects[name]))
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+
+	case r.Method == "GET" && object != "":
+		data, found := f.objects[object]
+		if !found {
+			f.writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		if r.URL.Query().Get("alt") == "media" {
+			w.Write(data)
+		} else {
+			json.NewEncoder(w).Encode(f.objectResource(object, data))
+		}
+
+	case r.Method == "DELETE" && object != "":
+		if _, found := f.objects[object]; !found {
+			f.writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		delete(f.objects, object)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		f.writeError(w, http.StatusBadRequest, "unhandled request "+r.Method+" "+p)
+	}
+}
+
+// readUpload parses a simple (uploadType=media) or multipart upload
+func readUpload(r *http.Request) (string, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		data, err := ioutil.ReadAll(r.Body)
+		return r.URL.Query().Get("name"), data, err
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	metadataPart, err := reader.NextPart()
+	if err != nil {
+		return "", nil, err
+	}
+	metadata := &storage.Object{}
+	if err := json.NewDecoder(metadataPart).Decode(metadata); err != nil {
+		return "", nil, err
+	}
+	mediaPart, err := reader.NextPart()
+	if err != nil && err != io.EOF {
+		return "", nil, err
+	}
+	var data []byte
+	if mediaPart != nil {
+		data, err = ioutil.ReadAll(mediaPart)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return metadata.Name, data, nil
+}
+
+func newFakeGCSPath(t *testing.T, key string) (*GSPath, func()) {
+	server := httptest.NewServer(&fakeGCS{objects: make(map[string][]byte)})
+	client, err := storage.New(http.DefaultClient)
+	if err != nil {
+		t.Fatalf("error building storage client: %v", err)
+	}
+	client.BasePath = server.URL + "/"
+	return NewGSPath(client, "bucket", key), server.Close
+}
+
+func TestGSPath(t *testing.T) {
+	base, closer := newFakeGCSPath(t, "cluster")
+	defer closer()
+
+	if base.Path() != "gs://bucket/cluster" {
+		t.Fatalf("unexpected path %q", base.Path())
+	}
+
+	config := base.Join("config")
+	if _, err := config.ReadFile(); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist reading missing file, got %v", err)
+	}
+
+	if err := config.CreateFile([]byte("first")); err != nil {
+		t.Fatalf("error creating file: %v", err)
+	}
+	if err := config.CreateFile([]byte("second")); !os.IsExist(err) {
+		t.Fatalf("expected exists error creating file twice, got %v", err)
+	}
+	if err := base.Join("pki", "issued", "ca").WriteFile([]byte("cert")); err != nil {
+		t.Fatalf("error writing file: %v", err)
+	}
+
+	data, err := config.ReadFile()
+	if err != nil {
+		t.Fatalf("error reading file: %v", err)
+	}
+	if string(data) != "first" {
+		t.Fatalf("unexpected contents %q", data)
+	}
+
+	children, err := base.ReadDir()
+	if err != nil {
+		t.Fatalf("error listing directory: %v", err)
+	}
+	if len(children) != 1 || children[0].Base() != "config" {
+		t.Fatalf("unexpected children: %v", children)
+	}
+
+	tree, err := base.ReadTree()
+	if err != nil {
+		t.Fatalf("error listing tree: %v", err)
+	}
+	if len(tree) != 2 {
+		t.Fatalf("unexpected tree: %v", tree)
+	}
+	hash, err := tree[0].(HasHash).PreferredHash()
+	if err != nil || hash == nil {
+		t.Fatalf("expected hash from listing, got %v (err=%v)", hash, err)
+	}
+
+	if err := config.Remove(); err != nil {
+		t.Fatalf("error removing file: %v", err)
+	}
+	if err := config.Remove(); !os.IsNotExist(err) {
+		t.Fatalf("expected not-exist removing missing file, got %v", err)
+	}
+}
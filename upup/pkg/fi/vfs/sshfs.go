@@ -207,6 +207,9 @@ func (p *SSHPath) ReadFile() ([]byte, error) {
 
 	f, err := sftpClient.Open(p.path)
 	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
 		return nil, fmt.Errorf("error opening file %s over sftp: %v", p, err)
 	}
 	defer f.Close()
@@ -65,6 +65,9 @@ func IsClusterReadable(p Path) bool {
 	case *S3Path:
 		return true
 
+	case *GSPath:
+		return true
+
 	case *SSHPath:
 		return false
 
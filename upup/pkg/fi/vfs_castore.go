@@ -11,6 +11,7 @@ import (
 	"k8s.io/kops/upup/pkg/fi/vfs"
 	"math/big"
 	"os"
+	"sort"
 	"strings"
 	"time"
 )
@@ -20,14 +21,17 @@ type VFSCAStore struct {
 	basedir        vfs.Path
 	caCertificates *certificates
 	caPrivateKeys  *privateKeys
+	// keyProvider encrypts private keys, if set
+	keyProvider KeyProvider
 }
 
 var _ CAStore = &VFSCAStore{}
 
-func NewVFSCAStore(basedir vfs.Path, dryrun bool) (CAStore, error) {
+func NewVFSCAStore(basedir vfs.Path, dryrun bool, keyProvider KeyProvider) (CAStore, error) {
 	c := &VFSCAStore{
-		dryrun:  dryrun,
-		basedir: basedir,
+		dryrun:      dryrun,
+		basedir:     basedir,
+		keyProvider: keyProvider,
 	}
 	//err := os.MkdirAll(path.Join(basedir, "private"), 0700)
 	//if err != nil {
@@ -416,6 +420,10 @@ func (c *VFSCAStore) loadOnePrivateKey(p vfs.Path) (*PrivateKey, error) {
 		}
 		return nil, err
 	}
+	data, err = DecryptData(c.keyProvider, data)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting private key from %q: %v", p, err)
+	}
 	k, err := ParsePEMPrivateKey(data)
 	if err != nil {
 		return nil, fmt.Errorf("error parsing private key from %q: %v", p, err)
@@ -509,7 +517,11 @@ func (c *VFSCAStore) storePrivateKey(privateKey *PrivateKey, p vfs.Path) error {
 		return err
 	}
 
-	return p.WriteFile(data.Bytes())
+	encrypted, err := EncryptData(c.keyProvider, data.Bytes())
+	if err != nil {
+		return fmt.Errorf("error encrypting private key: %v", err)
+	}
+	return p.WriteFile(encrypted)
 }
 
 func (c *VFSCAStore) storeCertificate(cert *Certificate, p vfs.Path) error {
@@ -541,3 +553,105 @@ func buildSerial(timestamp int64) *big.Int {
 
 	return serial
 }
+
+func (c *VFSCAStore) RotateCA() (*Certificate, error) {
+	glog.Infof("Creating new CA certificate")
+
+	// generateCACertificate stores the new CA with a newer serial, so it becomes the primary
+	err := c.generateCACertificate()
+	if err != nil {
+		return nil, err
+	}
+	return c.caCertificates.Primary(), nil
+}
+
+func (c *VFSCAStore) RotateKeypair(id string) (*Certificate, error) {
+	if id == CertificateId_CA {
+		return nil, fmt.Errorf("the CA must be rotated with RotateCA")
+	}
+
+	cert, err := c.FindCert(id)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil || cert.Certificate == nil {
+		return nil, fmt.Errorf("cannot find certificate %q", id)
+	}
+	if cert.IsCA {
+		return nil, fmt.Errorf("certificate %q is a CA certificate; cannot rotate", id)
+	}
+
+	// We keep the same subject, names & usages; only the key, serial and signer change
+	existing := cert.Certificate
+	template := &x509.Certificate{
+		Subject:               existing.Subject,
+		DNSNames:              existing.DNSNames,
+		EmailAddresses:        existing.EmailAddresses,
+		IPAddresses:           existing.IPAddresses,
+		KeyUsage:              existing.KeyUsage,
+		ExtKeyUsage:           existing.ExtKeyUsage,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+
+	newCert, _, err := c.CreateKeypair(id, template)
+	if err != nil {
+		return nil, err
+	}
+	return newCert, nil
+}
+
+func (c *VFSCAStore) RetireKeypair(id string) ([]string, error) {
+	certs, err := c.loadCertificates(c.buildCertificatePoolPath(id))
+	if err != nil {
+		return nil, err
+	}
+	if certs == nil || certs.primary == "" {
+		return nil, fmt.Errorf("cannot find certificate %q", id)
+	}
+
+	var retired []string
+	for serial := range certs.certificates {
+		if serial == certs.primary {
+			continue
+		}
+		p := c.buildCertificatePoolPath(id).Join(serial + ".crt")
+		glog.Infof("Retiring certificate %s", p)
+		if err := p.Remove(); err != nil && !os.IsNotExist(err) {
+			return retired, fmt.Errorf("error removing certificate %s: %v", p, err)
+		}
+		retired = append(retired, serial)
+	}
+
+	keys, err := c.loadPrivateKeys(c.buildPrivateKeyPoolPath(id))
+	if err != nil {
+		return retired, err
+	}
+	if keys != nil {
+		for serial := range keys.keys {
+			if serial == certs.primary {
+				continue
+			}
+			p := c.buildPrivateKeyPoolPath(id).Join(serial + ".key")
+			glog.Infof("Retiring private key %s", p)
+			if err := p.Remove(); err != nil && !os.IsNotExist(err) {
+				return retired, fmt.Errorf("error removing private key %s: %v", p, err)
+			}
+		}
+	}
+
+	if id == CertificateId_CA {
+		// Reload our cached copy of the CA
+		c.caCertificates, err = c.loadCertificates(c.buildCertificatePoolPath(CertificateId_CA))
+		if err != nil {
+			return retired, err
+		}
+		c.caPrivateKeys, err = c.loadPrivateKeys(c.buildPrivateKeyPoolPath(CertificateId_CA))
+		if err != nil {
+			return retired, err
+		}
+	}
+
+	sort.Strings(retired)
+	return retired, nil
+}
@@ -0,0 +1,81 @@
+package fi
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"testing"
+)
+
+func TestRotateCA(t *testing.T) {
+	dir, err := ioutil.TempDir("", "castore")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	caStore, err := NewVFSCAStore(vfs.NewFSPath(dir), false, nil)
+	if err != nil {
+		t.Fatalf("error building CA store: %v", err)
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kubelet"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+	}
+	oldCert, _, err := caStore.CreateKeypair("kubelet", template)
+	if err != nil {
+		t.Fatalf("error creating keypair: %v", err)
+	}
+
+	newCA, err := caStore.RotateCA()
+	if err != nil {
+		t.Fatalf("error rotating CA: %v", err)
+	}
+	pool, err := caStore.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error getting CA pool: %v", err)
+	}
+	if pool.Primary.Certificate.SerialNumber.Cmp(newCA.Certificate.SerialNumber) != 0 || len(pool.Secondary) != 1 {
+		t.Fatalf("expected new CA to be primary, with old CA as secondary")
+	}
+
+	newCert, err := caStore.RotateKeypair("kubelet")
+	if err != nil {
+		t.Fatalf("error rotating keypair: %v", err)
+	}
+	if err := newCert.Certificate.CheckSignatureFrom(newCA.Certificate); err != nil {
+		t.Fatalf("rotated keypair was not signed by new CA: %v", err)
+	}
+	if newCert.Certificate.Subject.CommonName != "kubelet" || newCert.Certificate.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Fatalf("rotated keypair did not keep subject & usage")
+	}
+
+	retired, err := caStore.RetireKeypair("kubelet")
+	if err != nil {
+		t.Fatalf("error retiring keypair: %v", err)
+	}
+	if len(retired) != 1 || retired[0] != oldCert.Certificate.SerialNumber.Text(10) {
+		t.Fatalf("unexpected retired serials: %v", retired)
+	}
+
+	if _, err := caStore.RetireKeypair(CertificateId_CA); err != nil {
+		t.Fatalf("error retiring CA: %v", err)
+	}
+	pool, err = caStore.CertificatePool(CertificateId_CA)
+	if err != nil {
+		t.Fatalf("error getting CA pool: %v", err)
+	}
+	if len(pool.Secondary) != 0 {
+		t.Fatalf("old CA was not retired")
+	}
+
+	// The rotated keypair can still be read back
+	key, err := caStore.FindPrivateKey("kubelet")
+	if err != nil || key == nil {
+		t.Fatalf("error reading rotated private key: %v", err)
+	}
+}
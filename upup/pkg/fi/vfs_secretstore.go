@@ -10,13 +10,16 @@ import (
 
 type VFSSecretStore struct {
 	basedir vfs.Path
+	// keyProvider encrypts secrets, if set
+	keyProvider KeyProvider
 }
 
 var _ SecretStore = &VFSSecretStore{}
 
-func NewVFSSecretStore(basedir vfs.Path) (SecretStore, error) {
+func NewVFSSecretStore(basedir vfs.Path, keyProvider KeyProvider) (SecretStore, error) {
 	c := &VFSSecretStore{
-		basedir: basedir,
+		basedir:     basedir,
+		keyProvider: keyProvider,
 	}
 	//err := os.MkdirAll(path.Join(basedir), 0700)
 	//if err != nil {
@@ -115,6 +118,10 @@ func (c *VFSSecretStore) loadSecret(p vfs.Path) (*Secret, error) {
 			return nil, nil
 		}
 	}
+	data, err = DecryptData(c.keyProvider, data)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting secret from %q: %v", p, err)
+	}
 	s := &Secret{}
 	err = json.Unmarshal(data, s)
 	if err != nil {
@@ -129,5 +136,9 @@ func (c *VFSSecretStore) createSecret(s *Secret, p vfs.Path) error {
 	if err != nil {
 		return fmt.Errorf("error serializing secret: %v", err)
 	}
+	data, err = EncryptData(c.keyProvider, data)
+	if err != nil {
+		return fmt.Errorf("error encrypting secret: %v", err)
+	}
 	return p.CreateFile(data)
 }
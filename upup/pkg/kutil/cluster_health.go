@@ -0,0 +1,81 @@
+package kutil
+
+import (
+	"fmt"
+	"github.com/golang/glog"
+	k8sapi "k8s.io/kubernetes/pkg/api"
+	"strings"
+	"time"
+)
+
+// ValidateClusterHealth checks that the API server is reachable, that every etcd member
+// reports as healthy, and that every registered node is Ready
+func ValidateClusterHealth(kubectl *Kubectl) error {
+	statuses, err := kubectl.GetComponentStatuses()
+	if err != nil {
+		return fmt.Errorf("API server is not reachable: %v", err)
+	}
+
+	etcdCount := 0
+	for i := range statuses {
+		cs := &statuses[i]
+		if !strings.HasPrefix(cs.Name, "etcd-") {
+			continue
+		}
+		etcdCount++
+		if !IsComponentHealthy(cs) {
+			return fmt.Errorf("etcd member %q is not healthy", cs.Name)
+		}
+	}
+	if etcdCount == 0 {
+		return fmt.Errorf("API server did not report any etcd members")
+	}
+
+	nodes, err := kubectl.GetNodes()
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("no nodes are registered")
+	}
+	var notReady []string
+	for i := range nodes {
+		if !IsNodeReady(&nodes[i]) {
+			notReady = append(notReady, nodes[i].Name)
+		}
+	}
+	if len(notReady) != 0 {
+		return fmt.Errorf("nodes are not Ready: %s", strings.Join(notReady, ", "))
+	}
+
+	return nil
+}
+
+// WaitForClusterHealth polls ValidateClusterHealth until it succeeds, or the timeout expires
+func WaitForClusterHealth(kubectl *Kubectl, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		err := ValidateClusterHealth(kubectl)
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cluster did not pass validation within %v: %v", timeout, err)
+		}
+
+		glog.Infof("Cluster did not pass validation, will retry: %v", err)
+		time.Sleep(pollInterval)
+	}
+}
+
+// IsComponentHealthy checks if the component has a Healthy condition with status True
+func IsComponentHealthy(cs *k8sapi.ComponentStatus) bool {
+	for _, c := range cs.Conditions {
+		if c.Type == k8sapi.ComponentHealthy {
+			return c.Status == k8sapi.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package kutil
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"golang.org/x/crypto/ssh"
+	"io/ioutil"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsmock"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"k8s.io/kops/upup/pkg/fi/vfs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// writeSSHPublicKey writes a new SSH public key, in authorized_keys format, into dir
+func writeSSHPublicKey(t *testing.T, dir string) string {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("error generating key: %v", err)
+	}
+	publicKey, err := ssh.NewPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatalf("error building SSH public key: %v", err)
+	}
+	p := path.Join(dir, "id_rsa.pub")
+	if err := ioutil.WriteFile(p, ssh.MarshalAuthorizedKey(publicKey), 0644); err != nil {
+		t.Fatalf("error writing SSH public key: %v", err)
+	}
+	return p
+}
+
+// buildClusterConfig builds the configuration that create cluster would, for a single-zone cluster
+func buildClusterConfig(t *testing.T, clusterName string, nodeCount int) (*api.Cluster, []*api.InstanceGroup) {
+	cluster := &api.Cluster{}
+	cluster.Name = clusterName
+	cluster.Spec.CloudProvider = "aws"
+	cluster.Spec.KubernetesVersion = "1.3.7"
+	cluster.Spec.Zones = []*api.ClusterZoneSpec{{Name: "us-east-1a"}}
+	for _, name := range []string{"main", "events"} {
+		cluster.Spec.EtcdClusters = append(cluster.Spec.EtcdClusters, &api.EtcdClusterSpec{
+			Name:    name,
+			Members: []*api.EtcdMemberSpec{{Name: "us-east-1a", Zone: "us-east-1a"}},
+		})
+	}
+
+	master := &api.InstanceGroup{}
+	master.Name = "master-us-east-1a"
+	master.Spec.Role = api.InstanceGroupRoleMaster
+	master.Spec.Zones = []string{"us-east-1a"}
+	master.Spec.MinSize = fi.Int(1)
+	master.Spec.MaxSize = fi.Int(1)
+
+	nodes := &api.InstanceGroup{}
+	nodes.Name = "nodes"
+	nodes.Spec.Role = api.InstanceGroupRoleNode
+	nodes.Spec.MinSize = fi.Int(nodeCount)
+	nodes.Spec.MaxSize = fi.Int(nodeCount)
+
+	instanceGroups := []*api.InstanceGroup{master, nodes}
+	for _, g := range instanceGroups {
+		g.Spec.Image = "ami-12345678"
+	}
+
+	if err := cluster.PerformAssignments(); err != nil {
+		t.Fatalf("error populating configuration: %v", err)
+	}
+	if err := api.PerformAssignmentsInstanceGroups(instanceGroups); err != nil {
+		t.Fatalf("error populating configuration: %v", err)
+	}
+	return cluster, instanceGroups
+}
+
+// applyCluster runs the update, as update cluster would, returning the plan for a dryrun
+func applyCluster(t *testing.T, cloud fi.Cloud, stateStore fi.StateStore, target string, outDir string, sshPublicKey string, nodeCount int) *fi.Plan {
+	cluster, instanceGroups := buildClusterConfig(t, stateStore.VFSPath().Base(), nodeCount)
+	if err := api.WriteConfig(stateStore, cluster, instanceGroups); err != nil {
+		t.Fatalf("error writing configuration: %v", err)
+	}
+
+	cmd := &cloudup.CreateClusterCmd{
+		Cluster:        cluster,
+		InstanceGroups: instanceGroups,
+		ModelStore:     "../../models",
+		Models:         []string{"config", "proto", "cloudup"},
+		StateStore:     stateStore,
+		Target:         target,
+		NodeModel:      "nodeup",
+		SSHPublicKey:   sshPublicKey,
+		OutDir:         outDir,
+		Cloud:          cloud,
+		// The mock is consistent, so there is nothing to wait for: fail fast
+		RunTasksOptions: fi.RunTasksOptions{MaxTaskAttempts: 1},
+	}
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("error running %s update: %v", target, err)
+	}
+	return cmd.Plan
+}
+
+func TestCreateUpdateDeleteWithMockAWS(t *testing.T) {
+	clusterName := "test.example.com"
+
+	dir, err := ioutil.TempDir("", "kops")
+	if err != nil {
+		t.Fatalf("error creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	sshPublicKey := writeSSHPublicKey(t, dir)
+
+	mock := awsmock.NewMockAWS("us-east-1")
+	cloud := mock.BuildCloud(map[string]string{awsup.TagClusterName: clusterName})
+	mock.EC2.AddImage(&ec2.Image{ImageId: aws.String("ami-12345678"), Name: aws.String("k8s-debian-jessie")})
+	if _, err := mock.Route53.CreateHostedZone(&route53.CreateHostedZoneInput{
+		Name:            aws.String("example.com"),
+		CallerReference: aws.String("test"),
+	}); err != nil {
+		t.Fatalf("error creating hosted zone: %v", err)
+	}
+
+	s3 := awsmock.NewMockS3()
+	defer s3.Close()
+	stateStore, err := fi.NewVFSStateStore(vfs.NewS3Path(s3.Client(), "state", ""), clusterName, false, nil)
+	if err != nil {
+		t.Fatalf("error building state store: %v", err)
+	}
+
+	// create cluster
+	applyCluster(t, cloud, stateStore, "direct", dir, sshPublicKey, 2)
+	if len(mock.EC2.ResourceIDs()) == 0 {
+		t.Fatalf("expected resources to be created")
+	}
+	if len(mock.IAM.Roles) == 0 || len(mock.IAM.InstanceProfiles) == 0 {
+		t.Fatalf("expected IAM roles & instance profiles to be created")
+	}
+	if len(mock.Autoscaling.Groups) != 2 {
+		t.Fatalf("expected master & node autoscaling groups, got %v", mock.Autoscaling.Groups)
+	}
+
+	// The masters publish the API & internal names; the user's own records must be kept
+	zoneID := sortedZoneIDs(mock)[0]
+	for _, name := range []string{"api", "api.internal", "etcd-us-east-1a.internal", "etcd-events-us-east-1a.internal", "www"} {
+		_, err := mock.Route53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(zoneID),
+			ChangeBatch: &route53.ChangeBatch{
+				Changes: []*route53.Change{{
+					Action: aws.String("CREATE"),
+					ResourceRecordSet: &route53.ResourceRecordSet{
+						Name:            aws.String(name + "." + clusterName),
+						Type:            aws.String("A"),
+						TTL:             aws.Int64(60),
+						ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("172.20.32.10")}},
+					},
+				}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("error creating record %q: %v", name, err)
+		}
+	}
+
+	// update cluster: only the node group should change
+	applyCluster(t, cloud, stateStore, "direct", dir, sshPublicKey, 3)
+	group := mock.Autoscaling.Groups["nodes."+clusterName]
+	if group == nil || aws.Int64Value(group.MaxSize) != 3 {
+		t.Fatalf("node autoscaling group was not updated: %v", group)
+	}
+
+	// delete cluster
+	d := &DeleteCluster{
+		ClusterName: clusterName,
+		Region:      "us-east-1",
+		Cloud:       cloud,
+	}
+	resources, err := d.ListResources()
+	if err != nil {
+		t.Fatalf("error listing resources: %v", err)
+	}
+	if err := d.DeleteResources(resources); err != nil {
+		t.Fatalf("error deleting resources: %v", err)
+	}
+
+	if remaining := mock.EC2.ResourceIDs(); len(remaining) != 0 {
+		t.Errorf("EC2 resources were not deleted: %v", remaining)
+	}
+	if len(mock.EC2.KeyPairs) != 0 {
+		t.Errorf("SSH keys were not deleted: %v", mock.EC2.KeyPairs)
+	}
+	if len(mock.Autoscaling.Groups) != 0 || len(mock.Autoscaling.LaunchConfigurations) != 0 {
+		t.Errorf("autoscaling groups were not deleted: %d groups, %d launch configurations remain", len(mock.Autoscaling.Groups), len(mock.Autoscaling.LaunchConfigurations))
+	}
+	if len(mock.ELB.LoadBalancers) != 0 {
+		t.Errorf("load balancers were not deleted: %v", mock.ELB.LoadBalancers)
+	}
+	if len(mock.IAM.Roles) != 0 || len(mock.IAM.InstanceProfiles) != 0 {
+		t.Errorf("IAM roles & instance profiles were not deleted: %v %v", mock.IAM.Roles, mock.IAM.InstanceProfiles)
+	}
+	if records := clusterRecords(mock, clusterName); len(records) != 1 || records[0] != "www."+clusterName+"./A" {
+		t.Errorf("expected only the user's DNS record to be kept, got %v", records)
+	}
+	if len(mock.Route53.Zones) != 1 {
+		t.Errorf("expected the hosted zone to be kept")
+	}
+}
+
+func sortedZoneIDs(mock *awsmock.MockAWS) []string {
+	var ids []string
+	for id := range mock.Route53.Zones {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// clusterRecords returns the names of the route53 records in the cluster's domain
+func clusterRecords(mock *awsmock.MockAWS, clusterName string) []string {
+	var names []string
+	for _, records := range mock.Route53.Records {
+		for key := range records {
+			if strings.HasSuffix(strings.SplitN(key, "/", 2)[0], "."+clusterName+".") {
+				names = append(names, key)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
 	"strconv"
 	"strings"
@@ -81,7 +82,7 @@ func (x *ImportCluster) ImportAWSCluster() error {
 
 	masterSubnetID := aws.StringValue(masterInstance.SubnetId)
 
-	subnets, err := DescribeSubnets(x.Cloud)
+	subnets, err := awstasks.DescribeSubnets(x.Cloud)
 	if err != nil {
 		return fmt.Errorf("error finding subnets: %v", err)
 	}
@@ -205,7 +206,7 @@ func (x *ImportCluster) ImportAWSCluster() error {
 	//}
 
 	{
-		groups, err := findAutoscalingGroups(awsCloud, awsCloud.Tags())
+		groups, err := awstasks.FindAutoscalingGroups(awsCloud, awsCloud.Tags())
 		if err != nil {
 			return fmt.Errorf("error listing autoscaling groups: %v", err)
 		}
@@ -490,7 +491,7 @@ func parseInt(s string) (int, error) {
 //}
 
 func findInstances(c *awsup.AWSCloud) ([]*ec2.Instance, error) {
-	filters := buildEC2Filters(c)
+	filters := awstasks.BuildEC2Filters(c)
 
 	request := &ec2.DescribeInstancesInput{
 		Filters: filters,
@@ -633,7 +634,7 @@ func (u *UserDataConfiguration) ParseKey(key string) (*fi.PrivateKey, error) {
 }
 
 func ParseUserDataConfiguration(raw []byte) (*UserDataConfiguration, error) {
-	userData, err := UserDataToString(raw)
+	userData, err := awstasks.UserDataToString(raw)
 	if err != nil {
 		return nil, err
 	}
@@ -697,17 +698,3 @@ func ParseUserDataConfiguration(raw []byte) (*UserDataConfiguration, error) {
 	}
 	return c, nil
 }
-
-func UserDataToString(userData []byte) (string, error) {
-	var err error
-	if len(userData) > 2 && userData[0] == 31 && userData[1] == 139 {
-		// GZIP
-		glog.V(2).Infof("gzip data detected; will decompress")
-
-		userData, err = gunzipBytes(userData)
-		if err != nil {
-			return "", fmt.Errorf("error decompressing user data: %v", err)
-		}
-	}
-	return string(userData), nil
-}
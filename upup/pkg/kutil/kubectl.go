@@ -1,8 +1,10 @@
 package kutil
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/golang/glog"
+	k8sapi "k8s.io/kubernetes/pkg/api"
 	"os"
 	"os/exec"
 	"strings"
@@ -10,6 +12,9 @@ import (
 
 type Kubectl struct {
 	KubectlPath string
+
+	// Context is the kubectl context to use; if not set we use the current context
+	Context string
 }
 
 func (k *Kubectl) GetCurrentContext() (string, error) {
@@ -41,11 +46,72 @@ func (k *Kubectl) GetConfig(minify bool, output string) (string, error) {
 	return s, nil
 }
 
+// GetNodes returns the nodes registered with the cluster
+func (k *Kubectl) GetNodes() ([]k8sapi.Node, error) {
+	s, err := k.execKubectl("get", "nodes", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := &k8sapi.NodeList{}
+	err = json.Unmarshal([]byte(s), nodes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing nodes from kubectl: %v", err)
+	}
+	return nodes.Items, nil
+}
+
+// GetComponentStatuses returns the health of the control-plane components, as reported by the API server
+func (k *Kubectl) GetComponentStatuses() ([]k8sapi.ComponentStatus, error) {
+	s, err := k.execKubectl("get", "componentstatuses", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := &k8sapi.ComponentStatusList{}
+	err = json.Unmarshal([]byte(s), statuses)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing componentstatuses from kubectl: %v", err)
+	}
+	return statuses.Items, nil
+}
+
+// Cordon marks the node as unschedulable
+func (k *Kubectl) Cordon(nodeName string) error {
+	_, err := k.execKubectl("cordon", nodeName)
+	if err != nil {
+		return fmt.Errorf("error cordoning node %q: %v", nodeName, err)
+	}
+	return nil
+}
+
+// Drain evicts all pods from the node, including pods that are not managed by a controller
+func (k *Kubectl) Drain(nodeName string) error {
+	_, err := k.execKubectl("drain", nodeName, "--force", "--ignore-daemonsets")
+	if err != nil {
+		return fmt.Errorf("error draining node %q: %v", nodeName, err)
+	}
+	return nil
+}
+
+// IsNodeReady checks if the node has a Ready condition with status True
+func IsNodeReady(node *k8sapi.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == k8sapi.NodeReady {
+			return c.Status == k8sapi.ConditionTrue
+		}
+	}
+	return false
+}
+
 func (k *Kubectl) execKubectl(args ...string) (string, error) {
 	kubectlPath := k.KubectlPath
 	if kubectlPath == "" {
 		kubectlPath = "kubectl" // Assume in PATH
 	}
+	if k.Context != "" {
+		args = append([]string{"--context", k.Context}, args...)
+	}
 	cmd := exec.Command(kubectlPath, args...)
 	env := os.Environ()
 	cmd.Env = env
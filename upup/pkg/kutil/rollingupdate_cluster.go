@@ -4,35 +4,77 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/autoscaling"
-	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
+const DefaultNodeReadyTimeout = 15 * time.Minute
+const DefaultValidateTimeout = 15 * time.Minute
+
+// pollInterval is how often we check whether replacement instances have become ready
+const pollInterval = 15 * time.Second
+
 // RollingUpdateCluster restarts cluster nodes
 type RollingUpdateCluster struct {
 	ClusterName string
 	Region      string
 	Cloud       fi.Cloud
-}
 
-func (c *RollingUpdateCluster) ListNodesets() (map[string]*Nodeset, error) {
-	cloud := c.Cloud.(*awsup.AWSCloud)
+	// BatchSize is the number of instances in a nodeset that we replace at the same time
+	BatchSize int
 
-	nodesets := make(map[string]*Nodeset)
+	// Surge will temporarily increase the size of the autoscaling group by the BatchSize,
+	// so that replacement instances are running before we take down the old ones
+	Surge bool
+
+	// CloudOnly skips the kubernetes API: we don't drain nodes or wait for them to register
+	CloudOnly bool
+
+	// NodeReadyTimeout is how long we wait for replacement instances to register as Ready nodes
+	NodeReadyTimeout time.Duration
+
+	// Kubectl is used to cordon & drain nodes, and to check that replacements are ready
+	Kubectl *Kubectl
+
+	// MastersFirst updates the master nodesets first, and then the node nodesets, one nodeset at a time.
+	// The cluster must pass validation before we start, and after each batch of instances is replaced.
+	MastersFirst bool
 
-	tags := cloud.BuildTags(nil)
+	// ValidateTimeout is how long we wait for the cluster to pass validation after each step
+	ValidateTimeout time.Duration
 
-	asgs, err := findAutoscalingGroups(cloud, tags)
+	// Force replaces every instance, even those running the current configuration
+	// (for example after rotating keys, which does not change the LaunchConfiguration)
+	Force bool
+}
+
+func (c *RollingUpdateCluster) ListNodesets() (map[string]*Nodeset, error) {
+	provider, err := fi.FindCloudProvider(c.Cloud.ProviderID())
 	if err != nil {
 		return nil, err
 	}
 
-	for _, asg := range asgs {
-		nodeset := buildNodeset(asg)
+	groups, err := provider.ListInstanceGroups(c.Cloud)
+	if err != nil {
+		return nil, err
+	}
+
+	nodesets := make(map[string]*Nodeset)
+	for _, group := range groups {
+		if c.Force {
+			group.NeedUpdate = append(group.NeedUpdate, group.Ready...)
+			group.Ready = nil
+		}
+		nodeset, err := buildNodeset(group)
+		if err != nil {
+			return nil, err
+		}
 		nodesets[nodeset.Name] = nodeset
 	}
 
@@ -44,6 +86,10 @@ func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodese
 		return nil
 	}
 
+	if c.MastersFirst {
+		return c.rollingUpdateSequential(nodesets)
+	}
+
 	var wg sync.WaitGroup
 	var resultsMutex sync.Mutex
 	results := make(map[string]error)
@@ -56,7 +102,7 @@ func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodese
 			resultsMutex.Unlock()
 
 			defer wg.Done()
-			err := nodeset.RollingUpdate(c.Cloud)
+			err := nodeset.RollingUpdate(c)
 
 			resultsMutex.Lock()
 			results[k] = err
@@ -75,26 +121,104 @@ func (c *RollingUpdateCluster) RollingUpdateNodesets(nodesets map[string]*Nodese
 	return nil
 }
 
+// rollingUpdateSequential updates the masters and then the nodes, one nodeset at a time.
+// If any step fails we stop immediately; because we only replace instances that are not running
+// the current LaunchConfiguration, re-running the rolling-update resumes where we left off (except with Force).
+func (c *RollingUpdateCluster) rollingUpdateSequential(nodesets map[string]*Nodeset) error {
+	var masters []*Nodeset
+	var nodes []*Nodeset
+	for _, nodeset := range nodesets {
+		if nodeset.IsMaster() {
+			masters = append(masters, nodeset)
+		} else {
+			nodes = append(nodes, nodeset)
+		}
+	}
+	sort.Sort(nodesetsByName(masters))
+	sort.Sort(nodesetsByName(nodes))
+
+	ordered := append(masters, nodes...)
+
+	if !c.CloudOnly {
+		err := ValidateClusterHealth(c.Kubectl)
+		if err != nil {
+			return fmt.Errorf("cluster did not pass validation before rolling-update: %v", err)
+		}
+	}
+
+	for i, nodeset := range ordered {
+		err := nodeset.RollingUpdate(c)
+		if err != nil {
+			var remaining []string
+			for _, n := range ordered[i:] {
+				remaining = append(remaining, n.Name)
+			}
+			return fmt.Errorf("rolling-update aborted in nodeset %q: %v\nNodesets not yet fully updated: %s\n%s", nodeset.Name, err, strings.Join(remaining, ", "), c.resumeHint())
+		}
+	}
+
+	return nil
+}
+
+// resumeHint tells the user what re-running the rolling-update will do
+func (c *RollingUpdateCluster) resumeHint() string {
+	if c.Force {
+		// With Force we can't tell which instances we already replaced
+		return "Re-running rolling-update with --force will replace every instance again, including those that have already been replaced"
+	}
+	return "Re-run rolling-update to resume; instances that have already been replaced will be skipped"
+}
+
+// validateStep is called after each batch of instances is replaced, when running in MastersFirst mode
+func (c *RollingUpdateCluster) validateStep() error {
+	if !c.MastersFirst || c.CloudOnly {
+		return nil
+	}
+
+	timeout := c.ValidateTimeout
+	if timeout == 0 {
+		timeout = DefaultValidateTimeout
+	}
+	return WaitForClusterHealth(c.Kubectl, timeout)
+}
+
 type Nodeset struct {
 	Name       string
 	Status     string
-	Ready      []*autoscaling.Instance
-	NeedUpdate []*autoscaling.Instance
+	Ready      []*fi.CloudInstance
+	NeedUpdate []*fi.CloudInstance
+
+	// Role is the role of the instances in the nodeset: Master or Node
+	Role string
+
+	asg *autoscaling.Group
 }
 
-func buildNodeset(g *autoscaling.Group) *Nodeset {
-	n := &Nodeset{
-		Name: aws.StringValue(g.AutoScalingGroupName),
-	}
+// IsMaster returns true if the nodeset is made up of master instances
+func (n *Nodeset) IsMaster() bool {
+	return n.Role == string(api.InstanceGroupRoleMaster)
+}
 
-	findLaunchConfigurationName := aws.StringValue(g.LaunchConfigurationName)
+// nodesetsByName sorts nodesets by name
+type nodesetsByName []*Nodeset
 
-	for _, i := range g.Instances {
-		if findLaunchConfigurationName == aws.StringValue(i.LaunchConfigurationName) {
-			n.Ready = append(n.Ready, i)
-		} else {
-			n.NeedUpdate = append(n.NeedUpdate, i)
-		}
+func (a nodesetsByName) Len() int           { return len(a) }
+func (a nodesetsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a nodesetsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+func buildNodeset(group *fi.CloudInstanceGroup) (*Nodeset, error) {
+	// The rolling update itself is currently only implemented for AWS autoscaling groups
+	asg, ok := group.Raw.(*autoscaling.Group)
+	if !ok {
+		return nil, fmt.Errorf("rolling-update is not supported for instance group %q of type %T", group.Name, group.Raw)
+	}
+
+	n := &Nodeset{
+		Name:       group.Name,
+		Role:       group.Role,
+		Ready:      group.Ready,
+		NeedUpdate: group.NeedUpdate,
+		asg:        asg,
 	}
 
 	if len(n.NeedUpdate) == 0 {
@@ -103,38 +227,262 @@ func buildNodeset(g *autoscaling.Group) *Nodeset {
 		n.Status = "NeedsUpdate"
 	}
 
-	return n
+	return n, nil
 }
 
-func (n *Nodeset) RollingUpdate(cloud fi.Cloud) error {
-	c := cloud.(*awsup.AWSCloud)
+// RollingUpdate replaces every out-of-date instance in the nodeset, in batches of c.BatchSize.
+// Each instance is drained before it is terminated, and we wait for the replacement
+// instances to register as Ready nodes before moving on to the next batch.
+func (n *Nodeset) RollingUpdate(c *RollingUpdateCluster) error {
+	cloud := c.Cloud.(*awsup.AWSCloud)
 
-	for _, i := range n.NeedUpdate {
-		glog.Infof("Stopping instance %q in nodeset %q", *i.InstanceId, n.Name)
+	if len(n.NeedUpdate) == 0 {
+		return nil
+	}
+
+	batchSize := c.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
 
-		// TODO: Evacuate through k8s first?
+	surge := c.Surge
 
-		// TODO: Temporarily increase size of ASG?
+	// Masters own their etcd volumes, so a surged master could not start etcd, and replacing
+	// several at once could lose quorum.  We always replace masters one at a time.
+	if n.IsMaster() {
+		batchSize = 1
+		surge = false
+	}
 
-		// TODO: Remove from ASG first so status is immediately updated?
+	desiredCapacity := aws.Int64Value(n.asg.DesiredCapacity)
+	maxSize := aws.Int64Value(n.asg.MaxSize)
 
-		// TODO: Batch termination, like a rolling-update
+	if surge && desiredCapacity+int64(batchSize) > maxSize {
+		glog.Infof("Temporarily increasing MaxSize of nodeset %q to %d", n.Name, desiredCapacity+int64(batchSize))
+		err := n.setMaxSize(cloud, desiredCapacity+int64(batchSize))
+		if err != nil {
+			return err
+		}
 
-		request := &ec2.TerminateInstancesInput{
-			InstanceIds: []*string{i.InstanceId},
+		defer func() {
+			glog.Infof("Restoring MaxSize of nodeset %q to %d", n.Name, maxSize)
+			err := n.setMaxSize(cloud, maxSize)
+			if err != nil {
+				glog.Warningf("error restoring MaxSize of nodeset %q: %v", n.Name, err)
+			}
+		}()
+	}
+
+	// updated is the number of instances we expect to be running the current LaunchConfiguration
+	updated := len(n.Ready)
+
+	for start := 0; start < len(n.NeedUpdate); start += batchSize {
+		end := start + batchSize
+		if end > len(n.NeedUpdate) {
+			end = len(n.NeedUpdate)
 		}
-		_, err := c.EC2.TerminateInstances(request)
-		if err != nil {
-			return fmt.Errorf("error deleting instance %q: %v", i.InstanceId, err)
+		batch := n.NeedUpdate[start:end]
+		updated += len(batch)
+
+		if surge {
+			glog.Infof("Increasing DesiredCapacity of nodeset %q to %d", n.Name, desiredCapacity+int64(len(batch)))
+			request := &autoscaling.SetDesiredCapacityInput{
+				AutoScalingGroupName: n.asg.AutoScalingGroupName,
+				DesiredCapacity:      aws.Int64(desiredCapacity + int64(len(batch))),
+			}
+			_, err := cloud.Autoscaling.SetDesiredCapacity(request)
+			if err != nil {
+				return fmt.Errorf("error increasing size of autoscaling group %q: %v", n.Name, err)
+			}
+
+			err = n.waitForUpdatedInstances(c, updated)
+			if err != nil {
+				return err
+			}
 		}
 
-		// TODO: Wait for node to appear back in k8s
-		time.Sleep(time.Minute)
+		for _, i := range batch {
+			err := n.drainInstance(c, i)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, i := range batch {
+			glog.Infof("Stopping instance %q in nodeset %q", i.ID, n.Name)
+
+			// When we surged, we decrement the desired capacity so the group returns to its original size
+			request := &autoscaling.TerminateInstanceInAutoScalingGroupInput{
+				InstanceId:                     aws.String(i.ID),
+				ShouldDecrementDesiredCapacity: aws.Bool(surge),
+			}
+			_, err := cloud.Autoscaling.TerminateInstanceInAutoScalingGroup(request)
+			if err != nil {
+				return fmt.Errorf("error deleting instance %q: %v", i.ID, err)
+			}
+		}
+
+		if !surge {
+			err := n.waitForUpdatedInstances(c, updated)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := c.validateStep()
+		if err != nil {
+			return fmt.Errorf("cluster did not pass validation after replacing instances in nodeset %q: %v", n.Name, err)
+		}
 	}
 
 	return nil
 }
 
+func (n *Nodeset) setMaxSize(cloud *awsup.AWSCloud, maxSize int64) error {
+	request := &autoscaling.UpdateAutoScalingGroupInput{
+		AutoScalingGroupName: n.asg.AutoScalingGroupName,
+		MaxSize:              aws.Int64(maxSize),
+	}
+	_, err := cloud.Autoscaling.UpdateAutoScalingGroup(request)
+	if err != nil {
+		return fmt.Errorf("error setting MaxSize of autoscaling group %q: %v", n.Name, err)
+	}
+	return nil
+}
+
+// drainInstance cordons and drains the k8s node running on the instance
+func (n *Nodeset) drainInstance(c *RollingUpdateCluster, i *fi.CloudInstance) error {
+	if c.CloudOnly {
+		return nil
+	}
+
+	cloud := c.Cloud.(*awsup.AWSCloud)
+
+	nodeName, err := findNodeName(cloud, i.ID)
+	if err != nil {
+		return err
+	}
+	if nodeName == "" {
+		glog.Warningf("Unable to determine node name for instance %q; won't drain", i.ID)
+		return nil
+	}
+
+	glog.Infof("Draining node %q (instance %q)", nodeName, i.ID)
+
+	err = c.Kubectl.Cordon(nodeName)
+	if err != nil {
+		return err
+	}
+
+	return c.Kubectl.Drain(nodeName)
+}
+
+// waitForUpdatedInstances waits until the autoscaling group has at least the expected number of in-service
+// instances running the current LaunchConfiguration, and (unless CloudOnly) they have all registered as Ready nodes
+func (n *Nodeset) waitForUpdatedInstances(c *RollingUpdateCluster, expected int) error {
+	timeout := c.NodeReadyTimeout
+	if timeout == 0 {
+		timeout = DefaultNodeReadyTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	for {
+		ready, err := n.countUpdatedInstances(c)
+		if err != nil {
+			// We expect transient errors (e.g. while the API server is restarting)
+			glog.Warningf("error checking instances in nodeset %q: %v", n.Name, err)
+		} else {
+			glog.Infof("Nodeset %q has %d of %d expected instances ready", n.Name, ready, expected)
+			if ready >= expected {
+				return nil
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout waiting for replacement instances in nodeset %q to become ready", n.Name)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// countUpdatedInstances returns the number of instances that are running the current LaunchConfiguration,
+// and that (unless CloudOnly) have registered with k8s as Ready.
+// Instances that we are going to replace are never counted (with Force, they may be running the current LaunchConfiguration).
+func (n *Nodeset) countUpdatedInstances(c *RollingUpdateCluster) (int, error) {
+	cloud := c.Cloud.(*awsup.AWSCloud)
+
+	request := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: []*string{n.asg.AutoScalingGroupName},
+	}
+	response, err := cloud.Autoscaling.DescribeAutoScalingGroups(request)
+	if err != nil {
+		return 0, fmt.Errorf("error describing autoscaling group %q: %v", n.Name, err)
+	}
+	if len(response.AutoScalingGroups) != 1 {
+		return 0, fmt.Errorf("found %d autoscaling groups with name %q", len(response.AutoScalingGroups), n.Name)
+	}
+	asg := response.AutoScalingGroups[0]
+
+	replacing := make(map[string]bool)
+	for _, i := range n.NeedUpdate {
+		replacing[i.ID] = true
+	}
+
+	var instanceIDs []string
+	for _, i := range asg.Instances {
+		if aws.StringValue(i.LaunchConfigurationName) != aws.StringValue(asg.LaunchConfigurationName) {
+			continue
+		}
+		if replacing[aws.StringValue(i.InstanceId)] {
+			continue
+		}
+		if aws.StringValue(i.LifecycleState) != "InService" {
+			continue
+		}
+		instanceIDs = append(instanceIDs, aws.StringValue(i.InstanceId))
+	}
+
+	if c.CloudOnly {
+		return len(instanceIDs), nil
+	}
+
+	nodes, err := c.Kubectl.GetNodes()
+	if err != nil {
+		return 0, err
+	}
+	readyNodes := make(map[string]bool)
+	for i := range nodes {
+		if IsNodeReady(&nodes[i]) {
+			readyNodes[nodes[i].Name] = true
+		}
+	}
+
+	count := 0
+	for _, instanceID := range instanceIDs {
+		nodeName, err := findNodeName(cloud, instanceID)
+		if err != nil {
+			return 0, err
+		}
+		if nodeName != "" && readyNodes[nodeName] {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// findNodeName returns the name of the k8s node for the instance; on AWS this is the private DNS name
+func findNodeName(cloud *awsup.AWSCloud, instanceID string) (string, error) {
+	instance, err := cloud.DescribeInstance(instanceID)
+	if err != nil {
+		return "", err
+	}
+	if instance == nil {
+		return "", nil
+	}
+	return aws.StringValue(instance.PrivateDnsName), nil
+}
+
 func (n *Nodeset) String() string {
 	return "nodeset:" + n.Name
 }
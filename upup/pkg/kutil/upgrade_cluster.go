@@ -8,6 +8,7 @@ import (
 	"github.com/golang/glog"
 	"k8s.io/kops/upup/pkg/api"
 	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
 	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
 	"time"
 )
@@ -50,22 +51,22 @@ func (x *UpgradeCluster) Upgrade() error {
 		return fmt.Errorf("error finding instances: %v", err)
 	}
 
-	volumes, err := DescribeVolumes(x.Cloud)
+	volumes, err := awstasks.DescribeVolumes(x.Cloud)
 	if err != nil {
 		return err
 	}
 
-	dhcpOptions, err := DescribeDhcpOptions(x.Cloud)
+	dhcpOptions, err := awstasks.DescribeDhcpOptions(x.Cloud)
 	if err != nil {
 		return err
 	}
 
-	autoscalingGroups, err := findAutoscalingGroups(awsCloud, oldTags)
+	autoscalingGroups, err := awstasks.FindAutoscalingGroups(awsCloud, oldTags)
 	if err != nil {
 		return err
 	}
 
-	elbs, _, err := DescribeELBs(x.Cloud)
+	elbs, _, err := awstasks.DescribeELBs(x.Cloud)
 	if err != nil {
 		return err
 	}
@@ -140,7 +141,7 @@ func (x *UpgradeCluster) Upgrade() error {
 			for {
 				_, err := awsCloud.EC2.DetachVolume(request)
 				if err != nil {
-					if AWSErrorCode(err) == "IncorrectState" {
+					if awstasks.AWSErrorCode(err) == "IncorrectState" {
 						glog.Infof("retrying to detach volume (master has probably not stopped yet): %q", err)
 						time.Sleep(5 * time.Second)
 						continue
@@ -198,7 +199,7 @@ func (x *UpgradeCluster) Upgrade() error {
 		}
 
 		if retagGateway {
-			gateways, err := DescribeInternetGatewaysIgnoreTags(x.Cloud)
+			gateways, err := awstasks.DescribeInternetGatewaysIgnoreTags(x.Cloud)
 			if err != nil {
 				return fmt.Errorf("error listing gateways: %v", err)
 			}
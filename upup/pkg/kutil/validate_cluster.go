@@ -0,0 +1,220 @@
+package kutil
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/golang/glog"
+	"k8s.io/kops/upup/pkg/api"
+	"k8s.io/kops/upup/pkg/fi"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awstasks"
+	"k8s.io/kops/upup/pkg/fi/cloudup/awsup"
+	"net"
+	"sort"
+	"strings"
+)
+
+// ValidateCluster checks that a running cluster matches its configuration
+type ValidateCluster struct {
+	Cluster        *api.Cluster
+	InstanceGroups []*api.InstanceGroup
+	Cloud          fi.Cloud
+	Kubectl        *Kubectl
+}
+
+const (
+	ValidationStatusOK     = "OK"
+	ValidationStatusFailed = "Failed"
+)
+
+// ValidationResult is the result of a single check performed by ValidateCluster
+type ValidationResult struct {
+	// Kind is the type of object that was checked: InstanceGroup, Node or DNS
+	Kind    string
+	Name    string
+	Status  string
+	Message string
+}
+
+// IsOK returns true if the check passed
+func (r *ValidationResult) IsOK() bool {
+	return r.Status == ValidationStatusOK
+}
+
+// Validate runs all the checks, returning a result for each
+func (v *ValidateCluster) Validate() ([]*ValidationResult, error) {
+	if v.Cloud.ProviderID() != fi.CloudProviderAWS {
+		return nil, fmt.Errorf("validate is not yet supported on cloud %q", v.Cloud.ProviderID())
+	}
+	cloud := v.Cloud.(*awsup.AWSCloud)
+
+	var results []*ValidationResult
+
+	asgs, err := awstasks.FindAutoscalingGroups(cloud, cloud.Tags())
+	if err != nil {
+		return nil, err
+	}
+	asgMap := make(map[string]*autoscaling.Group)
+	for _, asg := range asgs {
+		asgMap[aws.StringValue(asg.AutoScalingGroupName)] = asg
+	}
+
+	// expectedNodes maps from the node name to the InstanceGroup it belongs to
+	expectedNodes := make(map[string]*api.InstanceGroup)
+
+	for _, ig := range v.InstanceGroups {
+		asgName := AutoscalingGroupName(v.Cluster, ig)
+		asg := asgMap[asgName]
+
+		minSize, maxSize := instanceGroupSize(ig)
+
+		result := &ValidationResult{
+			Kind: "InstanceGroup",
+			Name: ig.Name,
+		}
+		results = append(results, result)
+
+		if asg == nil {
+			result.Status = ValidationStatusFailed
+			result.Message = fmt.Sprintf("autoscaling group %q not found", asgName)
+			continue
+		}
+
+		running := 0
+		for _, i := range asg.Instances {
+			if aws.StringValue(i.LifecycleState) != "InService" {
+				continue
+			}
+			running++
+
+			nodeName, err := findNodeName(cloud, aws.StringValue(i.InstanceId))
+			if err != nil {
+				return nil, err
+			}
+			if nodeName == "" {
+				glog.Warningf("Unable to determine node name for instance %q", aws.StringValue(i.InstanceId))
+				continue
+			}
+			expectedNodes[nodeName] = ig
+		}
+
+		if running < minSize || running > maxSize {
+			result.Status = ValidationStatusFailed
+			result.Message = fmt.Sprintf("%d instances running, expected between %d and %d", running, minSize, maxSize)
+		} else {
+			result.Status = ValidationStatusOK
+			result.Message = fmt.Sprintf("%d instances running (min %d, max %d)", running, minSize, maxSize)
+		}
+	}
+
+	results = append(results, v.validateNodes(expectedNodes)...)
+
+	results = append(results, validateDNS(v.Cluster.Spec.MasterPublicName))
+	results = append(results, validateDNS(v.Cluster.Spec.MasterInternalName))
+
+	return results, nil
+}
+
+// validateNodes checks that every instance we found is registered as a Ready node
+func (v *ValidateCluster) validateNodes(expectedNodes map[string]*api.InstanceGroup) []*ValidationResult {
+	nodes, err := v.Kubectl.GetNodes()
+	if err != nil {
+		return []*ValidationResult{
+			{
+				Kind:    "Node",
+				Status:  ValidationStatusFailed,
+				Message: fmt.Sprintf("unable to list nodes: %v", err),
+			},
+		}
+	}
+
+	nodeMap := make(map[string]int)
+	for i := range nodes {
+		nodeMap[nodes[i].Name] = i
+	}
+
+	// Report in order of node name, so the output is stable
+	var nodeNames []string
+	for nodeName := range expectedNodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+
+	var results []*ValidationResult
+	for _, nodeName := range nodeNames {
+		ig := expectedNodes[nodeName]
+		result := &ValidationResult{
+			Kind: "Node",
+			Name: nodeName,
+		}
+		results = append(results, result)
+
+		i, found := nodeMap[nodeName]
+		if !found {
+			result.Status = ValidationStatusFailed
+			result.Message = fmt.Sprintf("instance in group %q has not registered", ig.Name)
+			continue
+		}
+
+		if !IsNodeReady(&nodes[i]) {
+			result.Status = ValidationStatusFailed
+			result.Message = fmt.Sprintf("node in group %q is not Ready", ig.Name)
+			continue
+		}
+
+		result.Status = ValidationStatusOK
+		result.Message = fmt.Sprintf("node in group %q is Ready", ig.Name)
+	}
+	return results
+}
+
+// validateDNS checks that the name resolves
+func validateDNS(name string) *ValidationResult {
+	result := &ValidationResult{
+		Kind: "DNS",
+		Name: name,
+	}
+
+	if name == "" {
+		result.Status = ValidationStatusFailed
+		result.Message = "name not set in cluster configuration"
+		return result
+	}
+
+	addrs, err := net.LookupHost(name)
+	if err != nil {
+		result.Status = ValidationStatusFailed
+		result.Message = fmt.Sprintf("unable to resolve: %v", err)
+		return result
+	}
+
+	result.Status = ValidationStatusOK
+	result.Message = strings.Join(addrs, ",")
+	return result
+}
+
+// AutoscalingGroupName returns the name of the autoscaling group we create for the InstanceGroup
+func AutoscalingGroupName(cluster *api.Cluster, ig *api.InstanceGroup) string {
+	if ig.IsMaster() {
+		return ig.Name + ".masters." + cluster.Name
+	}
+	return ig.Name + "." + cluster.Name
+}
+
+// instanceGroupSize returns the min and max size of the InstanceGroup, applying the defaults used by the models
+func instanceGroupSize(ig *api.InstanceGroup) (int, int) {
+	defaultSize := 2
+	if ig.IsMaster() {
+		defaultSize = 1
+	}
+
+	minSize := defaultSize
+	if ig.Spec.MinSize != nil {
+		minSize = *ig.Spec.MinSize
+	}
+	maxSize := defaultSize
+	if ig.Spec.MaxSize != nil {
+		maxSize = *ig.Spec.MaxSize
+	}
+	return minSize, maxSize
+}